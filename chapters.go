@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+// chapterCheckInterval is how often the current chapter is re-evaluated.
+const chapterCheckInterval = 500 * time.Millisecond
+
+// chapterTracker announces chapter changes and handles chapter jumps for
+// the playing track. Chapters load in the background so playback starts
+// without waiting for the metadata call.
+type chapterTracker struct {
+	mu       sync.Mutex
+	chapters []player.Chapter
+}
+
+// set stores the track's chapters from its YouTube metadata.
+func (c *chapterTracker) set(meta *youtube.Metadata) {
+	if len(meta.Chapters) == 0 {
+		return
+	}
+
+	chapters := make([]player.Chapter, len(meta.Chapters))
+	for i, ch := range meta.Chapters {
+		chapters[i] = player.Chapter{
+			Title: ch.Title,
+			Start: time.Duration(ch.StartTime * float64(time.Second)),
+		}
+	}
+	out.Infof("%d chapters (keys: [ previous, ] next)", len(chapters))
+
+	c.mu.Lock()
+	c.chapters = chapters
+	c.mu.Unlock()
+}
+
+func (c *chapterTracker) get() []player.Chapter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chapters
+}
+
+// watch prints the chapter name whenever playback enters a new chapter.
+func (c *chapterTracker) watch(ctx context.Context, ctrl player.Controller) {
+	ticker := time.NewTicker(chapterCheckInterval)
+	defer ticker.Stop()
+
+	current := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			chapters := c.get()
+			index := player.ChapterAt(chapters, ctrl.Position())
+			if index < 0 || index == current {
+				continue
+			}
+			current = index
+			out.Infof("Chapter %d/%d: %s", index+1, len(chapters), chapters[index].Title)
+			out.Event("chapter", map[string]any{
+				"index": index + 1, "title": chapters[index].Title, "start": chapters[index].Start.Seconds(),
+			})
+		}
+	}
+}
+
+// jump seeks to the next or previous chapter.
+func (c *chapterTracker) jump(ctrl player.Controller, next bool) {
+	chapters := c.get()
+	if len(chapters) == 0 {
+		out.Infof("No chapters")
+		return
+	}
+
+	position := ctrl.Position()
+	index := player.PreviousChapter(chapters, position)
+	if next {
+		index = player.NextChapter(chapters, position)
+	}
+	if index < 0 {
+		return
+	}
+	ctrl.Seek(chapters[index].Start - position)
+}
@@ -4,12 +4,67 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/scrobble"
+	"github.com/thewind121212/natashi/internal/server"
 )
 
 // Config holds the CLI configuration parsed from arguments.
 type Config struct {
-	Platform string // Platform name (e.g., "youtube")
-	URL      string // Media URL
+	Platform  string          // Platform name (e.g., "youtube")
+	URLs      []string        // Media URLs, played in order ("-" reads a list from stdin)
+	Lists     []string        // M3U / plain-text URL lists, queued before URLs
+	Shuffle   bool            // Shuffle the queue (including expanded playlists)
+	Loop      bool            // Repeat the current track
+	RepeatAll bool            // Start the queue over after the last track
+	StartAt   time.Duration   // Offset to start each track at
+	Resume    bool            // Continue tracks from their saved position
+	Volume    int             // Volume in percent (0-200)
+	Device    string          // Audio output device
+	Format    string          // Preferred yt-dlp format selector
+	Cookies   CookieConfig    // YouTube cookies from the config file
+	Geo       GeoConfig       // Geo bypass from the config file
+	Scrobble  scrobble.Config // Scrobbling credentials from the config file
+	EQ        string          // Equalizer bands, e.g. "60=4,1k=-2"
+	Effects   []string        // Audio effects (bassboost, nightcore, loudnorm, karaoke, 8d)
+	Verbosity Verbosity       // Log level (-quiet / -verbose)
+	JSON      bool            // Emit machine-readable status lines on stdout
+	Search    string          // Search query (search subcommand)
+	Limit     int             // Number of search results
+	Daemon    *DaemonConfig   // Set for the daemon subcommand
+	Doctor    *DoctorConfig   // Set for the doctor subcommand
+}
+
+// CookieConfig selects the cookies yt-dlp uses (YT_COOKIES_* env vars
+// override these).
+type CookieConfig struct {
+	File     string
+	Profiles []string // Rotated to when YouTube blocks File
+	Browser  string
+}
+
+// GeoConfig makes YouTube extraction appear to come from a country, for
+// region-locked videos (YT_GEO_COUNTRY and YT_PROXY override these).
+type GeoConfig struct {
+	Country       string            `yaml:"country"`        // Two-letter code yt-dlp fakes X-Forwarded-For for
+	Proxy         string            `yaml:"proxy"`          // Proxy for yt-dlp and, if HTTP, FFmpeg
+	RegionProxies map[string]string `yaml:"region_proxies"` // Proxy per country, for requests choosing a region
+}
+
+// stringList is a flag.Value that collects repeated flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 // ParseArgs parses command line arguments and returns a Config.
@@ -17,37 +72,196 @@ type Config struct {
 func ParseArgs() (*Config, error) {
 	config := &Config{}
 
+	// music-bot daemon [flags]
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		daemon, err := parseDaemonArgs(os.Args[2:])
+		if err != nil {
+			return nil, err
+		}
+		config.Daemon = daemon
+		return config, nil
+	}
+
+	// music-bot doctor [flags]
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		doctor, err := parseDoctorArgs(os.Args[2:])
+		if err != nil {
+			return nil, err
+		}
+		config.Doctor = doctor
+		return config, nil
+	}
+
+	// Persistent defaults; flags below override them
+	settings, err := LoadSettings(SettingsPath())
+	if err != nil {
+		return nil, err
+	}
+	volume := 100
+	if settings.Volume != nil {
+		volume = *settings.Volume
+	}
+	config.Cookies = CookieConfig{File: settings.Cookies.File, Profiles: settings.Cookies.Profiles, Browser: settings.Cookies.Browser}
+	config.Geo = settings.Geo
+	config.Scrobble = settings.Scrobble
+
 	flag.StringVar(&config.Platform, "p", "", "Platform name (e.g., youtube)")
 	flag.StringVar(&config.Platform, "platform", "", "Platform name (e.g., youtube)")
-	flag.StringVar(&config.URL, "url", "", "Media URL to play")
+	flag.Var((*stringList)(&config.URLs), "url", "Media URL to play (repeatable)")
+	flag.Var((*stringList)(&config.Lists), "list", "M3U or text file of URLs to queue (repeatable)")
+	flag.BoolVar(&config.Shuffle, "shuffle", false, "Shuffle the queue")
+	flag.BoolVar(&config.Loop, "loop", false, "Repeat the current track")
+	flag.BoolVar(&config.RepeatAll, "repeat-all", false, "Repeat the whole queue")
+	flag.IntVar(&config.Volume, "volume", volume, "Volume in percent (0-200)")
+	flag.StringVar(&config.Device, "device", settings.Device, "Audio output device")
+	flag.StringVar(&config.Format, "format", settings.Format, "Preferred yt-dlp format selector")
+	flag.StringVar(&config.EQ, "eq", "", "Equalizer bands as freq=gain dB (e.g. 60=4,1k=-2)")
+	flag.Var((*stringList)(&config.Effects), "effect", "Audio effect: "+strings.Join(encoder.Effects(), ", ")+" (repeatable)")
+	flag.BoolVar(&config.Resume, "resume", false, "Continue tracks where they were left off")
+	flag.Func("start-at", "Start offset (e.g. 1m30s, 1:30 or 90)", func(value string) error {
+		offset, err := ParseOffset(value)
+		config.StartAt = offset
+		return err
+	})
+
+	flag.IntVar(&config.Limit, "limit", 5, "Number of search results (max 10)")
+	quiet := flag.Bool("quiet", false, "Only log errors")
+	verbose := flag.Bool("verbose", false, "Log debug details")
+	flag.BoolVar(&config.JSON, "json", false, "Emit JSON status lines on stdout")
 
 	flag.Usage = printUsage
-	flag.Parse()
 
-	// If no flags provided, try positional argument (backward compatibility)
-	if config.URL == "" && flag.NArg() > 0 {
-		config.URL = flag.Arg(0)
+	// music-bot search [flags] <query...>
+	search := len(os.Args) > 1 && os.Args[1] == "search"
+	if search {
+		flag.CommandLine.Parse(os.Args[2:])
+		config.Search = strings.Join(flag.Args(), " ")
+	} else {
+		flag.Parse()
+		// Positional arguments are queued after any -url flags
+		config.URLs = append(config.URLs, flag.Args()...)
+	}
+
+	switch {
+	case *quiet && *verbose:
+		return nil, fmt.Errorf("-quiet and -verbose are mutually exclusive")
+	case *quiet:
+		config.Verbosity = VerbosityQuiet
+	case *verbose:
+		config.Verbosity = VerbosityVerbose
 	}
 
 	// Validate required fields
-	if config.URL == "" {
+	if config.Volume < 0 || config.Volume > 200 {
+		return nil, fmt.Errorf("volume must be between 0 and 200")
+	}
+	if _, err := encoder.FilterChain(config.EQ, config.Effects, 48000); err != nil {
+		return nil, err
+	}
+	if search && config.Search == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if !search && len(config.URLs) == 0 && len(config.Lists) == 0 {
 		return nil, fmt.Errorf("URL is required")
 	}
 
 	return config, nil
 }
 
+// ParseOffset parses a start offset given as a Go duration ("1m30s"),
+// clock time ("1:30", "1:02:03") or plain seconds ("90", "90.5").
+func ParseOffset(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid offset %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	if strings.Count(value, ":") > 2 {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // printUsage prints the usage information.
 func printUsage() {
 	fmt.Println("\nUsage:")
-	fmt.Println("  music-bot -p <platform> -url <url>")
-	fmt.Println("  music-bot <youtube_url>")
+	fmt.Println("  music-bot -p <platform> -url <url> [-url <url>...]")
+	fmt.Println("  music-bot <youtube_url> [<youtube_url>...]")
+	fmt.Println("  music-bot -list playlist.m3u | cat urls.txt | music-bot -")
+	fmt.Println("  music-bot search [-limit n] <query>")
+	fmt.Println("  music-bot daemon [-port n] [-socket path] [-stdio] [-drain-grace 30s] [-config file.yaml]")
+	fmt.Println("  music-bot doctor [-url url] [-socket path]")
 	fmt.Println("\nFlags:")
 	fmt.Println("  -p, -platform    Platform name (youtube)")
-	fmt.Println("  -url             Media URL to play")
+	fmt.Println("  -url             Media URL or playlist to play (repeat to queue several)")
+	fmt.Println("  -list            M3U or text file of URLs / local paths (repeatable)")
+	fmt.Println("  -                Read a list of URLs from stdin")
+	fmt.Println("  -shuffle         Shuffle the queue")
+	fmt.Println("  -loop            Repeat the current track (n still skips)")
+	fmt.Println("  -repeat-all      Start the queue over after the last track")
+	fmt.Println("  -start-at        Start each track at an offset (1m30s, 1:30 or 90)")
+	fmt.Println("  -resume          Continue tracks where they were left off (saved after 1m)")
+	fmt.Println("  -volume          Volume in percent, 0-200 (default 100)")
+	fmt.Println("  -device          Audio output device (default: system default)")
+	fmt.Println("  -format          Preferred yt-dlp format selector (e.g. bestaudio[ext=webm])")
+	fmt.Println("  -eq              Equalizer bands as freq=gain in dB, e.g. 60=4,1k=-2,8k=3")
+	fmt.Println("  -effect          Audio effect, repeatable: bassboost, nightcore, loudnorm, karaoke, 8d")
+	fmt.Println("                   (nightcore speeds playback up; shown positions are approximate)")
+	fmt.Println("  -quiet           Only log errors")
+	fmt.Println("  -verbose         Log debug details")
+	fmt.Println("  -json            Emit JSON status lines on stdout (logs go to stderr)")
+	fmt.Println("  -limit           Number of search results (default 5, max 10)")
+	fmt.Println("\nDaemon flags:")
+	fmt.Println("  -port            HTTP API port (default $GO_API_PORT or 8180)")
+	fmt.Println("  -listen          HTTP API listen address, e.g. 0.0.0.0:8443 (overrides -port)")
+	fmt.Println("  -api-socket      Serve the HTTP API on this Unix socket instead of a TCP port")
+	fmt.Println("  -tls-cert        Serve the API over HTTPS with this certificate (with -tls-key)")
+	fmt.Println("  -tls-key         Private key for -tls-cert")
+	fmt.Printf("  -socket          Unix socket path (default $SOCKET_PATH or %s)\n", server.DefaultSocketPath)
+	fmt.Println("  -stdio           Speak the audio protocol on stdin/stdout when spawned by a bot; logs go")
+	fmt.Println("                   to stderr, there is no socket and no HTTP API unless -port is given,")
+	fmt.Println("                   and closing stdin shuts the server down")
+	fmt.Println("  -drain-grace     On SIGTERM, how long playing tracks may finish (default 30s, 0 stops at once)")
+	fmt.Println("  -config          YAML file with port, socket, settings (per-guild settings file),")
+	fmt.Println("                   positions (resume positions file), loudness_db, admin_token,")
+	fmt.Println("                   audit_log, drain_grace, retry, scrobble and logging settings, listen,")
+	fmt.Println("                   cache (dir and max_mb for download_first tracks and thumbnails),")
+	fmt.Println("                   prefetch (upcoming queue tracks to extract ahead, default 2, 0 disables),")
+	fmt.Println("                   api_socket (uses socket_access like the audio socket),")
+	fmt.Println("                   and tls (cert, key, or autocert hostnames with cache_dir and email;")
+	fmt.Println("                   redirect, e.g. \":80\", redirects plain HTTP to HTTPS)")
+	fmt.Println("\nDoctor flags (checks yt-dlp, FFmpeg, the socket directory and cookies):")
+	fmt.Println("  -url             Video to test extraction with (default: a public test video)")
+	fmt.Println("  -socket          Socket path whose directory is checked")
+	fmt.Println("\nConfig file (~/.config/music-bot/config.yaml):")
+	fmt.Println("  volume: 80")
+	fmt.Println("  device: default")
+	fmt.Println("  format: bestaudio[ext=webm]")
+	fmt.Println("  cookies:")
+	fmt.Println("    file: ~/cookies.txt      # or browser: firefox")
+	fmt.Println("  scrobble:                  # optional; also read by daemon -config")
+	fmt.Println("    lastfm: {api_key: ..., secret: ..., session_key: ...}")
+	fmt.Println("    listenbrainz: {token: ...}")
+	fmt.Println("\nKeys:")
+	fmt.Println("  space            Pause / resume")
+	fmt.Println("  left / right     Seek -10s / +10s")
+	fmt.Println("  up / down        Volume +10% / -10%")
+	fmt.Println("  [ / ]            Previous / next chapter")
+	fmt.Println("  n                Skip to the next track")
 	fmt.Println("\nExamples:")
 	fmt.Println("  music-bot -p youtube -url https://www.youtube.com/watch?v=dQw4w9WgXcQ")
-	fmt.Println("  music-bot https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	fmt.Println("  music-bot https://www.youtube.com/watch?v=dQw4w9WgXcQ https://www.youtube.com/watch?v=9bZkp7q19f0")
+	fmt.Println("  music-bot -shuffle https://www.youtube.com/playlist?list=PLxxxxxxxx")
+	fmt.Println("  music-bot -list ~/music/favourites.m3u -shuffle")
+	fmt.Println("  music-bot -effect bassboost -eq 8k=3 https://youtu.be/dQw4w9WgXcQ")
+	fmt.Println("  music-bot search \"never gonna give you up\"")
 	fmt.Println()
 }
 
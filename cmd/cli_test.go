@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOffset(t *testing.T) {
+	valid := map[string]time.Duration{
+		"1m30s":   90 * time.Second,
+		"90":      90 * time.Second,
+		"90.5":    90500 * time.Millisecond,
+		"1:30":    90 * time.Second,
+		"1:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+	}
+	for value, want := range valid {
+		if got, err := ParseOffset(value); err != nil || got != want {
+			t.Errorf("ParseOffset(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "-5", "1:2:3:4", "-1m"} {
+		if _, err := ParseOffset(value); err == nil {
+			t.Errorf("ParseOffset(%q): expected error", value)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/thewind121212/natashi/internal/cache"
+	"github.com/thewind121212/natashi/internal/logging"
+	"github.com/thewind121212/natashi/internal/scrobble"
+	"github.com/thewind121212/natashi/internal/server"
+)
+
+// DaemonConfig holds the settings for `music-bot daemon`.
+type DaemonConfig struct {
+	Port       int           `yaml:"port"`        // HTTP API port
+	Listen     string        `yaml:"listen"`      // HTTP API listen address, e.g. 0.0.0.0:8443 (overrides port)
+	APISocket  string        `yaml:"api_socket"`  // Unix socket serving the HTTP API instead of a TCP port
+	Socket     string        `yaml:"socket"`      // Unix socket path for audio
+	SocketTCP  string        `yaml:"socket_tcp"`  // TCP address serving audio instead of the Unix socket (no authentication)
+	Stdio      bool          `yaml:"-"`           // Serve audio on stdin/stdout as a child process (-stdio)
+	StdioHTTP  bool          `yaml:"-"`           // Keep the HTTP API in stdio mode (-port given)
+	Settings   string        `yaml:"settings"`    // JSON file for per-guild settings
+	Positions  string        `yaml:"positions"`   // JSON file for per-guild resume positions
+	Loudness   string        `yaml:"loudness_db"` // SQLite file caching loudness measurements
+	AdminToken string        `yaml:"admin_token"` // Bearer token for /admin endpoints (empty disables them)
+	AuditLog   string        `yaml:"audit_log"`   // JSON-lines file recording control actions (empty keeps them in memory)
+	DrainGrace time.Duration `yaml:"drain_grace"` // How long in-flight tracks may finish on shutdown
+	Prefetch   int           `yaml:"prefetch"`    // Upcoming queue tracks to extract ahead (0 disables)
+
+	Scrobble scrobble.Config    `yaml:"scrobble"` // Last.fm / ListenBrainz credentials
+	Logging  logging.Config     `yaml:"logging"`  // Log sinks and levels
+	Retry    server.RetryPolicy `yaml:"retry"`    // Backoff and budget for pipeline retries
+	Cache    cache.Config       `yaml:"cache"`    // Disk cache for download_first tracks and thumbnails
+
+	SocketAccess server.SocketAccess `yaml:"socket_access"` // Socket file mode/owner and allowed peer UIDs/GIDs
+	TLS          server.TLSConfig    `yaml:"tls"`           // HTTPS for the API (cert/key or autocert) and the HTTP redirect
+
+	stdout io.Writer // The process's stdout once ClaimStdout took it for the protocol
+}
+
+// defaultDaemonConfig honours GO_API_PORT, SOCKET_PATH and ADMIN_TOKEN like
+// cmd/playground.
+func defaultDaemonConfig() DaemonConfig {
+	config := DaemonConfig{
+		Port:       8180,
+		Socket:     server.DefaultSocketPath,
+		Settings:   GuildSettingsPath(),
+		Positions:  GuildPositionsPath(),
+		Loudness:   LoudnessCachePath(),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		DrainGrace: server.DefaultDrainGrace,
+		Cache:      cache.Config{Dir: DiskCachePath()},
+		Prefetch:   server.DefaultPrefetch,
+	}
+	if port, err := strconv.Atoi(os.Getenv("GO_API_PORT")); err == nil {
+		config.Port = port
+	}
+	if socket := os.Getenv("SOCKET_PATH"); socket != "" {
+		config.Socket = socket
+	}
+	return config
+}
+
+// parseDaemonArgs parses `music-bot daemon [flags]`. Settings come from the
+// defaults, then the -config file, then flags given on the command line.
+func parseDaemonArgs(args []string) (*DaemonConfig, error) {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	port := fs.Int("port", 0, "HTTP API port")
+	listen := fs.String("listen", "", "HTTP API listen address (overrides -port)")
+	apiSocket := fs.String("api-socket", "", "Unix socket serving the HTTP API instead of a TCP port")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	socket := fs.String("socket", "", "Unix socket path")
+	drainGrace := fs.Duration("drain-grace", 0, "How long in-flight tracks may finish on shutdown")
+	stdio := fs.Bool("stdio", false, "Speak the audio protocol on stdin/stdout (no socket, no HTTP API unless -port)")
+	configFile := fs.String("config", "", "YAML config file")
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := defaultDaemonConfig()
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", *configFile, err)
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			config.Port = *port
+		case "listen":
+			config.Listen = *listen
+		case "api-socket":
+			config.APISocket = *apiSocket
+		case "tls-cert":
+			config.TLS.CertFile = *tlsCert
+		case "tls-key":
+			config.TLS.KeyFile = *tlsKey
+		case "socket":
+			config.Socket = *socket
+		case "drain-grace":
+			config.DrainGrace = *drainGrace
+		case "stdio":
+			config.Stdio = *stdio
+		}
+	})
+	if config.Stdio {
+		config.StdioHTTP = *port != 0
+		config.Logging.Sinks = stdioLogSinks(config.Logging.Sinks)
+	}
+
+	if config.Port <= 0 || config.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", config.Port)
+	}
+	if config.Listen != "" {
+		if _, _, err := net.SplitHostPort(config.Listen); err != nil {
+			return nil, fmt.Errorf("invalid listen address %q: %w", config.Listen, err)
+		}
+	}
+	if len(config.TLS.Autocert) > 0 && config.TLS.CacheDir == "" {
+		config.TLS.CacheDir = AutocertCachePath()
+	}
+	if err := config.TLS.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// stdioLogSinks moves stdout logging to stderr, since in stdio mode stdout
+// carries the audio protocol (ClaimStdout also redirects os.Stdout).
+func stdioLogSinks(sinks []logging.SinkConfig) []logging.SinkConfig {
+	if len(sinks) == 0 {
+		return []logging.SinkConfig{{Type: "stderr"}}
+	}
+	moved := make([]logging.SinkConfig, len(sinks))
+	for i, sink := range sinks {
+		if sink.Type == "" || sink.Type == "stdout" {
+			sink.Type = "stderr"
+		}
+		moved[i] = sink
+	}
+	return moved
+}
+
+// ClaimStdout reserves stdout for the audio protocol in stdio mode: the
+// stdio transport keeps the real stdout and os.Stdout points at stderr from
+// then on, so no other writer (a log sink, a stray print) can corrupt the
+// frames. Call it before anything else holds on to os.Stdout.
+func (c *DaemonConfig) ClaimStdout() {
+	if !c.Stdio || c.stdout != nil {
+		return
+	}
+	c.stdout = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// ServerOptions converts the daemon settings for server.Run.
+func (c *DaemonConfig) ServerOptions() server.Options {
+	var transport server.Transport
+	switch {
+	case c.Stdio:
+		out := c.stdout
+		if out == nil {
+			out = os.Stdout
+		}
+		transport = server.NewStdioTransport(os.Stdin, out)
+	case c.SocketTCP != "":
+		transport = &server.TCPTransport{Address: c.SocketTCP}
+	}
+	addr := c.Listen
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", c.Port)
+	}
+	return server.Options{
+		HTTPAddr:   addr,
+		APISocket:  c.APISocket,
+		TLS:        c.TLS,
+		NoHTTP:     c.Stdio && !c.StdioHTTP,
+		SocketPath: c.Socket,
+		Socket:     c.SocketAccess,
+		Transport:  transport,
+		Scrobbler:  scrobble.New(c.Scrobble),
+		Settings:   c.Settings,
+		Positions:  c.Positions,
+		Loudness:   c.Loudness,
+		AdminToken: c.AdminToken,
+		AuditLog:   c.AuditLog,
+		DrainGrace: c.DrainGrace,
+		Retry:      c.Retry,
+		Cache:      c.Cache,
+		Prefetch:   c.Prefetch,
+	}
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/logging"
+	"github.com/thewind121212/natashi/internal/server"
+)
+
+func TestParseDaemonArgs_FlagsOverrideConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 9000\nsocket: /tmp/from-file.sock\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseDaemonArgs([]string{"-config", path, "-port", "9100"})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if config.Port != 9100 || config.Socket != "/tmp/from-file.sock" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if opts := config.ServerOptions(); opts.HTTPAddr != ":9100" {
+		t.Errorf("expected :9100, got %q", opts.HTTPAddr)
+	}
+}
+
+func TestParseDaemonArgs_Defaults(t *testing.T) {
+	t.Setenv("GO_API_PORT", "8200")
+	t.Setenv("SOCKET_PATH", "/run/natashi/audio.sock")
+	config, err := parseDaemonArgs(nil)
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if config.Port != 8200 || config.Socket != "/run/natashi/audio.sock" {
+		t.Errorf("unexpected defaults %+v", config)
+	}
+
+	if _, err := parseDaemonArgs([]string{"-port", "70000"}); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+func TestParseDaemonArgs_LoggingConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "logging:\n  level: warn\n  levels:\n    encoder: debug\n  sinks:\n    - type: file\n      path: /tmp/natashi.log\n      max_size_mb: 5\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseDaemonArgs([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	logs := config.Logging
+	if logs.Level != "warn" || logs.Levels["encoder"] != "debug" {
+		t.Errorf("unexpected levels %+v", logs)
+	}
+	if len(logs.Sinks) != 1 || logs.Sinks[0].Type != "file" || logs.Sinks[0].MaxSizeMB != 5 {
+		t.Errorf("unexpected sinks %+v", logs.Sinks)
+	}
+}
+
+func TestParseDaemonArgs_DrainGrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("drain_grace: 45s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseDaemonArgs([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if config.DrainGrace != 45*time.Second {
+		t.Errorf("expected 45s from the config file, got %v", config.DrainGrace)
+	}
+
+	config, err = parseDaemonArgs([]string{"-config", path, "-drain-grace", "0"})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if config.DrainGrace != 0 {
+		t.Errorf("expected -drain-grace to override the config file, got %v", config.DrainGrace)
+	}
+}
+
+func TestParseDaemonArgs_RetryConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "retry:\n  base_delay: 2s\n  max_delay: 1m\n  budget: 5\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseDaemonArgs([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	retry := config.ServerOptions().Retry
+	if retry.BaseDelay != 2*time.Second || retry.MaxDelay != time.Minute || retry.Budget != 5 {
+		t.Errorf("unexpected retry policy %+v", retry)
+	}
+}
+
+func TestParseDaemonArgs_SocketAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "socket_access:\n  mode: 0660\n  group: audio\n  allow_uids: [1000]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseDaemonArgs([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	access := config.ServerOptions().Socket
+	if access.Mode != 0o660 || access.Group != "audio" || len(access.AllowUIDs) != 1 || access.AllowUIDs[0] != 1000 {
+		t.Errorf("unexpected socket access %+v", access)
+	}
+}
+
+func TestParseDaemonArgs_Stdio(t *testing.T) {
+	config, err := parseDaemonArgs([]string{"-stdio"})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	opts := config.ServerOptions()
+	if !opts.NoHTTP || opts.Transport == nil || opts.Transport.Addr() != "stdio" {
+		t.Errorf("expected stdio transport without HTTP, got %+v", opts)
+	}
+	if sinks := config.Logging.Sinks; len(sinks) != 1 || sinks[0].Type != "stderr" {
+		t.Errorf("expected logs on stderr, got %+v", sinks)
+	}
+
+	config, err = parseDaemonArgs([]string{"-stdio", "-port", "9000"})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if opts := config.ServerOptions(); opts.NoHTTP || opts.HTTPAddr != ":9000" {
+		t.Errorf("expected the HTTP API with -port, got %+v", opts)
+	}
+}
+
+func TestDaemon_StdioKeepsStdoutForFrames(t *testing.T) {
+	pushed := make(chan struct{}, 1)
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case pushed <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer loki.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := fmt.Sprintf("settings: %s\npositions: %s\nloudness_db: %s\ncache: {dir: %s}\n"+
+		"logging:\n  sinks:\n    - {type: loki, url: %q}\n",
+		filepath.Join(dir, "settings.json"), filepath.Join(dir, "positions.json"),
+		filepath.Join(dir, "loudness.db"), filepath.Join(dir, "cache"), loki.URL)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseDaemonArgs([]string{"-stdio", "-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinR, stdoutW
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	config.ClaimStdout()
+	if os.Stdout != os.Stderr {
+		t.Fatal("expected os.Stdout pointed at stderr in stdio mode")
+	}
+	if err := logging.Configure(config.Logging); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer logging.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx, config.ServerOptions()) }()
+	lines := make(chan []string, 1)
+	go func() {
+		var read []string
+		scanner := bufio.NewScanner(stdoutR)
+		for scanner.Scan() {
+			read = append(read, scanner.Text())
+		}
+		lines <- read
+	}()
+
+	// A failed Loki push is reported on the next write; stray prints go
+	// wherever os.Stdout points
+	logger := logging.New("test")
+	for i := 0; i < 500; i++ {
+		logger.Infof("line %d", i)
+	}
+	select {
+	case <-pushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no Loki push")
+	}
+	time.Sleep(50 * time.Millisecond)
+	logger.Infof("after the failed push")
+	fmt.Println("stray print")
+
+	stdinW.Write([]byte(`{"type":"stop","id":"1","session_id":"guild-1"}` + "\n"))
+	time.Sleep(100 * time.Millisecond)
+	stdinW.Close() // The parent exits
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	stdoutW.Close()
+
+	read := <-lines
+	acked := false
+	for _, line := range read {
+		var ev server.Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Errorf("expected only protocol frames on stdout, got %q", line)
+		}
+		acked = acked || ev.Type == server.EventAck && ev.CommandID == "1"
+	}
+	if !acked {
+		t.Errorf("expected the stop acked on stdout, got %q", read)
+	}
+}
+
+func TestParseDaemonArgs_TLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "listen: 0.0.0.0:8443\ntls:\n  autocert: [music.example.com]\n  redirect: \":80\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseDaemonArgs([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	opts := config.ServerOptions()
+	if opts.HTTPAddr != "0.0.0.0:8443" || opts.TLS.Redirect != ":80" || opts.TLS.CacheDir == "" {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	if _, err := parseDaemonArgs([]string{"-tls-cert", "cert.pem"}); err == nil {
+		t.Error("expected error for a certificate without a key")
+	}
+	if _, err := parseDaemonArgs([]string{"-listen", "8443"}); err == nil {
+		t.Error("expected error for a listen address without a port")
+	}
+}
+
+func TestParseDaemonArgs_APISocket(t *testing.T) {
+	config, err := parseDaemonArgs([]string{"-api-socket", "/run/natashi/api.sock"})
+	if err != nil {
+		t.Fatalf("parseDaemonArgs failed: %v", err)
+	}
+	if opts := config.ServerOptions(); opts.APISocket != "/run/natashi/api.sock" {
+		t.Errorf("expected the API socket in the options, got %q", opts.APISocket)
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/thewind121212/natashi/internal/doctor"
+)
+
+// DoctorConfig holds the settings for `music-bot doctor`.
+type DoctorConfig struct {
+	URL     string       // Video used for the extraction check
+	Socket  string       // Audio socket path whose directory is checked
+	Cookies CookieConfig // From the config file, as for playback
+	Geo     GeoConfig    // From the config file, as for playback
+}
+
+// parseDoctorArgs parses `music-bot doctor [flags]`.
+func parseDoctorArgs(args []string) (*DoctorConfig, error) {
+	settings, err := LoadSettings(SettingsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	config := &DoctorConfig{
+		Cookies: CookieConfig{File: settings.Cookies.File, Profiles: settings.Cookies.Profiles, Browser: settings.Cookies.Browser},
+		Geo:     settings.Geo,
+	}
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.StringVar(&config.URL, "url", doctor.TestVideoURL, "Video to test extraction with")
+	fs.StringVar(&config.Socket, "socket", defaultDaemonConfig().Socket, "Unix socket path")
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Key is a keypress: a printable character, or one of the arrow keys.
+type Key rune
+
+// Arrow keys (decoded from ANSI escape sequences).
+const (
+	KeyUp Key = -(iota + 1)
+	KeyDown
+	KeyRight
+	KeyLeft
+)
+
+// ReadKeys switches the terminal to character mode (no Enter needed, no
+// echo) and returns a channel of keypresses from stdin. Call restore before
+// exiting to put the terminal back. Keys are read from /dev/tty when it is
+// available, so they still work when stdin is a piped URL list. Where stty
+// is unavailable (e.g. Windows), keys are still delivered, but only after
+// Enter.
+func ReadKeys() (keys <-chan Key, restore func()) {
+	tty := os.Stdin
+	if f, err := os.Open("/dev/tty"); err == nil {
+		tty = f
+	}
+
+	restore = func() {}
+	if saved, err := stty(tty, "-g"); err == nil {
+		if _, err := stty(tty, "-icanon", "-echo", "min", "1"); err == nil {
+			restore = func() { stty(tty, strings.TrimSpace(saved)) }
+		}
+	}
+
+	ch := make(chan Key, 16)
+	go func() {
+		defer close(ch)
+		in := bufio.NewReader(tty)
+		for {
+			b, err := in.ReadByte()
+			if err != nil {
+				return
+			}
+			if b != 0x1b {
+				ch <- Key(b)
+				continue
+			}
+			// ESC [ A-D: arrow keys
+			if next, err := in.ReadByte(); err != nil || next != '[' {
+				continue
+			}
+			code, err := in.ReadByte()
+			if err != nil {
+				return
+			}
+			if code >= 'A' && code <= 'D' {
+				ch <- KeyUp - Key(code-'A')
+			}
+		}
+	}()
+	return ch, restore
+}
+
+// stty runs stty against the given terminal.
+func stty(tty *os.File, args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = tty
+	out, err := c.Output()
+	return string(out), err
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Verbosity controls how much the CLI logs.
+type Verbosity int
+
+const (
+	VerbosityQuiet   Verbosity = iota - 1 // Errors only
+	VerbosityNormal                       // Progress messages (default)
+	VerbosityVerbose                      // Plus debug details
+)
+
+// Output writes CLI logs and, in JSON mode, machine-readable status events.
+// In JSON mode stdout carries only one JSON object per line; logs go to stderr.
+type Output struct {
+	verbosity Verbosity
+	json      bool
+
+	mu     sync.Mutex
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// NewOutput creates an Output for the parsed CLI flags.
+func NewOutput(config *Config) *Output {
+	return &Output{
+		verbosity: config.Verbosity,
+		json:      config.JSON,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+	}
+}
+
+// JSON reports whether status events are emitted as JSON lines.
+func (o *Output) JSON() bool {
+	return o.json
+}
+
+// Verbosity returns the configured log level.
+func (o *Output) Verbosity() Verbosity {
+	return o.verbosity
+}
+
+// Infof logs a progress message (hidden with -quiet).
+func (o *Output) Infof(format string, args ...any) {
+	if o.verbosity >= VerbosityNormal {
+		o.log("[INFO] ", format, args)
+	}
+}
+
+// Debugf logs a detail only shown with -verbose.
+func (o *Output) Debugf(format string, args ...any) {
+	if o.verbosity >= VerbosityVerbose {
+		o.log("[DEBUG] ", format, args)
+	}
+}
+
+// Errorf logs an error; in JSON mode it is also emitted as an "error" event.
+func (o *Output) Errorf(format string, args ...any) {
+	o.log("[ERROR] ", format, args)
+	o.Event("error", map[string]any{"message": fmt.Sprintf(format, args...)})
+}
+
+// Event emits a JSON status line ({"event": ..., "time": ..., fields...}).
+// No-op unless JSON mode is enabled.
+func (o *Output) Event(event string, fields map[string]any) {
+	if !o.json {
+		return
+	}
+	line := map[string]any{"event": event, "time": time.Now().UTC().Format(time.RFC3339Nano)}
+	for k, v := range fields {
+		line[k] = v
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stdout.Write(append(data, '\n'))
+}
+
+func (o *Output) log(prefix, format string, args []any) {
+	w := o.stdout
+	if o.json {
+		w = o.stderr // Keep stdout parseable
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fmt.Fprintf(w, prefix+format+"\n", args...)
+}
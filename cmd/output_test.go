@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newTestOutput(verbosity Verbosity, jsonMode bool) (*Output, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &Output{verbosity: verbosity, json: jsonMode, stdout: &stdout, stderr: &stderr}, &stdout, &stderr
+}
+
+func TestOutput_Verbosity(t *testing.T) {
+	out, stdout, _ := newTestOutput(VerbosityQuiet, false)
+	out.Infof("hidden")
+	out.Debugf("hidden")
+	out.Errorf("boom %d", 1)
+	if got := stdout.String(); got != "[ERROR] boom 1\n" {
+		t.Errorf("quiet: unexpected output %q", got)
+	}
+
+	out, stdout, _ = newTestOutput(VerbosityVerbose, false)
+	out.Infof("info")
+	out.Debugf("debug")
+	out.Event("ignored", nil)
+	if got := stdout.String(); got != "[INFO] info\n[DEBUG] debug\n" {
+		t.Errorf("verbose: unexpected output %q", got)
+	}
+}
+
+func TestOutput_JSONKeepsStdoutParseable(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityNormal, true)
+	out.Infof("progress")
+	out.Event("track_started", map[string]any{"index": 1})
+	out.Errorf("boom")
+
+	if !strings.Contains(stderr.String(), "[INFO] progress") || !strings.Contains(stderr.String(), "[ERROR] boom") {
+		t.Errorf("expected logs on stderr, got %q", stderr.String())
+	}
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("stdout line is not JSON: %q", line)
+		}
+		events = append(events, ev["event"].(string))
+	}
+	if strings.Join(events, ",") != "track_started,error" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
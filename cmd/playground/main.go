@@ -8,9 +8,10 @@ import (
 	"os/signal"
 	"syscall"
 
-	"music-bot/internal/platform/youtube"
-	"music-bot/internal/server"
-	"music-bot/pkg/deps"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/server"
+	"github.com/thewind121212/natashi/pkg/deps"
 )
 
 func main() {
@@ -23,13 +24,15 @@ func main() {
 	fmt.Println("=== Audio Playground Server ===")
 
 	// Check dependencies
-	checker := deps.NewChecker("yt-dlp", "ffmpeg")
+	checker := deps.NewCheckerFor(deps.Defaults()...)
 	if err := checker.CheckAndPrint(); err != nil {
 		os.Exit(1)
 	}
 
 	// Load YouTube config from environment
+	youtube.SetConfig(youtube.Config{Binary: checker.Path("yt-dlp")})
 	youtube.LoadConfigFromEnv()
+	encoder.SetFFmpegPath(checker.Path("ffmpeg"))
 
 	// Setup context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -40,37 +43,29 @@ func main() {
 
 	go func() {
 		<-sig
-		fmt.Println("\n[INFO] Shutting down...")
+		fmt.Println("\n[INFO] Shutting down, letting playing tracks finish (signal again to force)...")
 		cancel()
+		<-sig
+		os.Exit(1)
 	}()
 
-	// Create shared session manager
-	sessions := server.NewSessionManager(ctx)
-
-	// Start HTTP API server (Gin)
-	api := server.NewAPI(sessions)
-	router := server.SetupRouter(api)
-
+	// SIGHUP reloads the cookies file without dropping sessions
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	go func() {
-		fmt.Printf("[HTTP] API server listening on http://localhost%s\n", httpPort)
-		if err := router.Run(httpPort); err != nil {
-			fmt.Printf("[HTTP] Server error: %v\n", err)
+		for range hup {
+			server.ReloadCookies()
 		}
 	}()
 
-	// Start Unix socket server (audio streaming)
-	socketSrv := server.NewSocketServer("", sessions)
-	if err := socketSrv.Start(ctx); err != nil {
+	if err := server.Run(ctx, server.Options{
+		HTTPAddr:   httpPort,
+		SocketPath: os.Getenv("SOCKET_PATH"), // Same variable as the Node.js client; empty for the default
+		Degraded:   checker.Degraded(),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		DrainGrace: server.DefaultDrainGrace,
+	}); err != nil {
 		fmt.Printf("[ERROR] %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Println("[INFO] Ready!")
-	fmt.Println("[INFO] - HTTP API: http://localhost" + httpPort)
-	fmt.Println("[INFO] - Socket: /tmp/music-playground.sock")
-	fmt.Println("[INFO] Press Ctrl+C to stop")
-
-	// Wait for shutdown
-	<-ctx.Done()
-	socketSrv.Stop()
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+// PrintSearchResults prints numbered search results with duration and channel.
+func PrintSearchResults(out io.Writer, results []youtube.SearchResult) {
+	for i, r := range results {
+		fmt.Fprintf(out, "  %2d. %s [%s] - %s\n", i+1, r.Title, formatDuration(r.Duration), r.Channel)
+	}
+}
+
+// PickSearchResult prompts until the user enters a result number. It
+// returns false if the user quits (q or end of input).
+func PickSearchResult(in io.Reader, out io.Writer, results []youtube.SearchResult) (youtube.SearchResult, bool) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Pick a track [1-%d, q to quit]: ", len(results))
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return youtube.SearchResult{}, false
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "q" {
+			return youtube.SearchResult{}, false
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(results) {
+			return results[n-1], true
+		}
+		fmt.Fprintln(out, "Invalid choice")
+	}
+}
+
+// formatDuration formats seconds as m:ss or h:mm:ss ("live" if unknown).
+func formatDuration(seconds int) string {
+	if seconds <= 0 {
+		return "live"
+	}
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+func TestPickSearchResult(t *testing.T) {
+	results := []youtube.SearchResult{
+		{URL: "https://www.youtube.com/watch?v=a", Title: "A"},
+		{URL: "https://www.youtube.com/watch?v=b", Title: "B"},
+	}
+
+	var out bytes.Buffer
+	picked, ok := PickSearchResult(strings.NewReader("7\nx\n2\n"), &out, results)
+	if !ok || picked.Title != "B" {
+		t.Fatalf("expected B, got %+v (ok=%v)", picked, ok)
+	}
+	if got := strings.Count(out.String(), "Invalid choice"); got != 2 {
+		t.Errorf("expected 2 invalid choices, got %d", got)
+	}
+
+	if _, ok := PickSearchResult(strings.NewReader("q\n"), &out, results); ok {
+		t.Error("expected quit on q")
+	}
+	if _, ok := PickSearchResult(strings.NewReader(""), &out, results); ok {
+		t.Error("expected quit on end of input")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for seconds, want := range map[int]string{0: "live", 59: "0:59", 212: "3:32", 3725: "1:02:05"} {
+		if got := formatDuration(seconds); got != want {
+			t.Errorf("formatDuration(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/thewind121212/natashi/internal/scrobble"
+)
+
+// Settings are the persistent CLI defaults read from the config file.
+// Flags given on the command line take precedence.
+type Settings struct {
+	Volume  *int   `yaml:"volume"` // Percent (0-200); nil keeps 100
+	Device  string `yaml:"device"` // Audio output device
+	Format  string `yaml:"format"` // Preferred yt-dlp format selector
+	Cookies struct {
+		File     string   `yaml:"file"`     // cookies.txt path
+		Profiles []string `yaml:"profiles"` // More cookies.txt files, rotated to when YouTube blocks one
+		Browser  string   `yaml:"browser"`  // Browser to read cookies from
+	} `yaml:"cookies"`
+	Geo      GeoConfig       `yaml:"geo"`      // Region extraction runs from
+	Scrobble scrobble.Config `yaml:"scrobble"` // Last.fm / ListenBrainz credentials
+}
+
+// SettingsPath returns the config file location:
+// ~/.config/music-bot/config.yaml ($XDG_CONFIG_HOME on Linux).
+func SettingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "config.yaml")
+}
+
+// PositionsPath returns where saved playback positions are kept, next to
+// the config file.
+func PositionsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "positions.json")
+}
+
+// GuildSettingsPath returns where the daemon keeps per-guild settings,
+// next to the config file.
+func GuildSettingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "guild-settings.json")
+}
+
+// GuildPositionsPath returns where the daemon keeps resume positions per
+// guild and track, next to the config file.
+func GuildPositionsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "guild-positions.json")
+}
+
+// LoudnessCachePath returns where the daemon caches loudness measurements,
+// next to the config file.
+func LoudnessCachePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "loudness.db")
+}
+
+// AutocertCachePath returns where the daemon keeps Let's Encrypt
+// certificates, next to the config file.
+func AutocertCachePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "autocert")
+}
+
+// DiskCachePath returns where the daemon caches downloaded tracks, in the
+// user cache directory ($XDG_CACHE_HOME on Linux).
+func DiskCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "music-bot", "tracks")
+}
+
+// LoadSettings reads the config file at path. A missing file yields empty
+// settings.
+func LoadSettings(path string) (Settings, error) {
+	var settings Settings
+	if path == "" {
+		return settings, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `volume: 0
+device: alsa_output.usb
+format: bestaudio[ext=webm]
+cookies:
+  browser: firefox
+scrobble:
+  listenbrainz:
+    token: lb-token
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if settings.Volume == nil || *settings.Volume != 0 {
+		t.Errorf("expected volume 0, got %v", settings.Volume)
+	}
+	if settings.Device != "alsa_output.usb" || settings.Format != "bestaudio[ext=webm]" {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+	if settings.Cookies.Browser != "firefox" || settings.Cookies.File != "" {
+		t.Errorf("unexpected cookies: %+v", settings.Cookies)
+	}
+	if settings.Scrobble.ListenBrainz.Token != "lb-token" {
+		t.Errorf("unexpected scrobble config: %+v", settings.Scrobble)
+	}
+}
+
+func TestLoadSettings_Missing(t *testing.T) {
+	settings, err := LoadSettings(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("missing file should not be an error: %v", err)
+	}
+	if settings.Volume != nil || settings.Device != "" {
+		t.Errorf("expected empty settings, got %+v", settings)
+	}
+}
+
+func TestLoadSettings_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("volume: [loud"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSettings(path); err == nil {
+		t.Error("expected parse error")
+	}
+}
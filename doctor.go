@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/thewind121212/natashi/cmd"
+	"github.com/thewind121212/natashi/internal/doctor"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/pkg/deps"
+)
+
+// runDoctor runs `music-bot doctor` and returns the exit code: 0 if every
+// check passed or was skipped, 1 otherwise.
+func runDoctor(config *cmd.DoctorConfig) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Resolve binaries and cookies the same way playback does
+	checker := deps.NewCheckerFor(deps.Defaults()...)
+	checker.CheckAll()
+	youtube.SetConfig(youtube.Config{
+		CookiesFile:        expandHome(config.Cookies.File),
+		CookieProfiles:     expandHomes(config.Cookies.Profiles),
+		CookiesFromBrowser: config.Cookies.Browser,
+		GeoCountry:         config.Geo.Country,
+		Proxy:              config.Geo.Proxy,
+		RegionProxies:      config.Geo.RegionProxies,
+		Binary:             checker.Path("yt-dlp"),
+	})
+	youtube.LoadConfigFromEnv()
+	encoder.SetFFmpegPath(checker.Path("ffmpeg"))
+
+	fmt.Println("=== music-bot doctor ===")
+	results := doctor.Run(ctx, []doctor.Check{
+		doctor.Dependencies(checker),
+		doctor.Extraction(youtube.New(), config.URL),
+		doctor.Encode(),
+		doctor.Socket(config.Socket),
+		doctor.Cookies(),
+	})
+	if !doctor.Print(os.Stdout, results) {
+		return 1
+	}
+	return 0
+}
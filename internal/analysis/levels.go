@@ -0,0 +1,85 @@
+// Package analysis computes visualisation data (levels, spectrum) from the
+// decoded PCM tap of an encoding pipeline.
+package analysis
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SilenceFloor is the level reported for digital silence, in dBFS.
+const SilenceFloor = -96.0
+
+// Levels holds audio levels for one metering window, in dBFS.
+type Levels struct {
+	RMS  float64
+	Peak float64
+}
+
+// LevelMeter accumulates PCM s16le samples and reports RMS/peak levels
+// once per window.
+type LevelMeter struct {
+	window     int // Samples per report
+	onLevels   func(Levels)
+	sumSquares float64
+	peak       float64
+	count      int
+	carry      []byte // Odd trailing byte from the previous Write
+}
+
+// NewLevelMeter creates a meter that calls onLevels every window samples.
+func NewLevelMeter(window int, onLevels func(Levels)) *LevelMeter {
+	if window <= 0 {
+		window = 4800 // 100ms at 48kHz
+	}
+	return &LevelMeter{window: window, onLevels: onLevels}
+}
+
+// Write feeds PCM s16le data to the meter.
+func (m *LevelMeter) Write(pcm []byte) {
+	if len(m.carry) > 0 {
+		pcm = append(append([]byte(nil), m.carry...), pcm...)
+		m.carry = nil
+	}
+	for len(pcm) >= 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm))) / 32768.0
+		pcm = pcm[2:]
+
+		m.sumSquares += sample * sample
+		if abs := math.Abs(sample); abs > m.peak {
+			m.peak = abs
+		}
+		m.count++
+		if m.count == m.window {
+			m.flush()
+		}
+	}
+	if len(pcm) == 1 {
+		m.carry = []byte{pcm[0]}
+	}
+}
+
+// flush reports the current window and starts a new one.
+func (m *LevelMeter) flush() {
+	levels := Levels{
+		RMS:  ToDecibels(math.Sqrt(m.sumSquares / float64(m.count))),
+		Peak: ToDecibels(m.peak),
+	}
+	m.sumSquares, m.peak, m.count = 0, 0, 0
+	if m.onLevels != nil {
+		m.onLevels(levels)
+	}
+}
+
+// ToDecibels converts a linear amplitude (1.0 = full scale) to dBFS,
+// clamped to SilenceFloor.
+func ToDecibels(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return SilenceFloor
+	}
+	db := 20 * math.Log10(amplitude)
+	if db < SilenceFloor {
+		return SilenceFloor
+	}
+	return math.Round(db*10) / 10
+}
@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// pcmOf encodes samples as s16le.
+func pcmOf(samples ...int16) []byte {
+	pcm := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	return pcm
+}
+
+func TestLevelMeter_FullScaleSquareWave(t *testing.T) {
+	var got []Levels
+	meter := NewLevelMeter(4, func(l Levels) { got = append(got, l) })
+
+	pcm := pcmOf(-32768, -32768, -32768, -32768)
+	meter.Write(pcm[:3]) // Split mid-sample
+	meter.Write(pcm[3:])
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(got))
+	}
+	if got[0].RMS != 0 || got[0].Peak != 0 {
+		t.Errorf("expected 0 dBFS, got %+v", got[0])
+	}
+}
+
+func TestLevelMeter_Silence(t *testing.T) {
+	var got Levels
+	meter := NewLevelMeter(2, func(l Levels) { got = l })
+
+	meter.Write(pcmOf(0, 0))
+
+	if got.RMS != SilenceFloor || got.Peak != SilenceFloor {
+		t.Errorf("expected silence floor, got %+v", got)
+	}
+}
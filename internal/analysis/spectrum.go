@@ -0,0 +1,158 @@
+package analysis
+
+import (
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+)
+
+const (
+	fftSize         = 2048  // FFT window in samples (~43ms at 48kHz)
+	spectrumMinFreq = 40.0  // Lowest band edge in Hz
+	spectrumFloorDB = -80.0 // Magnitudes at or below this map to 0
+)
+
+// SpectrumAnalyzer accumulates PCM s16le mono samples and reports coarse,
+// log-spaced frequency band magnitudes at a fixed rate.
+type SpectrumAnalyzer struct {
+	sampleRate int
+	bands      int
+	hop        int // Samples between reports
+	onBands    func([]float64)
+
+	window   []float64 // Hann window coefficients
+	ring     []float64 // Last fftSize samples
+	pos      int       // Next write index in ring
+	filled   int       // Samples in ring (up to fftSize)
+	sinceHop int
+	edges    []int // FFT bin edges per band (len = bands+1)
+	carry    []byte
+}
+
+// NewSpectrumAnalyzer creates an analyzer that calls onBands with bands
+// magnitudes (0.0-1.0) rate times per second.
+func NewSpectrumAnalyzer(sampleRate, bands, rate int, onBands func([]float64)) *SpectrumAnalyzer {
+	if bands <= 0 {
+		bands = 32
+	}
+	if rate <= 0 {
+		rate = 10
+	}
+
+	a := &SpectrumAnalyzer{
+		sampleRate: sampleRate,
+		bands:      bands,
+		hop:        sampleRate / rate,
+		onBands:    onBands,
+		window:     make([]float64, fftSize),
+		ring:       make([]float64, fftSize),
+	}
+	for i := range a.window {
+		a.window[i] = 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(fftSize-1)))
+	}
+	a.edges = bandEdges(sampleRate, bands)
+	return a
+}
+
+// bandEdges splits the FFT bins between spectrumMinFreq and Nyquist into
+// log-spaced bands, each at least one bin wide.
+func bandEdges(sampleRate, bands int) []int {
+	nyquist := float64(sampleRate) / 2
+	binHz := float64(sampleRate) / fftSize
+	edges := make([]int, bands+1)
+	for i := 0; i <= bands; i++ {
+		freq := spectrumMinFreq * math.Pow(nyquist/spectrumMinFreq, float64(i)/float64(bands))
+		edges[i] = int(freq / binHz)
+		if i > 0 && edges[i] <= edges[i-1] {
+			edges[i] = edges[i-1] + 1
+		}
+	}
+	if edges[bands] > fftSize/2 {
+		edges[bands] = fftSize / 2
+	}
+	return edges
+}
+
+// Write feeds PCM s16le data to the analyzer.
+func (a *SpectrumAnalyzer) Write(pcm []byte) {
+	if len(a.carry) > 0 {
+		pcm = append(append([]byte(nil), a.carry...), pcm...)
+		a.carry = nil
+	}
+	for len(pcm) >= 2 {
+		a.ring[a.pos] = float64(int16(binary.LittleEndian.Uint16(pcm))) / 32768.0
+		pcm = pcm[2:]
+
+		a.pos = (a.pos + 1) % fftSize
+		if a.filled < fftSize {
+			a.filled++
+		}
+		a.sinceHop++
+		if a.sinceHop >= a.hop && a.filled == fftSize {
+			a.sinceHop = 0
+			a.report()
+		}
+	}
+	if len(pcm) == 1 {
+		a.carry = []byte{pcm[0]}
+	}
+}
+
+// report runs the FFT over the current window and emits band magnitudes.
+func (a *SpectrumAnalyzer) report() {
+	buf := make([]complex128, fftSize)
+	for i := 0; i < fftSize; i++ {
+		buf[i] = complex(a.ring[(a.pos+i)%fftSize]*a.window[i], 0)
+	}
+	fft(buf)
+
+	// Normalise so a full-scale sine peaks near 0 dB (Hann coherent gain 0.5)
+	scale := 4.0 / fftSize
+	out := make([]float64, a.bands)
+	for b := 0; b < a.bands; b++ {
+		peak := 0.0
+		for bin := a.edges[b]; bin < a.edges[b+1] && bin < fftSize/2; bin++ {
+			if mag := cmplx.Abs(buf[bin]) * scale; mag > peak {
+				peak = mag
+			}
+		}
+		db := ToDecibels(peak)
+		level := (db - spectrumFloorDB) / -spectrumFloorDB
+		out[b] = math.Round(math.Max(0, math.Min(1, level))*1000) / 1000
+	}
+
+	if a.onBands != nil {
+		a.onBands(out)
+	}
+}
+
+// fft computes an in-place iterative radix-2 FFT. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+
+	// Bit-reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := x[start+k]
+				v := x[start+k+size/2] * w
+				x[start+k] = u + v
+				x[start+k+size/2] = u - v
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpectrumAnalyzer_SinePeaksInItsBand(t *testing.T) {
+	const sampleRate = 48000
+	var got []float64
+	a := NewSpectrumAnalyzer(sampleRate, 32, 10, func(bands []float64) { got = bands })
+
+	samples := make([]int16, sampleRate/5)
+	for i := range samples {
+		samples[i] = int16(16000 * math.Sin(2*math.Pi*1000*float64(i)/sampleRate))
+	}
+	a.Write(pcmOf(samples...))
+
+	if len(got) != 32 {
+		t.Fatalf("expected 32 bands, got %d", len(got))
+	}
+	loudest := 0
+	for i, v := range got {
+		if v > got[loudest] {
+			loudest = i
+		}
+	}
+	edges := bandEdges(sampleRate, 32)
+	binHz := float64(sampleRate) / fftSize
+	low, high := float64(edges[loudest])*binHz, float64(edges[loudest+1])*binHz
+	if 1000 < low-binHz || 1000 > high+binHz {
+		t.Errorf("expected 1kHz in loudest band, got band %d (%.0f-%.0f Hz)", loudest, low, high)
+	}
+}
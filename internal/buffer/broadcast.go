@@ -0,0 +1,159 @@
+package buffer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultSubscriberCapacity = 64 // Chunks queued per subscriber before dropping
+
+// SubscriberConfig configures one Broadcast consumer.
+type SubscriberConfig struct {
+	Capacity int     // Chunks queued before new ones are dropped (default 64)
+	Pacing   *Config // Optional per-subscriber PacedBuffer in front of C()
+}
+
+// SubscriberStats is a snapshot of one subscriber's delivery counters.
+type SubscriberStats struct {
+	Delivered int64 // Chunks queued for the subscriber
+	Dropped   int64 // Chunks dropped because the subscriber fell behind
+}
+
+// Broadcast fans one audio stream out to any number of subscribers. Each
+// subscriber gets its own copy of every chunk, queued independently, so a
+// slow consumer drops its own audio without stalling the others.
+type Broadcast struct {
+	mu     sync.Mutex
+	subs   map[*Subscriber]struct{}
+	closed bool
+}
+
+// Subscriber is one consumer of a Broadcast.
+type Subscriber struct {
+	b      *Broadcast
+	raw    chan []byte
+	out    <-chan []byte
+	paced  *PacedBuffer
+	cancel context.CancelFunc
+
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+func NewBroadcast() *Broadcast {
+	return &Broadcast{subs: make(map[*Subscriber]struct{})}
+}
+
+// Subscribe adds a consumer. It receives chunks published from now on until
+// it is closed or the broadcast ends. Subscribing to a closed broadcast
+// returns a subscriber whose channel is already closed.
+func (b *Broadcast) Subscribe(cfg SubscriberConfig) *Subscriber {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = defaultSubscriberCapacity
+	}
+	s := &Subscriber{b: b, raw: make(chan []byte, cfg.Capacity)}
+	s.out = s.raw
+	if cfg.Pacing != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		s.paced = NewPacedBuffer(*cfg.Pacing)
+		s.out = s.paced.Start(ctx, s.raw)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.raw)
+	} else {
+		b.subs[s] = struct{}{}
+	}
+	return s
+}
+
+// Publish queues a copy of chunk for every subscriber. It never blocks; a
+// subscriber whose queue is full has the chunk counted as dropped. The caller
+// keeps ownership of chunk.
+func (b *Broadcast) Publish(chunk []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		c := GetChunk(len(chunk))
+		copy(c, chunk)
+		select {
+		case s.raw <- c:
+			s.delivered.Add(1)
+		default:
+			s.dropped.Add(1)
+			PutChunk(c)
+		}
+	}
+}
+
+// Tee forwards input to the returned channel unchanged, publishing a copy of
+// each chunk to the subscribers on the way.
+func (b *Broadcast) Tee(ctx context.Context, input <-chan []byte) <-chan []byte {
+	output := make(chan []byte)
+	go func() {
+		defer close(output)
+		for chunk := range input {
+			b.Publish(chunk)
+			select {
+			case <-ctx.Done():
+				return
+			case output <- chunk:
+			}
+		}
+	}()
+	return output
+}
+
+// Subscribers returns the number of active subscribers.
+func (b *Broadcast) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close ends the stream for every subscriber. Safe to call more than once.
+func (b *Broadcast) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for s := range b.subs {
+		close(s.raw)
+		delete(b.subs, s)
+	}
+}
+
+// C returns the subscriber's audio channel, closed when the subscriber or
+// the broadcast is closed. Chunks belong to the receiver (see PutChunk).
+func (s *Subscriber) C() <-chan []byte {
+	return s.out
+}
+
+// Paced returns the subscriber's pacing buffer, or nil if unpaced.
+func (s *Subscriber) Paced() *PacedBuffer {
+	return s.paced
+}
+
+// Stats returns the subscriber's delivery counters.
+func (s *Subscriber) Stats() SubscriberStats {
+	return SubscriberStats{Delivered: s.delivered.Load(), Dropped: s.dropped.Load()}
+}
+
+// Close unsubscribes and ends C().
+func (s *Subscriber) Close() {
+	s.b.mu.Lock()
+	if _, ok := s.b.subs[s]; ok {
+		delete(s.b.subs, s)
+		close(s.raw)
+	}
+	s.b.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
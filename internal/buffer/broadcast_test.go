@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBroadcast_SlowSubscriberDropsAlone(t *testing.T) {
+	b := NewBroadcast()
+	fast := b.Subscribe(SubscriberConfig{Capacity: 4})
+	slow := b.Subscribe(SubscriberConfig{Capacity: 1})
+
+	for id := byte(1); id <= 3; id++ {
+		b.Publish(chunk(id))
+	}
+	b.Close()
+
+	if got := drain(t, fast.C()); string(got) != string([]byte{1, 2, 3}) {
+		t.Errorf("fast subscriber: expected all chunks, got %v", got)
+	}
+	if got := drain(t, slow.C()); string(got) != string([]byte{1}) {
+		t.Errorf("slow subscriber: expected first chunk only, got %v", got)
+	}
+	if s := slow.Stats(); s.Delivered != 1 || s.Dropped != 2 {
+		t.Errorf("slow subscriber: expected 1 delivered, 2 dropped, got %+v", s)
+	}
+}
+
+func TestBroadcast_SubscribersGetCopies(t *testing.T) {
+	b := NewBroadcast()
+	a := b.Subscribe(SubscriberConfig{})
+	c := b.Subscribe(SubscriberConfig{})
+
+	data := chunk(7)
+	b.Publish(data)
+	data[0] = 9 // Publisher reuses its buffer
+
+	first, second := receive(t, a.C()), receive(t, c.C())
+	if first[0] != 7 || second[0] != 7 {
+		t.Fatalf("expected independent copies, got %d and %d", first[0], second[0])
+	}
+	first[0] = 1
+	if second[0] != 7 {
+		t.Error("subscribers share a chunk")
+	}
+}
+
+func TestBroadcast_CloseAndUnsubscribe(t *testing.T) {
+	b := NewBroadcast()
+	s := b.Subscribe(SubscriberConfig{
+		Pacing: &Config{Interval: time.Millisecond, Passthrough: true},
+	})
+	if s.Paced() == nil {
+		t.Fatal("expected paced subscriber")
+	}
+
+	s.Close()
+	s.Close() // Idempotent
+	if b.Subscribers() != 0 {
+		t.Errorf("expected no subscribers after Close, got %d", b.Subscribers())
+	}
+	drain(t, s.C())
+
+	b.Close()
+	late := b.Subscribe(SubscriberConfig{})
+	if got := drain(t, late.C()); len(got) != 0 {
+		t.Errorf("expected closed channel for late subscriber, got %v", got)
+	}
+}
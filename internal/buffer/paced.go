@@ -1,10 +1,65 @@
 package buffer
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/thewind121212/natashi/internal/ogg"
+)
+
+const (
+	defaultCapacity = 256 // Default ring capacity in chunks
+	defaultMinRate  = 0.5 // Slowest consumer-driven pacing rate
+	defaultMaxRate  = 2.0 // Fastest consumer-driven pacing rate
+	rateGain        = 0.5 // Rate change per 100% deviation from TargetLead
+
+	// underrunTolerance is how far releases may fall behind real time before
+	// it counts as an underrun (consumer ran out of audio).
+	underrunTolerance = 40 * time.Millisecond
+
+	// smoothResync is how far a smoothed buffer may fall behind its release
+	// schedule before the schedule restarts from now instead of catching up.
+	smoothResync = 100 * time.Millisecond
+
+	// oggProbeLimit is how many bytes may pass without a complete Ogg audio
+	// page before an Ogg-configured buffer falls back to bitrate estimates.
+	oggProbeLimit = 64 * 1024
 )
 
+// OverflowPolicy decides what happens when the buffer is full
+// (Capacity chunks or MaxBuffer of audio).
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued audio (default). Playback jumps
+	// forward, keeping latency bounded.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards incoming audio, keeping what is already queued.
+	DropNewest
+	// BlockUpstream stops reading input until there is room, pushing
+	// backpressure to the producer instead of losing audio.
+	BlockUpstream
+)
+
+// ParseOverflowPolicy parses "drop-oldest", "drop-newest" or "block".
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "drop-oldest":
+		return DropOldest, nil
+	case "drop-newest":
+		return DropNewest, nil
+	case "block", "block-upstream":
+		return BlockUpstream, nil
+	default:
+		return DropOldest, fmt.Errorf("unknown overflow policy %q", s)
+	}
+}
+
 type Config struct {
 	Bitrate     int
 	Prebuffer   time.Duration
@@ -13,14 +68,175 @@ type Config struct {
 	MaxBuffer   time.Duration
 	Interval    time.Duration
 	Passthrough bool
+	Capacity    int  // Ring capacity in chunks (default 256); oldest chunks are dropped when full
+	Ogg         bool // Input is an Ogg Opus stream: derive chunk durations from granule positions
+	Overflow    OverflowPolicy
+	Clock       Clock // Time source for pacing (default: real time)
+
+	// Smooth releases chunks on an absolute schedule (start + audio released
+	// so far) rather than delaying each chunk from the previous release, so
+	// bursty input comes out at an even cadence. With Ogg, chunks are split
+	// into pages so every 20ms frame is released on its own. Ignored with
+	// Passthrough.
+	Smooth bool
+
+	// Consumer-driven pacing: when TargetLead > 0, Ack adjusts the pacing
+	// rate so the consumer keeps about TargetLead of audio buffered. A
+	// Passthrough buffer releases at once while the consumer is below
+	// TargetLead and is paced at the rate once it is above.
+	TargetLead time.Duration
+	MinRate    float64 // Lower bound for the rate multiplier (default 0.5)
+	MaxRate    float64 // Upper bound for the rate multiplier (default 2.0)
+
+	// Optional observability callbacks, called from the buffer goroutine.
+	OnDrop     func(dropped time.Duration) // A chunk was dropped on overflow
+	OnUnderrun func(behind time.Duration)  // Output fell behind real time
+
+	// Fill-level watermarks. OnHighWater fires once when buffered audio
+	// reaches HighWater; OnLowWater fires once when it then falls to
+	// LowWater, so a producer can be throttled with hysteresis.
+	// Called from the buffer goroutine; ignored unless HighWater > 0.
+	LowWater    time.Duration
+	HighWater   time.Duration
+	OnLowWater  func(buffered time.Duration)
+	OnHighWater func(buffered time.Duration)
+}
+
+// Stats is a snapshot of PacedBuffer counters.
+type Stats struct {
+	ChunksBuffered int64         // Chunks accepted from input
+	ChunksDropped  int64         // Chunks dropped on overflow
+	Underruns      int64         // Times output fell behind real time
+	Queued         int           // Chunks currently queued
+	Buffered       time.Duration // Audio currently queued
 }
 
 type PacedBuffer struct {
-	cfg Config
+	cfg  Config
+	rate atomic.Uint64 // math.Float64bits of the pacing rate multiplier
+
+	chunksBuffered atomic.Int64
+	chunksDropped  atomic.Int64
+	underruns      atomic.Int64
+	queued         atomic.Int64
+	bufferedDur    atomic.Int64 // time.Duration
+
+	// Release clock for underrun detection (buffer goroutine only)
+	releaseStart time.Time
+	released     time.Duration
+
+	aboveHigh bool // HighWater reached and LowWater not yet (buffer goroutine only)
+
+	// Ogg duration tracking (buffer goroutine only)
+	ogg      ogg.OpusTracker
+	oggBytes int // Bytes seen before the first audio page
+
+	// Smooth mode (buffer goroutine only)
+	pages       *ogg.Scanner // Splits Ogg input into pages (nil until the first chunk)
+	split       bool         // Input is Ogg and is being split into pages
+	nextRelease time.Time    // When the next chunk is due
+
+	// Pause state, shared with Pause/Resume callers
+	pauseMu    sync.Mutex
+	pausedAt   time.Time     // Zero unless paused
+	pauseTotal time.Duration // Paused time not yet applied to the schedule
+	wake       chan struct{} // Nudges the buffer goroutine on Pause/Resume
 }
 
 func NewPacedBuffer(cfg Config) *PacedBuffer {
-	return &PacedBuffer{cfg: cfg}
+	if cfg.MinRate <= 0 {
+		cfg.MinRate = defaultMinRate
+	}
+	if cfg.MaxRate <= 0 {
+		cfg.MaxRate = defaultMaxRate
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	p := &PacedBuffer{cfg: cfg, wake: make(chan struct{}, 1)}
+	p.rate.Store(math.Float64bits(1))
+	return p
+}
+
+// Ack reports how much audio the consumer currently has buffered.
+// Below TargetLead the buffer releases chunks faster than real time so the
+// consumer isn't starved; above it, slower so it doesn't overflow.
+// Safe to call from any goroutine; no-op unless TargetLead is set.
+func (p *PacedBuffer) Ack(consumerBuffered time.Duration) {
+	if p.cfg.TargetLead <= 0 {
+		return
+	}
+	deviation := float64(p.cfg.TargetLead-consumerBuffered) / float64(p.cfg.TargetLead)
+	rate := 1 + deviation*rateGain
+	rate = math.Max(p.cfg.MinRate, math.Min(p.cfg.MaxRate, rate))
+	p.rate.Store(math.Float64bits(rate))
+}
+
+// Stats returns a snapshot of the buffer counters. Safe to call from any goroutine.
+func (p *PacedBuffer) Stats() Stats {
+	return Stats{
+		ChunksBuffered: p.chunksBuffered.Load(),
+		ChunksDropped:  p.chunksDropped.Load(),
+		Underruns:      p.underruns.Load(),
+		Queued:         int(p.queued.Load()),
+		Buffered:       time.Duration(p.bufferedDur.Load()),
+	}
+}
+
+// Pause stops releasing audio while keeping everything buffered. Input is
+// still accepted until the buffer is full, then blocked. The pacing clock is
+// frozen, so the pause doesn't count as an underrun or skew the schedule.
+// Safe to call from any goroutine.
+func (p *PacedBuffer) Pause() {
+	p.pauseMu.Lock()
+	if p.pausedAt.IsZero() {
+		p.pausedAt = p.cfg.Clock.Now()
+	}
+	p.pauseMu.Unlock()
+	p.nudge()
+}
+
+// Resume continues releasing audio after Pause. Safe to call from any goroutine.
+func (p *PacedBuffer) Resume() {
+	p.pauseMu.Lock()
+	if !p.pausedAt.IsZero() {
+		p.pauseTotal += p.cfg.Clock.Now().Sub(p.pausedAt)
+		p.pausedAt = time.Time{}
+	}
+	p.pauseMu.Unlock()
+	p.nudge()
+}
+
+func (p *PacedBuffer) nudge() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pauseState reports whether the buffer is paused and shifts the release
+// clocks past any pause that has ended since the last call.
+func (p *PacedBuffer) pauseState() (paused bool) {
+	p.pauseMu.Lock()
+	paused = !p.pausedAt.IsZero()
+	shift := p.pauseTotal
+	p.pauseTotal = 0
+	p.pauseMu.Unlock()
+
+	if shift > 0 {
+		if !p.releaseStart.IsZero() {
+			p.releaseStart = p.releaseStart.Add(shift)
+		}
+		if !p.nextRelease.IsZero() {
+			p.nextRelease = p.nextRelease.Add(shift)
+		}
+	}
+	return paused
+}
+
+// Rate returns the current pacing rate multiplier (1.0 = real time).
+func (p *PacedBuffer) Rate() float64 {
+	return math.Float64frombits(p.rate.Load())
 }
 
 func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []byte {
@@ -29,41 +245,67 @@ func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []b
 	go func() {
 		defer close(output)
 
-		var queue [][]byte
-		var buffered time.Duration
-		var timer *time.Timer
+		queue := newChunkQueue(p.cfg.Capacity)
+		var timer Timer
 		inputOpen := true
 		ready := false
 		started := false
 
 		for {
+			if p.pauseState() {
+				if timer != nil {
+					timer.Stop()
+					timer = nil
+				}
+				// Keep buffering while paused, up to MaxBuffer
+				in := input
+				if !inputOpen || queue.Full() || (p.cfg.MaxBuffer > 0 && queue.Buffered() >= p.cfg.MaxBuffer) {
+					in = nil
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-p.wake:
+				case chunk, ok := <-in:
+					if !ok {
+						inputOpen = false
+						continue
+					}
+					p.push(queue, chunk)
+				}
+				continue
+			}
+
 			if !ready {
-				if !inputOpen && len(queue) == 0 {
+				if !inputOpen && queue.Len() == 0 {
 					return
 				}
 
+				if p.atCapacity(queue) {
+					ready = true // Can't prebuffer more than the buffer holds
+					continue
+				}
+
 				select {
 				case <-ctx.Done():
 					return
 				case chunk, ok := <-input:
 					if !ok {
 						inputOpen = false
-						if len(queue) > 0 {
+						if queue.Len() > 0 {
 							ready = true
 						}
 						continue
 					}
-					queue = append(queue, chunk)
-					buffered += p.durationFor(chunk)
-					p.trimQueue(&queue, &buffered)
-					if buffered >= p.cfg.Prebuffer {
+					p.push(queue, chunk)
+					if queue.Buffered() >= p.cfg.Prebuffer {
 						ready = true
 					}
 				}
 				continue
 			}
 
-			if len(queue) == 0 {
+			if queue.Len() == 0 {
 				if !inputOpen {
 					return
 				}
@@ -75,19 +317,24 @@ func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []b
 						inputOpen = false
 						continue
 					}
-					queue = append(queue, chunk)
-					buffered += p.durationFor(chunk)
+					p.push(queue, chunk)
 				}
 				continue
 			}
 
-			if p.cfg.Passthrough {
-				chunk := queue[0]
-				queue = queue[1:]
-				buffered -= p.durationFor(chunk)
-				if buffered < 0 {
-					buffered = 0
+			// With BlockUpstream, stop reading input while full
+			in := input
+			if p.atCapacity(queue) {
+				in = nil
+			}
+
+			if p.cfg.Passthrough && !p.consumerAhead() {
+				if timer != nil {
+					timer.Stop()
+					timer = nil
 				}
+				chunk := p.release(queue)
+				started = true
 				select {
 				case <-ctx.Done():
 					return
@@ -98,13 +345,15 @@ func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []b
 
 			if timer == nil {
 				delay := time.Duration(0)
-				if started {
-					delay = p.durationFor(queue[0])
+				if started && p.smooth() {
+					delay = max(p.nextRelease.Sub(p.cfg.Clock.Now()), 0)
+				} else if started {
+					delay = time.Duration(float64(queue.Peek().dur) / p.Rate())
 					if delay < time.Millisecond {
 						delay = time.Millisecond
 					}
 				}
-				timer = time.NewTimer(delay)
+				timer = p.cfg.Clock.NewTimer(delay)
 			}
 
 			select {
@@ -113,21 +362,19 @@ func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []b
 					timer.Stop()
 				}
 				return
-			case chunk, ok := <-input:
+			case <-p.wake: // Re-check pause state
+			case chunk, ok := <-in:
 				if !ok {
 					inputOpen = false
 					continue
 				}
-				queue = append(queue, chunk)
-				buffered += p.durationFor(chunk)
-				p.trimQueue(&queue, &buffered)
-			case <-timer.C:
+				p.push(queue, chunk)
+			case <-timer.C():
 				timer = nil
-				chunk := queue[0]
-				queue = queue[1:]
-				buffered -= p.durationFor(chunk)
-				if buffered < 0 {
-					buffered = 0
+				dur := queue.Peek().dur
+				chunk := p.release(queue)
+				if p.smooth() {
+					p.advanceSchedule(dur)
 				}
 				started = true
 				select {
@@ -142,20 +389,157 @@ func (p *PacedBuffer) Start(ctx context.Context, input <-chan []byte) <-chan []b
 	return output
 }
 
-func (p *PacedBuffer) trimQueue(queue *[][]byte, buffered *time.Duration) {
-	if p.cfg.MaxBuffer <= 0 {
+// smooth reports whether chunks are released on the smooth schedule.
+func (p *PacedBuffer) smooth() bool {
+	return p.cfg.Smooth && !p.cfg.Passthrough
+}
+
+// consumerAhead reports whether Ack feedback says the consumer holds more
+// than TargetLead, so a Passthrough buffer should slow down.
+func (p *PacedBuffer) consumerAhead() bool {
+	return p.cfg.TargetLead > 0 && p.Rate() < 1
+}
+
+// push queues a chunk, or each of its Ogg pages in smooth mode.
+func (p *PacedBuffer) push(queue *chunkQueue, chunk []byte) {
+	if !p.cfg.Smooth || !p.cfg.Ogg || p.cfg.Passthrough {
+		p.pushEntry(queue, chunk, p.chunkDuration(chunk))
 		return
 	}
+	if !p.split {
+		// Only split streams that actually start with an Ogg page
+		if p.pages == nil {
+			p.pages = &ogg.Scanner{}
+			p.split = bytes.HasPrefix(chunk, []byte("OggS"))
+		}
+		if !p.split {
+			p.pushEntry(queue, chunk, p.chunkDuration(chunk))
+			return
+		}
+	}
+	for _, page := range p.pages.Write(chunk) {
+		p.pushEntry(queue, page.Data, p.ogg.Write(page.Data))
+	}
+	PutChunk(chunk) // Pages are copies
+}
 
-	for *buffered > p.cfg.MaxBuffer && len(*queue) > 0 {
-		dropped := (*queue)[0]
-		*queue = (*queue)[1:]
-		*buffered -= p.durationFor(dropped)
-		if *buffered < 0 {
-			*buffered = 0
-			break
+// pushEntry queues a chunk, applying the overflow policy if the ring is
+// full or the buffered duration exceeds MaxBuffer.
+func (p *PacedBuffer) pushEntry(queue *chunkQueue, chunk []byte, dur time.Duration) {
+	if p.cfg.Overflow == DropNewest && queue.Len() > 0 &&
+		(queue.Full() || (p.cfg.MaxBuffer > 0 && queue.Buffered()+dur > p.cfg.MaxBuffer)) {
+		p.drop(entry{data: chunk, dur: dur})
+		return
+	}
+
+	// DropOldest, or BlockUpstream when the producer got ahead anyway
+	if queue.Full() {
+		p.drop(queue.Pop())
+	}
+	queue.Push(chunk, dur)
+	p.chunksBuffered.Add(1)
+	p.trimQueue(queue)
+	p.updateLevel(queue)
+}
+
+// advanceSchedule moves the smooth-mode release schedule past a chunk of
+// dur. If releases fell too far behind (e.g. input ran dry), the schedule
+// restarts from now rather than bursting to catch up.
+func (p *PacedBuffer) advanceSchedule(dur time.Duration) {
+	now := p.cfg.Clock.Now()
+	if p.nextRelease.IsZero() || now.Sub(p.nextRelease) > smoothResync {
+		p.nextRelease = now
+	}
+	p.nextRelease = p.nextRelease.Add(time.Duration(float64(dur) / p.Rate()))
+}
+
+// atCapacity reports whether a BlockUpstream buffer should stop reading input.
+func (p *PacedBuffer) atCapacity(queue *chunkQueue) bool {
+	if p.cfg.Overflow != BlockUpstream {
+		return false
+	}
+	return queue.Full() || (p.cfg.MaxBuffer > 0 && queue.Buffered() >= p.cfg.MaxBuffer)
+}
+
+// release pops the next chunk for output and checks whether output has
+// fallen behind real time (an underrun on the consumer side).
+func (p *PacedBuffer) release(queue *chunkQueue) []byte {
+	e := queue.Pop()
+	p.updateLevel(queue)
+
+	now := p.cfg.Clock.Now()
+	if p.releaseStart.IsZero() {
+		p.releaseStart = now
+	}
+	if behind := now.Sub(p.releaseStart) - p.released; behind > underrunTolerance {
+		p.underruns.Add(1)
+		if p.cfg.OnUnderrun != nil {
+			p.cfg.OnUnderrun(behind)
 		}
+		p.released += behind // Count each gap once
+	}
+	p.released += e.dur
+	return e.data
+}
+
+func (p *PacedBuffer) trimQueue(queue *chunkQueue) {
+	if p.cfg.MaxBuffer <= 0 || p.cfg.Overflow == BlockUpstream {
+		return
+	}
+
+	for queue.Buffered() > p.cfg.MaxBuffer && queue.Len() > 0 {
+		p.drop(queue.Pop())
+	}
+}
+
+// drop discards a queued chunk and records it.
+func (p *PacedBuffer) drop(e entry) {
+	p.chunksDropped.Add(1)
+	if p.cfg.OnDrop != nil {
+		p.cfg.OnDrop(e.dur)
+	}
+	PutChunk(e.data)
+}
+
+// updateLevel publishes the queue fill level for Stats and fires the
+// watermark callbacks on crossings.
+func (p *PacedBuffer) updateLevel(queue *chunkQueue) {
+	buffered := queue.Buffered()
+	p.queued.Store(int64(queue.Len()))
+	p.bufferedDur.Store(int64(buffered))
+
+	if p.cfg.HighWater <= 0 {
+		return
+	}
+	switch {
+	case !p.aboveHigh && buffered >= p.cfg.HighWater:
+		p.aboveHigh = true
+		if p.cfg.OnHighWater != nil {
+			p.cfg.OnHighWater(buffered)
+		}
+	case p.aboveHigh && buffered <= p.cfg.LowWater:
+		p.aboveHigh = false
+		if p.cfg.OnLowWater != nil {
+			p.cfg.OnLowWater(buffered)
+		}
+	}
+}
+
+// chunkDuration returns how much audio a chunk carries. For Ogg streams this
+// is the exact granule delta of the pages the chunk completes (VBR-safe);
+// otherwise it is estimated from the configured bitrate.
+func (p *PacedBuffer) chunkDuration(chunk []byte) time.Duration {
+	if !p.cfg.Ogg {
+		return p.durationFor(chunk)
+	}
+	if p.ogg.HasPosition() {
+		return p.ogg.Write(chunk)
+	}
+	if p.oggBytes < oggProbeLimit {
+		p.oggBytes += len(chunk)
+		return p.ogg.Write(chunk) // Header pages carry no audio
 	}
+	return p.durationFor(chunk) // Not Ogg after all
 }
 
 func (p *PacedBuffer) durationFor(chunk []byte) time.Duration {
@@ -0,0 +1,398 @@
+package buffer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock. Every timer created or stopped is
+// reported on the created/stopped channels so tests can wait for the buffer
+// to schedule.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	created chan time.Duration
+	stopped chan struct{}
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	c       chan time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0), created: make(chan time.Duration, 64), stopped: make(chan struct{}, 64)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.fireLocked()
+	c.mu.Unlock()
+	c.created <- d
+	return t
+}
+
+// Advance moves the clock forward and fires due timers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fireLocked()
+}
+
+func (c *fakeClock) fireLocked() {
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case !t.at.After(c.now):
+			t.c <- c.now
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	wasActive := !t.stopped
+	t.stopped = true
+	t.clock.mu.Unlock()
+	t.clock.stopped <- struct{}{}
+	return wasActive
+}
+
+// waitTimer returns the delay of the next timer the buffer creates.
+func waitTimer(t *testing.T, clock *fakeClock) time.Duration {
+	t.Helper()
+	select {
+	case d := <-clock.created:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for timer")
+		return 0
+	}
+}
+
+func receive(t *testing.T, output <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case chunk, ok := <-output:
+		if !ok {
+			t.Fatal("output closed")
+		}
+		return chunk
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for output")
+		return nil
+	}
+}
+
+// drain collects the first byte of every chunk until output closes.
+func drain(t *testing.T, output <-chan []byte) []byte {
+	t.Helper()
+	var ids []byte
+	for {
+		select {
+		case chunk, ok := <-output:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, chunk[0])
+		case <-time.After(time.Second):
+			t.Fatal("timed out draining output")
+		}
+	}
+}
+
+func chunk(id byte) []byte {
+	return []byte{id, 0, 0, 0}
+}
+
+func TestPacedBuffer_PrebufferThenPaces(t *testing.T) {
+	clock := newFakeClock()
+	p := NewPacedBuffer(Config{
+		Interval:  20 * time.Millisecond,
+		Prebuffer: 60 * time.Millisecond,
+		Clock:     clock,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan []byte)
+	output := p.Start(ctx, input)
+
+	input <- chunk(1)
+	input <- chunk(2)
+	select {
+	case <-output:
+		t.Fatal("released before prebuffer was satisfied")
+	case <-clock.created:
+		t.Fatal("scheduled before prebuffer was satisfied")
+	default:
+	}
+
+	input <- chunk(3)
+	if d := waitTimer(t, clock); d != 0 {
+		t.Errorf("expected first chunk immediately, got delay %v", d)
+	}
+	if got := receive(t, output); got[0] != 1 {
+		t.Fatalf("expected chunk 1, got %d", got[0])
+	}
+
+	if d := waitTimer(t, clock); d != 20*time.Millisecond {
+		t.Errorf("expected 20ms delay, got %v", d)
+	}
+	select {
+	case <-output:
+		t.Fatal("released before the chunk delay elapsed")
+	default:
+	}
+	clock.Advance(20 * time.Millisecond)
+	if got := receive(t, output); got[0] != 2 {
+		t.Fatalf("expected chunk 2, got %d", got[0])
+	}
+}
+
+func TestPacedBuffer_PassthroughHonorsAck(t *testing.T) {
+	clock := newFakeClock()
+	p := NewPacedBuffer(Config{
+		Interval:    20 * time.Millisecond,
+		Passthrough: true,
+		TargetLead:  time.Second,
+		Clock:       clock,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan []byte)
+	output := p.Start(ctx, input)
+
+	input <- chunk(1)
+	if got := receive(t, output); got[0] != 1 {
+		t.Fatalf("expected chunk 1 passed straight through, got %d", got[0])
+	}
+
+	// The consumer is 1s over target: half speed
+	p.Ack(2 * time.Second)
+	input <- chunk(2)
+	if d := waitTimer(t, clock); d != 40*time.Millisecond {
+		t.Errorf("expected a 40ms delay at half speed, got %v", d)
+	}
+	clock.Advance(40 * time.Millisecond)
+	if got := receive(t, output); got[0] != 2 {
+		t.Fatalf("expected chunk 2, got %d", got[0])
+	}
+
+	// Back under target: straight through again
+	p.Ack(0)
+	input <- chunk(3)
+	if got := receive(t, output); got[0] != 3 {
+		t.Fatalf("expected chunk 3, got %d", got[0])
+	}
+}
+
+func TestPacedBuffer_DetectsUnderrun(t *testing.T) {
+	clock := newFakeClock()
+	var behind []time.Duration
+	p := NewPacedBuffer(Config{
+		Interval:   20 * time.Millisecond,
+		Prebuffer:  40 * time.Millisecond,
+		Clock:      clock,
+		OnUnderrun: func(d time.Duration) { behind = append(behind, d) },
+	})
+	input := make(chan []byte)
+	output := p.Start(context.Background(), input)
+
+	input <- chunk(1)
+	input <- chunk(2)
+	waitTimer(t, clock)
+	receive(t, output)
+
+	waitTimer(t, clock)
+	clock.Advance(100 * time.Millisecond) // Released 80ms late
+	receive(t, output)
+	close(input)
+	drain(t, output)
+
+	if p.Stats().Underruns != 1 || len(behind) != 1 || behind[0] != 80*time.Millisecond {
+		t.Errorf("expected one 80ms underrun, got %d %v", p.Stats().Underruns, behind)
+	}
+}
+
+func TestPacedBuffer_Overflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		want    []byte
+		dropped int64
+	}{
+		{"drop oldest", DropOldest, []byte{3, 4}, 2},
+		{"drop newest", DropNewest, []byte{1, 2}, 2},
+		{"block upstream", BlockUpstream, []byte{1, 2, 3, 4}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPacedBuffer(Config{
+				Interval:    20 * time.Millisecond,
+				Prebuffer:   time.Second,
+				MaxBuffer:   40 * time.Millisecond,
+				Passthrough: true,
+				Overflow:    tt.policy,
+			})
+			input := make(chan []byte)
+			output := p.Start(context.Background(), input)
+
+			go func() {
+				for id := byte(1); id <= 4; id++ {
+					input <- chunk(id)
+				}
+				close(input)
+			}()
+			if tt.policy != BlockUpstream {
+				// Let every chunk queue up before anything is released
+				for p.Stats().ChunksBuffered+p.Stats().ChunksDropped < 4 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			got := drain(t, output)
+			if string(got) != string(tt.want) {
+				t.Errorf("expected chunks %v, got %v", tt.want, got)
+			}
+			if d := p.Stats().ChunksDropped; d != tt.dropped {
+				t.Errorf("expected %d dropped, got %d", tt.dropped, d)
+			}
+		})
+	}
+}
+
+func TestPacedBuffer_Watermarks(t *testing.T) {
+	var events []string
+	p := NewPacedBuffer(Config{
+		Interval:    20 * time.Millisecond,
+		Prebuffer:   time.Second,
+		Passthrough: true,
+		HighWater:   60 * time.Millisecond,
+		LowWater:    20 * time.Millisecond,
+		OnHighWater: func(d time.Duration) { events = append(events, "high "+d.String()) },
+		OnLowWater:  func(d time.Duration) { events = append(events, "low "+d.String()) },
+	})
+	input := make(chan []byte)
+	output := p.Start(context.Background(), input)
+
+	for id := byte(1); id <= 4; id++ {
+		input <- chunk(id)
+	}
+	close(input)
+	drain(t, output)
+
+	want := []string{"high 60ms", "low 20ms"}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for s, want := range map[string]OverflowPolicy{
+		"":            DropOldest,
+		"drop-oldest": DropOldest,
+		"drop-newest": DropNewest,
+		"block":       BlockUpstream,
+	} {
+		if got, err := ParseOverflowPolicy(s); err != nil || got != want {
+			t.Errorf("ParseOverflowPolicy(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseOverflowPolicy("bogus"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestPacedBuffer_SmoothKeepsAbsoluteSchedule(t *testing.T) {
+	clock := newFakeClock()
+	p := NewPacedBuffer(Config{
+		Interval:  20 * time.Millisecond,
+		Prebuffer: 60 * time.Millisecond,
+		Smooth:    true,
+		Clock:     clock,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan []byte)
+	output := p.Start(ctx, input)
+
+	for id := byte(1); id <= 3; id++ {
+		input <- chunk(id)
+	}
+	waitTimer(t, clock)
+	receive(t, output)
+
+	if d := waitTimer(t, clock); d != 20*time.Millisecond {
+		t.Fatalf("expected 20ms delay, got %v", d)
+	}
+	clock.Advance(25 * time.Millisecond) // Woke up 5ms late
+	receive(t, output)
+
+	// The next release stays on the 20ms grid instead of drifting
+	if d := waitTimer(t, clock); d != 15*time.Millisecond {
+		t.Errorf("expected 15ms delay, got %v", d)
+	}
+}
+
+func TestPacedBuffer_PauseFreezesSchedule(t *testing.T) {
+	clock := newFakeClock()
+	p := NewPacedBuffer(Config{
+		Interval:  20 * time.Millisecond,
+		Prebuffer: 40 * time.Millisecond,
+		Clock:     clock,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := make(chan []byte)
+	output := p.Start(ctx, input)
+
+	input <- chunk(1)
+	input <- chunk(2)
+	waitTimer(t, clock)
+	receive(t, output)
+	waitTimer(t, clock)
+
+	p.Pause()
+	select {
+	case <-clock.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for pause")
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-output:
+		t.Fatal("released while paused")
+	default:
+	}
+
+	p.Resume()
+	waitTimer(t, clock)
+	clock.Advance(20 * time.Millisecond)
+	if got := receive(t, output); got[0] != 2 {
+		t.Fatalf("expected chunk 2 after resume, got %d", got[0])
+	}
+	if u := p.Stats().Underruns; u != 0 {
+		t.Errorf("expected pause not to count as underrun, got %d", u)
+	}
+}
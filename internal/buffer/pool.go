@@ -0,0 +1,28 @@
+package buffer
+
+import "sync"
+
+// pooledChunkSize is the capacity of newly allocated pooled chunks; it fits
+// one FFmpeg read (4KB for Opus, 16KB for PCM).
+const pooledChunkSize = 16384
+
+var chunkPool = sync.Pool{}
+
+// GetChunk returns a byte slice of length n, reusing a pooled chunk when one
+// with enough capacity is available.
+func GetChunk(n int) []byte {
+	if v, ok := chunkPool.Get().(*[]byte); ok && cap(*v) >= n {
+		return (*v)[:n]
+	}
+	return make([]byte, n, max(n, pooledChunkSize))
+}
+
+// PutChunk returns a chunk to the pool once its owner is done with it.
+// The caller must not use the chunk afterwards.
+func PutChunk(chunk []byte) {
+	if cap(chunk) < pooledChunkSize {
+		return // Not from GetChunk; let the GC have it
+	}
+	chunk = chunk[:0]
+	chunkPool.Put(&chunk)
+}
@@ -0,0 +1,67 @@
+package buffer
+
+import "time"
+
+// entry is a queued chunk with its duration, computed once on push.
+type entry struct {
+	data []byte
+	dur  time.Duration
+}
+
+// chunkQueue is a fixed-capacity FIFO ring of chunks that tracks the total
+// buffered duration. Push and Pop are O(1) and never reallocate.
+type chunkQueue struct {
+	entries  []entry
+	head     int
+	size     int
+	buffered time.Duration
+}
+
+func newChunkQueue(capacity int) *chunkQueue {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &chunkQueue{entries: make([]entry, capacity)}
+}
+
+func (q *chunkQueue) Len() int {
+	return q.size
+}
+
+func (q *chunkQueue) Full() bool {
+	return q.size == len(q.entries)
+}
+
+// Buffered returns the total duration of queued chunks.
+func (q *chunkQueue) Buffered() time.Duration {
+	return q.buffered
+}
+
+// Push appends a chunk. It returns false (and does nothing) if the ring is full.
+func (q *chunkQueue) Push(data []byte, dur time.Duration) bool {
+	if q.Full() {
+		return false
+	}
+	q.entries[(q.head+q.size)%len(q.entries)] = entry{data: data, dur: dur}
+	q.size++
+	q.buffered += dur
+	return true
+}
+
+// Peek returns the oldest chunk without removing it. The ring must not be empty.
+func (q *chunkQueue) Peek() entry {
+	return q.entries[q.head]
+}
+
+// Pop removes and returns the oldest chunk. The ring must not be empty.
+func (q *chunkQueue) Pop() entry {
+	e := q.entries[q.head]
+	q.entries[q.head] = entry{} // Release the chunk for GC/pooling
+	q.head = (q.head + 1) % len(q.entries)
+	q.size--
+	q.buffered -= e.dur
+	if q.size == 0 || q.buffered < 0 {
+		q.buffered = 0
+	}
+	return e
+}
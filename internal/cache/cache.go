@@ -0,0 +1,439 @@
+// Package cache keeps files on disk within a size budget, evicting the
+// least recently used first. Each kind of content (downloaded tracks and
+// thumbnails) shares the one budget; keys are prefixed with their kind,
+// e.g. "track:dQw4w9WgXcQ" or "thumb:dQw4w9WgXcQ".
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxMB is the size budget when Config.MaxMB is unset.
+const DefaultMaxMB = 1024
+
+// indexFile lists the entries in the cache directory.
+const indexFile = "index.json"
+
+// Config configures the cache.
+type Config struct {
+	Dir   string `yaml:"dir"`    // Directory of the cached files ("" disables the cache)
+	MaxMB int64  `yaml:"max_mb"` // Size budget in MiB (0 = DefaultMaxMB)
+}
+
+// entry is one cached file, as listed in the index.
+type entry struct {
+	Key      string    `json:"key"`
+	File     string    `json:"file"` // Name in the cache directory
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+
+	pins     int  // Readers holding the file; pinned entries aren't evicted
+	verified bool // Checksum matched since the cache was opened
+}
+
+// KindStats counts the entries of one kind.
+type KindStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Stats is a snapshot of the cache.
+type Stats struct {
+	Dir       string               `json:"dir"`
+	Entries   int                  `json:"entries"`
+	Bytes     int64                `json:"bytes"`
+	MaxBytes  int64                `json:"max_bytes"`
+	Pinned    int                  `json:"pinned"` // Entries in use, exempt from eviction
+	Hits      int64                `json:"hits"`
+	Misses    int64                `json:"misses"`
+	Evictions int64                `json:"evictions"`
+	Corrupt   int64                `json:"corrupt"` // Entries dropped for a missing file or checksum mismatch
+	Kinds     map[string]KindStats `json:"kinds"`
+}
+
+// Cache is a directory of files with an LRU size budget. Files handed out
+// by Get and Add stay on disk until released.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+	corrupt   int64
+}
+
+// Open opens (or creates) the cache in config.Dir. Entries whose file is
+// missing or has the wrong size are dropped, leftovers of the cache's own
+// not in the index (e.g. downloads interrupted by a crash) are deleted, and
+// the cache is trimmed to the budget. Other files in the directory are left
+// alone.
+func Open(config Config) (*Cache, error) {
+	if config.Dir == "" {
+		return nil, errors.New("cache: no directory")
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+	c := &Cache{
+		dir:      config.Dir,
+		maxBytes: max(config.MaxMB, 0) << 20,
+		entries:  make(map[string]*entry),
+	}
+	if c.maxBytes == 0 {
+		c.maxBytes = DefaultMaxMB << 20
+	}
+
+	var listed []*entry
+	data, err := os.ReadFile(filepath.Join(c.dir, indexFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read cache index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &listed); err != nil {
+			return nil, fmt.Errorf("parse cache index: %w", err)
+		}
+	}
+	for _, e := range listed {
+		info, err := os.Stat(filepath.Join(c.dir, e.File))
+		if err != nil || info.Size() != e.Size {
+			c.corrupt++
+			continue
+		}
+		c.entries[e.Key] = e
+		c.bytes += e.Size
+	}
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read cache directory: %w", err)
+	}
+	known := make(map[string]bool, len(c.entries))
+	for _, e := range c.entries {
+		known[e.File] = true
+	}
+	for _, file := range files {
+		if name := file.Name(); file.Type().IsRegular() && !known[name] && leftover(name) {
+			os.Remove(filepath.Join(c.dir, name))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictLocked()
+	return c, c.writeLocked()
+}
+
+// Dir returns the cache directory. Files created there for Add are renamed
+// into the cache instead of copied; name them "natashi-*" so Open deletes
+// them if a crash leaves them behind.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns the file cached under key, pinned until release is called.
+// The file's checksum is verified on its first use since Open; a corrupt
+// entry is dropped and reported as a miss.
+func (c *Cache) Get(key string) (path string, release func(), ok bool) {
+	c.mu.Lock()
+	e := c.entries[key]
+	if e == nil {
+		c.misses++
+		c.mu.Unlock()
+		return "", nil, false
+	}
+	e.pins++
+	verify := !e.verified
+	c.mu.Unlock()
+
+	// Hashed outside the lock; the pin keeps the entry in place
+	var sum string
+	if verify {
+		sum, _, _ = hashFile(filepath.Join(c.dir, e.File))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if verify && sum != e.SHA256 {
+		e.pins--
+		c.misses++
+		if c.entries[key] == e { // Not already dropped by a concurrent Get
+			c.corrupt++
+			c.removeLocked(e)
+			c.writeLocked()
+		}
+		return "", nil, false
+	}
+	e.verified = true
+	e.LastUsed = time.Now()
+	c.hits++
+	c.writeLocked()
+	return filepath.Join(c.dir, e.File), c.releaser(e), true
+}
+
+// Has reports whether key is cached, without verifying, pinning or
+// counting it as a hit or miss.
+func (c *Cache) Has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key] != nil
+}
+
+// Add moves the file at path into the cache under key and returns where it
+// is now, pinned until release is called. If key was added meanwhile, the
+// cached file is returned and path is deleted.
+func (c *Cache) Add(key, path string) (string, func(), error) {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("cache %s: %w", key, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.entries[key]; e != nil {
+		os.Remove(path)
+		e.pins++
+		e.LastUsed = time.Now()
+		return filepath.Join(c.dir, e.File), c.releaser(e), nil
+	}
+	name := fileName(key, filepath.Ext(path))
+	if err := moveFile(path, filepath.Join(c.dir, name)); err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("cache %s: %w", key, err)
+	}
+	now := time.Now()
+	e := &entry{Key: key, File: name, Size: size, SHA256: sum, Created: now, LastUsed: now, pins: 1, verified: true}
+	c.entries[key] = e
+	c.bytes += size
+	c.evictLocked()
+	return filepath.Join(c.dir, name), c.releaser(e), c.writeLocked()
+}
+
+// releaser returns the release func of one pin on e.
+func (c *Cache) releaser(e *entry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			e.pins--
+			if c.bytes > c.maxBytes {
+				c.evictLocked()
+				c.writeLocked()
+			}
+		})
+	}
+}
+
+// Purge deletes the unpinned entries of kind ("" = all kinds) and returns
+// how many were deleted.
+func (c *Cache) Purge(kind string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	purged := 0
+	for _, e := range c.entries {
+		if e.pins == 0 && (kind == "" || kindOf(e.Key) == kind) {
+			c.removeLocked(e)
+			purged++
+		}
+	}
+	c.writeLocked()
+	return purged
+}
+
+// Verify checks the checksum of every unpinned entry, dropping corrupt
+// ones, and returns how many were dropped.
+func (c *Cache) Verify() int {
+	c.mu.Lock()
+	entries := make([]*entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		if e.pins == 0 {
+			entries = append(entries, e)
+		}
+	}
+	c.mu.Unlock()
+
+	dropped := 0
+	for _, e := range entries {
+		sum, _, _ := hashFile(filepath.Join(c.dir, e.File))
+		c.mu.Lock()
+		if c.entries[e.Key] == e && e.pins == 0 {
+			if sum != e.SHA256 {
+				c.corrupt++
+				c.removeLocked(e)
+				dropped++
+			} else {
+				e.verified = true
+			}
+		}
+		c.mu.Unlock()
+	}
+	if dropped > 0 {
+		c.mu.Lock()
+		c.writeLocked()
+		c.mu.Unlock()
+	}
+	return dropped
+}
+
+// Stats returns a snapshot of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		Dir:       c.dir,
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Corrupt:   c.corrupt,
+		Kinds:     make(map[string]KindStats),
+	}
+	for _, e := range c.entries {
+		kind := stats.Kinds[kindOf(e.Key)]
+		kind.Entries++
+		kind.Bytes += e.Size
+		stats.Kinds[kindOf(e.Key)] = kind
+		if e.pins > 0 {
+			stats.Pinned++
+		}
+	}
+	return stats
+}
+
+// evictLocked deletes the least recently used unpinned entries until the
+// cache fits its budget. Caller must hold c.mu.
+func (c *Cache) evictLocked() {
+	if c.bytes <= c.maxBytes {
+		return
+	}
+	entries := make([]*entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.Before(entries[j].LastUsed) })
+	for _, e := range entries {
+		if c.bytes <= c.maxBytes {
+			return
+		}
+		if e.pins == 0 {
+			c.removeLocked(e)
+			c.evictions++
+		}
+	}
+}
+
+// removeLocked deletes e and its file. Caller must hold c.mu.
+func (c *Cache) removeLocked(e *entry) {
+	os.Remove(filepath.Join(c.dir, e.File))
+	delete(c.entries, e.Key)
+	c.bytes -= e.Size
+}
+
+// writeLocked writes the index atomically (temp file + rename). Caller
+// must hold c.mu.
+func (c *Cache) writeLocked() error {
+	entries := make([]*entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.dir, indexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write cache index: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// tempPrefix starts the names of files created in Dir for Add (see Dir).
+const tempPrefix = "natashi-"
+
+// leftover reports whether name is a file the cache made: an entry (see
+// fileName), a temporary file for Add or the index being written.
+func leftover(name string) bool {
+	if strings.HasPrefix(name, tempPrefix) || name == indexFile+".tmp" {
+		return true
+	}
+	base, _, _ := strings.Cut(name, ".")
+	_, err := hex.DecodeString(base)
+	return len(base) == 32 && err == nil
+}
+
+// kindOf returns the kind prefix of key ("track" for "track:abc").
+func kindOf(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
+
+// fileName returns the name key's file is stored under.
+func fileName(key, ext string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16]) + ext
+}
+
+// hashFile returns the SHA-256 and size of the file at path.
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// moveFile renames src to dst, copying when they are on different file
+// systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
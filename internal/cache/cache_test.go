@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addFile writes size bytes to a new file in c's directory and adds it
+// under key, releasing it right away.
+func addFile(t *testing.T, c *Cache, key string, size int) string {
+	t.Helper()
+	src := filepath.Join(c.Dir(), "tmp-"+strings.ReplaceAll(key, ":", "-")+".mka")
+	if err := os.WriteFile(src, []byte(strings.Repeat("a", size)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path, release, err := c.Add(key, src)
+	if err != nil {
+		t.Fatalf("Add(%s) failed: %v", key, err)
+	}
+	release()
+	return path
+}
+
+func TestCache_GetAndAdd(t *testing.T) {
+	c, err := Open(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, _, ok := c.Get("track:a"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	path := addFile(t, c, "track:a", 100)
+	if !c.Has("track:a") || c.Has("track:b") {
+		t.Error("expected Has to report only the added entry")
+	}
+	if filepath.Ext(path) != ".mka" {
+		t.Errorf("expected the extension kept, got %s", path)
+	}
+
+	got, release, ok := c.Get("track:a")
+	if !ok || got != path {
+		t.Fatalf("expected a hit at %s, got %q (%v)", path, got, ok)
+	}
+	release()
+	release() // Idempotent
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Bytes != 100 || stats.Pinned != 0 || stats.Kinds["track"].Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := Open(Config{Dir: t.TempDir(), MaxMB: 1})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	addFile(t, c, "track:a", 400<<10)
+	time.Sleep(10 * time.Millisecond)
+	addFile(t, c, "track:b", 400<<10)
+	time.Sleep(10 * time.Millisecond)
+
+	// Using a makes b the least recently used; pinning it keeps it too
+	_, release, _ := c.Get("track:a")
+	addFile(t, c, "track:c", 400<<10)
+	if _, _, ok := c.Get("track:b"); ok {
+		t.Error("expected b evicted")
+	}
+	for _, key := range []string{"track:a", "track:c"} {
+		if _, r, ok := c.Get(key); !ok {
+			t.Errorf("expected %s kept", key)
+		} else {
+			r()
+		}
+	}
+	release()
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Bytes > stats.MaxBytes {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCache_DropsCorruptEntries(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := Open(Config{Dir: dir})
+	path := addFile(t, c, "track:a", 100)
+	addFile(t, c, "track:b", 100)
+	os.WriteFile(filepath.Join(dir, "natashi-track-1.mka"), []byte("x"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644)
+	os.Mkdir(filepath.Join(dir, "music"), 0o755)
+
+	// Same size, different content: only the checksum notices
+	os.WriteFile(path, []byte(strings.Repeat("b", 100)), 0o644)
+	c, err := Open(Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "natashi-track-1.mka")); !os.IsNotExist(err) {
+		t.Error("expected an interrupted download deleted")
+	}
+	for _, name := range []string{"notes.txt", "music"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s, not made by the cache, kept: %v", name, err)
+		}
+	}
+	if _, _, ok := c.Get("track:a"); ok {
+		t.Error("expected the corrupt entry to miss")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the corrupt file deleted")
+	}
+	if _, r, ok := c.Get("track:b"); !ok {
+		t.Error("expected the intact entry to survive the reopen")
+	} else {
+		r()
+	}
+	if stats := c.Stats(); stats.Corrupt != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCache_PurgeAndVerify(t *testing.T) {
+	c, _ := Open(Config{Dir: t.TempDir()})
+	addFile(t, c, "track:a", 10)
+	addFile(t, c, "thumb:a", 10)
+	pathB := addFile(t, c, "track:b", 10)
+	_, release, _ := c.Get("track:a")
+	defer release()
+
+	os.WriteFile(pathB, []byte("0123456789"), 0o644)
+	if dropped := c.Verify(); dropped != 1 {
+		t.Errorf("expected Verify to drop 1 entry, dropped %d", dropped)
+	}
+	if purged := c.Purge("track"); purged != 0 {
+		t.Errorf("expected the pinned track kept, purged %d", purged)
+	}
+	if purged := c.Purge(""); purged != 1 {
+		t.Errorf("expected the thumbnail purged, purged %d", purged)
+	}
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Errorf("expected only the pinned entry left, got %+v", stats)
+	}
+}
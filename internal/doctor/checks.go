@@ -0,0 +1,160 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/pkg/deps"
+)
+
+// TestVideoURL is a long-lived public video used for the extraction check
+// ("Me at the zoo", the first YouTube upload).
+const TestVideoURL = "https://www.youtube.com/watch?v=jNQXAC9IVRw"
+
+// Dependencies checks that the required binaries are installed and run,
+// listing optional ones that are missing.
+func Dependencies(checker *deps.Checker) Check {
+	return Check{
+		Name: "dependencies",
+		Hint: "install yt-dlp and ffmpeg, or point YT_DLP_PATH / FFMPEG_PATH at them",
+		Run: func(ctx context.Context) (string, error) {
+			var found, missing []string
+			for _, result := range checker.Check(ctx) {
+				switch {
+				case result.OK():
+					found = append(found, fmt.Sprintf("%s %s", result.Name, result.Version))
+				case result.Optional:
+					found = append(found, fmt.Sprintf("%s missing (%s degraded)", result.Name, result.Feature))
+				default:
+					missing = append(missing, result.Err.Error())
+				}
+			}
+			if len(missing) > 0 {
+				return "", fmt.Errorf("%s", strings.Join(missing, "; "))
+			}
+			return strings.Join(found, ", "), nil
+		},
+	}
+}
+
+// Extraction checks that yt-dlp can resolve a stream URL for videoURL.
+func Extraction(extractor *youtube.Extractor, videoURL string) Check {
+	return Check{
+		Name:    "extraction",
+		Hint:    "update yt-dlp (yt-dlp -U), install node or deno for JS challenges, and refresh cookies if YouTube asks to sign in",
+		Timeout: 90 * time.Second,
+		Run: func(ctx context.Context) (string, error) {
+			streamURL, err := extractor.ExtractStreamURLContext(ctx, videoURL)
+			if err != nil {
+				return "", err
+			}
+			host := streamURL
+			if parsed, err := url.Parse(streamURL); err == nil && parsed.Host != "" {
+				host = parsed.Host
+			}
+			return fmt.Sprintf("resolved %s via %s", videoURL, host), nil
+		},
+	}
+}
+
+// Encode checks that FFmpeg can encode a generated tone to Ogg Opus, as
+// the daemon does for Discord and browsers.
+func Encode() Check {
+	return Check{
+		Name: "encode",
+		Hint: "install an FFmpeg build with libopus and the lavfi device (most distribution packages have both)",
+		Run: func(ctx context.Context) (string, error) {
+			cmd := exec.CommandContext(ctx, encoder.FFmpegPath(),
+				"-hide_banner", "-loglevel", "error",
+				"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+				"-c:a", "libopus", "-b:a", "128k", "-f", "ogg", "pipe:1")
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				if msg := strings.TrimSpace(stderr.String()); msg != "" {
+					return "", fmt.Errorf("%w: %s", err, msg)
+				}
+				return "", err
+			}
+			if !bytes.HasPrefix(out, []byte("OggS")) {
+				return "", fmt.Errorf("output is not Ogg (%d bytes)", len(out))
+			}
+			return fmt.Sprintf("1s tone encoded to %d bytes of Ogg Opus", len(out)), nil
+		},
+	}
+}
+
+// Socket checks that a Unix socket can be created and connected to in the
+// directory of socketPath, without touching a running daemon's socket.
+func Socket(socketPath string) Check {
+	return Check{
+		Name: "socket",
+		Hint: "make the socket directory writable or choose another path with daemon -socket",
+		Run: func(ctx context.Context) (string, error) {
+			dir := filepath.Dir(socketPath)
+			testPath := filepath.Join(dir, fmt.Sprintf("music-bot-doctor-%d.sock", os.Getpid()))
+			listener, err := net.Listen("unix", testPath)
+			if err != nil {
+				return "", err
+			}
+			defer os.Remove(testPath)
+			defer listener.Close()
+
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "unix", testPath)
+			if err != nil {
+				return "", err
+			}
+			conn.Close()
+
+			detail := fmt.Sprintf("can create sockets in %s", dir)
+			if conn, err := net.Dial("unix", socketPath); err == nil {
+				conn.Close()
+				detail += fmt.Sprintf("; a daemon is listening on %s", socketPath)
+			}
+			return detail, nil
+		},
+	}
+}
+
+// Cookies checks the configured cookies file: that it parses and holds
+// unexpired YouTube cookies.
+func Cookies() Check {
+	return Check{
+		Name: "cookies",
+		Hint: "export fresh cookies.txt from a logged-in browser (Netscape format), or set cookies.browser in the config file",
+		Run: func(ctx context.Context) (string, error) {
+			report, err := youtube.CheckCookies(time.Now())
+			if err != nil {
+				return "", err
+			}
+			if report == nil {
+				mode := youtube.CookieMode()
+				if mode == youtube.CookieModeBrowser {
+					return "read from the browser by yt-dlp (covered by the extraction check)", errSkipped
+				}
+				return "no cookies configured", errSkipped
+			}
+			valid := report.Total - report.Expired
+			if valid == 0 {
+				return "", fmt.Errorf("%s has no unexpired YouTube cookies (%d expired)", report.Path, report.Expired)
+			}
+			detail := fmt.Sprintf("%s: %d YouTube cookies, %d expired", report.Path, report.Total, report.Expired)
+			if !report.LoggedIn {
+				detail += ", not logged in"
+			}
+			return detail, nil
+		},
+	}
+}
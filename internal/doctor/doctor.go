@@ -0,0 +1,94 @@
+// Package doctor runs the functional environment checks behind
+// `music-bot doctor` and prints a pass/fail report with remediation hints.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// defaultTimeout bounds a check that doesn't set its own.
+const defaultTimeout = 30 * time.Second
+
+// errSkipped marks a check that doesn't apply to this setup.
+var errSkipped = errors.New("skipped")
+
+// Check is one functional check.
+type Check struct {
+	Name    string
+	Run     func(ctx context.Context) (detail string, err error)
+	Hint    string        // Remediation printed when the check fails
+	Timeout time.Duration // Default 30s
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Name    string
+	Detail  string // What was found, for passing and skipped checks
+	Err     error
+	Hint    string
+	Elapsed time.Duration
+}
+
+// Passed reports whether the check passed.
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Skipped reports whether the check didn't apply.
+func (r Result) Skipped() bool {
+	return errors.Is(r.Err, errSkipped)
+}
+
+// Run runs checks in order, each bounded by its timeout.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		timeout := check.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		detail, err := check.Run(checkCtx)
+		if err != nil && checkCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
+		cancel()
+
+		results = append(results, Result{
+			Name:    check.Name,
+			Detail:  detail,
+			Err:     err,
+			Hint:    check.Hint,
+			Elapsed: time.Since(start),
+		})
+	}
+	return results
+}
+
+// Print writes the report for results and returns true if no check failed.
+func Print(w io.Writer, results []Result) bool {
+	passed, failed, skipped := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Skipped():
+			skipped++
+			fmt.Fprintf(w, "[SKIP] %s: %s\n", r.Name, r.Detail)
+		case r.Passed():
+			passed++
+			fmt.Fprintf(w, "[PASS] %s: %s (%s)\n", r.Name, r.Detail, r.Elapsed.Round(time.Millisecond))
+		default:
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", r.Name, r.Err)
+			if r.Hint != "" {
+				fmt.Fprintf(w, "       Hint: %s\n", r.Hint)
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	return failed == 0
+}
@@ -0,0 +1,58 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAndPrint(t *testing.T) {
+	checks := []Check{
+		{Name: "ok", Run: func(context.Context) (string, error) { return "fine", nil }},
+		{Name: "broken", Hint: "reinstall it", Run: func(context.Context) (string, error) { return "", errors.New("exit status 1") }},
+		{Name: "n/a", Run: func(context.Context) (string, error) { return "not configured", errSkipped }},
+		{
+			Name:    "slow",
+			Timeout: 50 * time.Millisecond,
+			Run: func(ctx context.Context) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+		},
+	}
+
+	results := Run(context.Background(), checks)
+	var out bytes.Buffer
+	if Print(&out, results) {
+		t.Error("expected the report to fail")
+	}
+
+	report := out.String()
+	for _, want := range []string{
+		"[PASS] ok: fine",
+		"[FAIL] broken: exit status 1\n       Hint: reinstall it",
+		"[SKIP] n/a: not configured",
+		"[FAIL] slow: timed out after 50ms",
+		"1 passed, 2 failed, 1 skipped",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestSocket(t *testing.T) {
+	results := Run(context.Background(), []Check{Socket(filepath.Join(t.TempDir(), "audio.sock"))})
+	if !results[0].Passed() {
+		t.Fatalf("socket check failed: %v", results[0].Err)
+	}
+
+	results = Run(context.Background(), []Check{Socket("/nonexistent/dir/audio.sock")})
+	if results[0].Passed() {
+		t.Error("expected the socket check to fail for a missing directory")
+	}
+}
@@ -0,0 +1,90 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// ClipFormat is the file format of an exported clip.
+type ClipFormat string
+
+const (
+	ClipMP3  ClipFormat = "mp3"  // MP3 (libmp3lame), the most widely playable
+	ClipOpus ClipFormat = "opus" // Opus in Ogg
+	ClipM4A  ClipFormat = "m4a"  // AAC in MP4
+	ClipWAV  ClipFormat = "wav"  // Uncompressed PCM
+)
+
+// ParseClipFormat returns the clip format named s (default mp3).
+func ParseClipFormat(s string) (ClipFormat, error) {
+	switch f := ClipFormat(strings.ToLower(s)); f {
+	case "":
+		return ClipMP3, nil
+	case ClipMP3, ClipOpus, ClipM4A, ClipWAV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown clip format %q (want mp3, opus, m4a or wav)", s)
+}
+
+// ContentType returns the MIME type of files in format f.
+func (f ClipFormat) ContentType() string {
+	switch f {
+	case ClipOpus:
+		return "audio/ogg"
+	case ClipM4A:
+		return "audio/mp4"
+	case ClipWAV:
+		return "audio/wav"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// ExportClip encodes the audio between start and end (seconds into the
+// input) of streamURL to the file dst. It blocks until FFmpeg exits.
+func ExportClip(ctx context.Context, runner execx.CommandRunner, streamURL string, start, end float64, format ClipFormat, dst string) error {
+	args := clipArgs(streamURL, start, end, format, dst)
+	logger.Debugf("[FFmpeg] Clip args: %s", strings.Join(args, " "))
+
+	var stderr bytes.Buffer
+	cmd := runner.CommandContext(ctx, FFmpegPath(), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			lines := strings.Split(msg, "\n")
+			return errs.New(errs.ErrPipeline, "export clip: %w: %s", err, lines[len(lines)-1])
+		}
+		return errs.New(errs.ErrPipeline, "export clip: %w", err)
+	}
+	return nil
+}
+
+// clipArgs builds the FFmpeg arguments for ExportClip.
+func clipArgs(streamURL string, start, end float64, format ClipFormat, dst string) []string {
+	args := []string{
+		"-reconnect", "1",
+		"-reconnect_streamed", "1",
+		"-reconnect_delay_max", "5",
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-to", fmt.Sprintf("%.3f", end),
+		"-i", streamURL,
+		"-vn",
+		"-loglevel", "error",
+	}
+	switch format {
+	case ClipOpus:
+		args = append(args, "-c:a", "libopus", "-b:a", "160k", "-f", "ogg")
+	case ClipM4A:
+		args = append(args, "-c:a", "aac", "-b:a", "192k", "-f", "mp4")
+	case ClipWAV:
+		args = append(args, "-c:a", "pcm_s16le", "-f", "wav")
+	default:
+		args = append(args, "-c:a", "libmp3lame", "-q:a", "2", "-f", "mp3")
+	}
+	return append(args, "-y", dst)
+}
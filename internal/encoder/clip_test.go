@@ -0,0 +1,55 @@
+package encoder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestParseClipFormat(t *testing.T) {
+	if f, err := ParseClipFormat(""); err != nil || f != ClipMP3 {
+		t.Errorf("expected mp3 by default, got %q (%v)", f, err)
+	}
+	if f, err := ParseClipFormat("OPUS"); err != nil || f != ClipOpus {
+		t.Errorf("expected opus, got %q (%v)", f, err)
+	}
+	if _, err := ParseClipFormat("flac"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
+
+func TestClipArgs(t *testing.T) {
+	args := strings.Join(clipArgs("https://stream.invalid", 12, 42.5, ClipOpus, "/tmp/out.opus"), " ")
+
+	for _, want := range []string{"-ss 12.000 -to 42.500 -i https://stream.invalid", "-c:a libopus", "-y /tmp/out.opus"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in %s", want, args)
+		}
+	}
+}
+
+func TestExportClip(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "clip.mp3")
+	runner := execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", `printf clip > "$0"`, args[len(args)-1])
+	})
+	if err := ExportClip(context.Background(), runner, "https://stream.invalid", 0, 5, ClipMP3, dst); err != nil {
+		t.Fatalf("ExportClip failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "clip" {
+		t.Errorf("expected the clip to be written, got %q", data)
+	}
+
+	err := ExportClip(context.Background(), fakeFFmpeg("echo 'Server returned 404 Not Found' >&2; exit 1"),
+		"https://stream.invalid", 0, 5, ClipMP3, dst)
+	if !errors.Is(err, errs.ErrPipeline) || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("expected a pipeline error with FFmpeg's message, got %v", err)
+	}
+}
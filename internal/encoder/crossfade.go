@@ -0,0 +1,37 @@
+package encoder
+
+import (
+	"fmt"
+	"time"
+)
+
+// CrossfadeCurves are the fade curves a crossfade can use (FFmpeg's
+// acrossfade names): tri is linear, qsin keeps the power roughly constant.
+var CrossfadeCurves = []string{"tri", "qsin", "hsin", "esin", "log", "exp", "par", "qua", "cub"}
+
+// DefaultCrossfadeCurve is the curve used when none is chosen.
+const DefaultCrossfadeCurve = "qsin"
+
+// Crossfade describes the tail of the previous track to fade out while a
+// pipeline's own output fades in.
+type Crossfade struct {
+	URL      string        // Stream URL of the previous track
+	From     float64       // Position in the previous track the tail starts at, in seconds
+	Duration time.Duration // Overlap length (0 = no crossfade)
+	Curve    string        // One of CrossfadeCurves ("" = DefaultCrossfadeCurve)
+}
+
+// filter returns the acrossfade filter mixing the tail (input 1) into the
+// track (input 0).
+func (c Crossfade) filter() string {
+	curve := c.Curve
+	if curve == "" {
+		curve = DefaultCrossfadeCurve
+	}
+	return fmt.Sprintf("[1:a][0:a]acrossfade=d=%.3f:c1=%s:c2=%s", c.Duration.Seconds(), curve, curve)
+}
+
+// SetCrossfade sets the tail to crossfade from for the next Start.
+func (p *FFmpegPipeline) SetCrossfade(c Crossfade) {
+	p.crossfade = c
+}
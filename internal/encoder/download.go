@@ -0,0 +1,44 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// Download copies the audio of streamURL to the file dst without
+// re-encoding, fetching it through proxy ("" = direct), so it can be played
+// from disk. Matroska holds any codec YouTube serves. It blocks until the
+// whole input is written.
+func Download(ctx context.Context, runner execx.CommandRunner, streamURL, proxy, dst string) error {
+	args := downloadArgs(streamURL, proxy, dst)
+	logger.Debugf("[FFmpeg] Download args: %s", strings.Join(args, " "))
+
+	var stderr bytes.Buffer
+	cmd := runner.CommandContext(ctx, FFmpegPath(), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			lines := strings.Split(msg, "\n")
+			return errs.New(errs.ErrPipeline, "download: %w: %s", err, lines[len(lines)-1])
+		}
+		return errs.New(errs.ErrPipeline, "download: %w", err)
+	}
+	return nil
+}
+
+// downloadArgs builds the FFmpeg arguments for Download.
+func downloadArgs(streamURL, proxy, dst string) []string {
+	args := streamInputArgs(streamURL, proxy)
+	return append(args,
+		"-i", streamURL,
+		"-vn",
+		"-c:a", "copy",
+		"-loglevel", "error",
+		"-f", "matroska",
+		"-y", dst,
+	)
+}
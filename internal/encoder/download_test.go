@@ -0,0 +1,41 @@
+package encoder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestDownloadArgs(t *testing.T) {
+	args := strings.Join(downloadArgs("https://stream.invalid", "http://proxy.invalid:3128", "/tmp/track.mka"), " ")
+	for _, want := range []string{"-reconnect 1", "-http_proxy http://proxy.invalid:3128 -i https://stream.invalid", "-c:a copy", "-f matroska -y /tmp/track.mka"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in %s", want, args)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "track.mka")
+	runner := execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", `printf audio > "$0"`, args[len(args)-1])
+	})
+	if err := Download(context.Background(), runner, "https://stream.invalid", "", dst); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "audio" {
+		t.Errorf("expected the track to be written, got %q", data)
+	}
+
+	err := Download(context.Background(), fakeFFmpeg("echo 'Connection reset by peer' >&2; exit 1"), "https://stream.invalid", "", dst)
+	if !errors.Is(err, errs.ErrPipeline) || !strings.Contains(err.Error(), "Connection reset by peer") {
+		t.Errorf("expected a pipeline error with FFmpeg's message, got %v", err)
+	}
+}
@@ -2,7 +2,10 @@
 // It handles stream decoding via FFmpeg and encoding to Opus format.
 package encoder
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Format specifies the output format for encoded audio.
 type Format string
@@ -24,6 +27,22 @@ type Config struct {
 	Volume     float64 // Volume multiplier 0.0-2.0 (default: 1.0)
 }
 
+// ffmpegPath is the FFmpeg executable used by pipelines and players.
+var ffmpegPath = "ffmpeg"
+
+// SetFFmpegPath sets the FFmpeg executable, e.g. as resolved by
+// deps.Checker. Call it before starting any pipeline.
+func SetFFmpegPath(path string) {
+	if path != "" {
+		ffmpegPath = path
+	}
+}
+
+// FFmpegPath returns the FFmpeg executable in use.
+func FFmpegPath() string {
+	return ffmpegPath
+}
+
 // DefaultConfig returns the default encoding configuration
 // optimized for Discord audio quality.
 func DefaultConfig() Config {
@@ -49,12 +68,69 @@ type Pipeline interface {
 	// The channel is closed when the stream ends or Stop is called.
 	Output() <-chan []byte
 
-	// Pause pauses the pipeline (stops FFmpeg with SIGSTOP).
+	// Pause stops output at the next frame boundary without dropping
+	// anything already encoded.
 	Pause()
 
-	// Resume resumes the pipeline (continues FFmpeg with SIGCONT).
+	// Resume continues output from where Pause stopped.
 	Resume()
 
 	// Stop stops the encoding pipeline and releases resources.
 	Stop()
 }
+
+// FilterSetter is implemented by pipelines that can apply a volume and an
+// FFmpeg filter chain (used for per-guild settings).
+type FilterSetter interface {
+	// SetFilter sets the volume multiplier (0.0-2.0) and an extra -af
+	// filter chain, e.g. from FilterChain. Must be called before Start.
+	SetFilter(volume float64, filter string)
+}
+
+// ExpiryNotifier is implemented by pipelines that can tell when the stream
+// URL has expired mid-track (HTTP 403/410 from the CDN), so the caller can
+// switch to a fresh URL before the audio stops.
+type ExpiryNotifier interface {
+	// OnExpired sets fn to be called at most once per Start when the input
+	// reports an expired URL. fn must not block. Must be called before Start.
+	OnExpired(fn func())
+}
+
+// FadeSetter is implemented by pipelines that can fade their output in from
+// silence, so starts and seeks don't click.
+type FadeSetter interface {
+	// SetFadeIn fades the first d of output in (0 disables). Must be called
+	// before Start.
+	SetFadeIn(d time.Duration)
+}
+
+// CrossfadeSetter is implemented by pipelines that can mix the end of the
+// previous track into the start of their output.
+type CrossfadeSetter interface {
+	// SetCrossfade crossfades from c's tail on the next Start (a zero
+	// Crossfade disables it). Must be called before Start.
+	SetCrossfade(c Crossfade)
+}
+
+// EndSetter is implemented by pipelines that can stop at a position in the
+// input, so only a segment of a track plays.
+type EndSetter interface {
+	// SetEndAt stops output at sec seconds into the input (0 plays to the
+	// end). Must be called before Start.
+	SetEndAt(sec float64)
+}
+
+// ProxySetter is implemented by pipelines that can fetch the stream through
+// an HTTP proxy, e.g. the one the stream URL was extracted through.
+type ProxySetter interface {
+	// SetProxy sets the HTTP proxy URL ("" connects directly). Must be
+	// called before Start.
+	SetProxy(proxy string)
+}
+
+// BitrateSetter is implemented by pipelines whose Opus bitrate can be
+// chosen per start (used for adaptive bitrate).
+type BitrateSetter interface {
+	// SetBitrate overrides the Opus bitrate in bps. Must be called before Start.
+	SetBitrate(bps int)
+}
@@ -0,0 +1,99 @@
+package encoder
+
+import "strings"
+
+// ErrorClass classifies the errors FFmpeg reported on stderr.
+type ErrorClass int
+
+const (
+	ErrorNone    ErrorClass = iota // No recognised error
+	ErrorFatal                     // Bad input or arguments: retrying cannot help
+	ErrorNetwork                   // Connection dropped or truncated: retry at the position
+	ErrorExpired                   // Stream URL rejected (HTTP 403/410): retry with a fresh URL
+)
+
+// String returns the class name used in logs and events.
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorFatal:
+		return "fatal"
+	case ErrorNetwork:
+		return "network"
+	case ErrorExpired:
+		return "expired"
+	default:
+		return "none"
+	}
+}
+
+// Retryable reports whether a stream that ended with this class should be
+// resumed from where it stopped.
+func (c ErrorClass) Retryable() bool {
+	return c == ErrorNetwork || c == ErrorExpired
+}
+
+// ExitStatus describes how an FFmpeg run ended.
+type ExitStatus struct {
+	Code  int        // Process exit code (-1 if killed or not started)
+	Class ErrorClass // Most telling stderr error class seen
+	Error string     // The stderr line that set Class
+}
+
+// ExitReporter is implemented by pipelines that report how their process
+// ended, so retries can tell a dropped connection from a bad input.
+type ExitReporter interface {
+	// ExitStatus returns how the last run ended. Valid once Output is closed.
+	ExitStatus() ExitStatus
+}
+
+// Stderr fragments (lowercase) for each class. A higher class outranks a
+// lower one, so a network error beats a fatal one: a truncated download
+// often ends in decode errors as well.
+var (
+	fatalErrors = []string{
+		"invalid data found when processing input",
+		"404 not found",
+		"no such file or directory",
+		"invalid argument",
+		"does not contain any stream",
+		"error opening input",
+		"unrecognized option",
+		"unknown encoder",
+		"decoder not found",
+	}
+	networkErrors = []string{
+		"connection reset",
+		"connection refused",
+		"connection timed out",
+		"operation timed out",
+		"network is unreachable",
+		"broken pipe",
+		"stream ends prematurely",
+		"end of file",
+		"i/o error",
+		"input/output error",
+		"error in the pull function",
+		"tls",
+		"server returned 5",
+		"http error 5",
+	}
+)
+
+// classifyStderr returns the error class of one FFmpeg stderr line.
+func classifyStderr(line string) ErrorClass {
+	if isExpiredURL(line) {
+		return ErrorExpired
+	}
+	lower := strings.ToLower(line)
+	for _, fragment := range networkErrors {
+		if strings.Contains(lower, fragment) {
+			return ErrorNetwork
+		}
+	}
+	for _, fragment := range fatalErrors {
+		if strings.Contains(lower, fragment) {
+			return ErrorFatal
+		}
+	}
+	return ErrorNone
+}
@@ -0,0 +1,39 @@
+package encoder
+
+import "testing"
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		line string
+		want ErrorClass
+	}{
+		{"size=     512kB time=00:00:32.00 bitrate= 131.1kbits/s", ErrorNone},
+		{"https://stream.invalid: Invalid data found when processing input", ErrorFatal},
+		{"Server returned 404 Not Found", ErrorFatal},
+		{"Unrecognized option 'bogus'.", ErrorFatal},
+		{"[tls @ 0x55d] Error in the pull function.", ErrorNetwork},
+		{"[https @ 0x55d] Connection reset by peer", ErrorNetwork},
+		{"https://stream.invalid: Input/output error", ErrorNetwork},
+		{"Server returned 503 Service Unavailable", ErrorNetwork},
+		{"HTTP error 403 Forbidden", ErrorExpired},
+		{"Server returned 410 Gone", ErrorExpired},
+	}
+	for _, tt := range tests {
+		if got := classifyStderr(tt.line); got != tt.want {
+			t.Errorf("classifyStderr(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestErrorClass_Retryable(t *testing.T) {
+	for class, want := range map[ErrorClass]bool{
+		ErrorNone:    false,
+		ErrorFatal:   false,
+		ErrorNetwork: true,
+		ErrorExpired: true,
+	} {
+		if got := class.Retryable(); got != want {
+			t.Errorf("%s.Retryable() = %v, want %v", class, got, want)
+		}
+	}
+}
@@ -4,8 +4,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
-	"syscall"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
 )
 
 // FFmpegPipeline implements Pipeline using FFmpeg for decoding and encoding.
@@ -17,7 +25,22 @@ type FFmpegPipeline struct {
 	output         chan []byte
 	cancel         context.CancelFunc
 	readBufferSize int
-	sessionID      string // For logging which session this pipeline belongs to
+	sessionID      string              // For logging which session this pipeline belongs to
+	runner         execx.CommandRunner // Creates the FFmpeg process (replaceable in tests)
+	tap            TapFunc             // Optional decoded PCM analysis tap
+	bitrate        int                 // Opus bitrate override in bps (0 = format default)
+	filter         string              // Extra -af filter chain, applied before volume
+	endAt          float64             // Input position (seconds) to stop at (0 = end of input)
+	proxy          string              // HTTP proxy the input is fetched through (empty = direct)
+	fadeIn         time.Duration       // Fade-in at the start of output (0 = none)
+	crossfade      Crossfade           // Previous track's tail mixed into the start (zero = none)
+	aligner        frameAligner        // Cuts output at Ogg page / PCM sample boundaries
+	gate           readGate            // Holds the output reader while paused
+	onExpired      func()              // Called when stderr shows an expired stream URL
+	stderrDone     chan struct{}       // Closed when stderr has been read to the end
+
+	exitMu sync.Mutex
+	exit   ExitStatus // How the last run ended (see ExitReporter)
 }
 
 // NewFFmpegPipeline creates a new FFmpeg-based encoding pipeline.
@@ -26,6 +49,7 @@ func NewFFmpegPipeline(config Config) *FFmpegPipeline {
 		config:         config,
 		output:         make(chan []byte, 30), // Buffer ~600ms for smooth streaming without excessive latency
 		readBufferSize: 16384,
+		runner:         execx.Default,
 	}
 }
 
@@ -39,6 +63,11 @@ func (p *FFmpegPipeline) SetSessionID(id string) {
 	p.sessionID = id
 }
 
+// SetCommandRunner sets the runner used to create the FFmpeg process.
+func (p *FFmpegPipeline) SetCommandRunner(runner execx.CommandRunner) {
+	p.runner = runner
+}
+
 func (p *FFmpegPipeline) shortSessionID() string {
 	if len(p.sessionID) <= 8 {
 		return p.sessionID
@@ -58,26 +87,57 @@ func (p *FFmpegPipeline) Start(ctx context.Context, streamURL string, format For
 	}
 
 	args := p.buildArgs(streamURL, format, startAtSec)
-	fmt.Printf("[FFmpeg] [%s] Starting (format: %s)\n", p.shortSessionID(), format)
-	p.cmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	p.aligner = newFrameAligner(format, p.config.Channels)
+	p.setExit(ExitStatus{Code: -1})
+
+	// Optional analysis tap: a second FFmpeg output on an extra pipe.
+	// ExtraFiles is not supported on Windows, so the tap is disabled there.
+	var tapReader, tapWriter *os.File
+	if p.tap != nil && runtime.GOOS != "windows" {
+		var err error
+		tapReader, tapWriter, err = os.Pipe()
+		if err != nil {
+			return errs.New(errs.ErrPipeline, "failed to create tap pipe: %w", err)
+		}
+		args = append(args, p.tapArgs()...)
+	}
+
+	logger.Infof("[FFmpeg] [%s] Starting (format: %s)", p.shortSessionID(), format)
+	logger.Debugf("[FFmpeg] [%s] Args: %s", p.shortSessionID(), strings.Join(args, " "))
+	p.cmd = p.runner.CommandContext(ctx, FFmpegPath(), args...)
+	if tapWriter != nil {
+		p.cmd.ExtraFiles = []*os.File{tapWriter}
+	}
 
 	var err error
 	p.stdout, err = p.cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return errs.New(errs.ErrPipeline, "failed to create stdout pipe: %w", err)
 	}
 
 	// Capture stderr for debugging - FFmpeg sends errors/warnings here
 	p.stderr, err = p.cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return errs.New(errs.ErrPipeline, "failed to create stderr pipe: %w", err)
 	}
 
-	if err := p.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	err = p.cmd.Start()
+	if tapWriter != nil {
+		tapWriter.Close() // FFmpeg holds its own copy; EOF arrives when it exits
+		if err != nil {
+			tapReader.Close()
+		}
+	}
+	if err != nil {
+		return errs.New(errs.ErrPipeline, "failed to start ffmpeg: %w", err)
 	}
 
-	// Log stderr in background (helps debug premature stream endings)
+	if tapReader != nil {
+		go p.readTap(tapReader)
+	}
+
+	// Log and classify stderr in background (drives retry decisions)
+	p.stderrDone = make(chan struct{})
 	go p.readStderr()
 
 	go p.readOutput(ctx)
@@ -100,81 +160,68 @@ func (p *FFmpegPipeline) Stop() {
 	}
 }
 
-// Pause pauses FFmpeg using SIGSTOP and drains buffered output.
+// Pause stops forwarding output after the current chunk, which always ends
+// on an Ogg page or PCM sample boundary. FFmpeg keeps running until the
+// pipe fills; nothing already encoded is dropped.
 func (p *FFmpegPipeline) Pause() {
-	if p.cmd != nil && p.cmd.Process != nil {
-		// Send SIGSTOP to pause FFmpeg process
-		p.cmd.Process.Signal(syscall.SIGSTOP)
-		fmt.Printf("[FFmpeg] Paused (SIGSTOP) PID %d\n", p.cmd.Process.Pid)
-
-		// Drain any buffered chunks to prevent stale audio on resume
-		drained := 0
-		for {
-			select {
-			case <-p.output:
-				drained++
-			default:
-				if drained > 0 {
-					fmt.Printf("[FFmpeg] Drained %d buffered chunks\n", drained)
-				}
-				return
-			}
-		}
+	if p.gate.paused() {
+		return
 	}
+	p.gate.pause()
+	logger.Infof("[FFmpeg] [%s] Paused at frame boundary", p.shortSessionID())
 }
 
-// Resume resumes FFmpeg using SIGCONT.
+// Resume continues forwarding output from the frame where Pause stopped.
 func (p *FFmpegPipeline) Resume() {
-	if p.cmd != nil && p.cmd.Process != nil {
-		// Drain any remaining buffered chunks first
-		drained := 0
-		for {
-			select {
-			case <-p.output:
-				drained++
-			default:
-				goto done
-			}
-		}
-	done:
-		if drained > 0 {
-			fmt.Printf("[FFmpeg] Drained %d stale chunks before resume\n", drained)
-		}
-
-		// Send SIGCONT to resume FFmpeg process
-		p.cmd.Process.Signal(syscall.SIGCONT)
-		fmt.Printf("[FFmpeg] Resumed (SIGCONT) PID %d\n", p.cmd.Process.Pid)
+	if !p.gate.paused() {
+		return
 	}
+	p.gate.resume()
+	logger.Infof("[FFmpeg] [%s] Resumed", p.shortSessionID())
 }
 
 // buildArgs constructs FFmpeg command arguments based on format.
 func (p *FFmpegPipeline) buildArgs(streamURL string, format Format, startAtSec float64) []string {
-	volume := fmt.Sprintf("volume=%.2f", p.config.Volume)
+	filters := fmt.Sprintf("volume=%.2f", p.config.Volume)
+	if p.filter != "" {
+		filters = p.filter + "," + filters
+	}
+	if p.fadeIn > 0 {
+		// Output timestamps start at 0 after -ss, so this fades in every start
+		filters = fmt.Sprintf("afade=t=in:st=0:d=%.3f,%s", p.fadeIn.Seconds(), filters)
+	}
 	sampleRate := fmt.Sprintf("%d", p.config.SampleRate)
 	channels := fmt.Sprintf("%d", p.config.Channels)
 
-	// Base input args - robust reconnect for YouTube streams
-	args := []string{
-		"-reconnect", "1",
-		"-reconnect_streamed", "1",
-		"-reconnect_on_network_error", "1",
-		"-reconnect_on_http_error", "4xx,5xx",
-		"-reconnect_delay_max", "5",
-		"-multiple_requests", "1",
-		// HTTP headers to reduce YouTube CDN connection resets
-		"-user_agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
-		"-referer", "https://www.youtube.com/",
-	}
+	args := streamInputArgs(streamURL, p.proxy)
 
 	if startAtSec > 0 {
 		args = append(args, "-ss", fmt.Sprintf("%.3f", startAtSec))
 	}
+	if p.endAt > 0 {
+		// As an input option -to is a position in the input, not a duration,
+		// so restarts from a later -ss still stop at the same place
+		args = append(args, "-to", fmt.Sprintf("%.3f", p.endAt))
+	}
 
 	// Input
+	args = append(args, "-i", streamURL)
+
+	// Audio processing
+	if p.crossfade.Duration > 0 {
+		// The previous track's tail is a second input, read without -re so
+		// acrossfade doesn't hold the start back for the length of the tail
+		args = append(args, "-ss", fmt.Sprintf("%.3f", p.crossfade.From), "-t", fmt.Sprintf("%.3f", p.crossfade.Duration.Seconds()))
+		args = append(args, streamInputArgs(p.crossfade.URL, p.proxy)...)
+		args = append(args,
+			"-i", p.crossfade.URL,
+			"-filter_complex", p.crossfade.filter()+","+filters+"[out]",
+			"-map", "[out]",
+		)
+	} else {
+		args = append(args, "-af", filters)
+	}
 	args = append(args,
-		"-i", streamURL,
-		// Audio processing
-		"-af", volume,
 		"-ar", sampleRate,
 		"-ac", channels,
 		"-loglevel", "warning",
@@ -195,7 +242,7 @@ func (p *FFmpegPipeline) buildArgs(streamURL string, format Format, startAtSec f
 		args = append([]string{"-re"}, args...)
 		args = append(args,
 			"-c:a", "libopus",
-			"-b:a", "128000", // 128kbps for Discord
+			"-b:a", p.bitrateOr(128000), // 128kbps for Discord
 			"-vbr", "on", // Variable bitrate for better quality
 			"-compression_level", "10", // Max compression quality
 			"-frame_duration", "20", // 20ms frames (Discord standard)
@@ -211,7 +258,7 @@ func (p *FFmpegPipeline) buildArgs(streamURL string, format Format, startAtSec f
 		args = append([]string{"-re"}, args...)
 		args = append(args,
 			"-c:a", "libopus",
-			"-b:a", "256000", // 256kbps YouTube Premium quality
+			"-b:a", p.bitrateOr(256000), // 256kbps YouTube Premium quality
 			"-vbr", "on", // Variable bitrate for better quality
 			"-compression_level", "10", // Max compression quality
 			"-frame_duration", "20", // 20ms frames
@@ -226,9 +273,101 @@ func (p *FFmpegPipeline) buildArgs(streamURL string, format Format, startAtSec f
 	return args
 }
 
+// SetFadeIn sets the fade-in at the start of output for the next Start.
+func (p *FFmpegPipeline) SetFadeIn(d time.Duration) {
+	p.fadeIn = d
+}
+
+// SetEndAt sets the input position output stops at for the next Start.
+func (p *FFmpegPipeline) SetEndAt(sec float64) {
+	p.endAt = sec
+}
+
+// Headers FFmpeg sends with stream requests, like a browser on YouTube, to
+// reduce CDN connection resets.
+const (
+	StreamUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+	StreamReferer   = "https://www.youtube.com/"
+)
+
+// streamInputArgs returns the input options for reading url: robust
+// reconnects and browser-like headers for YouTube streams, fetched through
+// proxy if set. Local files (no URL scheme) need none.
+func streamInputArgs(url, proxy string) []string {
+	if !strings.Contains(url, "://") {
+		return nil
+	}
+	args := []string{
+		"-reconnect", "1",
+		"-reconnect_streamed", "1",
+		"-reconnect_on_network_error", "1",
+		"-reconnect_on_http_error", "4xx,5xx",
+		"-reconnect_delay_max", "5",
+		"-multiple_requests", "1",
+		"-user_agent", StreamUserAgent,
+		"-referer", StreamReferer,
+	}
+	if proxy != "" {
+		args = append(args, "-http_proxy", proxy)
+	}
+	return args
+}
+
+// SetProxy sets the HTTP proxy the input is fetched through on the next
+// Start.
+func (p *FFmpegPipeline) SetProxy(proxy string) {
+	p.proxy = proxy
+}
+
+// SetFilter sets the volume and an extra filter chain for the next Start.
+func (p *FFmpegPipeline) SetFilter(volume float64, filter string) {
+	p.config.Volume = volume
+	p.filter = filter
+}
+
+// ExitStatus returns how the last run ended (see ExitReporter).
+func (p *FFmpegPipeline) ExitStatus() ExitStatus {
+	p.exitMu.Lock()
+	defer p.exitMu.Unlock()
+	return p.exit
+}
+
+func (p *FFmpegPipeline) setExit(status ExitStatus) {
+	p.exitMu.Lock()
+	p.exit = status
+	p.exitMu.Unlock()
+}
+
+// OnExpired sets the handler for an expired stream URL (see ExpiryNotifier).
+func (p *FFmpegPipeline) OnExpired(fn func()) {
+	p.onExpired = fn
+}
+
+// isExpiredURL reports whether an FFmpeg stderr line shows the CDN
+// rejecting the stream URL, which happens when a googlevideo URL expires.
+func isExpiredURL(line string) bool {
+	line = strings.ToLower(line)
+	return strings.Contains(line, "403 forbidden") || strings.Contains(line, "410 gone")
+}
+
+// SetBitrate overrides the Opus bitrate (bps) for the next Start.
+// Zero restores the format default.
+func (p *FFmpegPipeline) SetBitrate(bps int) {
+	p.bitrate = bps
+}
+
+// bitrateOr returns the -b:a value: the override if set, else defaultBps.
+func (p *FFmpegPipeline) bitrateOr(defaultBps int) string {
+	if p.bitrate > 0 {
+		return fmt.Sprintf("%d", p.bitrate)
+	}
+	return fmt.Sprintf("%d", defaultBps)
+}
+
 // readStderr reads FFmpeg stderr and logs any errors/warnings.
 // This helps debug why streams end prematurely.
 func (p *FFmpegPipeline) readStderr() {
+	defer close(p.stderrDone)
 	if p.stderr == nil {
 		return
 	}
@@ -236,6 +375,7 @@ func (p *FFmpegPipeline) readStderr() {
 
 	buf := make([]byte, 4096)
 	var accumulated []byte
+	class := ErrorNone
 
 	for {
 		n, err := p.stderr.Read(buf)
@@ -256,14 +396,23 @@ func (p *FFmpegPipeline) readStderr() {
 				line := string(accumulated[:idx])
 				accumulated = accumulated[idx+1:]
 				if len(line) > 0 {
-					fmt.Printf("[FFmpeg] [%s] STDERR: %s\n", p.shortSessionID(), line)
+					logger.Warnf("[FFmpeg] [%s] STDERR: %s", p.shortSessionID(), line)
+				}
+				if c := classifyStderr(line); c > class {
+					class = c
+					p.exitMu.Lock()
+					p.exit.Class, p.exit.Error = c, line
+					p.exitMu.Unlock()
+					if c == ErrorExpired && p.onExpired != nil {
+						p.onExpired()
+					}
 				}
 			}
 		}
 		if err != nil {
 			// Log any remaining data
 			if len(accumulated) > 0 {
-				fmt.Printf("[FFmpeg] [%s] STDERR: %s\n", p.shortSessionID(), string(accumulated))
+				logger.Warnf("[FFmpeg] [%s] STDERR: %s", p.shortSessionID(), string(accumulated))
 			}
 			return
 		}
@@ -282,24 +431,34 @@ func (p *FFmpegPipeline) readOutput(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Printf("[FFmpeg] [%s] Stopped (context cancelled), total: %d bytes\n", p.shortSessionID(), totalBytes)
+			logger.Infof("[FFmpeg] [%s] Stopped (context cancelled), total: %d bytes", p.shortSessionID(), totalBytes)
 			p.waitAndLogExit()
 			return
 		default:
+			if !p.gate.wait(ctx) {
+				continue // Cancelled while paused
+			}
 			n, err := p.stdout.Read(buf)
 			if err != nil {
 				if err != io.EOF {
-					fmt.Printf("[FFmpeg] [%s] Read error: %v\n", p.shortSessionID(), err)
+					logger.Errorf("[FFmpeg] [%s] Read error: %v", p.shortSessionID(), err)
 				}
-				fmt.Printf("[FFmpeg] [%s] Stream ended, total: %d bytes in %d chunks\n", p.shortSessionID(), totalBytes, chunkCount)
+				logger.Infof("[FFmpeg] [%s] Stream ended, total: %d bytes in %d chunks", p.shortSessionID(), totalBytes, chunkCount)
 				p.waitAndLogExit()
 				return
 			}
 			if n > 0 {
-				chunk := make([]byte, n)
-				copy(chunk, buf[:n])
 				totalBytes += n
+				chunk := p.aligner.Write(buf[:n])
+				if chunk == nil {
+					continue // No complete frame yet
+				}
 				chunkCount++
+				// A read in progress when Pause was called is held here
+				if !p.gate.wait(ctx) {
+					buffer.PutChunk(chunk)
+					continue
+				}
 				select {
 				case p.output <- chunk:
 				case <-ctx.Done():
@@ -310,19 +469,24 @@ func (p *FFmpegPipeline) readOutput(ctx context.Context) {
 	}
 }
 
-// waitAndLogExit waits for FFmpeg to exit and logs the exit code.
+// waitAndLogExit waits for FFmpeg to exit and records and logs the exit
+// code. Stderr is read to the end first, so its classification is complete.
 func (p *FFmpegPipeline) waitAndLogExit() {
 	if p.cmd == nil {
 		return
 	}
+	<-p.stderrDone
 	err := p.cmd.Wait()
+	p.exitMu.Lock()
+	p.exit.Code = p.cmd.ProcessState.ExitCode()
+	p.exitMu.Unlock()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			fmt.Printf("[FFmpeg] [%s] Exited with code %d\n", p.shortSessionID(), exitErr.ExitCode())
+			logger.Warnf("[FFmpeg] [%s] Exited with code %d (%s errors)", p.shortSessionID(), exitErr.ExitCode(), p.ExitStatus().Class)
 		} else {
-			fmt.Printf("[FFmpeg] [%s] Wait error: %v\n", p.shortSessionID(), err)
+			logger.Errorf("[FFmpeg] [%s] Wait error: %v", p.shortSessionID(), err)
 		}
 	} else {
-		fmt.Printf("[FFmpeg] [%s] Exited normally (code 0)\n", p.shortSessionID())
+		logger.Infof("[FFmpeg] [%s] Exited normally (code 0)", p.shortSessionID())
 	}
 }
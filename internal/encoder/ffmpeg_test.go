@@ -0,0 +1,207 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// fakeFFmpeg returns a runner that replaces FFmpeg with a shell script.
+func fakeFFmpeg(script string) execx.CommandRunner {
+	return execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	})
+}
+
+func TestFFmpegPipeline_ForwardsOutput(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetCommandRunner(fakeFFmpeg("printf 'pcm-data'"))
+
+	if err := p.Start(context.Background(), "https://stream.invalid", FormatPCM, 0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var got bytes.Buffer
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case chunk, ok := <-p.Output():
+			if !ok {
+				if got.String() != "pcm-data" {
+					t.Errorf("expected output %q, got %q", "pcm-data", got.String())
+				}
+				return
+			}
+			got.Write(chunk)
+		case <-timeout:
+			t.Fatal("timed out waiting for pipeline output")
+		}
+	}
+}
+
+func TestFFmpegPipeline_BuildArgsSeek(t *testing.T) {
+	p := NewDefaultPipeline()
+
+	args := p.buildArgs("https://stream.invalid", FormatOpus, 12.5)
+
+	for i, arg := range args {
+		if arg == "-ss" {
+			if args[i+1] != "12.500" {
+				t.Errorf("expected -ss 12.500, got %s", args[i+1])
+			}
+			return
+		}
+	}
+	t.Error("expected -ss in args when startAtSec > 0")
+}
+
+func TestFFmpegPipeline_BuildArgsEndAt(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetEndAt(90)
+
+	args := strings.Join(p.buildArgs("https://stream.invalid", FormatOpus, 30), " ")
+
+	if !strings.Contains(args, "-ss 30.000 -to 90.000 -i https://stream.invalid") {
+		t.Errorf("expected -to as an input option after -ss, got %s", args)
+	}
+}
+
+func TestFFmpegPipeline_BuildArgsProxy(t *testing.T) {
+	p := NewDefaultPipeline()
+	if args := strings.Join(p.buildArgs("https://stream.invalid", FormatOpus, 0), " "); strings.Contains(args, "-http_proxy") {
+		t.Errorf("expected no proxy by default, got %s", args)
+	}
+
+	p.SetProxy("http://proxy.invalid:3128")
+	args := strings.Join(p.buildArgs("https://stream.invalid", FormatOpus, 0), " ")
+	if !strings.Contains(args, "-http_proxy http://proxy.invalid:3128 -i https://stream.invalid") {
+		t.Errorf("expected the proxy as an input option, got %s", args)
+	}
+}
+
+func TestFFmpegPipeline_BuildArgsLocalFile(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetProxy("http://proxy.invalid:3128")
+
+	args := strings.Join(p.buildArgs("/tmp/track.mka", FormatOpus, 0), " ")
+	if strings.Contains(args, "-reconnect") || strings.Contains(args, "-http_proxy") {
+		t.Errorf("expected no HTTP input options for a local file, got %s", args)
+	}
+}
+
+func TestFFmpegPipeline_BuildArgsFilter(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetFilter(0.5, "bass=g=8:f=100")
+
+	args := p.buildArgs("https://stream.invalid", FormatOpus, 0)
+
+	for i, arg := range args {
+		if arg == "-af" {
+			if args[i+1] != "bass=g=8:f=100,volume=0.50" {
+				t.Errorf("unexpected -af %s", args[i+1])
+			}
+			return
+		}
+	}
+	t.Error("expected -af in args")
+}
+
+func TestFFmpegPipeline_BuildArgsFadeIn(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetFadeIn(100 * time.Millisecond)
+
+	args := p.buildArgs("https://stream.invalid", FormatOpus, 30)
+
+	for i, arg := range args {
+		if arg == "-af" {
+			if !strings.HasPrefix(args[i+1], "afade=t=in:st=0:d=0.100,") {
+				t.Errorf("expected afade first in -af, got %s", args[i+1])
+			}
+			return
+		}
+	}
+	t.Error("expected -af in args")
+}
+
+func TestFFmpegPipeline_BuildArgsCrossfade(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetCrossfade(Crossfade{URL: "https://old.invalid", From: 200, Duration: 6 * time.Second, Curve: "tri"})
+
+	args := strings.Join(p.buildArgs("https://stream.invalid", FormatOpus, 0), " ")
+
+	if !strings.HasPrefix(args, "-re ") || strings.Count(args, "-re ") != 1 {
+		t.Errorf("expected -re only on the track input, got %s", args)
+	}
+	if !strings.Contains(args, "-i https://stream.invalid -ss 200.000 -t 6.000 ") || !strings.Contains(args, "-i https://old.invalid ") {
+		t.Errorf("expected the tail as a second, trimmed input, got %s", args)
+	}
+	if !strings.Contains(args, "-filter_complex [1:a][0:a]acrossfade=d=6.000:c1=tri:c2=tri,volume=1.00[out] -map [out]") {
+		t.Errorf("expected acrossfade before the filter chain, got %s", args)
+	}
+	if strings.Contains(args, "-af volume") {
+		t.Errorf("expected no -af with a crossfade, got %s", args)
+	}
+}
+
+func TestFFmpegVersion(t *testing.T) {
+	runner := fakeFFmpeg(`echo "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers"; echo "built with gcc 13"`)
+	version, err := FFmpegVersion(context.Background(), runner)
+	if err != nil {
+		t.Fatalf("FFmpegVersion failed: %v", err)
+	}
+	if version != "6.1.1-3ubuntu5" {
+		t.Errorf("unexpected version %q", version)
+	}
+
+	if _, err := FFmpegVersion(context.Background(), fakeFFmpeg("exit 127")); err == nil {
+		t.Error("expected error when ffmpeg is missing")
+	}
+}
+
+func TestFFmpegPipeline_ReportsExpiredURL(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetCommandRunner(fakeFFmpeg("echo 'HTTP error 403 Forbidden' >&2; echo 'HTTP error 403 Forbidden' >&2"))
+	expired := make(chan struct{}, 2)
+	p.OnExpired(func() { expired <- struct{}{} })
+
+	if err := p.Start(context.Background(), "https://stream.invalid", FormatPCM, 0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	for range p.Output() {
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the expired handler to be called")
+	}
+	select {
+	case <-expired:
+		t.Error("expected the expired handler to be called only once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFFmpegPipeline_ReportsExitStatus(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetCommandRunner(fakeFFmpeg("echo 'Invalid data found when processing input' >&2; echo 'Connection reset by peer' >&2; exit 3"))
+
+	if err := p.Start(context.Background(), "https://stream.invalid", FormatPCM, 0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	for range p.Output() {
+	}
+
+	exit := p.ExitStatus()
+	if exit.Code != 3 {
+		t.Errorf("expected exit code 3, got %d", exit.Code)
+	}
+	if exit.Class != ErrorNetwork || exit.Error != "Connection reset by peer" {
+		t.Errorf("expected the network error to outrank the fatal one, got %+v", exit)
+	}
+}
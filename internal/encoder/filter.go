@@ -0,0 +1,137 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// effect is a named audio effect.
+type effect struct {
+	description string
+	filter      func(sampleRate int) string // FFmpeg audio filter for the output rate
+}
+
+// effects maps effect names to their FFmpeg audio filters.
+var effects = map[string]effect{
+	"bassboost": {"Boost lows around 100Hz", func(int) string { return "bass=g=8:f=100" }},
+	// Resampled back to the output rate
+	"nightcore": {"Speed and pitch up by 25%", func(sr int) string { return fmt.Sprintf("asetrate=%d,aresample=%d", sr*5/4, sr) }},
+	"loudnorm":  {"EBU R128 loudness normalisation", func(int) string { return "loudnorm=I=-16:TP=-1.5:LRA=11" }},
+	// Mid (L+R) down to -36dB, sides untouched. Lead vocals are usually
+	// mixed to the center; so are bass and kick, and mono tracks go quiet.
+	"karaoke": {"Remove center-panned vocals", func(int) string { return "stereotools=mlev=0.015625" }},
+	// apulsator pans L/R once per 8s; aecho adds a short room reflection
+	"8d": {"Slow rotating pan with light reverb", func(int) string { return "apulsator=hz=0.125,aecho=0.8:0.7:40:0.25" }},
+}
+
+// Effects returns the supported effect names, sorted.
+func Effects() []string {
+	names := make([]string, 0, len(effects))
+	for name := range effects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EffectDescription returns a short description of the named effect, or ""
+// if there is no such effect.
+func EffectDescription(name string) string {
+	return effects[name].description
+}
+
+// Playback speed limits for TempoFilter.
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 2.0
+)
+
+// MaxPitch is the largest pitch shift in semitones, up or down.
+const MaxPitch = 12
+
+// TempoFilter returns the FFmpeg filter that plays audio at speed times its
+// normal rate without changing its pitch, or "" at 1x. Older FFmpeg only
+// accepts atempo factors of 0.5-2.0, so larger changes are chained.
+func TempoFilter(speed float64) string {
+	if speed <= 0 || speed == 1 {
+		return ""
+	}
+	var filters []string
+	for speed > MaxSpeed {
+		filters = append(filters, "atempo=2")
+		speed /= 2
+	}
+	for speed < MinSpeed {
+		filters = append(filters, "atempo=0.5")
+		speed *= 2
+	}
+	filters = append(filters, fmt.Sprintf("atempo=%.6g", speed))
+	return strings.Join(filters, ",")
+}
+
+// PitchFilter returns the FFmpeg filter that shifts audio by semitones and
+// plays it at speed, or "" when neither changes. Resampling shifts the
+// pitch and the tempo together; atempo then undoes the tempo change.
+func PitchFilter(semitones, speed float64, sampleRate int) string {
+	if semitones == 0 {
+		return TempoFilter(speed)
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	rate := int(math.Round(float64(sampleRate) * math.Pow(2, semitones/12)))
+	filter := fmt.Sprintf("asetrate=%d,aresample=%d", rate, sampleRate)
+	if tempo := TempoFilter(speed * float64(sampleRate) / float64(rate)); tempo != "" {
+		filter += "," + tempo
+	}
+	return filter
+}
+
+// FilterChain builds an FFmpeg -af filter chain from an equalizer spec and
+// effect names. The EQ spec is a comma-separated list of freq=gain bands,
+// e.g. "60=4,1k=-2,8k=3" (gain in dB, -20..20). Returns "" when there is
+// nothing to apply.
+func FilterChain(eq string, effectNames []string, sampleRate int) (string, error) {
+	var filters []string
+	if eq = strings.TrimSpace(eq); eq != "" {
+		for _, band := range strings.Split(eq, ",") {
+			freq, gain, err := parseBand(band)
+			if err != nil {
+				return "", err
+			}
+			filters = append(filters, fmt.Sprintf("equalizer=f=%g:t=o:w=1:g=%g", freq, gain))
+		}
+	}
+	for _, name := range effectNames {
+		effect, ok := effects[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return "", fmt.Errorf("unknown effect %q (available: %s)", name, strings.Join(Effects(), ", "))
+		}
+		filters = append(filters, effect.filter(sampleRate))
+	}
+	return strings.Join(filters, ","), nil
+}
+
+// parseBand parses one "freq=gain" EQ band; freq accepts a k suffix.
+func parseBand(band string) (freq, gain float64, err error) {
+	f, g, ok := strings.Cut(strings.TrimSpace(band), "=")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid EQ band %q (want freq=gain)", band)
+	}
+	multiplier := 1.0
+	if rest, ok := strings.CutSuffix(strings.ToLower(f), "k"); ok {
+		f, multiplier = rest, 1000
+	}
+	freq, err = strconv.ParseFloat(f, 64)
+	if err != nil || freq <= 0 || freq*multiplier > 20000 {
+		return 0, 0, fmt.Errorf("invalid EQ frequency in %q", band)
+	}
+	gain, err = strconv.ParseFloat(g, 64)
+	if err != nil || gain < -20 || gain > 20 {
+		return 0, 0, fmt.Errorf("invalid EQ gain in %q (want -20..20 dB)", band)
+	}
+	return freq * multiplier, gain, nil
+}
@@ -0,0 +1,80 @@
+package encoder
+
+import "testing"
+
+func TestFilterChain(t *testing.T) {
+	chain, err := FilterChain("60=4, 1k=-2.5", []string{"BassBoost", "nightcore"}, 48000)
+	if err != nil {
+		t.Fatalf("FilterChain failed: %v", err)
+	}
+	want := "equalizer=f=60:t=o:w=1:g=4,equalizer=f=1000:t=o:w=1:g=-2.5,bass=g=8:f=100,asetrate=60000,aresample=48000"
+	if chain != want {
+		t.Errorf("expected %q, got %q", want, chain)
+	}
+
+	if chain, err := FilterChain("", []string{"karaoke"}, 48000); err != nil || chain != "stereotools=mlev=0.015625" {
+		t.Errorf("unexpected karaoke chain %q, %v", chain, err)
+	}
+
+	if chain, err := FilterChain("", []string{"8D"}, 48000); err != nil || chain != "apulsator=hz=0.125,aecho=0.8:0.7:40:0.25" {
+		t.Errorf("unexpected 8d chain %q, %v", chain, err)
+	}
+
+	if chain, err := FilterChain("", nil, 48000); err != nil || chain != "" {
+		t.Errorf("expected empty chain, got %q, %v", chain, err)
+	}
+}
+
+func TestTempoFilter(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  string
+	}{
+		{1, ""},
+		{1.25, "atempo=1.25"},
+		{0.5, "atempo=0.5"},
+		{3, "atempo=2,atempo=1.5"},
+		{0.2, "atempo=0.5,atempo=0.5,atempo=0.8"},
+	}
+	for _, tt := range tests {
+		if got := TempoFilter(tt.speed); got != tt.want {
+			t.Errorf("TempoFilter(%g): expected %q, got %q", tt.speed, tt.want, got)
+		}
+	}
+}
+
+func TestPitchFilter(t *testing.T) {
+	tests := []struct {
+		semitones, speed float64
+		want             string
+	}{
+		{0, 1, ""},
+		{0, 1.5, "atempo=1.5"},
+		{12, 1, "asetrate=96000,aresample=48000,atempo=0.5"},
+		{-12, 1.5, "asetrate=24000,aresample=48000,atempo=2,atempo=1.5"},
+		{2, 1, "asetrate=53878,aresample=48000,atempo=0.890902"},
+	}
+	for _, tt := range tests {
+		if got := PitchFilter(tt.semitones, tt.speed, 48000); got != tt.want {
+			t.Errorf("PitchFilter(%g, %g): expected %q, got %q", tt.semitones, tt.speed, tt.want, got)
+		}
+	}
+}
+
+func TestFilterChain_Invalid(t *testing.T) {
+	cases := []struct {
+		eq      string
+		effects []string
+	}{
+		{"60", nil},
+		{"abc=3", nil},
+		{"30k=3", nil},
+		{"60=25", nil},
+		{"", []string{"reverb"}},
+	}
+	for _, c := range cases {
+		if _, err := FilterChain(c.eq, c.effects, 48000); err == nil {
+			t.Errorf("FilterChain(%q, %v): expected error", c.eq, c.effects)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package encoder
+
+import (
+	"context"
+	"sync"
+
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/ogg"
+)
+
+// frameAligner cuts FFmpeg output at frame boundaries, so a chunk never
+// ends in the middle of an Ogg page or a PCM sample and the pipeline can
+// pause between any two chunks without corrupting the stream.
+type frameAligner interface {
+	// Write consumes data and returns the complete frames it finished as
+	// one chunk from buffer.GetChunk, or nil if none are complete yet.
+	Write(data []byte) []byte
+}
+
+// newFrameAligner returns the aligner for format's output.
+func newFrameAligner(format Format, channels int) frameAligner {
+	if format == FormatPCM {
+		return &pcmAligner{frameSize: 2 * max(channels, 1)} // s16le
+	}
+	return &oggAligner{}
+}
+
+// oggAligner emits whole Ogg pages.
+type oggAligner struct {
+	scanner ogg.Scanner
+}
+
+func (a *oggAligner) Write(data []byte) []byte {
+	pages := a.scanner.Write(data)
+	if len(pages) == 0 {
+		return nil
+	}
+	size := 0
+	for _, page := range pages {
+		size += len(page.Data)
+	}
+	chunk := buffer.GetChunk(size)
+	offset := 0
+	for _, page := range pages {
+		offset += copy(chunk[offset:], page.Data)
+	}
+	return chunk
+}
+
+// pcmAligner emits whole sample frames (one sample for every channel).
+type pcmAligner struct {
+	frameSize int
+	rest      []byte // Partial frame carried into the next Write
+}
+
+func (a *pcmAligner) Write(data []byte) []byte {
+	size := (len(a.rest) + len(data)) / a.frameSize * a.frameSize
+	if size == 0 {
+		a.rest = append(a.rest, data...)
+		return nil
+	}
+	chunk := buffer.GetChunk(size)
+	carried := copy(chunk, a.rest)
+	used := copy(chunk[carried:], data)
+	a.rest = append(a.rest[:0], data[used:]...)
+	return chunk
+}
+
+// readGate holds the output reader between chunks while paused. FFmpeg is
+// left running and blocks once the pipe fills, so resuming continues from
+// the exact frame where output stopped.
+type readGate struct {
+	mu     sync.Mutex
+	closed chan struct{} // Non-nil while paused; closed on resume
+}
+
+// pause holds the reader before its next chunk.
+func (g *readGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed == nil {
+		g.closed = make(chan struct{})
+	}
+}
+
+// resume releases the reader.
+func (g *readGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed != nil {
+		close(g.closed)
+		g.closed = nil
+	}
+}
+
+// wait blocks while paused. Returns false if ctx is cancelled first.
+func (g *readGate) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	closed := g.closed
+	g.mu.Unlock()
+	if closed == nil {
+		return true
+	}
+	select {
+	case <-closed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// paused reports whether the gate is holding the reader.
+func (g *readGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closed != nil
+}
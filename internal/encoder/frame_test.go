@@ -0,0 +1,85 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// oggPage builds a minimal single-segment Ogg page.
+func oggPage(body string) []byte {
+	page := make([]byte, 28, 28+len(body))
+	copy(page, "OggS")
+	page[26] = 1
+	page[27] = byte(len(body))
+	return append(page, body...)
+}
+
+func TestOggAligner_EmitsWholePages(t *testing.T) {
+	first, second := oggPage("first"), oggPage("second")
+	stream := append(append([]byte(nil), first...), second...)
+
+	a := newFrameAligner(FormatOpus, 2)
+	if chunk := a.Write(stream[:10]); chunk != nil {
+		t.Fatalf("expected no chunk for a partial page, got %d bytes", len(chunk))
+	}
+	chunk := a.Write(stream[10 : len(first)+5])
+	if !bytes.Equal(chunk, first) {
+		t.Fatalf("expected the first page, got %q", chunk)
+	}
+	if chunk := a.Write(stream[len(first)+5:]); !bytes.Equal(chunk, second) {
+		t.Errorf("expected the second page, got %q", chunk)
+	}
+}
+
+func TestPCMAligner_EmitsWholeSamples(t *testing.T) {
+	a := newFrameAligner(FormatPCM, 2) // 4-byte stereo s16le frames
+	if chunk := a.Write([]byte("abc")); chunk != nil {
+		t.Fatalf("expected no chunk for a partial frame, got %q", chunk)
+	}
+	if chunk := a.Write([]byte("defghij")); string(chunk) != "abcdefgh" {
+		t.Fatalf("expected two whole frames, got %q", chunk)
+	}
+	if chunk := a.Write([]byte("k")); chunk != nil {
+		t.Fatalf("expected no chunk for a partial frame, got %q", chunk)
+	}
+	if chunk := a.Write([]byte("l")); string(chunk) != "ijkl" {
+		t.Errorf("expected the carried frame, got %q", chunk)
+	}
+}
+
+func TestFFmpegPipeline_PauseHoldsOutput(t *testing.T) {
+	p := NewDefaultPipeline()
+	p.SetCommandRunner(fakeFFmpeg("printf 'aaaa'; sleep 0.2; printf 'bbbb'"))
+	if err := p.Start(context.Background(), "https://stream.invalid", FormatPCM, 0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer p.Stop()
+
+	select {
+	case chunk := <-p.Output():
+		if string(chunk) != "aaaa" {
+			t.Fatalf("expected first chunk, got %q", chunk)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the first chunk")
+	}
+
+	p.Pause()
+	select {
+	case chunk := <-p.Output():
+		t.Fatalf("expected no output while paused, got %q", chunk)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	p.Resume()
+	select {
+	case chunk := <-p.Output():
+		if string(chunk) != "bbbb" {
+			t.Errorf("expected the held chunk after resume, got %q", chunk)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for output after resume")
+	}
+}
@@ -0,0 +1,6 @@
+package encoder
+
+import "github.com/thewind121212/natashi/internal/logging"
+
+// logger is the encoder's log output (component "encoder").
+var logger = logging.New("encoder")
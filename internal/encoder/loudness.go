@@ -0,0 +1,91 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// Loudness targets, the same as the single-pass "loudnorm" effect.
+const (
+	LoudnessTarget   = -16.0 // Integrated loudness in LUFS
+	LoudnessPeak     = -1.5  // Highest true peak in dBTP after the gain
+	maxLoudnessBoost = 20.0  // Largest gain in dB for very quiet tracks
+	loudnessFloor    = -99.0 // Stands in for -inf (silence) in measurements
+)
+
+// Loudness is an EBU R128 measurement of a whole track.
+type Loudness struct {
+	Integrated float64 `json:"integrated"` // Integrated loudness in LUFS
+	TruePeak   float64 `json:"true_peak"`  // True peak in dBTP
+	LRA        float64 `json:"lra"`        // Loudness range in LU
+}
+
+// Gain returns the linear gain in dB that brings the track to
+// LoudnessTarget without pushing its true peak above LoudnessPeak. Unlike
+// single-pass loudnorm, it leaves the dynamics untouched.
+func (l Loudness) Gain() float64 {
+	gain := min(LoudnessTarget-l.Integrated, LoudnessPeak-l.TruePeak)
+	return min(gain, maxLoudnessBoost)
+}
+
+// GainFilter returns the FFmpeg filter applying l's gain.
+func (l Loudness) GainFilter() string {
+	return fmt.Sprintf("volume=%.2fdB", l.Gain())
+}
+
+// MeasureLoudness runs a loudnorm analysis pass over streamURL without
+// encoding any output. It blocks until FFmpeg exits.
+func MeasureLoudness(ctx context.Context, runner execx.CommandRunner, streamURL string) (Loudness, error) {
+	args := []string{
+		"-hide_banner", "-nostats",
+		"-reconnect", "1",
+		"-reconnect_streamed", "1",
+		"-reconnect_delay_max", "5",
+		"-i", streamURL,
+		"-vn", "-sn", "-dn",
+		"-af", fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=11:print_format=json", LoudnessTarget, LoudnessPeak),
+		"-f", "null", "-",
+	}
+
+	var stderr bytes.Buffer
+	cmd := runner.CommandContext(ctx, FFmpegPath(), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, errs.New(errs.ErrPipeline, "measure loudness: %w", err)
+	}
+	loudness, err := parseLoudnorm(stderr.String())
+	if err != nil {
+		return Loudness{}, errs.New(errs.ErrPipeline, "measure loudness: %w", err)
+	}
+	return loudness, nil
+}
+
+// parseLoudnorm reads the JSON summary loudnorm prints at the end of stderr.
+func parseLoudnorm(stderr string) (Loudness, error) {
+	start := strings.LastIndex(stderr, "{")
+	end := strings.LastIndex(stderr, "}")
+	if start < 0 || end < start {
+		return Loudness{}, fmt.Errorf("no loudnorm summary in output")
+	}
+	var summary map[string]string
+	if err := json.Unmarshal([]byte(stderr[start:end+1]), &summary); err != nil {
+		return Loudness{}, fmt.Errorf("parse loudnorm summary: %w", err)
+	}
+
+	var l Loudness
+	for key, dst := range map[string]*float64{"input_i": &l.Integrated, "input_tp": &l.TruePeak, "input_lra": &l.LRA} {
+		value, err := strconv.ParseFloat(summary[key], 64)
+		if err != nil {
+			return Loudness{}, fmt.Errorf("parse loudnorm %s: %w", key, err)
+		}
+		*dst = max(value, loudnessFloor) // Silence measures -inf
+	}
+	return l, nil
+}
@@ -0,0 +1,73 @@
+package encoder
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const loudnormSummary = `[Parsed_loudnorm_0 @ 0x5581] 
+{
+	"input_i" : "-7.47",
+	"input_tp" : "0.46",
+	"input_lra" : "4.30",
+	"input_thresh" : "-17.68",
+	"output_i" : "-16.02",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.02"
+}
+`
+
+func TestParseLoudnorm(t *testing.T) {
+	l, err := parseLoudnorm("Input #0, matroska,webm, from 'https://stream.invalid':\n" + loudnormSummary)
+	if err != nil {
+		t.Fatalf("parseLoudnorm failed: %v", err)
+	}
+	if l != (Loudness{Integrated: -7.47, TruePeak: 0.46, LRA: 4.3}) {
+		t.Errorf("unexpected measurement %+v", l)
+	}
+
+	silent := strings.NewReplacer(`"-7.47"`, `"-inf"`, `"0.46"`, `"-inf"`).Replace(loudnormSummary)
+	if l, err := parseLoudnorm(silent); err != nil || l.Integrated != loudnessFloor {
+		t.Errorf("expected silence at the floor, got %+v (%v)", l, err)
+	}
+
+	if _, err := parseLoudnorm("Error opening input"); err == nil {
+		t.Error("expected error without a summary")
+	}
+}
+
+func TestLoudness_Gain(t *testing.T) {
+	tests := []struct {
+		name string
+		l    Loudness
+		want float64
+	}{
+		{"loud track is turned down", Loudness{Integrated: -7.5, TruePeak: 0.5}, -8.5},
+		{"quiet track is limited by its peak", Loudness{Integrated: -24, TruePeak: -4}, 2.5},
+		{"silence is capped", Loudness{Integrated: loudnessFloor, TruePeak: loudnessFloor}, maxLoudnessBoost},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Gain(); got != tt.want {
+			t.Errorf("%s: expected %.2f dB, got %.2f dB", tt.name, tt.want, got)
+		}
+	}
+	if got := (Loudness{Integrated: -7.5, TruePeak: 0.5}).GainFilter(); got != "volume=-8.50dB" {
+		t.Errorf("unexpected gain filter %q", got)
+	}
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	runner := fakeFFmpeg("cat >&2 <<'EOF'\n" + loudnormSummary + "EOF")
+	l, err := MeasureLoudness(context.Background(), runner, "https://stream.invalid")
+	if err != nil {
+		t.Fatalf("MeasureLoudness failed: %v", err)
+	}
+	if l.Integrated != -7.47 {
+		t.Errorf("unexpected measurement %+v", l)
+	}
+
+	if _, err := MeasureLoudness(context.Background(), fakeFFmpeg("exit 1"), "https://stream.invalid"); err == nil {
+		t.Error("expected error when FFmpeg fails")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"sync"
 	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
 )
 
 // Player plays audio directly to macOS audio device.
@@ -56,23 +58,23 @@ func (p *Player) Play(ctx context.Context, streamURL string) error {
 	}()
 
 	args := p.buildArgs(streamURL)
-	fmt.Printf("[Player] Starting FFmpeg: %v\n", args[:10]) // Print first 10 args
-	p.cmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	logger.Infof("[Player] Starting FFmpeg: %v", args[:10]) // Print first 10 args
+	p.cmd = exec.CommandContext(ctx, FFmpegPath(), args...)
 
 	// Capture stderr for progress
 	stderr, err := p.cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("stderr pipe: %w", err)
+		return errs.New(errs.ErrPipeline, "stderr pipe: %w", err)
 	}
 
 	// Discard stdout
 	p.cmd.Stdout = nil
 
 	if err := p.cmd.Start(); err != nil {
-		return fmt.Errorf("start ffmpeg: %w", err)
+		return errs.New(errs.ErrPipeline, "start ffmpeg: %w", err)
 	}
 
-	fmt.Printf("[Player] FFmpeg started, PID: %d\n", p.cmd.Process.Pid)
+	logger.Infof("[Player] FFmpeg started, PID: %d", p.cmd.Process.Pid)
 
 	// Read stderr in background (contains progress)
 	go p.readProgress(stderr)
@@ -83,10 +85,10 @@ func (p *Player) Play(ctx context.Context, streamURL string) error {
 		return ctx.Err() // Cancelled
 	}
 	if err != nil {
-		return fmt.Errorf("ffmpeg error: %w", err)
+		return errs.New(errs.ErrPipeline, "ffmpeg error: %w", err)
 	}
 
-	fmt.Println("[Player] Playback finished")
+	logger.Infof("[Player] Playback finished")
 	return nil
 }
 
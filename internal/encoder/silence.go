@@ -0,0 +1,87 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// Silence is a silent stretch of a track, in seconds from its start.
+type Silence struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// DetectSilence runs a silencedetect pass over streamURL without encoding
+// any output, calling found for each stretch quieter than noiseDB (dBFS)
+// that lasts at least minDuration. The pass decodes faster than real time,
+// so stretches are usually found before playback reaches them. It blocks
+// until FFmpeg exits.
+func DetectSilence(ctx context.Context, runner execx.CommandRunner, streamURL string, noiseDB float64, minDuration time.Duration, found func(Silence)) error {
+	args := []string{
+		"-hide_banner", "-nostats",
+		"-reconnect", "1",
+		"-reconnect_streamed", "1",
+		"-reconnect_delay_max", "5",
+		"-i", streamURL,
+		"-vn", "-sn", "-dn",
+		"-af", fmt.Sprintf("silencedetect=noise=%gdB:d=%g", noiseDB, minDuration.Seconds()),
+		"-f", "null", "-",
+	}
+
+	cmd := runner.CommandContext(ctx, FFmpegPath(), args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return errs.New(errs.ErrPipeline, "detect silence: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return errs.New(errs.ErrPipeline, "detect silence: %w", err)
+	}
+
+	var tail bytes.Buffer // Last lines, for the error message
+	start := -1.0
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := silenceValue(line, "silence_start:"); ok {
+			start = max(value, 0) // Negative when silence opens the track
+			continue
+		}
+		if value, ok := silenceValue(line, "silence_end:"); ok && start >= 0 {
+			found(Silence{Start: start, End: value})
+			start = -1
+			continue
+		}
+		if tail.Len() > 1024 {
+			tail.Reset()
+		}
+		tail.WriteString(line + "\n")
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(tail.String()); msg != "" {
+			return errs.New(errs.ErrPipeline, "detect silence: %w: %s", err, msg)
+		}
+		return errs.New(errs.ErrPipeline, "detect silence: %w", err)
+	}
+	return nil
+}
+
+// silenceValue parses the number following key in a silencedetect line,
+// e.g. "[silencedetect @ 0x5581] silence_end: 42.1 | silence_duration: 8".
+func silenceValue(line, key string) (float64, bool) {
+	_, rest, ok := strings.Cut(line, key)
+	if !ok {
+		return 0, false
+	}
+	field, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value, err := strconv.ParseFloat(field, 64)
+	return value, err == nil
+}
@@ -0,0 +1,39 @@
+package encoder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const silencedetectOutput = `Input #0, matroska,webm, from 'https://stream.invalid':
+[silencedetect @ 0x5581] silence_start: -0.01
+[silencedetect @ 0x5581] silence_end: 2.5 | silence_duration: 2.51
+[silencedetect @ 0x5581] silence_start: 241.3
+[silencedetect @ 0x5581] silence_end: 600.02 | silence_duration: 358.72
+`
+
+func TestDetectSilence(t *testing.T) {
+	runner := fakeFFmpeg("cat >&2 <<'EOF'\n" + silencedetectOutput + "EOF")
+	var got []Silence
+	err := DetectSilence(context.Background(), runner, "https://stream.invalid", -50, 2*time.Second, func(s Silence) {
+		got = append(got, s)
+	})
+	if err != nil {
+		t.Fatalf("DetectSilence failed: %v", err)
+	}
+	want := []Silence{{Start: 0, End: 2.5}, {Start: 241.3, End: 600.02}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("silence %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	err = DetectSilence(context.Background(), fakeFFmpeg("echo 'Error opening input' >&2; exit 1"), "https://stream.invalid", -50, time.Second, func(Silence) {})
+	if err == nil {
+		t.Error("expected error when FFmpeg fails")
+	}
+}
@@ -0,0 +1,60 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+)
+
+// TapChannels is the channel count of the PCM analysis tap (mono downmix).
+const TapChannels = 1
+
+// TapFunc receives decoded PCM from the analysis tap: s16le, TapChannels
+// channels, at Config.SampleRate. It runs on the tap reader goroutine, must
+// not block, and must not retain pcm after returning.
+type TapFunc func(pcm []byte)
+
+// PCMTapper is implemented by pipelines that can expose a decoded PCM copy
+// of their output for analysis (levels, spectrum) regardless of Format.
+type PCMTapper interface {
+	// SetPCMTap registers fn to receive decoded PCM. Must be called before Start.
+	SetPCMTap(fn TapFunc)
+}
+
+// SetPCMTap registers the analysis tap callback. Must be called before Start.
+func (p *FFmpegPipeline) SetPCMTap(fn TapFunc) {
+	p.tap = fn
+}
+
+// tapArgs returns a second FFmpeg output writing mono PCM to fd 3
+// (the first entry of cmd.ExtraFiles).
+func (p *FFmpegPipeline) tapArgs() []string {
+	var mapping []string
+	if p.crossfade.Duration > 0 {
+		mapping = []string{"-map", "0:a"} // The track, not the crossfade tail
+	}
+	return append(mapping,
+		"-af", fmt.Sprintf("volume=%.2f", p.config.Volume),
+		"-ar", fmt.Sprintf("%d", p.config.SampleRate),
+		"-ac", fmt.Sprintf("%d", TapChannels),
+		"-f", "s16le",
+		"pipe:3",
+	)
+}
+
+// readTap forwards the analysis tap output to the tap callback.
+// It must keep reading until EOF, otherwise FFmpeg blocks on the tap
+// and stalls the main output too.
+func (p *FFmpegPipeline) readTap(r *os.File) {
+	defer r.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			p.tap(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// FFmpegVersion runs "ffmpeg -version" through runner and returns the
+// version string from its banner (e.g. "6.1.1").
+func FFmpegVersion(ctx context.Context, runner execx.CommandRunner) (string, error) {
+	if runner == nil {
+		runner = execx.Default
+	}
+	out, err := runner.CommandContext(ctx, FFmpegPath(), "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg -version: %w", err)
+	}
+
+	// First line: "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 ..."
+	line, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "ffmpeg" || fields[1] != "version" {
+		return "", fmt.Errorf("unexpected ffmpeg -version output: %q", line)
+	}
+	return fields[2], nil
+}
@@ -0,0 +1,67 @@
+// Package errs defines the error kinds shared by the platform, encoder and
+// server packages. Errors are tagged with a kind so callers can branch with
+// errors.Is, and the server can map them to HTTP statuses and socket events,
+// without matching on message text.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Error kinds.
+var (
+	ErrNotFound   = errors.New("not found")         // Session, video or playlist entry doesn't exist
+	ErrExtraction = errors.New("extraction failed") // yt-dlp or another extractor failed
+	ErrPipeline   = errors.New("pipeline failed")   // FFmpeg failed to start or died
+	ErrTransport  = errors.New("transport failed")  // Output couldn't be delivered to the client
+)
+
+// kindError tags err with kind. Its message is err's, so wrapping doesn't
+// change what users see.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// Wrap tags err with kind (one of the Err* values). It returns nil if err
+// is nil.
+func Wrap(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// New creates an error of the given kind with a formatted message. %w
+// verbs are honoured as in fmt.Errorf.
+func New(kind error, format string, args ...any) error {
+	return Wrap(kind, fmt.Errorf(format, args...))
+}
+
+// Code returns a short machine-readable code for err: "not_found",
+// "extraction", "pipeline", "transport", "timeout", "cancelled" or
+// "internal" for anything untagged.
+func Code(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrExtraction):
+		return "extraction"
+	case errors.Is(err, ErrPipeline):
+		return "pipeline"
+	case errors.Is(err, ErrTransport):
+		return "transport"
+	}
+	return "internal"
+}
@@ -0,0 +1,48 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrap_KeepsMessageAndKind(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := Wrap(ErrExtraction, cause)
+
+	if err.Error() != "exit status 1" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if !errors.Is(err, ErrExtraction) || !errors.Is(err, cause) {
+		t.Error("expected both the kind and the cause to match")
+	}
+	if errors.Is(err, ErrPipeline) {
+		t.Error("unexpected kind match")
+	}
+	if Wrap(ErrExtraction, nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+}
+
+func TestCode(t *testing.T) {
+	timeout := fmt.Errorf("timed out after 1s: %w", context.DeadlineExceeded)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{New(ErrNotFound, "session not found"), "not_found"},
+		{fmt.Errorf("play: %w", Wrap(ErrExtraction, errors.New("boom"))), "extraction"},
+		{New(ErrPipeline, "start ffmpeg: %w", errors.New("boom")), "pipeline"},
+		{Wrap(ErrTransport, errors.New("broken pipe")), "transport"},
+		{Wrap(ErrExtraction, timeout), "timeout"},
+		{context.Canceled, "cancelled"},
+		{errors.New("boom"), "internal"},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err); got != tt.want {
+			t.Errorf("Code(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package execx abstracts process creation for packages that shell out to
+// yt-dlp and FFmpeg, so tests can substitute fake binaries.
+package execx
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CommandRunner creates external commands.
+// Implementations may rewrite the binary or arguments (e.g. to run a fake).
+type CommandRunner interface {
+	CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+// RunnerFunc adapts a function to the CommandRunner interface.
+type RunnerFunc func(ctx context.Context, name string, args ...string) *exec.Cmd
+
+// CommandContext calls f(ctx, name, args...).
+func (f RunnerFunc) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return f(ctx, name, args...)
+}
+
+// ExecRunner runs the real binaries found in PATH.
+type ExecRunner struct{}
+
+// CommandContext returns exec.CommandContext(ctx, name, args...).
+func (ExecRunner) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// Default is the runner used by packages when none is configured.
+var Default CommandRunner = ExecRunner{}
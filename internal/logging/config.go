@@ -0,0 +1,89 @@
+package logging
+
+import "fmt"
+
+// Config selects sinks and levels, typically from the daemon's YAML config:
+//
+//	logging:
+//	  level: info
+//	  levels: {encoder: debug}
+//	  sinks:
+//	    - type: stdout
+//	    - {type: file, path: /var/log/music-bot.log, max_size_mb: 20, max_files: 3}
+//	    - {type: syslog, address: "logs.local:514", network: udp}
+//	    - {type: loki, url: "http://loki:3100/loki/api/v1/push", labels: {app: music-bot}}
+type Config struct {
+	Level  string            `yaml:"level"`  // Default level (default info)
+	Levels map[string]string `yaml:"levels"` // Per-component overrides
+	Sinks  []SinkConfig      `yaml:"sinks"`  // Default: stdout only
+}
+
+// SinkConfig configures one sink; fields apply per type.
+type SinkConfig struct {
+	Type string `yaml:"type"` // stdout, stderr, file, syslog or loki
+
+	// file
+	Path      string `yaml:"path"`
+	MaxSizeMB int    `yaml:"max_size_mb"`
+	MaxFiles  int    `yaml:"max_files"`
+
+	// syslog (empty network/address: local daemon)
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
+	Tag     string `yaml:"tag"`
+
+	// loki
+	URL    string            `yaml:"url"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// Configure applies config to the global logger. On error the previous
+// configuration stays in place.
+func Configure(config Config) error {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]Level, len(config.Levels))
+	for component, name := range config.Levels {
+		if overrides[component], err = ParseLevel(name); err != nil {
+			return fmt.Errorf("level for %s: %w", component, err)
+		}
+	}
+
+	var sinks []Sink
+	for _, sc := range config.Sinks {
+		sink, err := newSink(sc)
+		if err != nil {
+			closeAll(sinks)
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, NewStdoutSink())
+	}
+	return SetSinks(level, overrides, sinks...)
+}
+
+func newSink(sc SinkConfig) (Sink, error) {
+	switch sc.Type {
+	case "", "stdout":
+		return NewStdoutSink(), nil
+	case "stderr":
+		return NewStderrSink(), nil
+	case "file":
+		if sc.Path == "" {
+			return nil, fmt.Errorf("file log sink needs a path")
+		}
+		return NewFileSink(sc.Path, sc.MaxSizeMB, sc.MaxFiles)
+	case "syslog":
+		return NewSyslogSink(sc.Network, sc.Address, sc.Tag)
+	case "loki":
+		if sc.URL == "" {
+			return nil, fmt.Errorf("loki log sink needs a url")
+		}
+		return NewLokiSink(sc.URL, sc.Labels), nil
+	}
+	return nil, fmt.Errorf("unknown log sink %q", sc.Type)
+}
@@ -0,0 +1,159 @@
+// Package logging routes the engine's log messages to configurable sinks
+// (stdout, a rotating file, syslog, Loki) with per-component levels.
+//
+// Packages create a Logger for their component and log through it; the
+// process configures where messages go once at startup with Configure.
+// Until then, messages print to stdout exactly as written.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is a log severity.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lower-case level name.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses "debug", "info", "warn"/"warning" or "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// Entry is one log message.
+type Entry struct {
+	Time      time.Time
+	Level     Level
+	Component string // Package that logged it, e.g. "encoder"
+	Message   string // Formatted message, without trailing newline
+}
+
+// Sink writes log entries somewhere. Write is called from many goroutines.
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// router is the process-wide log configuration.
+type router struct {
+	mu        sync.RWMutex
+	level     Level
+	overrides map[string]Level
+	sinks     []Sink
+}
+
+var global = &router{level: LevelInfo, sinks: []Sink{NewStdoutSink()}}
+
+// SetSinks replaces the global configuration and closes the previous sinks.
+// overrides sets levels for individual components.
+func SetSinks(level Level, overrides map[string]Level, sinks ...Sink) error {
+	global.mu.Lock()
+	old := global.sinks
+	global.level = level
+	global.overrides = overrides
+	global.sinks = sinks
+	global.mu.Unlock()
+	return closeAll(old)
+}
+
+// Close flushes and closes the configured sinks, reverting to stdout.
+func Close() error {
+	return SetSinks(LevelInfo, nil, NewStdoutSink())
+}
+
+func closeAll(sinks []Sink) error {
+	var errs []error
+	for _, sink := range sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *router) enabled(component string, level Level) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	min, ok := r.overrides[component]
+	if !ok {
+		min = r.level
+	}
+	return level >= min
+}
+
+func (r *router) write(entry Entry) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, sink := range r.sinks {
+		if err := sink.Write(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "[Logging] %T write failed: %v\n", sink, err)
+		}
+	}
+}
+
+// Logger logs for one component.
+type Logger struct {
+	component string
+}
+
+// New returns the logger for component (typically the package name).
+func New(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// Debugf logs a debug message.
+func (l *Logger) Debugf(format string, args ...any) { l.log(LevelDebug, format, args...) }
+
+// Infof logs an informational message.
+func (l *Logger) Infof(format string, args ...any) { l.log(LevelInfo, format, args...) }
+
+// Warnf logs a warning.
+func (l *Logger) Warnf(format string, args ...any) { l.log(LevelWarn, format, args...) }
+
+// Errorf logs an error.
+func (l *Logger) Errorf(format string, args ...any) { l.log(LevelError, format, args...) }
+
+func (l *Logger) log(level Level, format string, args ...any) {
+	if !global.enabled(l.component, level) {
+		return
+	}
+	global.write(Entry{
+		Time:      time.Now(),
+		Level:     level,
+		Component: l.component,
+		Message:   strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"),
+	})
+}
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySink keeps entries for inspection.
+type memorySink struct {
+	mu      sync.Mutex
+	entries []Entry
+	closed  bool
+}
+
+func (s *memorySink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestLogger_LevelsAndOverrides(t *testing.T) {
+	sink := &memorySink{}
+	SetSinks(LevelInfo, map[string]Level{"encoder": LevelDebug, "server": LevelError}, sink)
+	defer Close()
+
+	New("encoder").Debugf("[FFmpeg] args %d\n", 3)
+	New("server").Warnf("[Session] dropped")
+	New("server").Errorf("[Session] failed")
+	New("youtube").Debugf("hidden")
+	New("youtube").Infof("[YouTube] shown")
+
+	var got []string
+	for _, e := range sink.entries {
+		got = append(got, e.Component+":"+e.Level.String()+":"+e.Message)
+	}
+	want := []string{"encoder:debug:[FFmpeg] args 3", "server:error:[Session] failed", "youtube:info:[YouTube] shown"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	Close()
+	if !sink.closed {
+		t.Error("expected Close to close the sink")
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	for _, config := range []Config{
+		{Level: "loud"},
+		{Levels: map[string]string{"encoder": "verbose"}},
+		{Sinks: []SinkConfig{{Type: "kafka"}}},
+		{Sinks: []SinkConfig{{Type: "file"}}},
+	} {
+		if err := Configure(config); err == nil {
+			t.Errorf("Configure(%+v): expected error", config)
+		}
+	}
+}
+
+func TestFileSink_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "music-bot.log")
+	sink, err := NewFileSink(path, 1, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	sink.maxSize = 200 // Bytes, so a few lines rotate
+
+	for i := 0; i < 10; i++ {
+		entry := Entry{Time: time.Now(), Level: LevelInfo, Component: "server", Message: strings.Repeat("x", 60)}
+		if err := sink.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	sink.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s is %d bytes, over the limit", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("expected at most 2 rotated files")
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "info  server: xxx") {
+		t.Errorf("unexpected line format: %q", data)
+	}
+}
+
+func TestLokiSink_PushesOnClose(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := NewLokiSink(srv.URL, map[string]string{"app": "music-bot"})
+	sink.Write(Entry{Time: time.Unix(0, 42), Level: LevelWarn, Component: "encoder", Message: "[FFmpeg] slow"})
+	sink.Close()
+
+	var payload struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid push body %q: %v", body, err)
+	}
+	if len(payload.Streams) != 1 {
+		t.Fatalf("expected one stream, got %+v", payload.Streams)
+	}
+	stream := payload.Streams[0]
+	if stream.Stream["app"] != "music-bot" || stream.Stream["component"] != "encoder" || stream.Stream["level"] != "warn" {
+		t.Errorf("unexpected labels %v", stream.Stream)
+	}
+	if len(stream.Values) != 1 || stream.Values[0] != [2]string{"42", "[FFmpeg] slow"} {
+		t.Errorf("unexpected values %v", stream.Values)
+	}
+}
+
+func TestLokiSink_ReportsFailedPushes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// Under daemon -stdio, stdout carries the audio protocol
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	sink := NewLokiSink(srv.URL, nil)
+	sink.Write(Entry{Time: time.Now(), Level: LevelInfo, Component: "server", Message: "a"})
+	sink.Write(Entry{Time: time.Now(), Level: LevelInfo, Component: "server", Message: "b"})
+	err = sink.Close()
+	if err == nil || !strings.Contains(err.Error(), "dropped 2 entries") || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("expected the dropped entries reported, got %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("expected the drop reported once, got %v", err)
+	}
+
+	os.Stdout = stdout
+	w.Close()
+	if data, _ := io.ReadAll(r); len(data) > 0 {
+		t.Errorf("expected nothing on stdout, got %q", data)
+	}
+}
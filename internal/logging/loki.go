@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Loki batching
+const (
+	lokiFlushInterval = time.Second
+	lokiMaxBatch      = 500
+)
+
+// LokiSink pushes entries to Grafana Loki's push API in batches. Each
+// stream is labelled with the configured labels plus component and level.
+// Failed pushes are reported by the next Write or Close.
+type LokiSink struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []Entry
+	dropped int   // Entries lost to failed pushes since the last report
+	pushErr error // Last push failure
+	flushCh chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+	stopped chan struct{}
+}
+
+// NewLokiSink creates a sink pushing to url (e.g.
+// http://loki:3100/loki/api/v1/push).
+func NewLokiSink(url string, labels map[string]string) *LokiSink {
+	s := &LokiSink{
+		url:     url,
+		labels:  labels,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues the entry for the next push. It returns an error once for
+// entries dropped by failed pushes since the last report.
+func (s *LokiSink) Write(entry Entry) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, entry)
+	full := len(s.pending) >= lokiMaxBatch
+	err := s.droppedLocked()
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// Close pushes what is queued and stops the sink, returning an error if
+// entries were dropped since the last report.
+func (s *LokiSink) Close() error {
+	s.closed.Do(func() { close(s.done) })
+	<-s.stopped
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.droppedLocked()
+}
+
+// droppedLocked returns and clears the report of dropped entries.
+func (s *LokiSink) droppedLocked() error {
+	if s.dropped == 0 {
+		return nil
+	}
+	err := fmt.Errorf("Loki push failed, dropped %d entries: %w", s.dropped, s.pushErr)
+	s.dropped, s.pushErr = 0, nil
+	return err
+}
+
+func (s *LokiSink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(lokiFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			s.flush()
+			return
+		case <-ticker.C:
+		case <-s.flushCh:
+		}
+		s.flush()
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// flush pushes the queued entries, grouped into streams by label set.
+// Entries are dropped if the push fails, so a dead Loki can't grow memory;
+// they are counted for the next Write or Close to report.
+func (s *LokiSink) flush() {
+	s.mu.Lock()
+	entries := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, entry := range entries {
+		key := entry.Component + "/" + entry.Level.String()
+		stream, ok := streams[key]
+		if !ok {
+			labels := map[string]string{"component": entry.Component, "level": entry.Level.String()}
+			for k, v := range s.labels {
+				labels[k] = v
+			}
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			order = append(order, key)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Message})
+	}
+
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range order {
+		payload.Streams = append(payload.Streams, streams[key])
+	}
+	if err := s.push(payload); err != nil {
+		s.mu.Lock()
+		s.dropped += len(entries)
+		s.pushErr = err
+		s.mu.Unlock()
+	}
+}
+
+func (s *LokiSink) push(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// StdoutSink prints messages as written, like the plain fmt.Printf logs it
+// replaces.
+type StdoutSink struct {
+	mu  sync.Mutex
+	out io.Writer // Nil writes to os.Stdout
+}
+
+// NewStdoutSink creates a sink writing to os.Stdout, looked up on each
+// write so it follows the daemon pointing stdout at stderr in stdio mode.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{}
+}
+
+// NewStderrSink creates a StdoutSink writing to os.Stderr, for when stdout
+// carries data.
+func NewStderrSink() *StdoutSink {
+	return &StdoutSink{out: os.Stderr}
+}
+
+// Write prints the message.
+func (s *StdoutSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, err := fmt.Fprintln(out, entry.Message)
+	return err
+}
+
+// Close does nothing; stdout stays open.
+func (s *StdoutSink) Close() error { return nil }
+
+// formatLine renders an entry with timestamp, level and component, for
+// sinks that are read outside the terminal.
+func formatLine(entry Entry) string {
+	return fmt.Sprintf("%s %-5s %s: %s",
+		entry.Time.Format(time.RFC3339Nano), entry.Level, entry.Component, entry.Message)
+}
+
+// FileSink appends to a file, rotating it when it grows past maxSize:
+// path is renamed to path.1 (path.1 to path.2, ...), keeping maxFiles old
+// files.
+type FileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Defaults for FileSink.
+const (
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 5
+)
+
+// NewFileSink opens (or creates) path for appending. Zero maxSizeMB or
+// maxFiles use the defaults (10MB, 5 files).
+func NewFileSink(path string, maxSizeMB, maxFiles int) (*FileSink, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+	s := &FileSink{path: path, maxSize: int64(maxSizeMB) << 20, maxFiles: maxFiles}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// Write appends the entry, rotating first if the file is full.
+func (s *FileSink) Write(entry Entry) error {
+	line := formatLine(entry) + "\n"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.WriteString(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate shifts path.N-1 → path.N ... path → path.1 and reopens path.
+func (s *FileSink) rotate() error {
+	s.file.Close()
+	s.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxFiles))
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	return s.open()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogSink forwards entries to syslog (local, or remote via network and
+// address).
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink connects to syslog. Empty network and address use the
+// local syslog daemon.
+func NewSyslogSink(network, address, tag string) (*SyslogSink, error) {
+	if tag == "" {
+		tag = "music-bot"
+	}
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+// Write sends the entry with the matching syslog severity.
+func (s *SyslogSink) Write(entry Entry) error {
+	msg := entry.Component + ": " + entry.Message
+	switch entry.Level {
+	case LevelDebug:
+		return s.writer.Debug(msg)
+	case LevelWarn:
+		return s.writer.Warning(msg)
+	case LevelError:
+		return s.writer.Err(msg)
+	}
+	return s.writer.Info(msg)
+}
+
+// Close closes the syslog connection.
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package logging
+
+import "errors"
+
+// NewSyslogSink reports that syslog is unavailable on this platform.
+func NewSyslogSink(network, address, tag string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
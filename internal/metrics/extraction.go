@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// Extraction operations (the op label).
+const (
+	OpStreamURL = "stream_url"
+	OpMetadata  = "metadata"
+	OpSearch    = "search"
+)
+
+// extractionBuckets spans a cached yt-dlp answer to a call near its timeout.
+var extractionBuckets = []float64{0.25, 0.5, 1, 2, 3, 5, 8, 13, 20, 30, 60}
+
+var (
+	extractionDuration = Default.NewHistogramVec("music_bot_extraction_duration_seconds",
+		"Time taken by platform extraction calls (yt-dlp), successful or not.",
+		extractionBuckets, "platform", "op")
+	extractionTotal = Default.NewCounterVec("music_bot_extraction_total",
+		"Platform extraction calls.", "platform", "op")
+	extractionFailures = Default.NewCounterVec("music_bot_extraction_failures_total",
+		"Failed platform extraction calls by error class (see errs.Code).", "platform", "op", "class")
+)
+
+// ObserveExtraction records an extraction call of op on platform that
+// started at start and returned err. Calls cancelled by the caller (a
+// replaced play, a closed request) say nothing about the platform and are
+// not recorded.
+func ObserveExtraction(platform, op string, start time.Time, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	extractionDuration.Observe(time.Since(start).Seconds(), platform, op)
+	extractionTotal.Inc(platform, op)
+	if err != nil {
+		extractionFailures.Inc(platform, op, errs.Code(err))
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := &Registry{}
+	calls := r.NewCounterVec("calls_total", "Calls.", "op")
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.5}, "op")
+
+	calls.Inc("b")
+	calls.Add(2, "a")
+	latency.Observe(0.2, "a")
+	latency.Observe(0.5, "a")
+	latency.Observe(3, "a")
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	want := `# HELP calls_total Calls.
+# TYPE calls_total counter
+calls_total{op="a"} 2
+calls_total{op="b"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="a",le="0.5"} 2
+latency_seconds_bucket{op="a",le="1"} 2
+latency_seconds_bucket{op="a",le="+Inf"} 3
+latency_seconds_sum{op="a"} 3.7
+latency_seconds_count{op="a"} 3
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestObserveExtraction(t *testing.T) {
+	start := time.Now()
+	ObserveExtraction("test", OpSearch, start, nil)
+	ObserveExtraction("test", OpSearch, start, errs.New(errs.ErrExtraction, "yt-dlp failed"))
+	ObserveExtraction("test", OpSearch, start, fmt.Errorf("yt-dlp failed: %w", context.DeadlineExceeded))
+	ObserveExtraction("test", OpSearch, start, context.Canceled)
+
+	if got := extractionDuration.Count("test", OpSearch); got != 3 {
+		t.Errorf("expected 3 timed calls (cancelled skipped), got %d", got)
+	}
+	if got := extractionFailures.Value("test", OpSearch, "extraction"); got != 1 {
+		t.Errorf("expected 1 extraction failure, got %v", got)
+	}
+	if got := extractionFailures.Value("test", OpSearch, "timeout"); got != 1 {
+		t.Errorf("expected 1 timeout, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	Default.Write(&buf)
+	if !strings.Contains(buf.String(), `music_bot_extraction_total{platform="test",op="search"} 3`) {
+		t.Errorf("expected the calls in the default registry, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,15 @@
+package metrics
+
+// Prefetch results (the result label).
+const (
+	PrefetchHit     = "hit"     // The play used the prefetched stream URL
+	PrefetchExpired = "expired" // The prefetched URL expired before the play
+)
+
+var prefetchPlays = Default.NewCounterVec("music_bot_prefetch_plays_total",
+	"Plays of queue tracks whose stream URL was prefetched, by result.", "result")
+
+// ObservePrefetch records the play of a prefetched track with result.
+func ObservePrefetch(result string) {
+	prefetchPlays.Inc(result)
+}
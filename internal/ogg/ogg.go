@@ -0,0 +1,150 @@
+// Package ogg parses Ogg page headers from the Opus byte stream produced by
+// FFmpeg, so callers can derive exact audio timing from granule positions
+// instead of estimating it from bitrate or wall-clock time.
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// OpusSampleRate is the granule position rate for Ogg Opus (always 48kHz).
+const OpusSampleRate = 48000
+
+const (
+	headerSize = 27 // Fixed part of an Ogg page header
+	maxPage    = headerSize + 255 + 255*255
+)
+
+var capturePattern = []byte("OggS")
+
+// Page is the parsed header of a complete Ogg page.
+type Page struct {
+	HeaderType      byte
+	GranulePosition int64  // -1 if no packet finishes on this page
+	Serial          uint32 // Bitstream serial number
+	Sequence        uint32 // Page sequence number
+	Data            []byte // The complete page (header + body)
+	bodyOffset      int
+}
+
+// Body returns the page payload without the header and segment table.
+func (p Page) Body() []byte {
+	return p.Data[p.bodyOffset:]
+}
+
+// Scanner splits an arbitrarily chunked byte stream into Ogg pages.
+// Chunks do not need to be page-aligned: partial pages are kept until the
+// rest arrives.
+type Scanner struct {
+	buf []byte
+}
+
+// Write feeds data to the scanner and returns the pages completed by it.
+func (s *Scanner) Write(data []byte) []Page {
+	s.buf = append(s.buf, data...)
+
+	var pages []Page
+	consumed := 0
+	for {
+		rest := s.buf[consumed:]
+		start := bytes.Index(rest, capturePattern)
+		if start < 0 {
+			// Keep a possible partial capture pattern at the end
+			if len(rest) > len(capturePattern)-1 {
+				consumed += len(rest) - (len(capturePattern) - 1)
+			}
+			break
+		}
+		rest = rest[start:]
+		consumed += start
+
+		if len(rest) < headerSize {
+			break
+		}
+		if rest[4] != 0 { // Stream structure version must be 0
+			consumed++ // False capture pattern, resync
+			continue
+		}
+		segments := int(rest[26])
+		if len(rest) < headerSize+segments {
+			break
+		}
+		bodySize := 0
+		for _, lacing := range rest[headerSize : headerSize+segments] {
+			bodySize += int(lacing)
+		}
+		size := headerSize + segments + bodySize
+		if len(rest) < size {
+			break
+		}
+
+		pages = append(pages, Page{
+			HeaderType:      rest[5],
+			GranulePosition: int64(binary.LittleEndian.Uint64(rest[6:14])),
+			Serial:          binary.LittleEndian.Uint32(rest[14:18]),
+			Sequence:        binary.LittleEndian.Uint32(rest[18:22]),
+			Data:            append([]byte(nil), rest[:size]...),
+			bodyOffset:      headerSize + segments,
+		})
+		consumed += size
+	}
+
+	// Compact so the buffer never holds more than one partial page
+	s.buf = append(s.buf[:0], s.buf[consumed:]...)
+	if len(s.buf) > maxPage {
+		s.buf = s.buf[:0]
+	}
+	return pages
+}
+
+// Reset discards any buffered partial page.
+func (s *Scanner) Reset() {
+	s.buf = s.buf[:0]
+}
+
+// OpusTracker follows an Ogg Opus stream and reports how much audio has been
+// encoded so far, based on the granule position of the last complete page.
+type OpusTracker struct {
+	scanner  Scanner
+	preSkip  int64 // Samples to discard at the start (from OpusHead)
+	granule  int64 // Granule position of the last complete audio page
+	hasAudio bool
+}
+
+// Write feeds a chunk of the Ogg stream to the tracker and returns the
+// duration of audio completed by this chunk.
+func (t *OpusTracker) Write(chunk []byte) time.Duration {
+	before := t.Position()
+	for _, page := range t.scanner.Write(chunk) {
+		if body := page.Body(); bytes.HasPrefix(body, []byte("OpusHead")) && len(body) >= 12 {
+			t.preSkip = int64(binary.LittleEndian.Uint16(body[10:12]))
+			continue
+		}
+		if page.GranulePosition <= 0 || page.GranulePosition < t.granule {
+			continue
+		}
+		t.granule = page.GranulePosition
+		t.hasAudio = true
+	}
+	return t.Position() - before
+}
+
+// Position returns the duration of audio encoded so far (0 until the first
+// audio page is seen).
+func (t *OpusTracker) Position() time.Duration {
+	if !t.hasAudio {
+		return 0
+	}
+	samples := t.granule - t.preSkip
+	if samples < 0 {
+		samples = 0
+	}
+	return time.Duration(samples) * time.Second / OpusSampleRate
+}
+
+// HasPosition reports whether at least one audio page has been seen.
+func (t *OpusTracker) HasPosition() bool {
+	return t.hasAudio
+}
@@ -0,0 +1,75 @@
+package ogg
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildPage builds a minimal Ogg page with a single segment.
+func buildPage(granule int64, sequence uint32, body []byte) []byte {
+	page := make([]byte, headerSize+1, headerSize+1+len(body))
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:18], 1)
+	binary.LittleEndian.PutUint32(page[18:22], sequence)
+	page[26] = 1
+	page[27] = byte(len(body))
+	return append(page, body...)
+}
+
+// opusHead builds an OpusHead packet with the given pre-skip.
+func opusHead(preSkip uint16) []byte {
+	head := []byte("OpusHead\x01\x02\x00\x00\x80\xbb\x00\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint16(head[10:12], preSkip)
+	return head
+}
+
+func TestScanner_SplitAcrossChunks(t *testing.T) {
+	stream := append(buildPage(0, 0, []byte("first")), buildPage(960, 1, []byte("second"))...)
+
+	var s Scanner
+	var pages []Page
+	for i := 0; i < len(stream); i += 7 {
+		end := min(i+7, len(stream))
+		pages = append(pages, s.Write(stream[i:end])...)
+	}
+
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if string(pages[0].Body()) != "first" || string(pages[1].Body()) != "second" {
+		t.Errorf("unexpected bodies %q, %q", pages[0].Body(), pages[1].Body())
+	}
+	if pages[1].GranulePosition != 960 || pages[1].Sequence != 1 {
+		t.Errorf("unexpected header %+v", pages[1])
+	}
+}
+
+func TestScanner_SkipsGarbage(t *testing.T) {
+	var s Scanner
+	pages := s.Write(append([]byte("garbageOgg"), buildPage(480, 3, []byte("x"))...))
+
+	if len(pages) != 1 || pages[0].GranulePosition != 480 {
+		t.Fatalf("expected page after garbage, got %+v", pages)
+	}
+}
+
+func TestOpusTracker_Position(t *testing.T) {
+	var tr OpusTracker
+	tr.Write(buildPage(0, 0, opusHead(312)))
+	tr.Write(buildPage(0, 1, []byte("OpusTags")))
+	if tr.HasPosition() {
+		t.Fatal("expected no position before audio pages")
+	}
+
+	tr.Write(buildPage(312+960, 2, []byte("audio")))
+	got := tr.Write(buildPage(312+48000, 3, []byte("audio")))
+
+	if tr.Position() != time.Second {
+		t.Errorf("expected position 1s, got %v", tr.Position())
+	}
+	if got != time.Second-20*time.Millisecond {
+		t.Errorf("expected chunk duration 980ms, got %v", got)
+	}
+}
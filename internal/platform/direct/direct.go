@@ -0,0 +1,59 @@
+// Package direct plays URLs and local files that FFmpeg can open as-is,
+// without a platform-specific extraction step.
+package direct
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// Extractor implements platform.StreamExtractor for direct media URLs
+// (http, https, file) and local file paths. Register it after the
+// platform extractors so it only catches what they don't handle.
+type Extractor struct{}
+
+// New creates a direct extractor.
+func New() *Extractor {
+	return &Extractor{}
+}
+
+// Name returns the platform name.
+func (e *Extractor) Name() string {
+	return "direct"
+}
+
+// CanHandle returns true for http(s)/file URLs and existing local files.
+func (e *Extractor) CanHandle(url string) bool {
+	url = strings.TrimSpace(url)
+	for _, scheme := range []string{"http://", "https://", "file://"} {
+		if strings.HasPrefix(strings.ToLower(url), scheme) {
+			return true
+		}
+	}
+	info, err := os.Stat(url)
+	return err == nil && !info.IsDir()
+}
+
+// ExtractStreamURL returns the URL unchanged, or the absolute path for a
+// local file.
+func (e *Extractor) ExtractStreamURL(url string) (string, error) {
+	url = strings.TrimSpace(url)
+	if strings.Contains(url, "://") {
+		return url, nil
+	}
+	path, err := filepath.Abs(url)
+	if err != nil {
+		return "", errs.New(errs.ErrExtraction, "resolve path: %w", err)
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", errs.New(errs.ErrNotFound, "local file: %w", err)
+	} else if err != nil {
+		return "", errs.New(errs.ErrExtraction, "local file: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,33 @@
+package direct
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractor_CanHandle(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "song.mp3")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New()
+	for url, want := range map[string]bool{
+		"https://example.com/stream.mp3":  true,
+		"HTTP://example.com/a.ogg":        true,
+		"file:///tmp/a.flac":              true,
+		file:                              true,
+		dir:                               false,
+		filepath.Join(dir, "missing.mp3"): false,
+	} {
+		if got := e.CanHandle(url); got != want {
+			t.Errorf("CanHandle(%q) = %v, want %v", url, got, want)
+		}
+	}
+
+	if got, err := e.ExtractStreamURL(file); err != nil || got != file {
+		t.Errorf("ExtractStreamURL(%q) = %q, %v", file, got, err)
+	}
+}
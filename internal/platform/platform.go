@@ -1,5 +1,12 @@
 package platform
 
+import (
+	"context"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/metrics"
+)
+
 // StreamExtractor defines the interface for extracting audio streams from various platforms.
 // This follows the Interface Segregation Principle (ISP) and Dependency Inversion Principle (DIP).
 type StreamExtractor interface {
@@ -13,6 +20,28 @@ type StreamExtractor interface {
 	Name() string
 }
 
+// ContextExtractor is implemented by extractors whose extraction can be
+// cancelled (e.g. to kill a hung yt-dlp when the request or session ends).
+type ContextExtractor interface {
+	ExtractStreamURLContext(ctx context.Context, url string) (string, error)
+}
+
+// ExtractStreamURL extracts url with extractor, passing ctx along when the
+// extractor supports cancellation. Calls are recorded in the extraction
+// metrics under the extractor's name.
+func ExtractStreamURL(ctx context.Context, extractor StreamExtractor, url string) (string, error) {
+	start := time.Now()
+	var streamURL string
+	var err error
+	if ce, ok := extractor.(ContextExtractor); ok {
+		streamURL, err = ce.ExtractStreamURLContext(ctx, url)
+	} else {
+		streamURL, err = extractor.ExtractStreamURL(url)
+	}
+	metrics.ObserveExtraction(extractor.Name(), metrics.OpStreamURL, start, err)
+	return streamURL, err
+}
+
 // URLValidator defines the interface for validating URLs.
 type URLValidator interface {
 	// IsValid returns true if the URL is valid for this platform
@@ -0,0 +1,143 @@
+package youtube
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// Channel top-track limits
+const (
+	defaultChannelLimit = 10
+	maxChannelLimit     = 50
+	channelScan         = 100 // Latest uploads ranked by views
+)
+
+// ChannelTracks is a channel's most viewed uploads.
+type ChannelTracks struct {
+	Channel string // Channel name, when yt-dlp reports it
+	Entries []PlaylistEntry
+}
+
+// channelVideosURL returns the videos tab of the channel at input: a
+// channel, handle, /c/ or /user/ URL (YouTube Music artist pages included),
+// an @handle, or a bare channel ID.
+func channelVideosURL(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	switch {
+	case strings.HasPrefix(input, "@"):
+		return "https://www.youtube.com/" + input + "/videos", nil
+	case len(input) == 24 && strings.HasPrefix(input, "UC"):
+		return "https://www.youtube.com/channel/" + input + "/videos", nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || !strings.HasSuffix(u.Hostname(), "youtube.com") {
+		return "", fmt.Errorf("not a YouTube channel URL: %s", input)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case strings.HasPrefix(parts[0], "@") && len(parts[0]) > 1:
+		return "https://www.youtube.com/" + parts[0] + "/videos", nil
+	case (parts[0] == "channel" || parts[0] == "c" || parts[0] == "user") && len(parts) > 1 && parts[1] != "":
+		return "https://www.youtube.com/" + parts[0] + "/" + parts[1] + "/videos", nil
+	}
+	return "", fmt.Errorf("not a YouTube channel URL: %s", input)
+}
+
+// IsChannel reports whether input names a YouTube channel or artist.
+func (e *Extractor) IsChannel(input string) bool {
+	_, err := channelVideosURL(input)
+	return err == nil
+}
+
+// ChannelTopTracks returns up to limit (default 10, max 50) of a channel's
+// most viewed videos. YouTube's own popularity sort isn't available to
+// yt-dlp, so the channel's latest uploads are ranked by view count instead.
+func (e *Extractor) ChannelTopTracks(ctx context.Context, channelURL string, limit int) (ChannelTracks, error) {
+	videosURL, err := channelVideosURL(channelURL)
+	if err != nil {
+		return ChannelTracks{}, err
+	}
+	if limit < 0 {
+		return ChannelTracks{}, fmt.Errorf("limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultChannelLimit
+	}
+	limit = min(limit, maxChannelLimit)
+
+	args := []string{
+		"--ignore-config",
+		"--flat-playlist",
+		"--no-warnings",
+		"--no-check-certificate",
+		"--socket-timeout", "15",
+		"--playlist-end", strconv.Itoa(channelScan),
+		"-j",
+	}
+
+	args = append(args, getJsRuntimeArgs()...)
+	args = append(args, getCookieArgs()...)
+	args = append(args, getGeoArgs(ctx)...)
+	args = append(args, videosURL)
+
+	type ranked struct {
+		entry PlaylistEntry
+		views int64
+	}
+	var entries []ranked
+	var tracks ChannelTracks
+
+	stderr, err := e.streamYtDlp(ctx, callTimeout(config.PlaylistTimeout, defaultPlaylistTimeout), args, func(line []byte) error {
+		var entry struct {
+			ID        string  `json:"id"`
+			Title     string  `json:"title"`
+			Duration  float64 `json:"duration"`
+			Thumbnail string  `json:"thumbnail"`
+			ViewCount int64   `json:"view_count"`
+			Channel   string  `json:"playlist_uploader"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil || isUnavailableVideo(entry.ID, entry.Title) {
+			return nil
+		}
+		if tracks.Channel == "" {
+			tracks.Channel = entry.Channel
+		}
+		thumbnail := entry.Thumbnail
+		if thumbnail == "" {
+			thumbnail = "https://i.ytimg.com/vi/" + entry.ID + "/mqdefault.jpg"
+		}
+		entries = append(entries, ranked{
+			entry: PlaylistEntry{
+				ID:        entry.ID,
+				URL:       "https://www.youtube.com/watch?v=" + entry.ID,
+				Title:     entry.Title,
+				Duration:  int(entry.Duration),
+				Thumbnail: thumbnail,
+			},
+			views: entry.ViewCount,
+		})
+		return nil
+	})
+	if err != nil {
+		return ChannelTracks{}, fmt.Errorf("yt-dlp channel failed: %w: %s", err, strings.TrimSpace(string(stderr)))
+	}
+	if len(entries) == 0 {
+		return ChannelTracks{}, errs.New(errs.ErrNotFound, "no playable videos found on channel")
+	}
+
+	// Stable, so uploads with equal (or unknown) views stay newest first
+	slices.SortStableFunc(entries, func(a, b ranked) int { return cmp.Compare(b.views, a.views) })
+	for _, r := range entries[:min(limit, len(entries))] {
+		tracks.Entries = append(tracks.Entries, r.entry)
+	}
+	return tracks, nil
+}
@@ -0,0 +1,57 @@
+package youtube
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestChannelVideosURL(t *testing.T) {
+	for input, want := range map[string]string{
+		"@LofiGirl": "https://www.youtube.com/@LofiGirl/videos",
+		"https://www.youtube.com/@LofiGirl/featured":                 "https://www.youtube.com/@LofiGirl/videos",
+		"https://youtube.com/channel/UCSJ4gkVC6NrvII8umztf0Ow":       "https://www.youtube.com/channel/UCSJ4gkVC6NrvII8umztf0Ow/videos",
+		"https://music.youtube.com/channel/UCSJ4gkVC6NrvII8umztf0Ow": "https://www.youtube.com/channel/UCSJ4gkVC6NrvII8umztf0Ow/videos",
+		"UCSJ4gkVC6NrvII8umztf0Ow":                                   "https://www.youtube.com/channel/UCSJ4gkVC6NrvII8umztf0Ow/videos",
+		"https://www.youtube.com/user/someone":                       "https://www.youtube.com/user/someone/videos",
+	} {
+		got, err := channelVideosURL(input)
+		if err != nil || got != want {
+			t.Errorf("channelVideosURL(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://example.com/@x", "lofi", "https://www.youtube.com/channel/"} {
+		if _, err := channelVideosURL(input); err == nil {
+			t.Errorf("expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestChannelTopTracks(t *testing.T) {
+	var gotArgs []string
+	e := NewWithRunner(execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotArgs = args
+		return exec.CommandContext(ctx, "printf", "%s", `{"id": "aaaaaaaaaaa", "title": "New", "view_count": 10, "playlist_uploader": "Artist"}
+{"id": "bbbbbbbbbbb", "title": "Hit", "view_count": 5000, "duration": 200.5}
+{"id": "ccccccccccc", "title": "[Private video]"}
+{"id": "ddddddddddd", "title": "Classic", "view_count": 900}
+`)
+	}))
+
+	tracks, err := e.ChannelTopTracks(context.Background(), "@Artist", 2)
+	if err != nil {
+		t.Fatalf("ChannelTopTracks failed: %v", err)
+	}
+	if !strings.HasSuffix(strings.Join(gotArgs, " "), "https://www.youtube.com/@Artist/videos") {
+		t.Errorf("expected the videos tab to be listed, got %v", gotArgs)
+	}
+	if tracks.Channel != "Artist" || len(tracks.Entries) != 2 {
+		t.Fatalf("unexpected tracks %+v", tracks)
+	}
+	if tracks.Entries[0].Title != "Hit" || tracks.Entries[0].Duration != 200 || tracks.Entries[1].Title != "Classic" {
+		t.Errorf("expected entries by views, got %+v", tracks.Entries)
+	}
+}
@@ -0,0 +1,256 @@
+package youtube
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieReport summarises the YouTube cookies in a Netscape cookies.txt.
+type CookieReport struct {
+	Path     string
+	Total    int  // YouTube/Google cookies in the file
+	Expired  int  // Of which expired
+	LoggedIn bool // LOGIN_INFO or a SID cookie is present and unexpired
+
+	// LoginExpires is when the first unexpired login cookie expires, an
+	// estimate of when the cookies stop working (zero if unknown).
+	LoginExpires time.Time
+}
+
+// CheckCookies reads the configured cookies file and reports on its
+// YouTube cookies. It returns nil when no file is configured (no cookies
+// or browser cookies, which yt-dlp reads itself).
+func CheckCookies(now time.Time) (*CookieReport, error) {
+	mode, path := cookieSource()
+	if mode != CookieModeFile && mode != CookieModeDefaultFile {
+		return nil, nil
+	}
+	return checkCookieFile(path, now)
+}
+
+// checkCookieFile reports on the YouTube cookies in the file at path.
+func checkCookieFile(path string, now time.Time) (*CookieReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report := &CookieReport{Path: path}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_") // Real cookie, flagged HttpOnly
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("%s is not a Netscape cookies.txt (bad line %q)", path, truncate(line, 40))
+		}
+		domain := fields[0]
+		if !strings.HasSuffix(domain, "youtube.com") && !strings.HasSuffix(domain, "google.com") {
+			continue
+		}
+		report.Total++
+		expiry, _ := strconv.ParseInt(fields[4], 10, 64)
+		if expiry != 0 && time.Unix(expiry, 0).Before(now) {
+			report.Expired++
+			continue
+		}
+		switch fields[5] {
+		case "LOGIN_INFO", "SID", "__Secure-3PSID":
+			report.LoggedIn = true
+			if expires := time.Unix(expiry, 0); expiry != 0 && (report.LoginExpires.IsZero() || expires.Before(report.LoginExpires)) {
+				report.LoginExpires = expires
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// staged maps each staged copy to the cookies file it was copied from.
+var staged struct {
+	sync.Mutex
+	sources map[string]string
+}
+
+// stagedPath returns where the copy of source is staged: runtimeCookiesPath
+// for the first (or only) cookies file, numbered next to it for the other
+// profiles.
+func stagedPath(source string) string {
+	if i := slices.Index(cookieProfiles(), source); i > 0 {
+		return strings.TrimSuffix(runtimeCookiesPath, ".txt") + "-" + strconv.Itoa(i+1) + ".txt"
+	}
+	return runtimeCookiesPath
+}
+
+// cookieFile returns the staged copy of source for yt-dlp, copying source
+// on first use (or if the copy was removed). yt-dlp's own updates to the
+// copy are kept until ReloadCookies. If source can't be copied, yt-dlp
+// reads it directly.
+func cookieFile(source string) string {
+	path := stagedPath(source)
+	staged.Lock()
+	defer staged.Unlock()
+	if staged.sources[path] == source {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	if err := stageCookiesLocked(source, path); err != nil {
+		return source
+	}
+	return path
+}
+
+// stageCookiesLocked replaces the copy at path with source. The copy is
+// renamed into place so a yt-dlp process starting meanwhile never reads a
+// partial file. Caller must hold staged.
+func stageCookiesLocked(source, path string) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if staged.sources == nil {
+		staged.sources = make(map[string]string)
+	}
+	staged.sources[path] = source
+	return nil
+}
+
+// stagedSource returns the cookies file behind path as given to yt-dlp: the
+// source of a staged copy, else path itself.
+func stagedSource(path string) string {
+	staged.Lock()
+	defer staged.Unlock()
+	if source, ok := staged.sources[path]; ok {
+		return source
+	}
+	return path
+}
+
+// ReloadCookies re-reads the configured cookies files and stages fresh
+// copies for yt-dlp, e.g. after they were refreshed. Profiles in cooldown
+// are tried again. yt-dlp calls already running finish with the old
+// cookies. If any file isn't a valid cookies.txt nothing is reloaded. It
+// reports on the file now in use, or nil with browser or no cookies.
+func ReloadCookies(now time.Time) (*CookieReport, error) {
+	mode, source := cookieSource()
+	if mode != CookieModeFile && mode != CookieModeDefaultFile {
+		return nil, nil
+	}
+	sources := cookieProfiles()
+	if mode == CookieModeDefaultFile {
+		sources = []string{source}
+	}
+	for _, path := range sources {
+		if _, err := checkCookieFile(path, now); err != nil {
+			return nil, err
+		}
+	}
+	staged.Lock()
+	for _, path := range sources {
+		if err := stageCookiesLocked(path, stagedPath(path)); err != nil {
+			staged.Unlock()
+			return nil, fmt.Errorf("stage cookies: %w", err)
+		}
+	}
+	staged.Unlock()
+	resetCooldowns()
+	return CheckCookies(now)
+}
+
+// Auth probe: the first entry of the watch history only loads for a
+// signed-in account, and a watch page reveals a Premium subscription.
+const (
+	authProbeFeed    = ":ythistory"
+	authProbeVideo   = "https://www.youtube.com/watch?v=jNQXAC9IVRw" // "Me at the zoo", always available
+	authProbeTimeout = 45 * time.Second
+)
+
+// AuthStatus is the result of CheckAuth.
+type AuthStatus struct {
+	LoggedIn bool   // YouTube accepted the cookies
+	Premium  bool   // The account has YouTube Premium
+	Reason   string // Why the cookies were rejected
+}
+
+// CheckAuth checks the configured cookies with one cheap authenticated
+// yt-dlp call (nothing is downloaded). Rejected or missing cookies are
+// reported in the status; err is for probes that couldn't tell (yt-dlp
+// missing, network errors, timeouts).
+func (e *Extractor) CheckAuth(ctx context.Context) (AuthStatus, error) {
+	cookies := getCookieArgs()
+	if cookies == nil {
+		return AuthStatus{Reason: "no cookies configured"}, nil
+	}
+	// -v prints yt-dlp's debug lines, which include the Premium detection
+	args := []string{"-v", "--skip-download", "--flat-playlist", "--playlist-end", "1", "--print", "id"}
+	args = append(args, cookies...)
+	args = append(args, getGeoArgs(ctx)...)
+	args = append(args, getJsRuntimeArgs()...)
+	args = append(args, authProbeVideo, authProbeFeed)
+
+	out, err := e.runYtDlp(ctx, callTimeout(config.Timeout, authProbeTimeout), args)
+	if err == nil {
+		return AuthStatus{LoggedIn: true, Premium: isPremium(out)}, nil
+	}
+	if ctx.Err() == nil && needsSignIn(out) {
+		return AuthStatus{Reason: lastError(out)}, nil
+	}
+	return AuthStatus{}, err
+}
+
+// isPremium reports whether yt-dlp's debug output detected a Premium
+// subscription.
+func isPremium(out []byte) bool {
+	return strings.Contains(strings.ToLower(string(out)), "youtube premium subscription")
+}
+
+// needsSignIn reports whether yt-dlp output says the account isn't signed
+// in (cookies missing, expired or rotated out).
+func needsSignIn(out []byte) bool {
+	text := strings.ToLower(string(out))
+	return strings.Contains(text, "requires authentication") ||
+		strings.Contains(text, "sign in") ||
+		strings.Contains(text, "login required") ||
+		strings.Contains(text, "cookies are no longer valid")
+}
+
+// lastError returns yt-dlp's last ERROR line, without the prefix.
+func lastError(out []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if msg, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "ERROR:"); ok {
+			return strings.TrimSpace(msg)
+		}
+	}
+	return "not signed in"
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
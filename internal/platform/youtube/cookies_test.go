@@ -0,0 +1,118 @@
+package youtube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckCookies(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	data := "# Netscape HTTP Cookie File\n" +
+		".youtube.com\tTRUE\t/\tTRUE\t1900000000\tLOGIN_INFO\tabc\n" +
+		"#HttpOnly_.youtube.com\tTRUE\t/\tTRUE\t1700000000\tVISITOR_INFO1_LIVE\tdef\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tother\tghi\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	SetConfig(Config{CookiesFile: path})
+	defer SetConfig(Config{})
+
+	report, err := CheckCookies(now)
+	if err != nil {
+		t.Fatalf("CheckCookies failed: %v", err)
+	}
+	if report.Total != 2 || report.Expired != 1 || !report.LoggedIn || report.LoginExpires != time.Unix(1900000000, 0) {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	os.WriteFile(path, []byte("not a cookie file\n"), 0o600)
+	if _, err := CheckCookies(now); err == nil {
+		t.Error("expected error for a malformed file")
+	}
+
+	SetConfig(Config{CookiesFromBrowser: "firefox"})
+	if report, err := CheckCookies(now); report != nil || err != nil {
+		t.Errorf("expected no report for browser cookies, got %+v, %v", report, err)
+	}
+}
+
+func TestReloadCookies(t *testing.T) {
+	dir := t.TempDir()
+	defer func(path string) { runtimeCookiesPath = path }(runtimeCookiesPath)
+	runtimeCookiesPath = filepath.Join(dir, "staged.txt")
+	staged.sources = nil
+
+	path := filepath.Join(dir, "cookies.txt")
+	first := ".youtube.com\tTRUE\t/\tTRUE\t0\tSID\tfirst\n"
+	if err := os.WriteFile(path, []byte(first), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	SetConfig(Config{CookiesFile: path})
+	defer SetConfig(Config{})
+
+	if got := cookieFile(path); got != runtimeCookiesPath {
+		t.Fatalf("expected the staged copy, got %s", got)
+	}
+	// The source changing doesn't touch the copy yt-dlp is using
+	second := ".youtube.com\tTRUE\t/\tTRUE\t0\tSID\tsecond\n"
+	os.WriteFile(path, []byte(second), 0o600)
+	cookieFile(path)
+	if data, _ := os.ReadFile(runtimeCookiesPath); string(data) != first {
+		t.Errorf("expected the first cookies until reload, got %q", data)
+	}
+
+	report, err := ReloadCookies(time.Now())
+	if err != nil {
+		t.Fatalf("ReloadCookies failed: %v", err)
+	}
+	if report == nil || report.Total != 1 || !report.LoggedIn {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if data, _ := os.ReadFile(runtimeCookiesPath); string(data) != second {
+		t.Errorf("expected the reloaded cookies, got %q", data)
+	}
+
+	os.WriteFile(path, []byte("garbage\n"), 0o600)
+	if _, err := ReloadCookies(time.Now()); err == nil {
+		t.Error("expected error for a malformed file")
+	}
+	if data, _ := os.ReadFile(runtimeCookiesPath); string(data) != second {
+		t.Errorf("expected a bad file to keep the staged cookies, got %q", data)
+	}
+}
+
+func TestCheckAuth(t *testing.T) {
+	SetConfig(Config{})
+	if status, err := NewWithRunner(fakeYtDlp(`exit 1`)).CheckAuth(t.Context()); err != nil || status.LoggedIn || status.Reason == "" {
+		t.Errorf("expected signed out without cookies, got %+v, %v", status, err)
+	}
+
+	SetConfig(Config{CookiesFromBrowser: "firefox"})
+	defer SetConfig(Config{})
+	tests := []struct {
+		script   string
+		loggedIn bool
+		premium  bool
+		reason   string
+		fails    bool
+	}{
+		{`echo "[debug] [youtube] Detected YouTube Premium subscription" >&2; echo jNQXAC9IVRw; echo abc`, true, true, "", false},
+		{`echo jNQXAC9IVRw; echo abc`, true, false, "", false},
+		{`echo "ERROR: [youtube:tab] ythistory: This feed requires authentication. Use --cookies" >&2; exit 1`, false, false,
+			"[youtube:tab] ythistory: This feed requires authentication. Use --cookies", false},
+		{`echo "ERROR: Unable to download webpage: connection refused" >&2; exit 1`, false, false, "", true},
+	}
+	for _, tt := range tests {
+		status, err := NewWithRunner(fakeYtDlp(tt.script)).CheckAuth(t.Context())
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: unexpected error %v", tt.script, err)
+			continue
+		}
+		if status.LoggedIn != tt.loggedIn || status.Premium != tt.premium || status.Reason != tt.reason {
+			t.Errorf("%s: unexpected status %+v", tt.script, status)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package youtube
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// regionKey is the context key for a per-request region (see WithRegion).
+type regionKey struct{}
+
+// WithRegion returns ctx with extraction made from region, a two-letter
+// country code overriding Config.GeoCountry (and picking its proxy from
+// Config.RegionProxies). An empty region keeps the configured one.
+func WithRegion(ctx context.Context, region string) context.Context {
+	if region == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, regionKey{}, strings.ToUpper(region))
+}
+
+// ValidRegion reports whether region is usable with WithRegion: empty, or
+// two ASCII letters.
+func ValidRegion(region string) bool {
+	if region == "" {
+		return true
+	}
+	if len(region) != 2 {
+		return false
+	}
+	for _, c := range strings.ToUpper(region) {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// geoFor returns the country and proxy extraction uses under ctx. The proxy
+// is the region's from RegionProxies, else Config.Proxy.
+func geoFor(ctx context.Context) (country, proxy string) {
+	country = strings.ToUpper(strings.TrimSpace(config.GeoCountry))
+	if region, ok := ctx.Value(regionKey{}).(string); ok {
+		country = region
+	}
+	proxy = config.Proxy
+	for region, regional := range config.RegionProxies {
+		if country != "" && strings.EqualFold(region, country) {
+			proxy = regional
+		}
+	}
+	return country, proxy
+}
+
+// getGeoArgs returns yt-dlp arguments for geo bypass: a faked
+// X-Forwarded-For from the country and the proxy, if configured.
+func getGeoArgs(ctx context.Context) []string {
+	country, proxy := geoFor(ctx)
+	var args []string
+	if country != "" {
+		args = append(args, "--xff", country)
+	}
+	if proxy != "" {
+		args = append(args, "--proxy", proxy)
+	}
+	return args
+}
+
+// StreamProxy returns the proxy a stream extracted under ctx must be
+// fetched through: YouTube ties stream URLs to the extracting IP. FFmpeg
+// only speaks HTTP proxies, so SOCKS proxies are used for extraction alone
+// and StreamProxy returns "" for them.
+func StreamProxy(ctx context.Context) string {
+	_, proxy := geoFor(ctx)
+	if u, err := url.Parse(proxy); err != nil || u.Scheme != "http" {
+		return ""
+	}
+	return proxy
+}
@@ -0,0 +1,66 @@
+package youtube
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestGeoArgs(t *testing.T) {
+	SetConfig(Config{
+		GeoCountry:    "us",
+		Proxy:         "socks5://127.0.0.1:1080",
+		RegionProxies: map[string]string{"de": "http://de.proxy.invalid:3128"},
+	})
+	defer SetConfig(Config{})
+
+	ctx := context.Background()
+	if args := getGeoArgs(ctx); !slices.Equal(args, []string{"--xff", "US", "--proxy", "socks5://127.0.0.1:1080"}) {
+		t.Errorf("unexpected global geo args %v", args)
+	}
+	if proxy := StreamProxy(ctx); proxy != "" {
+		t.Errorf("expected no stream proxy for SOCKS, got %s", proxy)
+	}
+
+	ctx = WithRegion(ctx, "de")
+	if args := getGeoArgs(ctx); !slices.Equal(args, []string{"--xff", "DE", "--proxy", "http://de.proxy.invalid:3128"}) {
+		t.Errorf("unexpected per-region geo args %v", args)
+	}
+	if proxy := StreamProxy(ctx); proxy != "http://de.proxy.invalid:3128" {
+		t.Errorf("expected the region's proxy for streaming, got %q", proxy)
+	}
+
+	SetConfig(Config{})
+	if args := getGeoArgs(context.Background()); args != nil {
+		t.Errorf("expected no geo args by default, got %v", args)
+	}
+}
+
+func TestExtractStreamURL_Region(t *testing.T) {
+	SetConfig(Config{RegionProxies: map[string]string{"JP": "http://jp.proxy.invalid:3128"}})
+	defer SetConfig(Config{})
+
+	var got []string
+	e := NewWithRunner(execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		got = args
+		return exec.CommandContext(ctx, "echo", "https://cdn.invalid/audio?mime=audio")
+	}))
+	if _, err := e.ExtractStreamURLContext(WithRegion(context.Background(), "jp"), "dQw4w9WgXcQ"); err != nil {
+		t.Fatalf("ExtractStreamURLContext failed: %v", err)
+	}
+	if joined := strings.Join(got, " "); !strings.Contains(joined, "--xff JP --proxy http://jp.proxy.invalid:3128") {
+		t.Errorf("expected geo args for JP, got %s", joined)
+	}
+}
+
+func TestValidRegion(t *testing.T) {
+	for region, want := range map[string]bool{"": true, "US": true, "de": true, "USA": false, "1A": false, "é": false} {
+		if got := ValidRegion(region); got != want {
+			t.Errorf("ValidRegion(%q) = %v, want %v", region, got, want)
+		}
+	}
+}
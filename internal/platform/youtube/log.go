@@ -0,0 +1,6 @@
+package youtube
+
+import "github.com/thewind121212/natashi/internal/logging"
+
+// logger is the extractor's log output (component "youtube").
+var logger = logging.New("youtube")
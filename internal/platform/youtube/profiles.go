@@ -0,0 +1,133 @@
+package youtube
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookieCooldown is how long a cookies profile YouTube blocked is skipped.
+const cookieCooldown = 30 * time.Minute
+
+// rotation tracks which cookies profile is in use and which are cooling
+// down after YouTube blocked them.
+var rotation struct {
+	sync.Mutex
+	active   string
+	cooldown map[string]time.Time // Blocked profiles, until when
+}
+
+// cookieProfiles returns the configured cookies files in rotation order:
+// CookiesFile, then CookieProfiles.
+func cookieProfiles() []string {
+	var profiles []string
+	for _, path := range append([]string{config.CookiesFile}, config.CookieProfiles...) {
+		if path = strings.TrimSpace(path); path != "" && !slices.Contains(profiles, path) {
+			profiles = append(profiles, path)
+		}
+	}
+	return profiles
+}
+
+// activeProfile returns the profile extractions use: the current one unless
+// it is cooling down, else the next one that isn't (or, if all are, the one
+// whose cooldown ends first).
+func activeProfile(profiles []string, now time.Time) string {
+	rotation.Lock()
+	defer rotation.Unlock()
+	return activeProfileLocked(profiles, now)
+}
+
+func activeProfileLocked(profiles []string, now time.Time) string {
+	start := max(slices.Index(profiles, rotation.active), 0)
+	soonest := ""
+	for i := range profiles {
+		profile := profiles[(start+i)%len(profiles)]
+		until := rotation.cooldown[profile]
+		if !now.Before(until) {
+			rotation.active = profile
+			return profile
+		}
+		if soonest == "" || until.Before(rotation.cooldown[soonest]) {
+			soonest = profile
+		}
+	}
+	rotation.active = soonest
+	return soonest
+}
+
+// isBlocked reports whether yt-dlp output says YouTube blocked the account
+// behind the cookies: bot detection or rate limiting.
+func isBlocked(out []byte) bool {
+	text := strings.ToLower(string(out))
+	return strings.Contains(text, "confirm you're not a bot") ||
+		strings.Contains(text, "confirm you’re not a bot") ||
+		strings.Contains(text, "http error 429") ||
+		strings.Contains(text, "too many requests")
+}
+
+// noteBlocked puts the cookies profile a failed yt-dlp call with args used
+// in cooldown if out says YouTube blocked it, so later calls rotate to the
+// next profile.
+func noteBlocked(args []string, out []byte) {
+	i := slices.Index(args, "--cookies")
+	if i < 0 || i+1 >= len(args) || !isBlocked(out) {
+		return
+	}
+	profile := stagedSource(args[i+1])
+	profiles := cookieProfiles()
+	if !slices.Contains(profiles, profile) {
+		return // The default cookies file; there is nothing to rotate to
+	}
+
+	now := time.Now()
+	rotation.Lock()
+	defer rotation.Unlock()
+	if rotation.cooldown == nil {
+		rotation.cooldown = make(map[string]time.Time)
+	}
+	rotation.cooldown[profile] = now.Add(cookieCooldown)
+	if next := activeProfileLocked(profiles, now); next != profile {
+		logger.Warnf("[YouTube] Cookies %s blocked, using %s (retrying it in %v)", filepath.Base(profile), filepath.Base(next), cookieCooldown)
+	} else {
+		logger.Warnf("[YouTube] Cookies %s blocked and no other profile is available", filepath.Base(profile))
+	}
+}
+
+// resetCooldowns makes every profile available again, e.g. after the
+// cookies files were refreshed.
+func resetCooldowns() {
+	rotation.Lock()
+	defer rotation.Unlock()
+	rotation.cooldown = nil
+}
+
+// CookieProfile is the rotation state of one cookies file.
+type CookieProfile struct {
+	Name          string     `json:"name"` // File name
+	Active        bool       `json:"active"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"` // Skipped until then after YouTube blocked it
+}
+
+// CookieProfiles reports the rotation state of the configured cookies
+// files, in rotation order.
+func CookieProfiles() []CookieProfile {
+	profiles := cookieProfiles()
+	if len(profiles) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rotation.Lock()
+	defer rotation.Unlock()
+	active := activeProfileLocked(profiles, now)
+	states := make([]CookieProfile, len(profiles))
+	for i, profile := range profiles {
+		states[i] = CookieProfile{Name: filepath.Base(profile), Active: profile == active}
+		if until, ok := rotation.cooldown[profile]; ok && now.Before(until) {
+			states[i].CooldownUntil = &until
+		}
+	}
+	return states
+}
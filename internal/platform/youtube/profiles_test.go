@@ -0,0 +1,65 @@
+package youtube
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCookieRotation(t *testing.T) {
+	dir := t.TempDir()
+	defer func(path string) { runtimeCookiesPath = path }(runtimeCookiesPath)
+	runtimeCookiesPath = filepath.Join(dir, "staged.txt")
+	staged.sources = nil
+	defer resetCooldowns()
+
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	for _, path := range []string{first, second} {
+		os.WriteFile(path, []byte(".youtube.com\tTRUE\t/\tTRUE\t0\tSID\t"+filepath.Base(path)+"\n"), 0o600)
+	}
+	SetConfig(Config{CookiesFile: first, CookieProfiles: []string{second}})
+	defer SetConfig(Config{})
+
+	args := getCookieArgs()
+	if !slices.Equal(args, []string{"--cookies", runtimeCookiesPath}) {
+		t.Fatalf("expected the first profile's staged copy, got %v", args)
+	}
+
+	// A failure that isn't a block keeps the profile
+	e := NewWithRunner(fakeYtDlp(`echo "ERROR: Video unavailable" >&2; exit 1`))
+	e.runYtDlp(t.Context(), time.Second, args)
+	if _, source := cookieSource(); source != first {
+		t.Errorf("expected %s still in use, got %s", first, source)
+	}
+
+	e = NewWithRunner(fakeYtDlp(`echo "ERROR: [youtube] abc: Sign in to confirm you're not a bot" >&2; exit 1`))
+	e.runYtDlp(t.Context(), time.Second, args)
+	if _, source := cookieSource(); source != second {
+		t.Errorf("expected rotation to %s, got %s", second, source)
+	}
+	next := getCookieArgs()
+	if next[1] == runtimeCookiesPath || stagedSource(next[1]) != second {
+		t.Errorf("expected the second profile's own staged copy, got %v", next)
+	}
+
+	profiles := CookieProfiles()
+	if len(profiles) != 2 || profiles[0].Active || profiles[0].CooldownUntil == nil || !profiles[1].Active {
+		t.Errorf("unexpected rotation state %+v", profiles)
+	}
+
+	// With every profile blocked, the one back soonest is used
+	e = NewWithRunner(fakeYtDlp(`echo "ERROR: HTTP Error 429: Too Many Requests" >&2; exit 1`))
+	e.runYtDlp(t.Context(), time.Second, next)
+	if _, source := cookieSource(); source != first {
+		t.Errorf("expected %s back first, got %s", first, source)
+	}
+
+	if _, err := ReloadCookies(time.Now()); err != nil {
+		t.Fatalf("ReloadCookies failed: %v", err)
+	}
+	if profiles := CookieProfiles(); profiles[0].CooldownUntil != nil || profiles[1].CooldownUntil != nil {
+		t.Errorf("expected reload to clear cooldowns, got %+v", profiles)
+	}
+}
@@ -0,0 +1,234 @@
+package youtube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/metrics"
+)
+
+// Search limits
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 50
+	maxSearchScan      = 200 // Most raw results one filtered page looks through
+)
+
+// uploadDates maps SearchOptions.UploadDate to YouTube's upload date filter.
+var uploadDates = map[string]byte{"hour": 1, "today": 2, "week": 3, "month": 4, "year": 5}
+
+// SearchResult represents a single search result.
+type SearchResult struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	Duration  int    `json:"duration"`
+	Thumbnail string `json:"thumbnail"`
+	Channel   string `json:"channel"`
+}
+
+// SearchOptions narrows and pages a search.
+type SearchOptions struct {
+	Limit       int    // Results per page (default 5, max 50)
+	Offset      int    // Raw results to skip; continue with SearchPage.NextOffset
+	MinDuration int    // Shortest duration in seconds (0 = no bound)
+	MaxDuration int    // Longest duration in seconds (0 = no bound)
+	UploadDate  string // Uploaded within the last hour, today, week, month or year ("" = any time)
+	Channel     string // Channel name or ID, case-insensitive ("" = any)
+}
+
+// Validate checks the filters.
+func (o SearchOptions) Validate() error {
+	if o.Limit < 0 || o.Offset < 0 || o.MinDuration < 0 || o.MaxDuration < 0 {
+		return fmt.Errorf("limit, offset and durations must not be negative")
+	}
+	if o.MaxDuration > 0 && o.MaxDuration < o.MinDuration {
+		return fmt.Errorf("max_duration must not be below min_duration")
+	}
+	if _, ok := uploadDates[o.UploadDate]; o.UploadDate != "" && !ok {
+		return fmt.Errorf("unknown upload date %q (want hour, today, week, month or year)", o.UploadDate)
+	}
+	return nil
+}
+
+// filtered reports whether some filters are applied to yt-dlp's results
+// rather than by YouTube, so a page may need more than Limit of them.
+func (o SearchOptions) filtered() bool {
+	return o.MinDuration > 0 || o.MaxDuration > 0 || o.Channel != ""
+}
+
+// matches reports whether r passes the duration and channel filters.
+func (o SearchOptions) matches(r SearchResult, channelID string) bool {
+	if o.MinDuration > 0 && r.Duration < o.MinDuration {
+		return false
+	}
+	if o.MaxDuration > 0 && (r.Duration == 0 || r.Duration > o.MaxDuration) {
+		return false // Live streams have no duration
+	}
+	if o.Channel != "" && !strings.EqualFold(r.Channel, o.Channel) && !strings.EqualFold(channelID, o.Channel) {
+		return false
+	}
+	return true
+}
+
+// SearchPage is one page of search results.
+type SearchPage struct {
+	Results    []SearchResult
+	NextOffset int // Offset of the next page (0 = no more results)
+}
+
+// searchURL returns the YouTube results page for query, limited to videos
+// and, if set, to an upload date. The sp parameter is YouTube's protobuf
+// encoded filter: field 2 holds the filters, with the upload date in field 1
+// and the result type in field 2.
+func searchURL(query, uploadDate string) string {
+	filters := []byte{0x10, 0x01} // Videos only
+	if date, ok := uploadDates[uploadDate]; ok {
+		filters = append([]byte{0x08, date}, filters...)
+	}
+	sp := append([]byte{0x12, byte(len(filters))}, filters...)
+	return "https://www.youtube.com/results?search_query=" + url.QueryEscape(query) +
+		"&sp=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sp))
+}
+
+// Search searches YouTube for videos matching the query.
+func (e *Extractor) Search(query string, limit int) ([]SearchResult, error) {
+	return e.SearchContext(context.Background(), query, limit)
+}
+
+// SearchContext is Search, cancelled with ctx.
+func (e *Extractor) SearchContext(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	page, err := e.SearchPage(ctx, query, SearchOptions{Limit: limit})
+	return page.Results, err
+}
+
+// SearchPage returns one page of videos matching query and opts. Duration
+// and channel filters are applied to YouTube's results, so a page scans
+// further ahead for them (up to maxSearchScan results).
+func (e *Extractor) SearchPage(ctx context.Context, query string, opts SearchOptions) (SearchPage, error) {
+	if err := opts.Validate(); err != nil {
+		return SearchPage{}, err
+	}
+	start := time.Now()
+	page, err := e.searchPage(ctx, query, opts)
+	metrics.ObserveExtraction(e.Name(), metrics.OpSearch, start, err)
+	return page, err
+}
+
+// searchPage is SearchPage for validated opts, without the metrics.
+func (e *Extractor) searchPage(ctx context.Context, query string, opts SearchOptions) (SearchPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	window := limit
+	if opts.filtered() {
+		window = min(limit*3, maxSearchLimit)
+	}
+
+	page := SearchPage{Results: make([]SearchResult, 0, limit)}
+	offset := opts.Offset
+	for scanned := 0; scanned < maxSearchScan; scanned += window {
+		entries, err := e.searchEntries(ctx, searchURL(query, opts.UploadDate), offset, window)
+		if err != nil {
+			return SearchPage{}, err
+		}
+		for _, entry := range entries {
+			offset++
+			if !opts.matches(entry.result, entry.channelID) {
+				continue
+			}
+			page.Results = append(page.Results, entry.result)
+			if len(page.Results) == limit {
+				page.NextOffset = offset
+				return page, nil
+			}
+		}
+		if len(entries) < window {
+			return page, nil // No more results
+		}
+	}
+	page.NextOffset = offset
+	return page, nil
+}
+
+// searchEntry is a search result with the fields only used for filtering.
+type searchEntry struct {
+	result    SearchResult
+	channelID string
+}
+
+// searchEntries lists up to count results of the search at resultsURL
+// after skipping offset of them.
+func (e *Extractor) searchEntries(ctx context.Context, resultsURL string, offset, count int) ([]searchEntry, error) {
+	args := []string{
+		"--ignore-config",
+		"--flat-playlist",
+		"--no-warnings",
+		"--no-check-certificate",
+		"--socket-timeout", "10",
+		"--playlist-items", fmt.Sprintf("%d:%d", offset+1, offset+count),
+		"-j",
+	}
+
+	args = append(args, getJsRuntimeArgs()...)
+	args = append(args, getCookieArgs()...)
+	args = append(args, getGeoArgs(ctx)...)
+	args = append(args, resultsURL)
+
+	out, err := e.runYtDlp(ctx, callTimeout(config.Timeout, defaultTimeout), args)
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp search failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	entries := make([]searchEntry, 0, len(lines))
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			ID        string  `json:"id"`
+			Title     string  `json:"title"`
+			Duration  float64 `json:"duration"` // yt-dlp may report fractional seconds
+			Thumbnail string  `json:"thumbnail"`
+			Channel   string  `json:"channel"`
+			ChannelID string  `json:"channel_id"`
+			Uploader  string  `json:"uploader"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+
+		thumbnail := entry.Thumbnail
+		if thumbnail == "" && entry.ID != "" {
+			thumbnail = "https://i.ytimg.com/vi/" + entry.ID + "/mqdefault.jpg"
+		}
+
+		channel := entry.Channel
+		if channel == "" {
+			channel = entry.Uploader
+		}
+
+		entries = append(entries, searchEntry{
+			result: SearchResult{
+				ID:        entry.ID,
+				URL:       "https://www.youtube.com/watch?v=" + entry.ID,
+				Title:     entry.Title,
+				Duration:  int(entry.Duration),
+				Thumbnail: thumbnail,
+				Channel:   channel,
+			},
+			channelID: entry.ChannelID,
+		})
+	}
+
+	return entries, nil
+}
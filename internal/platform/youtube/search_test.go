@@ -0,0 +1,93 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestSearchURL(t *testing.T) {
+	if got := searchURL("lofi beats", ""); got != "https://www.youtube.com/results?search_query=lofi+beats&sp=EgIQAQ%3D%3D" {
+		t.Errorf("unexpected videos-only URL %s", got)
+	}
+	if got := searchURL("lofi", "week"); !strings.HasSuffix(got, "&sp=EgQIAxAB") {
+		t.Errorf("expected the this-week filter, got %s", got)
+	}
+}
+
+// fakeSearch serves total search results through --playlist-items. Result
+// i is 60*i seconds long; odd results come from channel "Other".
+func fakeSearch(total int, calls *int) execx.CommandRunner {
+	return execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		*calls++
+		var start, end int
+		for i, arg := range args {
+			if arg == "--playlist-items" {
+				fmt.Sscanf(args[i+1], "%d:%d", &start, &end)
+			}
+		}
+		var out strings.Builder
+		for i := start; i <= min(end, total); i++ {
+			channel := "Lofi Girl"
+			if i%2 == 1 {
+				channel = "Other"
+			}
+			fmt.Fprintf(&out, `{"id": "video%05d", "title": "Track %d", "duration": %d.0, "channel": %q}`+"\n", i, i, 60*i, channel)
+		}
+		return exec.CommandContext(ctx, "printf", "%s", out.String())
+	})
+}
+
+func TestSearchPage_Pages(t *testing.T) {
+	var calls int
+	e := NewWithRunner(fakeSearch(12, &calls))
+
+	page, err := e.SearchPage(context.Background(), "lofi", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if len(page.Results) != 5 || page.Results[0].Title != "Track 1" || page.Results[0].Duration != 60 || page.NextOffset != 5 {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	page, err = e.SearchPage(context.Background(), "lofi", SearchOptions{Limit: 10, Offset: page.NextOffset})
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if len(page.Results) != 7 || page.Results[0].Title != "Track 6" || page.NextOffset != 0 {
+		t.Fatalf("expected the last 7 results and no next page, got %d results, next %d", len(page.Results), page.NextOffset)
+	}
+}
+
+func TestSearchPage_Filters(t *testing.T) {
+	var calls int
+	e := NewWithRunner(fakeSearch(100, &calls))
+
+	page, err := e.SearchPage(context.Background(), "lofi", SearchOptions{
+		Limit:       3,
+		MinDuration: 180,
+		MaxDuration: 900,
+		Channel:     "lofi girl",
+	})
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	var titles []string
+	for _, r := range page.Results {
+		titles = append(titles, r.Title)
+	}
+	if strings.Join(titles, ",") != "Track 4,Track 6,Track 8" || page.NextOffset != 8 {
+		t.Errorf("unexpected filtered page %v (next %d)", titles, page.NextOffset)
+	}
+	if calls != 1 {
+		t.Errorf("expected one yt-dlp call for a 9-result window, got %d", calls)
+	}
+
+	if _, err := e.SearchPage(context.Background(), "lofi", SearchOptions{UploadDate: "decade"}); err == nil {
+		t.Error("expected error for an unknown upload date")
+	}
+}
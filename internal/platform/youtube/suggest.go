@@ -0,0 +1,65 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// suggestEndpoint is YouTube's search suggestion API. The firefox client
+// answers with plain JSON: ["query", ["completion", ...]].
+const suggestEndpoint = "https://suggestqueries.google.com/complete/search?client=firefox&ds=yt&oe=utf-8"
+
+// suggestTimeout keeps suggestions well inside Discord's 3s autocomplete
+// deadline.
+const suggestTimeout = 2 * time.Second
+
+// Suggester completes search queries with YouTube's suggestion API. It
+// needs no yt-dlp, so it answers in well under a second.
+type Suggester struct {
+	Endpoint string // Overridable for tests
+	Client   *http.Client
+}
+
+// NewSuggester creates a Suggester for YouTube.
+func NewSuggester() *Suggester {
+	return &Suggester{
+		Endpoint: suggestEndpoint,
+		Client:   &http.Client{Timeout: suggestTimeout},
+	}
+}
+
+// Suggest returns completions for query, most likely first.
+func (s *Suggester) Suggest(ctx context.Context, query string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Endpoint+"&q="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, errs.New(errs.ErrExtraction, "suggest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.New(errs.ErrExtraction, "suggest: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, errs.New(errs.ErrExtraction, "suggest: %w", err)
+	}
+	var reply []json.RawMessage
+	if err := json.Unmarshal(body, &reply); err != nil || len(reply) < 2 {
+		return nil, errs.New(errs.ErrExtraction, "suggest: unexpected response %.100q", body)
+	}
+	var suggestions []string
+	if err := json.Unmarshal(reply[1], &suggestions); err != nil {
+		return nil, errs.New(errs.ErrExtraction, "suggest: parse suggestions: %w", err)
+	}
+	return suggestions, nil
+}
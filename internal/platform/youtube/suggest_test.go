@@ -0,0 +1,45 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+func TestSuggester_Suggest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "never gonna" || r.URL.Query().Get("ds") != "yt" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`["never gonna",["never gonna give you up","never gonna let you down"],[],{"google:suggestsubtypes":[[512],[512]]}]`))
+	}))
+	defer srv.Close()
+	s := NewSuggester()
+	s.Endpoint = srv.URL + "/complete/search?client=firefox&ds=yt"
+
+	got, err := s.Suggest(context.Background(), "never gonna")
+	if err != nil {
+		t.Fatalf("Suggest failed: %v", err)
+	}
+	if strings.Join(got, "|") != "never gonna give you up|never gonna let you down" {
+		t.Errorf("unexpected suggestions %q", got)
+	}
+}
+
+func TestSuggester_Failure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	s := NewSuggester()
+	s.Endpoint = srv.URL + "/?client=firefox"
+
+	if _, err := s.Suggest(context.Background(), "lofi"); !errors.Is(err, errs.ErrExtraction) {
+		t.Errorf("expected an extraction error, got %v", err)
+	}
+}
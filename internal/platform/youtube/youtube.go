@@ -1,12 +1,24 @@
 package youtube
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand/v2"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/metrics"
 )
 
 // Config holds YouTube extractor configuration.
@@ -15,13 +27,40 @@ type Config struct {
 	CookiesFromBrowser string
 	// CookiesFile path to cookies.txt file (alternative to browser cookies)
 	CookiesFile string
+	// CookieProfiles are more cookies files, rotated to in turn when YouTube
+	// blocks the one in use (bot detection, HTTP 429)
+	CookieProfiles []string
+	// GeoCountry is a two-letter country code yt-dlp fakes with an
+	// X-Forwarded-For header to get around region locks (--xff)
+	GeoCountry string
+	// Proxy is a proxy URL for yt-dlp and, if HTTP, for streaming (e.g. http://10.0.0.2:3128)
+	Proxy string
+	// RegionProxies selects a proxy by country code, for GeoCountry or a
+	// per-request region (see WithRegion), instead of Proxy
+	RegionProxies map[string]string
+	// Format is a preferred yt-dlp format selector, tried before the defaults
+	Format string
+	// Timeout bounds each yt-dlp call (default 45s)
+	Timeout time.Duration
+	// PlaylistTimeout bounds playlist listing, which is slower (default 2m)
+	PlaylistTimeout time.Duration
+	// Binary is the yt-dlp executable, e.g. as resolved by deps.Checker (default "yt-dlp")
+	Binary string
 }
 
 var config Config
 
+// runtimeCookiesPath is the writable copy of the cookies file handed to
+// yt-dlp (which rewrites it), in the OS temp directory. It is staged once
+// and replaced by ReloadCookies.
+var runtimeCookiesPath = filepath.Join(os.TempDir(), "yt-cookies.txt")
+
 const (
 	defaultCookiesPath = "/app/secrets/youtube_cookies.txt"
-	runtimeCookiesPath = "/tmp/yt-cookies.txt"
+
+	defaultTimeout         = 45 * time.Second
+	defaultPlaylistTimeout = 2 * time.Minute
+	versionTimeout         = 10 * time.Second
 )
 
 // SetConfig sets the YouTube extractor configuration.
@@ -29,52 +68,186 @@ func SetConfig(c Config) {
 	config = c
 }
 
-// LoadConfigFromEnv loads configuration from environment variables.
+// LoadConfigFromEnv loads configuration from environment variables. Set
+// variables override values from SetConfig.
 func LoadConfigFromEnv() {
-	config.CookiesFromBrowser = os.Getenv("YT_COOKIES_BROWSER")
-	config.CookiesFile = os.Getenv("YT_COOKIES_FILE")
+	if browser := os.Getenv("YT_COOKIES_BROWSER"); browser != "" {
+		config.CookiesFromBrowser = browser
+	}
+	if file := os.Getenv("YT_COOKIES_FILE"); file != "" {
+		config.CookiesFile = file
+	}
+	if profiles := os.Getenv("YT_COOKIES_PROFILES"); profiles != "" {
+		config.CookieProfiles = filepath.SplitList(profiles)
+	}
+	if country := os.Getenv("YT_GEO_COUNTRY"); country != "" {
+		config.GeoCountry = country
+	}
+	if proxy := os.Getenv("YT_PROXY"); proxy != "" {
+		config.Proxy = proxy
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("YT_TIMEOUT")); err == nil && timeout > 0 {
+		config.Timeout = timeout
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("YT_PLAYLIST_TIMEOUT")); err == nil && timeout > 0 {
+		config.PlaylistTimeout = timeout
+	}
 }
 
-// getCookieArgs returns yt-dlp arguments for cookie authentication.
-func getCookieArgs() []string {
-	cookiesFile := strings.TrimSpace(config.CookiesFile)
-	if cookiesFile != "" {
-		fmt.Printf("[YouTube] Using cookies file: %s\n", cookiesFile)
-		return []string{"--cookies", prepareCookieFile(cookiesFile)}
+// Binary returns the yt-dlp executable in use.
+func Binary() string {
+	if config.Binary != "" {
+		return config.Binary
 	}
+	return "yt-dlp"
+}
 
-	cookiesFromBrowser := strings.TrimSpace(config.CookiesFromBrowser)
-	if cookiesFromBrowser != "" {
-		fmt.Printf("[YouTube] Using cookies from browser: %s\n", cookiesFromBrowser)
-		return []string{"--cookies-from-browser", cookiesFromBrowser}
+// callTimeout returns the configured timeout, or fallback if unset.
+func callTimeout(configured, fallback time.Duration) time.Duration {
+	if configured > 0 {
+		return configured
 	}
+	return fallback
+}
+
+// Cookie modes reported by CookieMode.
+const (
+	CookieModeFile        = "file"
+	CookieModeBrowser     = "browser"
+	CookieModeDefaultFile = "default_file"
+	CookieModeNone        = "none"
+)
 
+// cookieSource returns the cookie mode in effect and its file path or
+// browser name.
+func cookieSource() (mode, source string) {
+	if profiles := cookieProfiles(); len(profiles) > 0 {
+		return CookieModeFile, activeProfile(profiles, time.Now())
+	}
+	if cookiesFromBrowser := strings.TrimSpace(config.CookiesFromBrowser); cookiesFromBrowser != "" {
+		return CookieModeBrowser, cookiesFromBrowser
+	}
 	if _, err := os.Stat(defaultCookiesPath); err == nil {
-		fmt.Printf("[YouTube] Using default cookies file: %s\n", defaultCookiesPath)
-		return []string{"--cookies", prepareCookieFile(defaultCookiesPath)}
+		return CookieModeDefaultFile, defaultCookiesPath
 	}
+	return CookieModeNone, ""
+}
 
-	return nil
+// CookieMode returns how yt-dlp is authenticated: "file", "browser",
+// "default_file" or "none".
+func CookieMode() string {
+	mode, _ := cookieSource()
+	return mode
 }
 
-func prepareCookieFile(sourcePath string) string {
-	data, err := os.ReadFile(sourcePath)
-	if err != nil {
-		return sourcePath
-	}
-	if err := os.WriteFile(runtimeCookiesPath, data, 0600); err != nil {
-		return sourcePath
+// getCookieArgs returns yt-dlp arguments for cookie authentication.
+func getCookieArgs() []string {
+	switch mode, source := cookieSource(); mode {
+	case CookieModeFile:
+		logger.Infof("[YouTube] Using cookies file: %s", source)
+		return []string{"--cookies", cookieFile(source)}
+	case CookieModeBrowser:
+		logger.Infof("[YouTube] Using cookies from browser: %s", source)
+		return []string{"--cookies-from-browser", source}
+	case CookieModeDefaultFile:
+		logger.Infof("[YouTube] Using default cookies file: %s", source)
+		return []string{"--cookies", cookieFile(source)}
 	}
-	return runtimeCookiesPath
+	return nil
 }
 
 // Extractor implements platform.StreamExtractor for YouTube.
 // Single Responsibility: Only handles YouTube stream extraction.
-type Extractor struct{}
+type Extractor struct {
+	runner execx.CommandRunner // Creates yt-dlp processes (replaceable in tests)
+}
 
 // New creates a new YouTube extractor.
 func New() *Extractor {
-	return &Extractor{}
+	return NewWithRunner(execx.Default)
+}
+
+// NewWithRunner creates a YouTube extractor that runs yt-dlp through runner.
+func NewWithRunner(runner execx.CommandRunner) *Extractor {
+	return &Extractor{runner: runner}
+}
+
+// runYtDlp runs yt-dlp with the given arguments and returns its combined
+// output. The process is killed when ctx is done or timeout elapses.
+func (e *Extractor) runYtDlp(ctx context.Context, timeout time.Duration, args []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := e.runner.CommandContext(ctx, Binary(), args...)
+	cmd.WaitDelay = time.Second // Don't wait on pipes held open by killed children
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		noteBlocked(args, out)
+	}
+	return out, ytDlpError(ctx, timeout, out, err)
+}
+
+// streamYtDlp runs yt-dlp like runYtDlp but hands each stdout line to fn
+// as soon as it is printed. It returns stderr. If fn fails, yt-dlp is
+// killed and fn's error returned.
+func (e *Extractor) streamYtDlp(ctx context.Context, timeout time.Duration, args []string, fn func(line []byte) error) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := e.runner.CommandContext(ctx, Binary(), args...)
+	cmd.WaitDelay = time.Second // Don't wait on pipes held open by killed children
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrExtraction, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errs.Wrap(errs.ErrExtraction, err)
+	}
+
+	var fnErr error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024) // -j lines can be large
+	for scanner.Scan() {
+		if fnErr = fn(scanner.Bytes()); fnErr != nil {
+			cancel()
+			break
+		}
+	}
+	err = cmd.Wait()
+	if fnErr != nil {
+		return stderr.Bytes(), fnErr
+	}
+	if err != nil {
+		noteBlocked(args, stderr.Bytes())
+	}
+	return stderr.Bytes(), ytDlpError(ctx, timeout, stderr.Bytes(), err)
+}
+
+// ytDlpError tags the error of a yt-dlp run: timeouts and failed
+// extractions as ErrExtraction, unavailable videos as ErrNotFound.
+func ytDlpError(ctx context.Context, timeout time.Duration, out []byte, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errs.New(errs.ErrExtraction, "timed out after %s: %w", timeout, ctx.Err())
+	}
+	if err != nil && ctx.Err() != nil {
+		return errs.Wrap(errs.ErrExtraction, ctx.Err())
+	}
+	if err != nil && isUnavailable(out) {
+		return errs.Wrap(errs.ErrNotFound, err)
+	}
+	return errs.Wrap(errs.ErrExtraction, err)
+}
+
+// isUnavailable reports whether yt-dlp output says the video doesn't
+// exist or can't be watched (deleted, private, removed).
+func isUnavailable(out []byte) bool {
+	text := strings.ToLower(string(out))
+	return strings.Contains(text, "video unavailable") ||
+		strings.Contains(text, "private video") ||
+		strings.Contains(text, "has been removed") ||
+		strings.Contains(text, "does not exist") // Channels
 }
 
 // Name returns the platform name.
@@ -96,6 +269,11 @@ func (e *Extractor) CanHandle(url string) bool {
 
 // ExtractStreamURL extracts the direct audio stream URL from a YouTube URL.
 func (e *Extractor) ExtractStreamURL(youtubeURL string) (string, error) {
+	return e.ExtractStreamURLContext(context.Background(), youtubeURL)
+}
+
+// ExtractStreamURLContext is ExtractStreamURL, cancelled with ctx.
+func (e *Extractor) ExtractStreamURLContext(ctx context.Context, youtubeURL string) (string, error) {
 	youtubeURL = normalizeYouTubeURL(youtubeURL)
 	args := []string{
 		"--ignore-config",
@@ -109,45 +287,133 @@ func (e *Extractor) ExtractStreamURL(youtubeURL string) (string, error) {
 
 	// Add cookie args for authenticated access (better quality)
 	args = append(args, getCookieArgs()...)
+	args = append(args, getGeoArgs(ctx)...)
 
-	// Try common audio format selectors first
+	// Try the preferred format, then common audio format selectors
 	formatSelectors := []string{"bestaudio/best", "bestaudio", "best"}
+	if config.Format != "" {
+		formatSelectors = append([]string{config.Format}, formatSelectors...)
+	}
 	for _, selector := range formatSelectors {
 		formatArgs := append(append([]string{}, args...), "-f", selector, "--get-url", youtubeURL)
-		url, err := runYtDlpGetURL(formatArgs)
+		url, err := e.runYtDlpGetURL(ctx, formatArgs)
 		if err == nil {
 			return url, nil
 		}
+		if ctx.Err() != nil {
+			return "", errs.New(errs.ErrExtraction, "yt-dlp failed: %w", ctx.Err())
+		}
 	}
 
 	// Fallback: no format selector (may return multiple URLs)
 	fallbackArgs := append(append([]string{}, args...), "--get-url", youtubeURL)
-	url, err := runYtDlpGetURL(fallbackArgs)
+	url, err := e.runYtDlpGetURL(ctx, fallbackArgs)
 	if err != nil {
 		return "", err
 	}
 	return url, nil
 }
 
-func getJsRuntimeArgs() []string {
-	if _, err := exec.LookPath("node"); err == nil {
-		return []string{"--js-runtimes", "node"}
+// JSRuntime returns the JavaScript runtime yt-dlp will use for YouTube's
+// player challenges ("node" or "deno"), or "" if neither is installed.
+func JSRuntime() string {
+	for _, name := range []string{"node", "deno"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
 	}
-	if _, err := exec.LookPath("deno"); err == nil {
-		return []string{"--js-runtimes", "deno"}
+	return ""
+}
+
+func getJsRuntimeArgs() []string {
+	if runtime := JSRuntime(); runtime != "" {
+		return []string{"--js-runtimes", runtime}
 	}
 	return nil
 }
 
+// Version returns the installed yt-dlp version (e.g. "2025.01.15").
+func (e *Extractor) Version(ctx context.Context) (string, error) {
+	out, err := e.runYtDlp(ctx, versionTimeout, []string{"--version"})
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp --version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // Metadata holds the JSON output from yt-dlp.
 type Metadata struct {
-	Title     string `json:"title"`
-	Duration  int    `json:"duration"`
-	Thumbnail string `json:"thumbnail"`
+	Title      string      `json:"title"`
+	Duration   int         `json:"duration"`
+	Thumbnail  string      `json:"thumbnail"`
+	Chapters   []Chapter   `json:"chapters,omitempty"`
+	Artist     string      `json:"artist,omitempty"`      // Music metadata, when YouTube knows it
+	Track      string      `json:"track,omitempty"`       // Music metadata, when YouTube knows it
+	Album      string      `json:"album,omitempty"`       // Music metadata, when YouTube knows it
+	Uploader   string      `json:"uploader,omitempty"`    // Channel name
+	UploadDate string      `json:"upload_date,omitempty"` // YYYYMMDD
+	ViewCount  int64       `json:"view_count,omitempty"`
+	LikeCount  int64       `json:"like_count,omitempty"`
+	AgeLimit   int         `json:"age_limit,omitempty"` // Minimum viewer age (18 = age-restricted)
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`
+}
+
+// Thumbnail is one available size of a video's thumbnail.
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// parseMetadata decodes yt-dlp's -j output. Only thumbnails with a known
+// size are kept, smallest first, and newer yt-dlp's artists list fills
+// Artist.
+func parseMetadata(out []byte) (*Metadata, error) {
+	var meta Metadata
+	if err := json.Unmarshal(out, &meta); err != nil {
+		return nil, err
+	}
+	var extra struct {
+		Artists []string `json:"artists"`
+	}
+	json.Unmarshal(out, &extra)
+	if meta.Artist == "" && len(extra.Artists) > 0 {
+		meta.Artist = strings.Join(extra.Artists, ", ")
+	}
+
+	sized := meta.Thumbnails[:0]
+	for _, thumb := range meta.Thumbnails {
+		if thumb.Width > 0 && thumb.Height > 0 {
+			sized = append(sized, thumb)
+		}
+	}
+	slices.SortStableFunc(sized, func(a, b Thumbnail) int { return a.Width - b.Width })
+	meta.Thumbnails = sized
+	return &meta, nil
+}
+
+// Chapter is a titled section of a video (times in seconds).
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
 }
 
 // ExtractMetadata extracts track metadata without downloading.
 func (e *Extractor) ExtractMetadata(youtubeURL string) (*Metadata, error) {
+	return e.ExtractMetadataContext(context.Background(), youtubeURL)
+}
+
+// ExtractMetadataContext is ExtractMetadata, cancelled with ctx.
+func (e *Extractor) ExtractMetadataContext(ctx context.Context, youtubeURL string) (*Metadata, error) {
+	start := time.Now()
+	meta, err := e.extractMetadata(ctx, youtubeURL)
+	metrics.ObserveExtraction(e.Name(), metrics.OpMetadata, start, err)
+	return meta, err
+}
+
+// extractMetadata is ExtractMetadataContext without the metrics.
+func (e *Extractor) extractMetadata(ctx context.Context, youtubeURL string) (*Metadata, error) {
 	youtubeURL = normalizeYouTubeURL(youtubeURL)
 	args := []string{
 		"--ignore-config",
@@ -161,18 +427,17 @@ func (e *Extractor) ExtractMetadata(youtubeURL string) (*Metadata, error) {
 
 	args = append(args, getJsRuntimeArgs()...)
 	args = append(args, getCookieArgs()...)
+	args = append(args, getGeoArgs(ctx)...)
 	args = append(args, youtubeURL)
 
-	cmd := exec.Command("yt-dlp", args...)
-
-	out, err := cmd.CombinedOutput()
+	out, err := e.runYtDlp(ctx, callTimeout(config.Timeout, defaultTimeout), args)
 	if err != nil {
 		return nil, fmt.Errorf("yt-dlp metadata failed: %w: %s", err, strings.TrimSpace(string(out)))
 	}
 
-	var meta Metadata
-	if err := json.Unmarshal(out, &meta); err != nil {
-		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	meta, err := parseMetadata(out)
+	if err != nil {
+		return nil, errs.New(errs.ErrExtraction, "failed to parse metadata: %w", err)
 	}
 
 	if meta.Thumbnail == "" {
@@ -181,7 +446,7 @@ func (e *Extractor) ExtractMetadata(youtubeURL string) (*Metadata, error) {
 		}
 	}
 
-	return &meta, nil
+	return meta, nil
 }
 
 // IsPlaylist checks if the URL is a YouTube playlist.
@@ -192,6 +457,7 @@ func (e *Extractor) IsPlaylist(youtubeURL string) bool {
 
 // PlaylistEntry represents a single video in a playlist.
 type PlaylistEntry struct {
+	ID        string `json:"id"`
 	URL       string `json:"url"`
 	Title     string `json:"title"`
 	Duration  int    `json:"duration"`
@@ -228,6 +494,55 @@ func isUnavailableVideo(id, title string) bool {
 // ExtractPlaylist extracts all videos from a YouTube playlist.
 // Deleted, private, and unavailable videos are automatically filtered out.
 func (e *Extractor) ExtractPlaylist(playlistURL string) ([]PlaylistEntry, error) {
+	return e.ExtractPlaylistContext(context.Background(), playlistURL)
+}
+
+// ExtractPlaylistContext is ExtractPlaylist, cancelled with ctx.
+func (e *Extractor) ExtractPlaylistContext(ctx context.Context, playlistURL string) ([]PlaylistEntry, error) {
+	page, err := e.ExtractPlaylistPage(ctx, playlistURL, PlaylistOptions{})
+	return page.Entries, err
+}
+
+// PlaylistOptions selects part of a playlist.
+type PlaylistOptions struct {
+	Limit   int  // Most entries to list (0 = all)
+	Offset  int  // Entries to skip from the start
+	Dedupe  bool // Drop repeats of a video already listed
+	Shuffle bool // Randomise the order (ExtractPlaylistPage only)
+}
+
+// PlaylistPage is part of a playlist.
+type PlaylistPage struct {
+	Entries    []PlaylistEntry
+	Total      int // Entries in the whole playlist, including unavailable ones (0 = unknown)
+	NextOffset int // Offset of the next page (0 = no more entries)
+}
+
+// ExtractPlaylistPage lists the entries of a YouTube playlist selected by
+// opts. Only the selected entries are fetched, so a page of a long playlist
+// returns quickly. Unavailable videos are filtered out, so a page can hold
+// fewer than opts.Limit entries.
+func (e *Extractor) ExtractPlaylistPage(ctx context.Context, playlistURL string, opts PlaylistOptions) (PlaylistPage, error) {
+	var entries []PlaylistEntry
+	page, err := e.StreamPlaylist(ctx, playlistURL, opts, func(entry PlaylistEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if opts.Shuffle {
+		rand.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+	}
+	page.Entries = entries
+	return page, err
+}
+
+// StreamPlaylist is ExtractPlaylistPage, but calls fn with each entry as
+// soon as yt-dlp lists it instead of returning them, so callers can start
+// on the first tracks of a large playlist right away. The returned page has
+// no Entries. If fn fails, extraction stops and fn's error is returned.
+func (e *Extractor) StreamPlaylist(ctx context.Context, playlistURL string, opts PlaylistOptions, fn func(PlaylistEntry) error) (PlaylistPage, error) {
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return PlaylistPage{}, fmt.Errorf("limit and offset must not be negative")
+	}
 	playlistURL = normalizeYouTubeURL(playlistURL)
 	args := []string{
 		"--ignore-config",
@@ -238,43 +553,52 @@ func (e *Extractor) ExtractPlaylist(playlistURL string) ([]PlaylistEntry, error)
 		"--socket-timeout", "15",
 		"-j", // JSON output per entry
 	}
+	if opts.Offset > 0 {
+		args = append(args, "--playlist-start", strconv.Itoa(opts.Offset+1))
+	}
+	if opts.Limit > 0 {
+		args = append(args, "--playlist-end", strconv.Itoa(opts.Offset+opts.Limit))
+	}
 
 	args = append(args, getJsRuntimeArgs()...)
 	args = append(args, getCookieArgs()...)
+	args = append(args, getGeoArgs(ctx)...)
 	args = append(args, playlistURL)
 
-	cmd := exec.Command("yt-dlp", args...)
-
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("yt-dlp playlist failed: %w: %s", err, strings.TrimSpace(string(out)))
-	}
-
 	// yt-dlp outputs one JSON per line for flat-playlist
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	entries := make([]PlaylistEntry, 0, len(lines))
+	found := 0
 	skippedCount := 0
+	listed := 0 // Entries yt-dlp returned, including unavailable ones
+	total := 0
+	seen := make(map[string]bool) // Video IDs listed so far, for Dedupe
+	var fnErr error
 
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+	stderr, err := e.streamYtDlp(ctx, callTimeout(config.PlaylistTimeout, defaultPlaylistTimeout), args, func(line []byte) error {
 		var entry struct {
 			ID        string `json:"id"`
 			Title     string `json:"title"`
 			Duration  int    `json:"duration"`
 			Thumbnail string `json:"thumbnail"`
 			URL       string `json:"url"`
+			Count     int    `json:"playlist_count"`
 		}
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue // Skip malformed entries
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil // Skip malformed entries
 		}
+		listed++
+		total = max(total, entry.Count)
 
 		// Filter out deleted/private/unavailable videos
 		if isUnavailableVideo(entry.ID, entry.Title) {
 			skippedCount++
-			fmt.Printf("[YouTube] Skipping unavailable video: %s (ID: %s)\n", entry.Title, entry.ID)
-			continue
+			logger.Infof("[YouTube] Skipping unavailable video: %s (ID: %s)", entry.Title, entry.ID)
+			return nil
+		}
+		if opts.Dedupe {
+			if seen[entry.ID] {
+				return nil
+			}
+			seen[entry.ID] = true
 		}
 
 		// Build full URL if only ID provided
@@ -290,35 +614,47 @@ func (e *Extractor) ExtractPlaylist(playlistURL string) ([]PlaylistEntry, error)
 			thumbnail = "https://i.ytimg.com/vi/" + entry.ID + "/mqdefault.jpg"
 		}
 
-		entries = append(entries, PlaylistEntry{
+		found++
+		fnErr = fn(PlaylistEntry{
+			ID:        entry.ID,
 			URL:       url,
 			Title:     entry.Title,
 			Duration:  entry.Duration,
 			Thumbnail: thumbnail,
 		})
+		return fnErr
+	})
+	if fnErr != nil {
+		return PlaylistPage{}, fnErr
+	}
+	if err != nil {
+		return PlaylistPage{}, fmt.Errorf("yt-dlp playlist failed: %w: %s", err, strings.TrimSpace(string(stderr)))
 	}
 
 	if skippedCount > 0 {
-		fmt.Printf("[YouTube] Filtered out %d unavailable video(s) from playlist\n", skippedCount)
+		logger.Infof("[YouTube] Filtered out %d unavailable video(s) from playlist", skippedCount)
 	}
 
-	if len(entries) == 0 {
-		return nil, fmt.Errorf("no playable videos found in playlist (all videos may be deleted or private)")
+	if found == 0 && opts.Offset == 0 {
+		return PlaylistPage{}, errs.New(errs.ErrNotFound, "no playable videos found in playlist (all videos may be deleted or private)")
 	}
 
-	return entries, nil
+	page := PlaylistPage{Total: total}
+	if opts.Limit > 0 && listed == opts.Limit && (total == 0 || opts.Offset+listed < total) {
+		page.NextOffset = opts.Offset + listed
+	}
+	return page, nil
 }
 
-func runYtDlpGetURL(args []string) (string, error) {
-	cmd := exec.Command("yt-dlp", args...)
-	out, err := cmd.CombinedOutput()
+func (e *Extractor) runYtDlpGetURL(ctx context.Context, args []string) (string, error) {
+	out, err := e.runYtDlp(ctx, callTimeout(config.Timeout, defaultTimeout), args)
 	if err != nil {
 		return "", fmt.Errorf("yt-dlp failed: %w: %s", err, strings.TrimSpace(string(out)))
 	}
 
 	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
 	if len(lines) == 0 {
-		return "", fmt.Errorf("yt-dlp returned empty URL")
+		return "", errs.New(errs.ErrExtraction, "yt-dlp returned empty URL")
 	}
 
 	// Prefer audio-only URL when multiple URLs are returned
@@ -358,6 +694,12 @@ func normalizeYouTubeURL(input string) string {
 	return trimmed
 }
 
+// VideoID returns the video ID of a YouTube URL or bare ID, or "" if value
+// is neither.
+func VideoID(value string) string {
+	return extractYouTubeID(value)
+}
+
 func extractYouTubeID(value string) string {
 	if isYouTubeID(value) {
 		return value
@@ -374,88 +716,3 @@ func extractYouTubeID(value string) string {
 	}
 	return ""
 }
-
-// SearchResult represents a single search result.
-type SearchResult struct {
-	ID        string `json:"id"`
-	URL       string `json:"url"`
-	Title     string `json:"title"`
-	Duration  int    `json:"duration"`
-	Thumbnail string `json:"thumbnail"`
-	Channel   string `json:"channel"`
-}
-
-// Search searches YouTube for videos matching the query.
-func (e *Extractor) Search(query string, limit int) ([]SearchResult, error) {
-	if limit <= 0 {
-		limit = 5
-	}
-	if limit > 10 {
-		limit = 10
-	}
-
-	searchQuery := fmt.Sprintf("ytsearch%d:%s", limit, query)
-
-	args := []string{
-		"--ignore-config",
-		"--flat-playlist",
-		"--no-warnings",
-		"--no-check-certificate",
-		"--socket-timeout", "10",
-		"-j",
-	}
-
-	args = append(args, getJsRuntimeArgs()...)
-	args = append(args, getCookieArgs()...)
-	args = append(args, searchQuery)
-
-	cmd := exec.Command("yt-dlp", args...)
-
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("yt-dlp search failed: %w: %s", err, strings.TrimSpace(string(out)))
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	results := make([]SearchResult, 0, len(lines))
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		var entry struct {
-			ID        string `json:"id"`
-			Title     string `json:"title"`
-			Duration  int    `json:"duration"`
-			Thumbnail string `json:"thumbnail"`
-			Channel   string `json:"channel"`
-			Uploader  string `json:"uploader"`
-		}
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue
-		}
-
-		url := "https://www.youtube.com/watch?v=" + entry.ID
-
-		thumbnail := entry.Thumbnail
-		if thumbnail == "" && entry.ID != "" {
-			thumbnail = "https://i.ytimg.com/vi/" + entry.ID + "/mqdefault.jpg"
-		}
-
-		channel := entry.Channel
-		if channel == "" {
-			channel = entry.Uploader
-		}
-
-		results = append(results, SearchResult{
-			ID:        entry.ID,
-			URL:       url,
-			Title:     entry.Title,
-			Duration:  entry.Duration,
-			Thumbnail: thumbnail,
-			Channel:   channel,
-		})
-	}
-
-	return results, nil
-}
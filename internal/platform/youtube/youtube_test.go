@@ -0,0 +1,167 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// fakeYtDlp returns a runner that replaces yt-dlp with a shell script.
+func fakeYtDlp(script string) execx.CommandRunner {
+	return execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	})
+}
+
+func TestExtractStreamURL_PrefersAudioURL(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`printf 'https://cdn.invalid/video?mime=video\nhttps://cdn.invalid/audio?mime=audio\n'`))
+
+	url, err := e.ExtractStreamURL("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatalf("ExtractStreamURL failed: %v", err)
+	}
+	if url != "https://cdn.invalid/audio?mime=audio" {
+		t.Errorf("expected audio URL, got %s", url)
+	}
+}
+
+func TestExtractStreamURL_Failure(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`echo "ERROR: Video unavailable" >&2; exit 1`))
+
+	if _, err := e.ExtractStreamURL("dQw4w9WgXcQ"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("expected not found error when the video is unavailable, got %v", err)
+	}
+
+	e = NewWithRunner(fakeYtDlp(`echo "ERROR: Sign in to confirm you're not a bot" >&2; exit 1`))
+	if _, err := e.ExtractStreamURL("dQw4w9WgXcQ"); !errors.Is(err, errs.ErrExtraction) {
+		t.Errorf("expected extraction error when yt-dlp fails, got %v", err)
+	}
+}
+
+func TestExtractMetadata_Timeout(t *testing.T) {
+	SetConfig(Config{Timeout: 100 * time.Millisecond})
+	defer SetConfig(Config{})
+	e := NewWithRunner(fakeYtDlp(`exec sleep 5`))
+
+	start := time.Now()
+	_, err := e.ExtractMetadata("dQw4w9WgXcQ")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+}
+
+func TestExtractStreamURLContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := NewWithRunner(fakeYtDlp(`exec sleep 5`))
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	// Cancelling stops the format fallbacks too, not just the running call
+	start := time.Now()
+	if _, err := e.ExtractStreamURLContext(ctx, "dQw4w9WgXcQ"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+}
+
+func TestExtractMetadata_Chapters(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`echo '{"title":"Mix","duration":600,"chapters":[{"title":"Intro","start_time":0,"end_time":95.5},{"title":"Main","start_time":95.5,"end_time":600}]}'`))
+
+	meta, err := e.ExtractMetadata("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+	if len(meta.Chapters) != 2 || meta.Chapters[1].Title != "Main" || meta.Chapters[1].StartTime != 95.5 {
+		t.Errorf("unexpected chapters %+v", meta.Chapters)
+	}
+}
+
+func TestExtractMetadata_Details(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`echo '{"title":"Song","duration":200,"uploader":"Label","artists":["A","B"],"track":"Song","album":"LP","upload_date":"20240131","view_count":12345,"like_count":67,"age_limit":18,"thumbnails":[{"url":"big","width":1280,"height":720},{"url":"unsized"},{"url":"small","width":120,"height":90}]}'`))
+
+	meta, err := e.ExtractMetadata("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+	if meta.Artist != "A, B" || meta.Album != "LP" || meta.Uploader != "Label" || meta.UploadDate != "20240131" {
+		t.Errorf("unexpected details %+v", meta)
+	}
+	if meta.ViewCount != 12345 || meta.LikeCount != 67 || meta.AgeLimit != 18 {
+		t.Errorf("unexpected counts %+v", meta)
+	}
+	if len(meta.Thumbnails) != 2 || meta.Thumbnails[0].URL != "small" || meta.Thumbnails[1].Width != 1280 {
+		t.Errorf("unexpected thumbnails %+v", meta.Thumbnails)
+	}
+}
+
+func TestExtractPlaylistPage(t *testing.T) {
+	var gotArgs []string
+	e := NewWithRunner(execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotArgs = args
+		return exec.CommandContext(ctx, "printf", "%s", `{"id": "aaaaaaaaaaa", "title": "One", "playlist_count": 2000}
+{"id": "bbbbbbbbbbb", "title": "[Deleted video]", "playlist_count": 2000}
+{"id": "ccccccccccc", "title": "Three", "playlist_count": 2000}
+`)
+	}))
+
+	page, err := e.ExtractPlaylistPage(context.Background(), "https://www.youtube.com/playlist?list=PL1", PlaylistOptions{Limit: 3, Offset: 100})
+	if err != nil {
+		t.Fatalf("ExtractPlaylistPage failed: %v", err)
+	}
+	args := strings.Join(gotArgs, " ")
+	if !strings.Contains(args, "--playlist-start 101") || !strings.Contains(args, "--playlist-end 103") {
+		t.Errorf("expected entries 101-103 to be requested, got %s", args)
+	}
+	if len(page.Entries) != 2 || page.Total != 2000 || page.NextOffset != 103 {
+		t.Errorf("unexpected page: %d entries, total %d, next %d", len(page.Entries), page.Total, page.NextOffset)
+	}
+}
+
+func TestStreamPlaylist_DeliversEntriesAsListed(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`echo '{"id": "aaaaaaaaaaa", "title": "One"}'; sleep 10; echo '{"id": "bbbbbbbbbbb", "title": "Two"}'`))
+	errStop := errors.New("stop")
+
+	start := time.Now()
+	var got []PlaylistEntry
+	_, err := e.StreamPlaylist(context.Background(), "https://www.youtube.com/playlist?list=PL1", PlaylistOptions{}, func(entry PlaylistEntry) error {
+		got = append(got, entry)
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+	if len(got) != 1 || got[0].URL != "https://www.youtube.com/watch?v=aaaaaaaaaaa" {
+		t.Errorf("unexpected entries %+v", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected yt-dlp to be stopped with the callback, took %v", elapsed)
+	}
+}
+
+func TestExtractPlaylistPage_DedupeAndShuffle(t *testing.T) {
+	e := NewWithRunner(fakeYtDlp(`for id in aaaaaaaaaaa bbbbbbbbbbb aaaaaaaaaaa ccccccccccc bbbbbbbbbbb; do echo "{\"id\": \"$id\", \"title\": \"$id\"}"; done`))
+
+	page, err := e.ExtractPlaylistPage(context.Background(), "https://www.youtube.com/playlist?list=PL1", PlaylistOptions{Dedupe: true, Shuffle: true})
+	if err != nil {
+		t.Fatalf("ExtractPlaylistPage failed: %v", err)
+	}
+	ids := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		ids[i] = entry.ID
+	}
+	slices.Sort(ids)
+	if strings.Join(ids, ",") != "aaaaaaaaaaa,bbbbbbbbbbb,ccccccccccc" {
+		t.Errorf("expected each video once, got %v", ids)
+	}
+}
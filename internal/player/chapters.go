@@ -0,0 +1,48 @@
+package player
+
+import "time"
+
+// restartChapterWithin is how far into a chapter "previous" still jumps to
+// the chapter before instead of restarting the current one.
+const restartChapterWithin = 3 * time.Second
+
+// Chapter is a titled section of a track.
+type Chapter struct {
+	Title string
+	Start time.Duration
+}
+
+// ChapterAt returns the index of the chapter playing at position, or -1
+// before the first chapter. Chapters must be sorted by Start.
+func ChapterAt(chapters []Chapter, position time.Duration) int {
+	index := -1
+	for i, c := range chapters {
+		if c.Start > position {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// NextChapter returns the index of the chapter after the one at position,
+// or -1 if there is none.
+func NextChapter(chapters []Chapter, position time.Duration) int {
+	if next := ChapterAt(chapters, position) + 1; next < len(chapters) {
+		return next
+	}
+	return -1
+}
+
+// PreviousChapter returns the chapter to jump back to: the start of the
+// current chapter, or the one before if playback is near its start.
+func PreviousChapter(chapters []Chapter, position time.Duration) int {
+	current := ChapterAt(chapters, position)
+	if current < 0 {
+		return -1
+	}
+	if position-chapters[current].Start < restartChapterWithin && current > 0 {
+		return current - 1
+	}
+	return current
+}
@@ -0,0 +1,39 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChapterNavigation(t *testing.T) {
+	chapters := []Chapter{
+		{Title: "Intro", Start: 0},
+		{Title: "Verse", Start: 30 * time.Second},
+		{Title: "Outro", Start: 90 * time.Second},
+	}
+
+	tests := []struct {
+		position       time.Duration
+		at, next, prev int
+	}{
+		{0, 0, 1, 0},
+		{31 * time.Second, 1, 2, 0}, // Near chapter start: back to the previous
+		{45 * time.Second, 1, 2, 1}, // Mid-chapter: restart it
+		{100 * time.Second, 2, -1, 2},
+	}
+	for _, tt := range tests {
+		if got := ChapterAt(chapters, tt.position); got != tt.at {
+			t.Errorf("ChapterAt(%v) = %d, want %d", tt.position, got, tt.at)
+		}
+		if got := NextChapter(chapters, tt.position); got != tt.next {
+			t.Errorf("NextChapter(%v) = %d, want %d", tt.position, got, tt.next)
+		}
+		if got := PreviousChapter(chapters, tt.position); got != tt.prev {
+			t.Errorf("PreviousChapter(%v) = %d, want %d", tt.position, got, tt.prev)
+		}
+	}
+
+	if got := ChapterAt(nil, time.Minute); got != -1 {
+		t.Errorf("expected -1 without chapters, got %d", got)
+	}
+}
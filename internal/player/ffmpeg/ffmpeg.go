@@ -4,22 +4,60 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"music-bot/internal/player"
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+// Volume limits for SetVolume (0% - 200%).
+const (
+	minVolume = 0.0
+	maxVolume = 2.0
 )
 
 // Player implements player.AudioPlayer using FFmpeg.
 // Single Responsibility: Only handles audio playback via FFmpeg.
+// It also implements player.Controller: seeking and volume changes restart
+// FFmpeg from the current position. Pausing suspends FFmpeg where the OS
+// allows (SIGSTOP) and otherwise stops it and restarts at the same position
+// on resume.
 type Player struct {
 	config player.Config
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	volume    float64
+	base      time.Duration // Stream position the current FFmpeg started at
+	startedAt time.Time
+	pausedAt  time.Time     // Zero unless paused
+	pausedFor time.Duration // Time spent paused since startedAt
+	startAt   time.Duration // One-off start offset for the next Play (SetStartAt)
+	held      bool          // Paused by stopping FFmpeg (suspend unavailable)
+	restart   chan time.Duration
+	resumed   chan struct{} // Signalled when a held pause ends
 }
 
 // New creates a new FFmpeg player with the given configuration.
 func New(config player.Config) *Player {
-	return &Player{config: config}
+	config.Volume = math.Max(minVolume, math.Min(maxVolume, config.Volume))
+	if config.Logf == nil {
+		config.Logf = func(format string, args ...any) {
+			fmt.Printf("[INFO] "+format+"\n", args...)
+		}
+	}
+	return &Player{
+		config:  config,
+		volume:  config.Volume,
+		restart: make(chan time.Duration, 1),
+		resumed: make(chan struct{}, 1),
+	}
 }
 
 // NewDefault creates a new FFmpeg player with default configuration.
@@ -34,77 +72,263 @@ func (p *Player) Name() string {
 
 // Play starts playing the audio from the given stream URL.
 func (p *Player) Play(ctx context.Context, streamURL string) error {
-	cmd := p.buildCommand(streamURL)
-	cmd.Stderr = os.Stderr // show ffmpeg progress in terminal
+	// Drop a seek left over from the previous track
+	select {
+	case <-p.restart:
+	default:
+	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("stdout pipe failed: %w", err)
+	p.mu.Lock()
+	position := p.config.StartAt
+	if p.startAt > 0 {
+		position, p.startAt = p.startAt, 0
 	}
+	p.mu.Unlock()
+	for {
+		p.mu.Lock()
+		volume := p.volume
+		p.mu.Unlock()
+
+		cmd := p.buildCommand(streamURL, position, volume)
+		cmd.Stderr = os.Stderr // show ffmpeg progress in terminal
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return fmt.Errorf("stdout pipe failed: %w", err)
+		}
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("FFmpeg failed to start: %w", err)
+		}
+
+		p.config.Logf("FFmpeg running (PID: %d)", cmd.Process.Pid)
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("FFmpeg failed to start: %w", err)
+		p.mu.Lock()
+		p.cmd = cmd
+		p.base = position
+		p.startedAt = time.Now()
+		p.pausedAt = time.Time{}
+		p.pausedFor = 0
+		p.mu.Unlock()
+
+		// Drain stdout to prevent pipe blocking
+		go io.Copy(io.Discard, stdout)
+
+		// Wait for either context cancellation or command completion
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		select {
+		case <-ctx.Done():
+			p.config.Logf("Stopping...")
+			cmd.Process.Kill()
+			<-done
+			p.clearCommand()
+			p.config.Logf("Done.")
+			return ctx.Err()
+		case position = <-p.restart:
+			// Seek, volume change or held pause: restart FFmpeg at the new position
+			cmd.Process.Kill()
+			<-done
+			if !p.waitWhileHeld(ctx) {
+				p.clearCommand()
+				return ctx.Err()
+			}
+			select {
+			case position = <-p.restart: // Seeked while held
+			default:
+			}
+		case err := <-done:
+			p.clearCommand()
+			if err != nil {
+				return fmt.Errorf("FFmpeg exited: %w", err)
+			}
+			p.config.Logf("Playback finished.")
+			return nil
+		}
 	}
+}
 
-	fmt.Printf("[INFO] FFmpeg running (PID: %d)\n", cmd.Process.Pid)
+// SetStartAt makes the next Play start at offset instead of Config.StartAt.
+func (p *Player) SetStartAt(offset time.Duration) {
+	p.mu.Lock()
+	p.startAt = offset
+	p.mu.Unlock()
+}
 
-	// Drain stdout to prevent pipe blocking
-	go io.Copy(io.Discard, stdout)
+// clearCommand forgets the finished FFmpeg process and its position.
+func (p *Player) clearCommand() {
+	p.mu.Lock()
+	p.cmd = nil
+	p.base = 0
+	p.startedAt = time.Time{}
+	p.mu.Unlock()
+}
 
-	// Wait for either context cancellation or command completion
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
+// TogglePause pauses or resumes playback and reports whether it is now paused.
+func (p *Player) TogglePause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return false
+	}
+	if p.pausedAt.IsZero() {
+		if err := suspend(p.cmd.Process); err != nil {
+			// Stop FFmpeg instead; Play restarts it here on resume
+			p.held = true
+			p.requestRestartLocked(p.positionLocked())
+		}
+		p.pausedAt = time.Now()
+		return true
+	}
+	if p.held {
+		p.held = false
+		select {
+		case p.resumed <- struct{}{}:
+		default:
+		}
+	} else {
+		unsuspend(p.cmd.Process)
+	}
+	p.pausedFor += time.Since(p.pausedAt)
+	p.pausedAt = time.Time{}
+	return false
+}
 
-	select {
-	case <-ctx.Done():
-		fmt.Println("\n[INFO] Stopping...")
-		cmd.Process.Kill()
-		fmt.Println("[INFO] Done.")
-		return ctx.Err()
-	case err := <-done:
-		if err != nil {
-			return fmt.Errorf("FFmpeg exited: %w", err)
+// waitWhileHeld blocks while a pause has FFmpeg stopped. Returns false if
+// ctx is cancelled first.
+func (p *Player) waitWhileHeld(ctx context.Context) bool {
+	for {
+		p.mu.Lock()
+		held := p.held
+		p.mu.Unlock()
+		if !held {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-p.resumed:
 		}
-		fmt.Println("[INFO] Playback finished.")
-		return nil
 	}
 }
 
+// Position returns the current playback position in the stream.
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.positionLocked()
+}
+
+func (p *Player) positionLocked() time.Duration {
+	if p.startedAt.IsZero() {
+		return p.base
+	}
+	now := time.Now()
+	if !p.pausedAt.IsZero() {
+		now = p.pausedAt
+	}
+	return p.base + now.Sub(p.startedAt) - p.pausedFor
+}
+
+// Seek moves playback by offset (negative to go back) and returns the new position.
+func (p *Player) Seek(offset time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	position := max(p.positionLocked()+offset, 0)
+	p.requestRestartLocked(position)
+	return position
+}
+
+// Volume returns the current volume multiplier (1.0 = 100%).
+func (p *Player) Volume() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.volume
+}
+
+// SetVolume changes the volume (clamped to 0.0-2.0) and returns the value applied.
+func (p *Player) SetVolume(volume float64) float64 {
+	volume = math.Round(math.Max(minVolume, math.Min(maxVolume, volume))*100) / 100
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if volume != p.volume {
+		p.volume = volume
+		p.requestRestartLocked(p.positionLocked())
+	}
+	return volume
+}
+
+// requestRestartLocked asks Play to restart FFmpeg at position, replacing
+// any restart still pending.
+func (p *Player) requestRestartLocked(position time.Duration) {
+	if p.cmd == nil {
+		return
+	}
+	select {
+	case <-p.restart:
+	default:
+	}
+	p.restart <- position
+}
+
 // buildCommand creates the FFmpeg command based on the current OS.
-func (p *Player) buildCommand(streamURL string) *exec.Cmd {
+func (p *Player) buildCommand(streamURL string, position time.Duration, volume float64) *exec.Cmd {
 	channels := fmt.Sprintf("%d", p.config.Channels)
 	sampleRate := fmt.Sprintf("%d", p.config.SampleRate)
 	device := p.config.Device
 
+	args := []string{"-nostdin"} // Keys belong to the CLI controls, not ffmpeg
+	if p.config.LogLevel != "" {
+		args = append(args, "-loglevel", p.config.LogLevel, "-nostats")
+	}
+	if position > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(position.Seconds(), 'f', 3, 64))
+	}
+	args = append(args, "-i", streamURL)
+	var filters []string
+	if p.config.Filter != "" {
+		filters = append(filters, p.config.Filter)
+	}
+	if volume != 1.0 {
+		filters = append(filters, fmt.Sprintf("volume=%.2f", volume))
+	}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+
+	ffmpeg := p.config.FFmpegPath
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
 	switch runtime.GOOS {
 	case "linux":
 		// PulseAudio (most modern Linux)
-		return exec.Command("ffmpeg",
-			"-i", streamURL,
+		return exec.Command(ffmpeg, append(args,
 			"-f", "pulse",
 			"-ac", channels,
 			"-ar", sampleRate,
 			device,
-		)
+		)...)
 
 	case "darwin":
 		// macOS AudioToolbox
-		return exec.Command("ffmpeg",
-			"-i", streamURL,
+		return exec.Command(ffmpeg, append(args,
 			"-f", "audiotoolbox",
 			"-ac", channels,
 			"-ar", sampleRate,
 			device,
-		)
+		)...)
 
 	default: // windows
 		// DirectSound - default audio device
-		return exec.Command("ffmpeg",
-			"-i", streamURL,
+		return exec.Command(ffmpeg, append(args,
 			"-f", "dshow",
 			"-ac", channels,
 			"-ar", sampleRate,
 			"audio=@device_pk_{00000000-0000-0000-0000-000000000000}",
-		)
+		)...)
 	}
 }
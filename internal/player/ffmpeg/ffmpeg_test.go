@@ -0,0 +1,47 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+func TestBuildCommand_PositionAndVolume(t *testing.T) {
+	p := NewDefault()
+
+	args := strings.Join(p.buildCommand("http://stream", 0, 1.0).Args, " ")
+	if strings.Contains(args, "-ss") || strings.Contains(args, "-af") {
+		t.Errorf("expected no seek or volume filter by default, got %q", args)
+	}
+
+	args = strings.Join(p.buildCommand("http://stream", 90*time.Second, 1.5).Args, " ")
+	if !strings.Contains(args, "-ss 90.000 -i http://stream -af volume=1.50") {
+		t.Errorf("expected seek before input and volume filter, got %q", args)
+	}
+}
+
+func TestBuildCommand_Filter(t *testing.T) {
+	config := player.DefaultConfig()
+	config.Filter = "bass=g=8:f=100"
+	p := New(config)
+
+	args := strings.Join(p.buildCommand("http://stream", 0, 0.5).Args, " ")
+	if !strings.Contains(args, "-af bass=g=8:f=100,volume=0.50") {
+		t.Errorf("expected filter chain before volume, got %q", args)
+	}
+}
+
+func TestSetVolume_Clamps(t *testing.T) {
+	p := New(player.DefaultConfig())
+	if v := p.SetVolume(3); v != maxVolume {
+		t.Errorf("expected %v, got %v", maxVolume, v)
+	}
+	if v := p.SetVolume(-1); v != minVolume {
+		t.Errorf("expected %v, got %v", minVolume, v)
+	}
+	if v := p.SetVolume(0.30000001); v != 0.3 {
+		t.Errorf("expected 0.3, got %v", v)
+	}
+}
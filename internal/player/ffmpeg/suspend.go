@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+
+package ffmpeg
+
+import (
+	"os"
+	"syscall"
+)
+
+// suspend stops proc in place (SIGSTOP) so pause keeps its exact position.
+func suspend(proc *os.Process) error {
+	return proc.Signal(syscall.SIGSTOP)
+}
+
+// unsuspend continues a process stopped by suspend.
+func unsuspend(proc *os.Process) error {
+	return proc.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package ffmpeg
+
+import (
+	"errors"
+	"os"
+)
+
+// suspend reports that processes cannot be stopped in place here; pause
+// falls back to stopping FFmpeg and restarting it on resume.
+func suspend(proc *os.Process) error {
+	return errors.ErrUnsupported
+}
+
+// unsuspend is never reached without suspend.
+func unsuspend(proc *os.Process) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,80 @@
+package player
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseM3U reads a queue from an M3U/M3U8 playlist or a plain list of URLs,
+// one per line. Blank lines and comments are skipped; #EXTINF titles and
+// durations are kept. Relative local paths are resolved against baseDir.
+func ParseM3U(r io.Reader, baseDir string) ([]Track, error) {
+	var tracks []Track
+	title, duration := "", 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:<duration>[ attributes],<title>
+			if i := strings.Index(line, ","); i >= 0 {
+				title = strings.TrimSpace(line[i+1:])
+				duration = extinfDuration(line[len("#EXTINF:"):i])
+			}
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		}
+
+		url := line
+		if !strings.Contains(url, "://") && !filepath.IsAbs(url) && baseDir != "" && looksLikePath(url) {
+			url = filepath.Join(baseDir, url)
+		}
+		tracks = append(tracks, Track{URL: url, Title: title, Duration: duration})
+		title, duration = "", 0
+	}
+	return tracks, scanner.Err()
+}
+
+// extinfDuration parses the duration of an #EXTINF line, which is -1 (or
+// missing) when unknown and may be followed by attributes.
+func extinfDuration(field string) int {
+	field, _, _ = strings.Cut(strings.TrimSpace(field), " ")
+	seconds, err := strconv.ParseFloat(field, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return int(seconds)
+}
+
+// WriteM3U writes tracks as an extended M3U playlist.
+func WriteM3U(w io.Writer, tracks []Track) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	for _, t := range tracks {
+		if t.Title != "" || t.Duration > 0 {
+			duration := t.Duration
+			if duration == 0 {
+				duration = -1
+			}
+			// A line break in a title would start a new entry
+			title := strings.Join(strings.Fields(t.Title), " ")
+			fmt.Fprintf(bw, "#EXTINF:%d,%s\n", duration, title)
+		}
+		bw.WriteString(t.URL + "\n")
+	}
+	return bw.Flush()
+}
+
+// looksLikePath reports whether a list entry is a file path rather than a
+// bare identifier such as a YouTube video ID.
+func looksLikePath(entry string) bool {
+	return strings.ContainsAny(entry, `/\`) || filepath.Ext(entry) != ""
+}
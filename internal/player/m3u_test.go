@@ -0,0 +1,66 @@
+package player
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	list := `#EXTM3U
+#EXTINF:212,Rick Astley - Never Gonna Give You Up
+https://www.youtube.com/watch?v=dQw4w9WgXcQ
+
+# plain entries
+music/song.mp3
+/abs/track.flac
+dQw4w9WgXcQ
+`
+	tracks, err := ParseM3U(strings.NewReader(list), "/home/me")
+	if err != nil {
+		t.Fatalf("ParseM3U failed: %v", err)
+	}
+
+	want := []Track{
+		{URL: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", Title: "Rick Astley - Never Gonna Give You Up", Duration: 212},
+		{URL: "/home/me/music/song.mp3"},
+		{URL: "/abs/track.flac"},
+		{URL: "dQw4w9WgXcQ"},
+	}
+	if len(tracks) != len(want) {
+		t.Fatalf("expected %d tracks, got %+v", len(want), tracks)
+	}
+	for i := range want {
+		if tracks[i] != want[i] {
+			t.Errorf("track %d: expected %+v, got %+v", i, want[i], tracks[i])
+		}
+	}
+}
+
+func TestWriteM3U_RoundTrip(t *testing.T) {
+	tracks := []Track{
+		{URL: "https://www.youtube.com/watch?v=a", Title: "First", Duration: 180},
+		{URL: "https://www.youtube.com/watch?v=b", Title: "Two\nlines"},
+		{URL: "https://www.youtube.com/watch?v=c"},
+	}
+	var b strings.Builder
+	if err := WriteM3U(&b, tracks); err != nil {
+		t.Fatalf("WriteM3U failed: %v", err)
+	}
+	if !strings.Contains(b.String(), "#EXTINF:-1,Two lines\n") {
+		t.Errorf("expected an unknown duration and a one-line title, got:\n%s", b.String())
+	}
+
+	parsed, err := ParseM3U(strings.NewReader(b.String()), "")
+	if err != nil {
+		t.Fatalf("ParseM3U failed: %v", err)
+	}
+	tracks[1].Title = "Two lines"
+	if len(parsed) != len(tracks) {
+		t.Fatalf("expected %d tracks, got %+v", len(tracks), parsed)
+	}
+	for i := range tracks {
+		if parsed[i] != tracks[i] {
+			t.Errorf("track %d: expected %+v, got %+v", i, tracks[i], parsed[i])
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package player
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // AudioPlayer defines the interface for playing audio streams.
 // This follows the Dependency Inversion Principle (DIP).
@@ -13,11 +16,43 @@ type AudioPlayer interface {
 	Name() string
 }
 
+// Controller is implemented by players that can be controlled while Play
+// is running. All methods are safe to call from other goroutines.
+type Controller interface {
+	// TogglePause pauses or resumes playback and reports whether it is now paused.
+	TogglePause() bool
+
+	// Seek moves playback by offset and returns the new position.
+	Seek(offset time.Duration) time.Duration
+
+	// Position returns the current playback position.
+	Position() time.Duration
+
+	// Volume returns the current volume multiplier (1.0 = 100%).
+	Volume() float64
+
+	// SetVolume changes the volume and returns the value actually applied.
+	SetVolume(volume float64) float64
+}
+
+// StartSetter is implemented by players that can start the next Play at a
+// given offset, overriding Config.StartAt once (used to resume).
+type StartSetter interface {
+	SetStartAt(offset time.Duration)
+}
+
 // Config holds player configuration options.
 type Config struct {
-	Channels   int    // Number of audio channels (default: 2)
-	SampleRate int    // Sample rate in Hz (default: 48000)
-	Device     string // Output device (default: "default")
+	Channels   int           // Number of audio channels (default: 2)
+	SampleRate int           // Sample rate in Hz (default: 48000)
+	Device     string        // Output device (default: "default")
+	Volume     float64       // Volume multiplier 0.0-2.0, 0 mutes (default: 1.0)
+	StartAt    time.Duration // Offset each track starts playing from (default: 0)
+	Filter     string        // Extra FFmpeg -af filter chain, e.g. from encoder.FilterChain
+	FFmpegPath string        // FFmpeg executable (default: "ffmpeg")
+
+	LogLevel string                           // FFmpeg -loglevel; "" keeps FFmpeg's default progress output
+	Logf     func(format string, args ...any) // Player messages (default: "[INFO] ..." on stdout)
 }
 
 // DefaultConfig returns the default player configuration.
@@ -26,5 +61,6 @@ func DefaultConfig() Config {
 		Channels:   2,
 		SampleRate: 48000,
 		Device:     "default",
+		Volume:     1.0,
 	}
 }
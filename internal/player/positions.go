@@ -0,0 +1,120 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSavedPositions caps the store; the least recently saved entries are
+// dropped first.
+const maxSavedPositions = 200
+
+// savedPosition is one entry in the positions file.
+type savedPosition struct {
+	Seconds float64   `json:"seconds"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// PositionStore remembers the last playback position per URL in a small
+// JSON file, so long content can be resumed later.
+type PositionStore struct {
+	path string // Empty keeps positions in memory only
+
+	mu        sync.Mutex
+	positions map[string]savedPosition
+}
+
+// NewPositionStore creates an in-memory store.
+func NewPositionStore() *PositionStore {
+	return &PositionStore{positions: make(map[string]savedPosition)}
+}
+
+// OpenPositionStore loads the store at path. A missing file yields an
+// empty store; it is created on the first Save.
+func OpenPositionStore(path string) (*PositionStore, error) {
+	s := NewPositionStore()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read positions: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.positions); err != nil {
+		return nil, fmt.Errorf("parse positions %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the saved position for url.
+func (s *PositionStore) Get(url string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.positions[url]
+	return time.Duration(saved.Seconds * float64(time.Second)), ok
+}
+
+// Save records position for url and writes the store.
+func (s *PositionStore) Save(url string, position time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[url] = savedPosition{Seconds: position.Seconds(), SavedAt: time.Now()}
+	s.evictLocked()
+	return s.writeLocked()
+}
+
+// Forget removes url (e.g. once it has played to the end) and writes the
+// store.
+func (s *PositionStore) Forget(url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.positions[url]; !ok {
+		return nil
+	}
+	delete(s.positions, url)
+	return s.writeLocked()
+}
+
+// evictLocked drops the oldest entries beyond maxSavedPositions.
+func (s *PositionStore) evictLocked() {
+	if len(s.positions) <= maxSavedPositions {
+		return
+	}
+	urls := make([]string, 0, len(s.positions))
+	for url := range s.positions {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return s.positions[urls[i]].SavedAt.Before(s.positions[urls[j]].SavedAt)
+	})
+	for _, url := range urls[:len(urls)-maxSavedPositions] {
+		delete(s.positions, url)
+	}
+}
+
+// writeLocked writes the store atomically (temp file + rename).
+func (s *PositionStore) writeLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("write positions: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write positions: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
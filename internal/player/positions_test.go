@@ -0,0 +1,60 @@
+package player
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPositionStore_SaveAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "positions.json")
+
+	store, err := OpenPositionStore(path)
+	if err != nil {
+		t.Fatalf("OpenPositionStore failed: %v", err)
+	}
+	if _, ok := store.Get("https://a"); ok {
+		t.Fatal("expected empty store")
+	}
+	if err := store.Save("https://a", 90*time.Second); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save("https://b", 30*time.Second); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Forget("https://b"); err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+
+	reopened, err := OpenPositionStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if pos, ok := reopened.Get("https://a"); !ok || pos != 90*time.Second {
+		t.Errorf("expected 90s, got %v (ok=%v)", pos, ok)
+	}
+	if _, ok := reopened.Get("https://b"); ok {
+		t.Error("expected forgotten URL to be gone")
+	}
+}
+
+func TestPositionStore_EvictsOldest(t *testing.T) {
+	store, err := OpenPositionStore(filepath.Join(t.TempDir(), "positions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= maxSavedPositions; i++ {
+		store.positions[fmt.Sprintf("url-%d", i)] = savedPosition{
+			Seconds: 1, SavedAt: time.Unix(int64(i), 0),
+		}
+	}
+	store.evictLocked()
+
+	if len(store.positions) != maxSavedPositions {
+		t.Fatalf("expected %d entries, got %d", maxSavedPositions, len(store.positions))
+	}
+	if _, ok := store.Get("url-0"); ok {
+		t.Error("expected the oldest entry to be evicted")
+	}
+}
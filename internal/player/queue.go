@@ -0,0 +1,84 @@
+package player
+
+import "math/rand/v2"
+
+// Track is one queued item.
+type Track struct {
+	URL      string
+	Title    string // Optional display title (e.g. from a playlist)
+	Duration int    // Optional duration in seconds (0 = unknown)
+}
+
+// String returns the title if known, otherwise the URL.
+func (t Track) String() string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return t.URL
+}
+
+// RepeatMode controls what happens when a track or the queue ends.
+type RepeatMode int
+
+const (
+	RepeatOff RepeatMode = iota // Play the queue once
+	RepeatOne                   // Replay the current track when it finishes
+	RepeatAll                   // Start over after the last track
+)
+
+// Queue is an ordered list of tracks played one after another.
+type Queue struct {
+	items  []Track
+	index  int
+	Repeat RepeatMode
+}
+
+// NewQueue creates a queue positioned at the first item.
+func NewQueue(items []Track) *Queue {
+	return &Queue{items: items}
+}
+
+// Current returns the track to play, or false once the queue is finished.
+func (q *Queue) Current() (Track, bool) {
+	if q.index >= len(q.items) {
+		return Track{}, false
+	}
+	return q.items[q.index], true
+}
+
+// Next advances to the following item (wrapping around with RepeatAll).
+// It reports false when there is none.
+func (q *Queue) Next() bool {
+	if q.index < len(q.items) {
+		q.index++
+	}
+	if q.Repeat == RepeatAll && q.index == len(q.items) {
+		q.index = 0
+	}
+	return q.index < len(q.items)
+}
+
+// Finished moves on after the current track played to the end: it stays
+// on the track with RepeatOne, otherwise it behaves like Next.
+func (q *Queue) Finished() bool {
+	if q.Repeat == RepeatOne && q.index < len(q.items) {
+		return true
+	}
+	return q.Next()
+}
+
+// Position returns the 1-based position of the current item.
+func (q *Queue) Position() int {
+	return q.index + 1
+}
+
+// Len returns the number of items in the queue.
+func (q *Queue) Len() int {
+	return len(q.items)
+}
+
+// Shuffle randomizes the order of the tracks not yet played.
+func (q *Queue) Shuffle() {
+	rest := q.items[min(q.index, len(q.items)):]
+	rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+}
@@ -0,0 +1,66 @@
+package player
+
+import "testing"
+
+func TestQueue_PlaysInOrder(t *testing.T) {
+	q := NewQueue([]Track{{URL: "a"}, {URL: "b"}})
+
+	if item, ok := q.Current(); !ok || item.URL != "a" || q.Position() != 1 {
+		t.Fatalf("expected a at 1, got %q at %d (ok=%v)", item, q.Position(), ok)
+	}
+	if !q.Next() {
+		t.Fatal("expected a second item")
+	}
+	if item, _ := q.Current(); item.URL != "b" {
+		t.Fatalf("expected b, got %q", item)
+	}
+	if q.Next() {
+		t.Fatal("expected end of queue")
+	}
+	if _, ok := q.Current(); ok {
+		t.Error("expected no current item after the end")
+	}
+	q.Next() // Stays finished
+	if _, ok := q.Current(); ok {
+		t.Error("expected queue to stay finished")
+	}
+}
+
+func TestQueue_ShuffleKeepsPlayedTracks(t *testing.T) {
+	tracks := []Track{{URL: "a"}, {URL: "b"}, {URL: "c"}, {URL: "d"}}
+	q := NewQueue(tracks)
+	q.Next()
+	q.Shuffle()
+
+	if tracks[0].URL != "a" {
+		t.Errorf("expected played track to stay first, got %q", tracks[0].URL)
+	}
+	seen := map[string]bool{}
+	for _, track := range tracks {
+		seen[track.URL] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("expected all tracks after shuffle, got %v", tracks)
+	}
+}
+
+func TestQueue_Repeat(t *testing.T) {
+	q := NewQueue([]Track{{URL: "a"}, {URL: "b"}})
+	q.Repeat = RepeatOne
+	q.Finished()
+	if item, _ := q.Current(); item.URL != "a" {
+		t.Fatalf("expected RepeatOne to replay a, got %q", item.URL)
+	}
+	q.Next() // Skipping still advances
+	if item, _ := q.Current(); item.URL != "b" {
+		t.Fatalf("expected skip to b, got %q", item.URL)
+	}
+
+	q.Repeat = RepeatAll
+	if !q.Finished() {
+		t.Fatal("expected RepeatAll to wrap around")
+	}
+	if item, _ := q.Current(); item.URL != "a" || q.Position() != 1 {
+		t.Errorf("expected a at 1 after wrap, got %q at %d", item.URL, q.Position())
+	}
+}
@@ -0,0 +1,112 @@
+package scrobble
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lastFMEndpoint is the Last.fm API root.
+const lastFMEndpoint = "https://ws.audioscrobbler.com/2.0/"
+
+// LastFM scrobbles with an authenticated Last.fm session key.
+type LastFM struct {
+	APIKey     string
+	Secret     string
+	SessionKey string
+	Endpoint   string // Overridable for tests
+	Client     *http.Client
+}
+
+// NewLastFM creates a Last.fm scrobbler.
+func NewLastFM(apiKey, secret, sessionKey string) *LastFM {
+	return &LastFM{
+		APIKey:     apiKey,
+		Secret:     secret,
+		SessionKey: sessionKey,
+		Endpoint:   lastFMEndpoint,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NowPlaying calls track.updateNowPlaying.
+func (l *LastFM) NowPlaying(ctx context.Context, track Track) error {
+	return l.call(ctx, "track.updateNowPlaying", trackParams(track))
+}
+
+// Scrobble calls track.scrobble.
+func (l *LastFM) Scrobble(ctx context.Context, track Track) error {
+	params := trackParams(track)
+	params.Set("timestamp", strconv.FormatInt(track.StartedAt.Unix(), 10))
+	return l.call(ctx, "track.scrobble", params)
+}
+
+func trackParams(track Track) url.Values {
+	params := url.Values{}
+	params.Set("artist", track.Artist)
+	params.Set("track", track.Title)
+	if track.Duration > 0 {
+		params.Set("duration", strconv.Itoa(int(track.Duration.Seconds())))
+	}
+	return params
+}
+
+// call signs and posts an API method.
+func (l *LastFM) call(ctx context.Context, method string, params url.Values) error {
+	params.Set("method", method)
+	params.Set("api_key", l.APIKey)
+	params.Set("sk", l.SessionKey)
+	params.Set("api_sig", l.sign(params))
+	params.Set("format", "json") // Not part of the signature
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.Endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("last.fm %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Error != 0 {
+		return fmt.Errorf("last.fm %s: %s (error %d)", method, result.Message, result.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("last.fm %s: HTTP %d", method, resp.StatusCode)
+	}
+	return nil
+}
+
+// sign computes api_sig: md5 of the sorted name+value pairs plus the secret.
+func (l *LastFM) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+	b.WriteString(l.Secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package scrobble
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// listenBrainzEndpoint is the ListenBrainz submit-listens API.
+const listenBrainzEndpoint = "https://api.listenbrainz.org/1/submit-listens"
+
+// ListenBrainz submits listens with a user token.
+type ListenBrainz struct {
+	Token    string
+	Endpoint string // Overridable for tests
+	Client   *http.Client
+}
+
+// NewListenBrainz creates a ListenBrainz scrobbler.
+func NewListenBrainz(token string) *ListenBrainz {
+	return &ListenBrainz{
+		Token:    token,
+		Endpoint: listenBrainzEndpoint,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type listenBrainzPayload struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64          `json:"listened_at,omitempty"`
+	TrackMetadata map[string]any `json:"track_metadata"`
+}
+
+// NowPlaying submits a "playing_now" listen.
+func (l *ListenBrainz) NowPlaying(ctx context.Context, track Track) error {
+	return l.submit(ctx, "playing_now", listenBrainzListen{TrackMetadata: trackMetadata(track)})
+}
+
+// Scrobble submits a "single" listen.
+func (l *ListenBrainz) Scrobble(ctx context.Context, track Track) error {
+	return l.submit(ctx, "single", listenBrainzListen{
+		ListenedAt:    track.StartedAt.Unix(),
+		TrackMetadata: trackMetadata(track),
+	})
+}
+
+func trackMetadata(track Track) map[string]any {
+	info := map[string]any{"submission_client": "music-bot"}
+	if track.Duration > 0 {
+		info["duration_ms"] = track.Duration.Milliseconds()
+	}
+	return map[string]any{
+		"artist_name":     track.Artist,
+		"track_name":      track.Title,
+		"additional_info": info,
+	}
+}
+
+func (l *ListenBrainz) submit(ctx context.Context, listenType string, listen listenBrainzListen) error {
+	body, err := json.Marshal(listenBrainzPayload{ListenType: listenType, Payload: []listenBrainzListen{listen}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+l.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("listenbrainz %s: %w", listenType, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("listenbrainz %s: HTTP %d: %s", listenType, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
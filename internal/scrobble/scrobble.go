@@ -0,0 +1,115 @@
+// Package scrobble submits listening history to Last.fm and ListenBrainz.
+package scrobble
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Track is a listen to submit.
+type Track struct {
+	Artist    string
+	Title     string
+	Duration  time.Duration // 0 if unknown
+	StartedAt time.Time
+}
+
+// Scrobbler submits now-playing and completed-listen events.
+type Scrobbler interface {
+	// NowPlaying announces the track that just started.
+	NowPlaying(ctx context.Context, track Track) error
+
+	// Scrobble records a completed listen.
+	Scrobble(ctx context.Context, track Track) error
+}
+
+// Config holds the API credentials, typically from a YAML config file.
+// Services left empty are disabled.
+type Config struct {
+	LastFM struct {
+		APIKey     string `yaml:"api_key"`
+		Secret     string `yaml:"secret"`
+		SessionKey string `yaml:"session_key"`
+	} `yaml:"lastfm"`
+	ListenBrainz struct {
+		Token string `yaml:"token"`
+	} `yaml:"listenbrainz"`
+}
+
+// New returns a scrobbler for the configured services, or nil if none are.
+func New(config Config) Scrobbler {
+	var scrobblers multi
+	if lf := config.LastFM; lf.APIKey != "" && lf.Secret != "" && lf.SessionKey != "" {
+		scrobblers = append(scrobblers, NewLastFM(lf.APIKey, lf.Secret, lf.SessionKey))
+	}
+	if config.ListenBrainz.Token != "" {
+		scrobblers = append(scrobblers, NewListenBrainz(config.ListenBrainz.Token))
+	}
+	switch len(scrobblers) {
+	case 0:
+		return nil
+	case 1:
+		return scrobblers[0]
+	}
+	return scrobblers
+}
+
+// multi fans events out to several services.
+type multi []Scrobbler
+
+func (m multi) NowPlaying(ctx context.Context, track Track) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.NowPlaying(ctx, track))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multi) Scrobble(ctx context.Context, track Track) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Scrobble(ctx, track))
+	}
+	return errors.Join(errs...)
+}
+
+// Last.fm submission rules
+const (
+	minTrackLength  = 30 * time.Second
+	maxListenNeeded = 4 * time.Minute
+)
+
+// ShouldScrobble applies the Last.fm rule: the track is longer than 30s and
+// was played for half its length or 4 minutes, whichever comes first. With
+// an unknown duration, 4 minutes of playback are required.
+func ShouldScrobble(duration, played time.Duration) bool {
+	if duration == 0 {
+		return played >= maxListenNeeded
+	}
+	if duration <= minTrackLength {
+		return false
+	}
+	return played >= min(duration/2, maxListenNeeded)
+}
+
+// titleNoise matches video decorations that are not part of a song title.
+var titleNoise = regexp.MustCompile(`(?i)\s*[(\[](official|lyrics?|audio|video|music video|hd|hq|4k|visuali[sz]er|mv)[^)\]]*[)\]]`)
+
+// ParseTitle derives artist and title from video metadata. Explicit
+// artist/track fields win; otherwise "Artist - Title" is split, falling
+// back to the uploader (auto-generated "X - Topic" channels are unwrapped).
+func ParseTitle(title, artist, track, uploader string) (string, string) {
+	if artist != "" && track != "" {
+		return artist, track
+	}
+	title = strings.TrimSpace(titleNoise.ReplaceAllString(title, ""))
+	for _, sep := range []string{" - ", " – ", " — ", " | "} {
+		if a, t, ok := strings.Cut(title, sep); ok && a != "" && t != "" {
+			return strings.TrimSpace(a), strings.TrimSpace(t)
+		}
+	}
+	return strings.TrimSuffix(uploader, " - Topic"), title
+}
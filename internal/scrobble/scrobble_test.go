@@ -0,0 +1,134 @@
+package scrobble
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseTitle(t *testing.T) {
+	cases := []struct {
+		title, artist, track, uploader string
+		wantArtist, wantTitle          string
+	}{
+		{"Rick Astley - Never Gonna Give You Up (Official Music Video)", "", "", "Rick Astley", "Rick Astley", "Never Gonna Give You Up"},
+		{"ignored", "Daft Punk", "One More Time", "", "Daft Punk", "One More Time"},
+		{"Blinding Lights [HD]", "", "", "The Weeknd - Topic", "The Weeknd", "Blinding Lights"},
+	}
+	for _, c := range cases {
+		artist, title := ParseTitle(c.title, c.artist, c.track, c.uploader)
+		if artist != c.wantArtist || title != c.wantTitle {
+			t.Errorf("ParseTitle(%q) = %q, %q; want %q, %q", c.title, artist, title, c.wantArtist, c.wantTitle)
+		}
+	}
+}
+
+func TestShouldScrobble(t *testing.T) {
+	cases := []struct {
+		duration, played time.Duration
+		want             bool
+	}{
+		{20 * time.Second, 20 * time.Second, false},
+		{3 * time.Minute, 80 * time.Second, false},
+		{3 * time.Minute, 90 * time.Second, true},
+		{time.Hour, 4 * time.Minute, true},
+		{0, 3 * time.Minute, false},
+		{0, 4 * time.Minute, true},
+	}
+	for _, c := range cases {
+		if got := ShouldScrobble(c.duration, c.played); got != c.want {
+			t.Errorf("ShouldScrobble(%v, %v) = %v, want %v", c.duration, c.played, got, c.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if New(Config{}) != nil {
+		t.Error("expected nil scrobbler without credentials")
+	}
+	var config Config
+	config.ListenBrainz.Token = "token"
+	if _, ok := New(config).(*ListenBrainz); !ok {
+		t.Error("expected a ListenBrainz scrobbler")
+	}
+	config.LastFM.APIKey, config.LastFM.Secret, config.LastFM.SessionKey = "key", "secret", "sk"
+	if _, ok := New(config).(multi); !ok {
+		t.Error("expected both services")
+	}
+}
+
+func TestLastFM_Scrobble(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"scrobbles":{}}`))
+	}))
+	defer srv.Close()
+
+	lf := NewLastFM("key", "secret", "sk")
+	lf.Endpoint = srv.URL
+	track := Track{Artist: "Artist", Title: "Song", StartedAt: time.Unix(1700000000, 0)}
+	if err := lf.Scrobble(context.Background(), track); err != nil {
+		t.Fatalf("Scrobble failed: %v", err)
+	}
+
+	if form.Get("method") != "track.scrobble" || form.Get("timestamp") != "1700000000" || form.Get("artist") != "Artist" {
+		t.Errorf("unexpected form: %v", form)
+	}
+	signed := url.Values{}
+	for k, v := range form {
+		if k != "api_sig" && k != "format" {
+			signed[k] = v
+		}
+	}
+	if form.Get("api_sig") != lf.sign(signed) {
+		t.Error("api_sig does not cover the submitted parameters")
+	}
+}
+
+func TestLastFM_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":9,"message":"Invalid session key"}`))
+	}))
+	defer srv.Close()
+
+	lf := NewLastFM("key", "secret", "sk")
+	lf.Endpoint = srv.URL
+	if err := lf.NowPlaying(context.Background(), Track{Artist: "A", Title: "B"}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestListenBrainz_NowPlaying(t *testing.T) {
+	var auth string
+	var payload listenBrainzPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+	}))
+	defer srv.Close()
+
+	lb := NewListenBrainz("token")
+	lb.Endpoint = srv.URL
+	if err := lb.NowPlaying(context.Background(), Track{Artist: "A", Title: "B", Duration: time.Minute}); err != nil {
+		t.Fatalf("NowPlaying failed: %v", err)
+	}
+
+	if auth != "Token token" {
+		t.Errorf("unexpected Authorization %q", auth)
+	}
+	if payload.ListenType != "playing_now" || len(payload.Payload) != 1 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if listen := payload.Payload[0]; listen.ListenedAt != 0 || listen.TrackMetadata["track_name"] != "B" {
+		t.Errorf("unexpected listen: %+v", listen)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+// Adaptive bitrate configuration
+const (
+	abrLowBuffer       = 200 * time.Millisecond // Consumer buffer below this counts as unhealthy
+	abrHealthyBuffer   = 1 * time.Second        // Consumer buffer above this counts as healthy
+	abrStepUpAfter     = 30 * time.Second       // Sustained health required before stepping up
+	abrMinSwitchPeriod = 10 * time.Second       // Minimum time between bitrate changes
+)
+
+// bitrateLadders lists the Opus bitrates (bps) adaptive bitrate can choose
+// from per format, lowest first. The last entry is the format default.
+var bitrateLadders = map[encoder.Format][]int{
+	encoder.FormatOpus: {64000, 96000, 128000},
+	encoder.FormatWeb:  {96000, 128000, 192000, 256000},
+}
+
+// Opus bitrate limits (bps) for explicitly set bitrates
+const (
+	minOpusBitrate = 6000
+	maxOpusBitrate = 510000
+)
+
+// Feedback is a consumer's report of its playback buffer health.
+type Feedback struct {
+	Buffered  time.Duration // Audio buffered on the consumer side
+	Underruns int           // Underruns since the previous report
+}
+
+// abrController steps a session's bitrate down when the consumer reports
+// underruns or a starving buffer, and back up after sustained health.
+type abrController struct {
+	ladder       []int
+	level        int       // Index into ladder
+	healthySince time.Time // Zero while unhealthy
+	lastChange   time.Time
+}
+
+// newABRController creates a controller starting at the format's default
+// bitrate, or nil if the format has no bitrate ladder (PCM).
+func newABRController(format encoder.Format) *abrController {
+	ladder, ok := bitrateLadders[format]
+	if !ok {
+		return nil
+	}
+	return &abrController{ladder: ladder, level: len(ladder) - 1}
+}
+
+// newCappedABRController creates a controller for format whose ladder tops
+// out at bps (e.g. a Discord channel's bitrate): the format's steps below
+// it, then bps itself, starting at bps. Returns nil for PCM.
+func newCappedABRController(format encoder.Format, bps int) *abrController {
+	ladder, ok := bitrateLadders[format]
+	if !ok {
+		return nil
+	}
+	capped := []int{}
+	for _, step := range ladder {
+		if step < bps {
+			capped = append(capped, step)
+		}
+	}
+	capped = append(capped, bps)
+	return &abrController{ladder: capped, level: len(capped) - 1}
+}
+
+// Bitrate returns the current bitrate in bps.
+func (c *abrController) Bitrate() int {
+	return c.ladder[c.level]
+}
+
+// Observe applies a feedback report and returns the new bitrate and true
+// if the bitrate should change.
+func (c *abrController) Observe(fb Feedback, now time.Time) (int, bool) {
+	unhealthy := fb.Underruns > 0 || fb.Buffered < abrLowBuffer
+	healthy := !unhealthy && fb.Buffered >= abrHealthyBuffer
+
+	if !healthy {
+		c.healthySince = time.Time{}
+	} else if c.healthySince.IsZero() {
+		c.healthySince = now
+	}
+
+	if !c.lastChange.IsZero() && now.Sub(c.lastChange) < abrMinSwitchPeriod {
+		return c.Bitrate(), false
+	}
+
+	switch {
+	case unhealthy && c.level > 0:
+		c.level--
+	case healthy && c.level < len(c.ladder)-1 && now.Sub(c.healthySince) >= abrStepUpAfter:
+		c.level++
+		c.healthySince = now
+	default:
+		return c.Bitrate(), false
+	}
+
+	c.lastChange = now
+	return c.Bitrate(), true
+}
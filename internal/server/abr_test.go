@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestABRController_NoLadderForPCM(t *testing.T) {
+	if c := newABRController(encoder.FormatPCM); c != nil {
+		t.Error("expected nil controller for PCM")
+	}
+}
+
+func TestABRController_StepsDownOnUnderrun(t *testing.T) {
+	c := newABRController(encoder.FormatOpus)
+	now := time.Now()
+
+	bitrate, changed := c.Observe(Feedback{Buffered: time.Second, Underruns: 2}, now)
+	if !changed || bitrate != 96000 {
+		t.Fatalf("expected step down to 96000, got %d (changed=%v)", bitrate, changed)
+	}
+
+	// Another underrun within the switch period must not change again
+	if _, changed := c.Observe(Feedback{Underruns: 1}, now.Add(time.Second)); changed {
+		t.Error("expected no change within minimum switch period")
+	}
+
+	bitrate, changed = c.Observe(Feedback{Underruns: 1}, now.Add(abrMinSwitchPeriod))
+	if !changed || bitrate != 64000 {
+		t.Fatalf("expected step down to 64000, got %d (changed=%v)", bitrate, changed)
+	}
+
+	// Already at the lowest rung
+	if _, changed := c.Observe(Feedback{Underruns: 1}, now.Add(2*abrMinSwitchPeriod)); changed {
+		t.Error("expected no change at lowest bitrate")
+	}
+}
+
+func TestABRController_StepsUpAfterSustainedHealth(t *testing.T) {
+	c := newABRController(encoder.FormatWeb)
+	now := time.Now()
+	c.Observe(Feedback{Underruns: 1}, now) // 256k -> 192k
+
+	healthy := Feedback{Buffered: 2 * time.Second}
+	start := now.Add(abrMinSwitchPeriod)
+	if _, changed := c.Observe(healthy, start); changed {
+		t.Fatal("expected no step up before sustained health")
+	}
+
+	bitrate, changed := c.Observe(healthy, start.Add(abrStepUpAfter))
+	if !changed || bitrate != 256000 {
+		t.Fatalf("expected step up to 256000, got %d (changed=%v)", bitrate, changed)
+	}
+}
+
+func TestABRController_Capped(t *testing.T) {
+	c := newCappedABRController(encoder.FormatOpus, 96000)
+	if c.Bitrate() != 96000 {
+		t.Fatalf("expected to start at the cap, got %d", c.Bitrate())
+	}
+	if bitrate, changed := c.Observe(Feedback{Underruns: 1}, time.Now()); !changed || bitrate != 64000 {
+		t.Errorf("expected step down below the cap to 64000, got %d (changed=%v)", bitrate, changed)
+	}
+
+	c = newCappedABRController(encoder.FormatOpus, 384000)
+	if got := c.ladder; len(got) != 4 || got[3] != 384000 {
+		t.Errorf("expected the cap above the format's ladder, got %v", got)
+	}
+}
+
+// oggPage builds a single-segment Ogg page ending at granule (48kHz samples).
+func oggPage(granule int64, sequence uint32, body string) []byte {
+	page := make([]byte, 28, 28+len(body))
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(page[18:22], sequence)
+	page[26] = 1
+	page[27] = byte(len(body))
+	return append(page, body...)
+}
+
+func TestFeedback_PacesWebOutput(t *testing.T) {
+	pipeline := &feedPipeline{fakePipeline: newFakePipeline(), feed: make(chan []byte), paused: make(chan struct{}, 1)}
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline { return pipeline })
+	router := SetupRouter(NewAPI(sm))
+	if err := sm.StartPlayback("guild-1", "fake://track", "web", PlaybackOptions{Duration: 60}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	audio := make(chan time.Time, 64)
+	go func() {
+		for msg := range messages {
+			if msg.audio != nil {
+				audio <- time.Now()
+			}
+		}
+	}()
+
+	// 100ms pages: past the 500ms prebuffer they pass straight through
+	sequence := uint32(0)
+	send := func(pages int) {
+		for range pages {
+			sequence++
+			pipeline.feed <- oggPage(int64(sequence)*4800, sequence, "audio")
+		}
+	}
+	pipeline.feed <- oggPage(0, 0, "OpusHead\x01\x02\x00\x00\x80\xbb\x00\x00\x00\x00\x00")
+	send(6)
+	for range 6 {
+		select {
+		case <-audio:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the prebuffered pages released at once")
+		}
+	}
+
+	// A consumer 3s ahead halves the rate: each 100ms page now takes 200ms
+	req := httptest.NewRequest("POST", "/session/guild-1/feedback", strings.NewReader(`{"buffered_ms": 3000}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("feedback failed: %d %s", w.Code, w.Body.String())
+	}
+	started := time.Now()
+	send(3)
+	var last time.Time
+	for range 3 {
+		select {
+		case last = <-audio:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the pages released")
+		}
+	}
+	if elapsed := last.Sub(started); elapsed < 300*time.Millisecond {
+		t.Errorf("expected feedback to pace the pages, released in %v", elapsed)
+	}
+	sm.StopAll()
+}
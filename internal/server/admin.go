@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+// AdminResponse is the response for the /admin endpoints.
+type AdminResponse struct {
+	Status          string `json:"status"`
+	Draining        bool   `json:"draining"`
+	Stopped         int    `json:"stopped,omitempty"` // Sessions stopped by stop-all
+	SessionsActive  int    `json:"sessions_active"`
+	SessionsPlaying int    `json:"sessions_playing"`
+}
+
+// SetAdminToken sets the bearer token required by the /admin endpoints.
+// With no token the admin endpoints are disabled.
+func (a *API) SetAdminToken(token string) {
+	a.adminToken = token
+}
+
+// adminAuth rejects requests without `Authorization: Bearer <token>`.
+func adminAuth(api *API) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.adminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API disabled (no admin token configured)"})
+			return
+		}
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// StopAll handles POST /admin/sessions/stop-all.
+func (a *API) StopAll(c *gin.Context) {
+	stopped := a.sessions.StopAll()
+	a.audit(c, AuditStopAll, "", map[string]any{"stopped": stopped}, nil)
+	logger.Infof("[Admin] Stopped %d sessions", stopped)
+	c.JSON(http.StatusOK, a.adminResponse("stopped", stopped))
+}
+
+// Drain handles POST /admin/drain: new plays are refused with 503 while
+// current tracks finish. Poll sessions_playing until it reaches zero.
+func (a *API) Drain(c *gin.Context) {
+	a.sessions.SetDraining(true)
+	a.audit(c, AuditDrain, "", nil, nil)
+	logger.Infof("[Admin] Draining, %d sessions playing", a.sessions.StreamingSessionCount())
+	c.JSON(http.StatusOK, a.adminResponse("draining", 0))
+}
+
+// Undrain handles DELETE /admin/drain, accepting new plays again.
+func (a *API) Undrain(c *gin.Context) {
+	a.sessions.SetDraining(false)
+	a.audit(c, AuditUndrain, "", nil, nil)
+	logger.Infof("[Admin] Drain cancelled")
+	c.JSON(http.StatusOK, a.adminResponse("ok", 0))
+}
+
+// CookiesResponse is the response for POST /admin/cookies/reload.
+type CookiesResponse struct {
+	Status   string `json:"status"`
+	Mode     string `json:"cookie_mode"`         // file, browser, default_file or none
+	Path     string `json:"path,omitempty"`      // Cookies file reloaded
+	Total    int    `json:"total,omitempty"`     // YouTube/Google cookies in it
+	Expired  int    `json:"expired,omitempty"`   // Of which expired
+	LoggedIn bool   `json:"logged_in,omitempty"` // A login cookie is present and unexpired
+	Message  string `json:"message,omitempty"`
+}
+
+// ReloadCookies handles POST /admin/cookies/reload, the same as SIGHUP.
+func (a *API) ReloadCookies(c *gin.Context) {
+	report, err := ReloadCookies()
+	a.audit(c, AuditCookies, "", nil, err)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, CookiesResponse{Status: "error", Mode: youtube.CookieMode(), Message: err.Error()})
+		return
+	}
+	resp := CookiesResponse{Status: "reloaded", Mode: youtube.CookieMode()}
+	if report != nil {
+		resp.Path, resp.Total, resp.Expired, resp.LoggedIn = report.Path, report.Total, report.Expired, report.LoggedIn
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReloadCookies re-stages the yt-dlp cookies file after it was refreshed.
+// Playing sessions are untouched; extractions from now on use the new
+// cookies. On error the previous cookies stay in use.
+func ReloadCookies() (*youtube.CookieReport, error) {
+	report, err := youtube.ReloadCookies(time.Now())
+	switch {
+	case err != nil:
+		logger.Warnf("[Admin] Cookie reload failed, keeping the current cookies: %v", err)
+	case report == nil:
+		logger.Infof("[Admin] No cookies file to reload (cookie mode %s)", youtube.CookieMode())
+	default:
+		logger.Infof("[Admin] Reloaded cookies from %s: %d YouTube cookies, %d expired, logged in: %v",
+			report.Path, report.Total, report.Expired, report.LoggedIn)
+	}
+	return report, err
+}
+
+// CookieCheckResponse is the response for GET /admin/cookies/check.
+type CookieCheckResponse struct {
+	Status   string     `json:"status"` // valid, invalid or error (the probe couldn't tell)
+	Mode     string     `json:"cookie_mode"`
+	LoggedIn bool       `json:"logged_in"`
+	Premium  bool       `json:"premium"`
+	Expires  *time.Time `json:"expires,omitempty"` // When the first login cookie expires (cookies files only)
+	Expired  int        `json:"expired,omitempty"` // Expired YouTube cookies in the file
+	Message  string     `json:"message,omitempty"` // Why the cookies were rejected or the probe failed
+}
+
+// CheckCookies handles GET /admin/cookies/check: a signed-in yt-dlp probe
+// reports whether the cookies still work, e.g. for an alert before users
+// see extraction failures.
+func (a *API) CheckCookies(c *gin.Context) {
+	resp := CookieCheckResponse{Mode: youtube.CookieMode()}
+	report, err := youtube.CheckCookies(time.Now())
+	if err != nil {
+		resp.Status, resp.Message = "invalid", err.Error()
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if report != nil {
+		resp.Expired = report.Expired
+		if !report.LoginExpires.IsZero() {
+			resp.Expires = &report.LoginExpires
+		}
+	}
+
+	status, err := a.checkAuth(c.Request.Context())
+	if err != nil {
+		logger.Warnf("[Admin] Cookie check failed: %v", err)
+		resp.Status, resp.Message = "error", err.Error()
+		c.JSON(httpStatus(err), resp)
+		return
+	}
+	resp.LoggedIn, resp.Premium = status.LoggedIn, status.Premium
+	resp.Status = "valid"
+	if !status.LoggedIn {
+		resp.Status, resp.Message = "invalid", status.Reason
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) adminResponse(status string, stopped int) AdminResponse {
+	return AdminResponse{
+		Status:          status,
+		Draining:        a.sessions.Draining(),
+		Stopped:         stopped,
+		SessionsActive:  a.sessions.ActiveSessionCount(),
+		SessionsPlaying: a.sessions.StreamingSessionCount(),
+	}
+}
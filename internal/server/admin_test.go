@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+func adminRequest(router http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	router := SetupRouter(api)
+
+	if w := adminRequest(router, "POST", "/admin/drain", "secret"); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 with no admin token configured, got %d", w.Code)
+	}
+
+	api.SetAdminToken("secret")
+	if w := adminRequest(router, "POST", "/admin/drain", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", w.Code)
+	}
+	if w := adminRequest(router, "POST", "/admin/drain", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for a wrong token, got %d", w.Code)
+	}
+	if api.sessions.Draining() {
+		t.Error("unauthorized request started draining")
+	}
+}
+
+func TestAdminDrain(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	api.SetAdminToken("secret")
+	router := SetupRouter(api)
+
+	w := adminRequest(router, "POST", "/admin/drain", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AdminResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Draining {
+		t.Errorf("expected draining in response, got %+v", resp)
+	}
+
+	req, _ := http.NewRequest("POST", "/session/guild-1/play", strings.NewReader(`{"url": "https://youtube.com/watch?v=test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while draining, got %d", w.Code)
+	}
+
+	adminRequest(router, "DELETE", "/admin/drain", "secret")
+	if api.sessions.Draining() {
+		t.Error("expected draining to be cancelled")
+	}
+}
+
+func TestSessionManager_StopAll(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline("a", "b")
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	sm.StartPlayback("guild-1", "fake://one", "opus", PlaybackOptions{})
+	sm.StartPlayback("guild-2", "fake://two", "opus", PlaybackOptions{})
+
+	if stopped := sm.StopAll(); stopped != 2 {
+		t.Errorf("expected 2 sessions stopped, got %d", stopped)
+	}
+	if n := sm.ActiveSessionCount(); n != 0 {
+		t.Errorf("expected no sessions left, got %d", n)
+	}
+}
+
+// holdPipeline emits one chunk and then keeps its output open until
+// stopped, like a track that is still playing.
+type holdPipeline struct {
+	*fakePipeline
+}
+
+func (p *holdPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	go func() {
+		defer close(p.output)
+		select {
+		case p.output <- []byte("a"):
+		case <-ctx.Done():
+			return
+		}
+		<-ctx.Done()
+	}()
+	return nil
+}
+
+func TestSessionManager_Drain(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &holdPipeline{fakePipeline: newFakePipeline()}
+	})
+	draining := make(chan struct{}, 1)
+	go func() {
+		for msg := range messages {
+			if msg.event["type"] == string(EventServerDraining) {
+				draining <- struct{}{}
+			}
+		}
+	}()
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+	deadline := time.Now().Add(2 * time.Second)
+	for sm.StreamingSessionCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if sm.Drain(100 * time.Millisecond) {
+		t.Error("expected drain to time out with a track still playing")
+	}
+	select {
+	case <-draining:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a server_draining event")
+	}
+	if err := sm.StartPlayback("guild-2", "fake://track", "opus", PlaybackOptions{}); !errors.Is(err, errDraining) {
+		t.Errorf("expected errDraining, got %v", err)
+	}
+
+	sm.StopAll()
+	if !sm.Drain(100 * time.Millisecond) {
+		t.Error("expected drain to finish once no track is playing")
+	}
+}
+
+func TestAdminReloadCookies(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	api.SetAdminToken("secret")
+	router := SetupRouter(api)
+
+	youtube.SetConfig(youtube.Config{CookiesFromBrowser: "firefox"})
+	defer youtube.SetConfig(youtube.Config{})
+	w := adminRequest(router, "POST", "/admin/cookies/reload", "secret")
+	var resp CookiesResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Mode != youtube.CookieModeBrowser {
+		t.Errorf("expected a no-op reload for browser cookies, got %d %+v", w.Code, resp)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(path, []byte("not a cookie file\n"), 0o600)
+	youtube.SetConfig(youtube.Config{CookiesFile: path})
+	if w := adminRequest(router, "POST", "/admin/cookies/reload", "secret"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for a malformed cookies file, got %d", w.Code)
+	}
+	if got := api.sessions.audit.Query(AuditFilter{Action: AuditCookies}); len(got) != 2 || got[0].Error == "" {
+		t.Errorf("expected both reloads audited, got %+v", got)
+	}
+}
+
+func TestAdminCheckCookies(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	api.SetAdminToken("secret")
+	router := SetupRouter(api)
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	os.WriteFile(path, []byte(fmt.Sprintf(".youtube.com\tTRUE\t/\tTRUE\t%d\tLOGIN_INFO\tabc\n", expires.Unix())), 0o600)
+	youtube.SetConfig(youtube.Config{CookiesFile: path})
+	defer youtube.SetConfig(youtube.Config{})
+
+	api.checkAuth = func(context.Context) (youtube.AuthStatus, error) {
+		return youtube.AuthStatus{LoggedIn: true, Premium: true}, nil
+	}
+	w := adminRequest(router, "GET", "/admin/cookies/check", "secret")
+	var resp CookieCheckResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Status != "valid" || !resp.Premium || resp.Expires == nil || !resp.Expires.Equal(expires) {
+		t.Errorf("expected valid Premium cookies expiring %v, got %d %+v", expires, w.Code, resp)
+	}
+
+	api.checkAuth = func(context.Context) (youtube.AuthStatus, error) {
+		return youtube.AuthStatus{Reason: "This feed requires authentication"}, nil
+	}
+	w = adminRequest(router, "GET", "/admin/cookies/check", "secret")
+	resp = CookieCheckResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Status != "invalid" || resp.LoggedIn || resp.Message == "" {
+		t.Errorf("expected invalid cookies, got %d %+v", w.Code, resp)
+	}
+
+	api.checkAuth = func(context.Context) (youtube.AuthStatus, error) {
+		return youtube.AuthStatus{}, errs.New(errs.ErrExtraction, "connection refused")
+	}
+	if w := adminRequest(router, "GET", "/admin/cookies/check", "secret"); w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 when the probe fails, got %d", w.Code)
+	}
+}
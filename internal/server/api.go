@@ -1,60 +1,174 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"music-bot/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
 )
 
 // API handles HTTP control endpoints.
 type API struct {
-	sessions *SessionManager
+	sessions   *SessionManager
+	clips      *ClipManager
+	queues     *QueueStore
+	suggester  *youtube.Suggester
+	deps       *depsProbe
+	degraded   []string
+	adminToken string
+
+	// search finds tracks for play requests by query
+	search func(ctx context.Context, query string, limit int) ([]youtube.SearchResult, error)
+	// checkAuth probes whether YouTube accepts the configured cookies
+	checkAuth func(ctx context.Context) (youtube.AuthStatus, error)
+	// thumbnailBase is the URL thumbnails are fetched under
+	thumbnailBase string
 }
 
 // NewAPI creates a new API handler.
 func NewAPI(sessions *SessionManager) *API {
 	return &API{
-		sessions: sessions,
+		sessions:  sessions,
+		clips:     NewClipManager(sessions),
+		queues:    sessions.Queues(),
+		suggester: youtube.NewSuggester(),
+		deps:      newDepsProbe(execx.Default),
+		search:    youtube.New().SearchContext,
+		checkAuth: youtube.New().CheckAuth,
+
+		thumbnailBase: thumbnailBase,
 	}
 }
 
+// SetDegraded sets the features reported as unavailable in /health and
+// /debug/deps (see deps.Checker.Degraded).
+func (a *API) SetDegraded(features []string) {
+	a.degraded = features
+}
+
 // PlayRequest is the request body for play endpoint.
 type PlayRequest struct {
-	URL      string  `json:"url" binding:"required"`
+	URL      string  `json:"url"`   // Track URL; required unless query is set
+	Query    string  `json:"query"` // Optional: search YouTube and play the top result instead of url
 	Format   string  `json:"format"`
 	StartAt  float64 `json:"start_at"`
-	Duration float64 `json:"duration"` // Optional: track duration from Node.js (skips yt-dlp metadata call)
+	EndAt    float64 `json:"end_at"`        // Optional: stop at this position in seconds (plays only start_at to end_at)
+	Duration float64 `json:"duration"`      // Optional: track duration from Node.js (skips yt-dlp metadata call)
+	Levels   bool    `json:"levels"`        // Optional: emit audio level (VU) events over the socket
+	Spectrum bool    `json:"spectrum"`      // Optional: emit spectrum analyser events over the socket
+	Token    string  `json:"request_token"` // Optional: unique per play request; a retry with the same token doesn't restart the track
+	Resume   bool    `json:"resume"`        // Optional: start where this session last left the track (ignored with start_at)
+	Region   string  `json:"region"`        // Optional: two-letter country to extract from (geo bypass), overriding the configured one
+
+	// Optional: a direct stream URL the caller already extracted, played
+	// without running yt-dlp, and when it expires (Unix seconds; 0 reads
+	// YouTube's expire parameter)
+	StreamURL     string `json:"stream_url"`
+	StreamExpires int64  `json:"stream_expires"`
 }
 
 // PlayResponse is the response for play endpoint.
 type PlayResponse struct {
-	Status    string `json:"status"`
-	SessionID string `json:"session_id"`
-	Message   string `json:"message,omitempty"`
+	Status    string        `json:"status"`
+	SessionID string        `json:"session_id"`
+	Message   string        `json:"message,omitempty"`
+	Track     *SearchResult `json:"track,omitempty"` // The search result played, for query requests
 }
 
 // StatusResponse is the response for status endpoint.
 type StatusResponse struct {
-	SessionID string `json:"session_id"`
+	SessionID string       `json:"session_id"`
+	Status    string       `json:"status"`
+	BytesSent int64        `json:"bytes_sent"`
+	Position  float64      `json:"position"`            // Playback position in seconds of the track
+	Remaining float64      `json:"remaining,omitempty"` // Seconds until the track ends at the current speed
+	Speed     float64      `json:"speed,omitempty"`
+	URL       string       `json:"url,omitempty"`
+	Buffer    *BufferStats `json:"buffer,omitempty"` // Output buffer health (paced formats only)
+
+	*Diagnostics // Retry and error history (found sessions only)
+}
+
+// BufferStats reports output buffer counters for the current playback attempt.
+type BufferStats struct {
+	ChunksBuffered int64 `json:"chunks_buffered"`
+	ChunksDropped  int64 `json:"chunks_dropped"`
+	Underruns      int64 `json:"underruns"`
+	Queued         int   `json:"queued"`
+	BufferedMs     int64 `json:"buffered_ms"`
+}
+
+// FeedbackRequest is the request body for feedback endpoint.
+type FeedbackRequest struct {
+	BufferedMs int `json:"buffered_ms"` // Audio buffered on the consumer side
+	Underruns  int `json:"underruns"`   // Underruns since the previous report
+}
+
+// FeedbackResponse is the response for feedback endpoint.
+type FeedbackResponse struct {
 	Status    string `json:"status"`
-	BytesSent int64  `json:"bytes_sent"`
-	URL       string `json:"url,omitempty"`
+	SessionID string `json:"session_id"`
+	Bitrate   int    `json:"bitrate,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// BitrateRequest is the request body for bitrate endpoint.
+type BitrateRequest struct {
+	Bitrate int `json:"bitrate" binding:"required"` // Opus bitrate in bps, e.g. a Discord channel's 96000
+}
+
+// SettingsResponse is the response for the settings endpoints.
+type SettingsResponse struct {
+	Status    string           `json:"status"`
+	SessionID string           `json:"session_id"`
+	Settings  *SessionSettings `json:"settings,omitempty"`
+	Message   string           `json:"message,omitempty"`
+}
+
+// FilterInfo describes an audio effect for the filters endpoint.
+type FilterInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// FiltersResponse is the response for the filters endpoint.
+type FiltersResponse struct {
+	Effects []FilterInfo `json:"effects"`
+	EQ      string       `json:"eq"`    // Format of the eq setting
+	Speed   [2]float64   `json:"speed"` // Range of the speed setting
+	Pitch   [2]float64   `json:"pitch"` // Range of the pitch setting in semitones
 }
 
 // MetadataResponse is the response for metadata endpoint.
 type MetadataResponse struct {
-	URL        string `json:"url"`
-	Title      string `json:"title"`
-	Duration   int    `json:"duration"`
-	Thumbnail  string `json:"thumbnail"`
-	IsPlaylist bool   `json:"is_playlist"`
-	Error      string `json:"error,omitempty"`
+	URL           string              `json:"url"`
+	Title         string              `json:"title"`
+	Duration      int                 `json:"duration"`
+	Thumbnail     string              `json:"thumbnail"`
+	IsPlaylist    bool                `json:"is_playlist"`
+	Uploader      string              `json:"uploader,omitempty"`
+	Artist        string              `json:"artist,omitempty"`
+	Track         string              `json:"track,omitempty"`
+	Album         string              `json:"album,omitempty"`
+	UploadDate    string              `json:"upload_date,omitempty"` // YYYY-MM-DD
+	ViewCount     int64               `json:"view_count,omitempty"`
+	LikeCount     int64               `json:"like_count,omitempty"`
+	AgeRestricted bool                `json:"age_restricted"`
+	Thumbnails    []youtube.Thumbnail `json:"thumbnails,omitempty"` // Available sizes, smallest first
+	Error         string              `json:"error,omitempty"`
 }
 
 // PlaylistEntry represents a video in a playlist.
 type PlaylistEntry struct {
+	ID        string `json:"id"`
 	URL       string `json:"url"`
 	Title     string `json:"title"`
 	Duration  int    `json:"duration"`
@@ -63,9 +177,29 @@ type PlaylistEntry struct {
 
 // PlaylistResponse is the response for playlist endpoint.
 type PlaylistResponse struct {
+	URL        string          `json:"url"`
+	Count      int             `json:"count"`
+	Total      int             `json:"total,omitempty"`       // Entries in the whole playlist, when known
+	NextOffset int             `json:"next_offset,omitempty"` // Pass as offset for the next page (absent on the last page)
+	Entries    []PlaylistEntry `json:"entries"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// PlaylistStreamEnd is the last line of a streamed playlist response.
+type PlaylistStreamEnd struct {
+	Done       bool   `json:"done"`
+	Count      int    `json:"count"`
+	Total      int    `json:"total,omitempty"`
+	NextOffset int    `json:"next_offset,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ChannelResponse is the response for the channel endpoint.
+type ChannelResponse struct {
 	URL     string          `json:"url"`
+	Channel string          `json:"channel,omitempty"`
 	Count   int             `json:"count"`
-	Entries []PlaylistEntry `json:"entries"`
+	Entries []PlaylistEntry `json:"entries"` // Most viewed first
 	Error   string          `json:"error,omitempty"`
 }
 
@@ -81,10 +215,26 @@ type SearchResult struct {
 
 // SearchResponse is the response for search endpoint.
 type SearchResponse struct {
-	Query   string         `json:"query"`
-	Count   int            `json:"count"`
-	Results []SearchResult `json:"results"`
-	Error   string         `json:"error,omitempty"`
+	Query      string         `json:"query"`
+	Count      int            `json:"count"`
+	Results    []SearchResult `json:"results"`
+	NextOffset int            `json:"next_offset,omitempty"` // Pass as offset for the next page (absent on the last page)
+	Error      string         `json:"error,omitempty"`
+}
+
+// SuggestResponse is the response for the suggest endpoint.
+type SuggestResponse struct {
+	Query       string   `json:"query"`
+	Suggestions []string `json:"suggestions"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// validSessionID rejects requests for session IDs that can't be framed on
+// the audio socket (see validateSessionID).
+func validSessionID(c *gin.Context) {
+	if err := validateSessionID(c.Param("id")); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
 }
 
 // Play starts a new playback session.
@@ -107,18 +257,57 @@ func (a *API) Play(c *gin.Context) {
 		})
 		return
 	}
+	if (req.URL == "") == (req.Query == "") {
+		c.JSON(http.StatusBadRequest, PlayResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   "exactly one of url and query is required",
+		})
+		return
+	}
+
+	var track *SearchResult
+	if req.Query != "" {
+		result, err := a.searchTrack(c.Request.Context(), req.Query)
+		if err != nil {
+			c.JSON(httpStatus(err), PlayResponse{
+				Status:    "error",
+				SessionID: sessionID,
+				Message:   err.Error(),
+			})
+			return
+		}
+		track = &result
+		req.URL = result.URL
+		if req.Duration == 0 {
+			req.Duration = float64(result.Duration)
+		}
+	}
 
 	format := req.Format
 	if format == "" {
 		format = "pcm"
 	}
 
-	fmt.Printf("[API] Play request: session=%s url=%s format=%s duration=%.0f\n", sessionID, req.URL, format, req.Duration)
+	logger.Infof("[API] Play request: session=%s url=%s format=%s duration=%.0f", sessionID, req.URL, format, req.Duration)
 
 	// Start playback (this is non-blocking now)
-	err := a.sessions.StartPlayback(sessionID, req.URL, format, req.StartAt, req.Duration)
+	err := a.sessions.StartPlayback(sessionID, req.URL, format, PlaybackOptions{
+		StartAt:  req.StartAt,
+		EndAt:    req.EndAt,
+		Duration: req.Duration,
+		Levels:   req.Levels,
+		Spectrum: req.Spectrum,
+		Token:    req.Token,
+		Resume:   req.Resume,
+		Region:   req.Region,
+
+		StreamURL:     req.StreamURL,
+		StreamExpires: unixTime(req.StreamExpires),
+	})
+	a.audit(c, AuditPlay, sessionID, map[string]any{"url": req.URL, "format": format, "start_at": req.StartAt}, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PlayResponse{
+		c.JSON(httpStatus(err), PlayResponse{
 			Status:    "error",
 			SessionID: sessionID,
 			Message:   err.Error(),
@@ -129,9 +318,39 @@ func (a *API) Play(c *gin.Context) {
 	c.JSON(http.StatusOK, PlayResponse{
 		Status:    "playing",
 		SessionID: sessionID,
+		Track:     track,
 	})
 }
 
+// searchTrack returns the top YouTube search result for query.
+func (a *API) searchTrack(ctx context.Context, query string) (SearchResult, error) {
+	logger.Infof("[API] Play search: q=%s", query)
+	results, err := a.search(ctx, query, 1)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("search failed: %w", err)
+	}
+	if len(results) == 0 {
+		return SearchResult{}, errs.New(errs.ErrNotFound, "no results for %q", query)
+	}
+	r := results[0]
+	return SearchResult{
+		ID:        r.ID,
+		URL:       r.URL,
+		Title:     r.Title,
+		Duration:  r.Duration,
+		Thumbnail: r.Thumbnail,
+		Channel:   r.Channel,
+	}, nil
+}
+
+// unixTime converts Unix seconds to a time, with 0 as the zero time.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
 // Stop stops a playback session.
 func (a *API) Stop(c *gin.Context) {
 	sessionID := c.Param("id")
@@ -143,9 +362,10 @@ func (a *API) Stop(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Stop request: session=%s\n", sessionID)
+	logger.Infof("[API] Stop request: session=%s", sessionID)
 
 	a.sessions.Stop(sessionID)
+	a.audit(c, AuditStop, sessionID, nil, nil)
 
 	c.JSON(http.StatusOK, PlayResponse{
 		Status:    "stopped",
@@ -164,11 +384,12 @@ func (a *API) Pause(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Pause request: session=%s\n", sessionID)
+	logger.Infof("[API] Pause request: session=%s", sessionID)
 
 	err := a.sessions.Pause(sessionID)
+	a.audit(c, AuditPause, sessionID, nil, err)
 	if err != nil {
-		c.JSON(http.StatusNotFound, PlayResponse{
+		c.JSON(httpStatus(err), PlayResponse{
 			Status:    "error",
 			SessionID: sessionID,
 			Message:   err.Error(),
@@ -193,11 +414,12 @@ func (a *API) Resume(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Resume request: session=%s\n", sessionID)
+	logger.Infof("[API] Resume request: session=%s", sessionID)
 
 	err := a.sessions.Resume(sessionID)
+	a.audit(c, AuditResume, sessionID, nil, err)
 	if err != nil {
-		c.JSON(http.StatusNotFound, PlayResponse{
+		c.JSON(httpStatus(err), PlayResponse{
 			Status:    "error",
 			SessionID: sessionID,
 			Message:   err.Error(),
@@ -211,6 +433,218 @@ func (a *API) Resume(c *gin.Context) {
 	})
 }
 
+// Feedback receives consumer buffer health for adaptive bitrate.
+func (a *API) Feedback(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, FeedbackResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   fmt.Sprintf("invalid request: %v", err),
+		})
+		return
+	}
+
+	bitrate, err := a.sessions.Feedback(sessionID, Feedback{
+		Buffered:  time.Duration(req.BufferedMs) * time.Millisecond,
+		Underruns: req.Underruns,
+	})
+	if err != nil {
+		c.JSON(httpStatus(err), FeedbackResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, FeedbackResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		Bitrate:   bitrate,
+	})
+}
+
+// SetBitrate changes a session's Opus bitrate, e.g. when the Discord
+// channel's bitrate changes mid-song.
+func (a *API) SetBitrate(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var req BitrateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, FeedbackResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   fmt.Sprintf("invalid request: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("[API] Bitrate request: session=%s bitrate=%d", sessionID, req.Bitrate)
+
+	bitrate, err := a.sessions.SetBitrate(sessionID, req.Bitrate)
+	a.audit(c, AuditBitrate, sessionID, map[string]any{"bitrate": req.Bitrate}, err)
+	if err != nil {
+		c.JSON(httpStatus(err), FeedbackResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, FeedbackResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		Bitrate:   bitrate,
+	})
+}
+
+// GetSettings returns the saved settings for a session ID.
+func (a *API) GetSettings(c *gin.Context) {
+	sessionID := c.Param("id")
+	settings := a.sessions.Settings().Get(sessionID)
+	c.JSON(http.StatusOK, SettingsResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		Settings:  &settings,
+	})
+}
+
+// UpdateSettings changes the saved settings for a session ID. Volume and
+// filters take effect on the next play; speed and pitch also change the
+// playing track.
+func (a *API) UpdateSettings(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var patch SettingsPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, SettingsResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   fmt.Sprintf("invalid request: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("[API] Settings update: session=%s", sessionID)
+	a.updateSettings(c, sessionID, patch)
+}
+
+// FiltersPatch is the request body for PATCH /session/:id/filters: the
+// filter settings, each changed only if present.
+type FiltersPatch struct {
+	EQ      *string   `json:"eq"`
+	Speed   *float64  `json:"speed"`
+	Pitch   *float64  `json:"pitch"`
+	Effects *[]string `json:"effects"`
+}
+
+// UpdateFilters changes a session's filter settings like UpdateSettings:
+// speed and pitch apply to the playing track at once, the equalizer and
+// effects from the next play.
+func (a *API) UpdateFilters(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var filters FiltersPatch
+	if err := c.ShouldBindJSON(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, SettingsResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   fmt.Sprintf("invalid request: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("[API] Filters update: session=%s", sessionID)
+	a.updateSettings(c, sessionID, SettingsPatch{
+		EQ:      filters.EQ,
+		Speed:   filters.Speed,
+		Pitch:   filters.Pitch,
+		Effects: filters.Effects,
+	})
+}
+
+// updateSettings applies patch to sessionID's settings and responds with
+// the result.
+func (a *API) updateSettings(c *gin.Context, sessionID string, patch SettingsPatch) {
+	settings, err := a.sessions.Settings().Update(sessionID, patch)
+	a.audit(c, AuditSettings, sessionID, map[string]any{"patch": patch}, err)
+	if err != nil {
+		c.JSON(httpStatus(err), SettingsResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   err.Error(),
+		})
+		return
+	}
+	if patch.Speed != nil || patch.Pitch != nil {
+		a.sessions.SetTempo(sessionID, settings.speed(), settings.Pitch)
+	}
+
+	c.JSON(http.StatusOK, SettingsResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		Settings:  &settings,
+	})
+}
+
+// Filters lists the effects a session's settings can enable, e.g. to build
+// filter toggles in the client; PATCH /session/:id/filters sets them.
+func (a *API) Filters(c *gin.Context) {
+	names := encoder.Effects()
+	effects := make([]FilterInfo, len(names))
+	for i, name := range names {
+		effects[i] = FilterInfo{Name: name, Description: encoder.EffectDescription(name)}
+	}
+	c.JSON(http.StatusOK, FiltersResponse{
+		Effects: effects,
+		EQ:      "freq=gain bands, comma-separated (e.g. 60=4,1k=-2; gain -20..20 dB)",
+		Speed:   [2]float64{encoder.MinSpeed, encoder.MaxSpeed},
+		Pitch:   [2]float64{-encoder.MaxPitch, encoder.MaxPitch},
+	})
+}
+
+// FormatRequest is the request body for the format endpoint.
+type FormatRequest struct {
+	Format string `json:"format" binding:"required"` // pcm, opus or web
+}
+
+// SetFormat switches a session's output format at its current position.
+func (a *API) SetFormat(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var req FormatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PlayResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   fmt.Sprintf("invalid request: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("[API] Format request: session=%s format=%s", sessionID, req.Format)
+
+	err := a.sessions.SetFormat(sessionID, req.Format)
+	a.audit(c, AuditFormat, sessionID, map[string]any{"format": req.Format}, err)
+	if err != nil {
+		c.JSON(httpStatus(err), PlayResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PlayResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+	})
+}
+
 // Status returns the status of a playback session.
 func (a *API) Status(c *gin.Context) {
 	sessionID := c.Param("id")
@@ -230,12 +664,28 @@ func (a *API) Status(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, StatusResponse{
+	resp := StatusResponse{
 		SessionID: sessionID,
 		Status:    session.GetStateString(),
 		BytesSent: session.BytesSent,
+		Position:  session.Position(),
+		Remaining: session.Remaining(),
+		Speed:     session.Speed(),
 		URL:       session.URL,
-	})
+
+		Diagnostics: session.Diagnostics(),
+	}
+	if stats, ok := session.BufferStats(); ok {
+		resp.Buffer = &BufferStats{
+			ChunksBuffered: stats.ChunksBuffered,
+			ChunksDropped:  stats.ChunksDropped,
+			Underruns:      stats.Underruns,
+			Queued:         stats.Queued,
+			BufferedMs:     stats.Buffered.Milliseconds(),
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // Metadata extracts track metadata without starting playback.
@@ -248,7 +698,7 @@ func (a *API) Metadata(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Metadata request: url=%s\n", url)
+	logger.Infof("[API] Metadata request: url=%s", url)
 
 	extractor := youtube.New()
 	if !extractor.CanHandle(url) {
@@ -262,9 +712,9 @@ func (a *API) Metadata(c *gin.Context) {
 	// Check if it's a playlist
 	isPlaylist := extractor.IsPlaylist(url)
 
-	meta, err := extractor.ExtractMetadata(url)
+	meta, err := extractor.ExtractMetadataContext(c.Request.Context(), url)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, MetadataResponse{
+		c.JSON(httpStatus(err), MetadataResponse{
 			URL:   url,
 			Error: fmt.Sprintf("failed to extract metadata: %v", err),
 		})
@@ -272,14 +722,33 @@ func (a *API) Metadata(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, MetadataResponse{
-		URL:        url,
-		Title:      meta.Title,
-		Duration:   meta.Duration,
-		Thumbnail:  meta.Thumbnail,
-		IsPlaylist: isPlaylist,
+		URL:           url,
+		Title:         meta.Title,
+		Duration:      meta.Duration,
+		Thumbnail:     meta.Thumbnail,
+		IsPlaylist:    isPlaylist,
+		Uploader:      meta.Uploader,
+		Artist:        meta.Artist,
+		Track:         meta.Track,
+		Album:         meta.Album,
+		UploadDate:    isoDate(meta.UploadDate),
+		ViewCount:     meta.ViewCount,
+		LikeCount:     meta.LikeCount,
+		AgeRestricted: meta.AgeLimit >= 18,
+		Thumbnails:    meta.Thumbnails,
 	})
 }
 
+// isoDate converts yt-dlp's YYYYMMDD dates to YYYY-MM-DD. Anything else is
+// returned unchanged.
+func isoDate(date string) string {
+	t, err := time.Parse("20060102", date)
+	if err != nil {
+		return date
+	}
+	return t.Format(time.DateOnly)
+}
+
 // Playlist extracts all videos from a YouTube playlist.
 func (a *API) Playlist(c *gin.Context) {
 	url := c.Query("url")
@@ -290,7 +759,23 @@ func (a *API) Playlist(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Playlist request: url=%s\n", url)
+	opts := youtube.PlaylistOptions{
+		Dedupe:  c.Query("dedupe") == "true",
+		Shuffle: c.Query("shuffle") == "true",
+	}
+	err := queryInts(c, map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset})
+	if err == nil && opts.Shuffle && c.Query("stream") == "true" {
+		err = fmt.Errorf("shuffle can't be combined with stream")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, PlaylistResponse{
+			URL:   url,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("[API] Playlist request: url=%s limit=%d offset=%d", url, opts.Limit, opts.Offset)
 
 	extractor := youtube.New()
 	if !extractor.CanHandle(url) {
@@ -309,9 +794,14 @@ func (a *API) Playlist(c *gin.Context) {
 		return
 	}
 
-	entries, err := extractor.ExtractPlaylist(url)
+	if c.Query("stream") == "true" {
+		a.streamPlaylist(c, extractor, url, opts)
+		return
+	}
+
+	page, err := extractor.ExtractPlaylistPage(c.Request.Context(), url, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PlaylistResponse{
+		c.JSON(httpStatus(err), PlaylistResponse{
 			URL:   url,
 			Error: fmt.Sprintf("failed to extract playlist: %v", err),
 		})
@@ -319,9 +809,10 @@ func (a *API) Playlist(c *gin.Context) {
 	}
 
 	// Convert to API response type
-	apiEntries := make([]PlaylistEntry, len(entries))
-	for i, e := range entries {
+	apiEntries := make([]PlaylistEntry, len(page.Entries))
+	for i, e := range page.Entries {
 		apiEntries[i] = PlaylistEntry{
+			ID:        e.ID,
 			URL:       e.URL,
 			Title:     e.Title,
 			Duration:  e.Duration,
@@ -330,9 +821,112 @@ func (a *API) Playlist(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, PlaylistResponse{
+		URL:        url,
+		Count:      len(apiEntries),
+		Total:      page.Total,
+		NextOffset: page.NextOffset,
+		Entries:    apiEntries,
+	})
+}
+
+// streamPlaylist answers /playlist?stream=true with NDJSON: one
+// PlaylistEntry per line as yt-dlp lists it, then a PlaylistStreamEnd
+// line. Failures before the first entry get the usual error response.
+func (a *API) streamPlaylist(c *gin.Context, extractor *youtube.Extractor, url string, opts youtube.PlaylistOptions) {
+	enc := json.NewEncoder(c.Writer)
+	count := 0
+	page, err := extractor.StreamPlaylist(c.Request.Context(), url, opts, func(e youtube.PlaylistEntry) error {
+		if count == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		count++
+		if err := enc.Encode(PlaylistEntry{
+			ID:        e.ID,
+			URL:       e.URL,
+			Title:     e.Title,
+			Duration:  e.Duration,
+			Thumbnail: e.Thumbnail,
+		}); err != nil {
+			return err // Client went away
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && count == 0 {
+		c.JSON(httpStatus(err), PlaylistResponse{
+			URL:   url,
+			Error: fmt.Sprintf("failed to extract playlist: %v", err),
+		})
+		return
+	}
+	if count == 0 {
+		c.Header("Content-Type", "application/x-ndjson") // Empty page past the end
+	}
+
+	end := PlaylistStreamEnd{Done: err == nil, Count: count, Total: page.Total, NextOffset: page.NextOffset}
+	if err != nil {
+		end.Error = fmt.Sprintf("failed to extract playlist: %v", err)
+	}
+	enc.Encode(end)
+}
+
+// Channel lists a channel's or artist's most viewed videos, for "play
+// artist" commands.
+func (a *API) Channel(c *gin.Context) {
+	url := c.Query("url")
+	if url == "" {
+		c.JSON(http.StatusBadRequest, ChannelResponse{
+			Error: "url query parameter is required",
+		})
+		return
+	}
+
+	var limit int
+	if err := queryInts(c, map[string]*int{"limit": &limit}); err != nil {
+		c.JSON(http.StatusBadRequest, ChannelResponse{
+			URL:   url,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("[API] Channel request: url=%s limit=%d", url, limit)
+
+	extractor := youtube.New()
+	if !extractor.IsChannel(url) {
+		c.JSON(http.StatusBadRequest, ChannelResponse{
+			URL:   url,
+			Error: "URL is not a YouTube channel",
+		})
+		return
+	}
+
+	tracks, err := extractor.ChannelTopTracks(c.Request.Context(), url, limit)
+	if err != nil {
+		c.JSON(httpStatus(err), ChannelResponse{
+			URL:   url,
+			Error: fmt.Sprintf("failed to list channel: %v", err),
+		})
+		return
+	}
+
+	entries := make([]PlaylistEntry, len(tracks.Entries))
+	for i, e := range tracks.Entries {
+		entries[i] = PlaylistEntry{
+			ID:        e.ID,
+			URL:       e.URL,
+			Title:     e.Title,
+			Duration:  e.Duration,
+			Thumbnail: e.Thumbnail,
+		}
+	}
+
+	c.JSON(http.StatusOK, ChannelResponse{
 		URL:     url,
-		Count:   len(apiEntries),
-		Entries: apiEntries,
+		Channel: tracks.Channel,
+		Count:   len(entries),
+		Entries: entries,
 	})
 }
 
@@ -346,13 +940,22 @@ func (a *API) Search(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[API] Search request: q=%s\n", query)
+	opts, err := searchOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, SearchResponse{
+			Query: query,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("[API] Search request: q=%s limit=%d offset=%d", query, opts.Limit, opts.Offset)
 
 	extractor := youtube.New()
 
-	results, err := extractor.Search(query, 5)
+	page, err := extractor.SearchPage(c.Request.Context(), query, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, SearchResponse{
+		c.JSON(httpStatus(err), SearchResponse{
 			Query: query,
 			Error: fmt.Sprintf("search failed: %v", err),
 		})
@@ -360,8 +963,8 @@ func (a *API) Search(c *gin.Context) {
 	}
 
 	// Convert to API response type
-	apiResults := make([]SearchResult, len(results))
-	for i, r := range results {
+	apiResults := make([]SearchResult, len(page.Results))
+	for i, r := range page.Results {
 		apiResults[i] = SearchResult{
 			ID:        r.ID,
 			URL:       r.URL,
@@ -373,8 +976,70 @@ func (a *API) Search(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, SearchResponse{
-		Query:   query,
-		Count:   len(apiResults),
-		Results: apiResults,
+		Query:      query,
+		Count:      len(apiResults),
+		Results:    apiResults,
+		NextOffset: page.NextOffset,
 	})
 }
+
+// Suggest returns YouTube's completions for a partial query, fast enough
+// for Discord autocomplete.
+func (a *API) Suggest(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusOK, SuggestResponse{Suggestions: []string{}}) // Autocomplete asks before anything is typed
+		return
+	}
+
+	suggestions, err := a.suggester.Suggest(c.Request.Context(), query)
+	if err != nil {
+		logger.Warnf("[API] Suggest failed for q=%s: %v", query, err)
+		c.JSON(httpStatus(err), SuggestResponse{
+			Query: query,
+			Error: fmt.Sprintf("suggest failed: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, SuggestResponse{
+		Query:       query,
+		Suggestions: suggestions,
+	})
+}
+
+// searchOptions reads the paging and filter query parameters of /search:
+// limit, offset, min_duration and max_duration (seconds), upload_date
+// (hour, today, week, month or year) and channel.
+func searchOptions(c *gin.Context) (youtube.SearchOptions, error) {
+	opts := youtube.SearchOptions{
+		UploadDate: c.Query("upload_date"),
+		Channel:    c.Query("channel"),
+	}
+	err := queryInts(c, map[string]*int{
+		"limit":        &opts.Limit,
+		"offset":       &opts.Offset,
+		"min_duration": &opts.MinDuration,
+		"max_duration": &opts.MaxDuration,
+	})
+	if err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// queryInts reads the named integer query parameters into their targets,
+// leaving absent ones unchanged. Negative values are rejected.
+func queryInts(c *gin.Context, params map[string]*int) error {
+	for name, dst := range params {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		*dst = n
+	}
+	return nil
+}
@@ -3,12 +3,18 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/platform"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
 )
 
 func init() {
@@ -93,6 +99,49 @@ func TestPlayEndpoint_MissingURL(t *testing.T) {
 	}
 }
 
+func TestPlayEndpoint_Query(t *testing.T) {
+	sessions := NewSessionManager(context.Background())
+	api := NewAPI(sessions)
+	var searched string
+	api.search = func(ctx context.Context, query string, limit int) ([]youtube.SearchResult, error) {
+		searched = query
+		if query == "nothing" {
+			return nil, nil
+		}
+		return []youtube.SearchResult{{ID: "abc", URL: "https://www.youtube.com/watch?v=abc", Title: "Song", Duration: 200}}, nil
+	}
+	router := gin.New()
+	router.POST("/session/:id/play", api.Play)
+
+	play := func(body string) (*httptest.ResponseRecorder, PlayResponse) {
+		req, _ := http.NewRequest("POST", "/session/query-session/play", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp PlayResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := play(`{"query": "some song"}`)
+	if w.Code != http.StatusOK || resp.Track == nil {
+		t.Fatalf("expected the top result to play, got %d %+v", w.Code, resp)
+	}
+	if searched != "some song" || resp.Track.ID != "abc" || resp.Track.Title != "Song" {
+		t.Errorf("unexpected track %+v for query %q", resp.Track, searched)
+	}
+	if url := sessions.Get("query-session").URL; url != resp.Track.URL {
+		t.Errorf("expected session to play %s, got %s", resp.Track.URL, url)
+	}
+
+	if w, _ := play(`{"query": "nothing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without results, got %d", w.Code)
+	}
+	if w, _ := play(`{"query": "a", "url": "https://youtube.com/watch?v=x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 with both url and query, got %d", w.Code)
+	}
+}
+
 func TestPlayEndpoint_InvalidJSON(t *testing.T) {
 	router, _ := setupTestRouter()
 
@@ -170,6 +219,32 @@ func TestStatusEndpoint_NoSession(t *testing.T) {
 	}
 }
 
+func TestStatusEndpoint_Diagnostics(t *testing.T) {
+	router, sessions := setupTestRouter()
+	exitCode := 1
+	sessions.sessions["guild-1"] = &Session{
+		ID:           "guild-1",
+		State:        StateStreaming,
+		seekOffset:   42,
+		retryCount:   2,
+		lastError:    "Connection reset by peer",
+		lastExitCode: &exitCode,
+	}
+
+	req, _ := http.NewRequest("GET", "/session/guild-1/status", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp StatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Diagnostics == nil || resp.RetryCount != 2 || resp.LastError != "Connection reset by peer" ||
+		resp.LastExitCode == nil || *resp.LastExitCode != 1 || resp.SeekOffset != 42 {
+		t.Errorf("unexpected diagnostics in %s", w.Body.String())
+	}
+}
+
 func TestSessionState_String(t *testing.T) {
 	tests := []struct {
 		state    SessionState
@@ -220,3 +295,190 @@ func TestPlaylistEndpoint_MissingURL(t *testing.T) {
 		t.Errorf("expected status 400, got %d", w.Code)
 	}
 }
+
+func TestSearchEndpoint_InvalidFilters(t *testing.T) {
+	router, _ := setupTestRouter()
+	api := NewAPI(NewSessionManager(context.Background()))
+	router.GET("/search", api.Search)
+
+	for _, query := range []string{
+		"q=lofi&limit=ten",
+		"q=lofi&offset=-5",
+		"q=lofi&min_duration=600&max_duration=60",
+		"q=lofi&upload_date=decade",
+	} {
+		req, _ := http.NewRequest("GET", "/search?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestSuggestEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["lofi",["lofi hip hop","lofi girl"]]`))
+	}))
+	defer srv.Close()
+	router, _ := setupTestRouter()
+	api := NewAPI(NewSessionManager(context.Background()))
+	api.suggester.Endpoint = srv.URL + "/?client=firefox"
+	router.GET("/suggest", api.Suggest)
+
+	req, _ := http.NewRequest("GET", "/suggest?q=lofi", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp SuggestResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Suggestions) != 2 || resp.Suggestions[0] != "lofi hip hop" {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPlaylistEndpoint_InvalidOptions(t *testing.T) {
+	router, _ := setupTestRouter()
+	api := NewAPI(NewSessionManager(context.Background()))
+	router.GET("/playlist", api.Playlist)
+
+	for _, query := range []string{"limit=-1", "shuffle=true&stream=true"} {
+		req, _ := http.NewRequest("GET", "/playlist?url=https://www.youtube.com/playlist?list=PL1&"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestChannelEndpoint_InvalidRequest(t *testing.T) {
+	router, _ := setupTestRouter()
+	api := NewAPI(NewSessionManager(context.Background()))
+	router.GET("/channel", api.Channel)
+
+	for _, query := range []string{"", "url=@Artist&limit=-1", "url=https://www.youtube.com/watch?v=dQw4w9WgXcQ"} {
+		req, _ := http.NewRequest("GET", "/channel?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errSessionNotFound, http.StatusNotFound},
+		{errs.New(errs.ErrExtraction, "yt-dlp failed"), http.StatusBadGateway},
+		{errs.New(errs.ErrExtraction, "timed out: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errs.New(errs.ErrPipeline, "ffmpeg exited"), http.StatusInternalServerError},
+		{errNoConnection, http.StatusServiceUnavailable},
+		{errNoABR, http.StatusBadRequest},
+		{errDraining, http.StatusServiceUnavailable},
+		{errInvalidSeek, http.StatusBadRequest},
+		{errInvalidRange, http.StatusBadRequest},
+		{errInvalidRegion, http.StatusBadRequest},
+		{errNotPlaying, http.StatusConflict},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := httpStatus(tt.err); got != tt.want {
+			t.Errorf("httpStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDepsEndpoint(t *testing.T) {
+	api := NewAPI(NewSessionManager(context.Background()))
+	api.deps = newDepsProbe(execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if name == "yt-dlp" {
+			return exec.CommandContext(ctx, "echo", "2025.01.15")
+		}
+		return exec.CommandContext(ctx, "sh", "-c", "exit 127") // ffmpeg not installed
+	}))
+	api.SetDegraded([]string{"segmented downloads"})
+	router := SetupRouter(api)
+
+	req, _ := http.NewRequest("GET", "/debug/deps", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp DepsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.YtDlp.Found || resp.YtDlp.Version != "2025.01.15" {
+		t.Errorf("unexpected yt-dlp status %+v", resp.YtDlp)
+	}
+	if resp.FFmpeg.Found || resp.FFmpeg.Error == "" {
+		t.Errorf("expected ffmpeg to be reported missing, got %+v", resp.FFmpeg)
+	}
+	if resp.CookieMode == "" {
+		t.Error("expected a cookie mode")
+	}
+	if len(resp.Degraded) != 1 || resp.Degraded[0] != "segmented downloads" {
+		t.Errorf("unexpected degraded features %v", resp.Degraded)
+	}
+
+	req, _ = http.NewRequest("GET", "/health", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var health map[string]any
+	json.Unmarshal(w.Body.Bytes(), &health)
+	if degraded, _ := health["degraded"].([]any); len(degraded) != 1 {
+		t.Errorf("expected degraded features in health, got %v", health["degraded"])
+	}
+	if health["yt_dlp_version"] != "2025.01.15" || health["ffmpeg_version"] != "missing" {
+		t.Errorf("unexpected health dependency fields %v", health)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := SetupRouter(NewAPI(NewSessionManager(context.Background())))
+	if _, err := platform.ExtractStreamURL(context.Background(), &fakeExtractor{}, "fake://metrics"); err != nil {
+		t.Fatalf("ExtractStreamURL failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected a text exposition, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `music_bot_extraction_duration_seconds_count{platform="fake",op="stream_url"}`) {
+		t.Errorf("expected the extraction to be recorded, got:\n%s", w.Body.String())
+	}
+}
+
+func TestIsoDate(t *testing.T) {
+	for in, want := range map[string]string{"20240131": "2024-01-31", "": "", "2024": "2024"} {
+		if got := isoDate(in); got != want {
+			t.Errorf("isoDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSessionEndpoints_InvalidID(t *testing.T) {
+	api := NewAPI(NewSessionManager(context.Background()))
+	router := SetupRouter(api)
+
+	for _, path := range []string{"/session/0123456789012345678901234/status", "/session/guild%201/status"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit log limits
+const (
+	maxAuditEntries    = 10000 // Entries kept in memory for GET /admin/audit
+	defaultAuditLimit  = 100
+	maxAuditQueryLimit = 1000
+)
+
+// callerHeader names the caller on HTTP requests, e.g. the Discord user a
+// bot acts for. Without it an API key is identified by its fingerprint.
+const callerHeader = "X-Caller"
+
+// Audited actions
+const (
+	AuditPlay        = "play"
+	AuditStop        = "stop"
+	AuditPause       = "pause"
+	AuditResume      = "resume"
+	AuditSeek        = "seek"
+	AuditFormat      = "format"
+	AuditBitrate     = "bitrate"
+	AuditSettings    = "settings"
+	AuditQueueSave   = "queue_save"
+	AuditQueueImport = "queue_import"
+	AuditStopAll     = "stop_all"
+	AuditDrain       = "drain"
+	AuditUndrain     = "undrain"
+	AuditCookies     = "cookies_reload"
+	AuditCachePurge  = "cache_purge"
+)
+
+// AuditEntry records one control action.
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	Action    string         `json:"action"`
+	SessionID string         `json:"session_id,omitempty"`
+	Caller    string         `json:"caller,omitempty"`  // X-Caller / socket caller, else "key:<fingerprint>" or "uid:<n>"
+	Address   string         `json:"address,omitempty"` // Client IP, or "unix" for the audio socket
+	Via       string         `json:"via"`               // "http" or "socket"
+	Params    map[string]any `json:"params,omitempty"`
+	Error     string         `json:"error,omitempty"` // Why the action failed
+}
+
+// AuditLog keeps the latest control actions in memory and optionally
+// appends every action to a JSON-lines file.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry // Ring buffer, oldest at next once full
+	next    int
+	file    *os.File // nil keeps entries in memory only
+}
+
+// NewAuditLog creates an in-memory audit log.
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// OpenAuditLog opens (or creates) the audit file at path for appending.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Record adds entry, stamped with the current time if it has none.
+func (l *AuditLog) Record(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < maxAuditEntries {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+		l.next = (l.next + 1) % maxAuditEntries
+	}
+	if l.file != nil {
+		line, _ := json.Marshal(entry)
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			logger.Warnf("[Audit] Write failed: %v", err)
+		}
+	}
+}
+
+// AuditFilter selects entries for Query; zero fields match anything.
+type AuditFilter struct {
+	SessionID string
+	Action    string
+	Caller    string
+	Since     time.Time
+	Limit     int // Most entries returned (newest first)
+}
+
+// Query returns the entries matching filter, newest first.
+func (l *AuditLog) Query(filter AuditFilter) []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	matches := []AuditEntry{}
+	for i := range len(l.entries) {
+		// Walk back from the newest entry
+		entry := l.entries[(l.next-1-i+2*len(l.entries))%len(l.entries)]
+		if filter.Limit > 0 && len(matches) == filter.Limit {
+			break
+		}
+		if (filter.SessionID != "" && entry.SessionID != filter.SessionID) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.Caller != "" && entry.Caller != filter.Caller) ||
+			entry.Time.Before(filter.Since) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+	return matches
+}
+
+// Close closes the audit file.
+func (l *AuditLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// SetAuditLog replaces where control actions are recorded (in memory only
+// by default).
+func (m *SessionManager) SetAuditLog(log *AuditLog) {
+	m.audit = log
+}
+
+// auditCommand records a socket command from conn.
+func (m *SessionManager) auditCommand(conn net.Conn, action string, cmd Command, params map[string]any, err error) {
+	caller := cmd.Caller
+	if caller == "" {
+		if uid, _, credErr := peerCredentials(conn); credErr == nil {
+			caller = "uid:" + strconv.Itoa(uid)
+		}
+	}
+	entry := AuditEntry{
+		Action:    action,
+		SessionID: cmd.SessionID,
+		Caller:    caller,
+		Address:   socketAddress(conn),
+		Via:       "socket",
+		Params:    params,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.audit.Record(entry)
+}
+
+// socketAddress returns the client address of conn for the audit log: the
+// remote address on TCP, else the network name ("unix").
+func socketAddress(conn net.Conn) string {
+	if conn == nil {
+		return ""
+	}
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	if addr.Network() == "tcp" {
+		return addr.String()
+	}
+	return addr.Network()
+}
+
+// audit records an HTTP control action on sessionID (empty for actions on
+// every session).
+func (a *API) audit(c *gin.Context, action, sessionID string, params map[string]any, err error) {
+	entry := AuditEntry{
+		Action:    action,
+		SessionID: sessionID,
+		Caller:    requestCaller(c.Request),
+		Address:   c.ClientIP(),
+		Via:       "http",
+		Params:    params,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	a.sessions.audit.Record(entry)
+}
+
+// requestCaller identifies who made r: the X-Caller header, else the
+// fingerprint of its bearer token (never the token itself).
+func requestCaller(r *http.Request) string {
+	if caller := strings.TrimSpace(r.Header.Get(callerHeader)); caller != "" {
+		return caller
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "key:" + hex.EncodeToString(sum[:4])
+	}
+	return ""
+}
+
+// AuditResponse is the response for GET /admin/audit.
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// Audit handles GET /admin/audit?session_id=&action=&caller=&since=&limit=,
+// listing recorded control actions newest first. since is RFC 3339.
+func (a *API) Audit(c *gin.Context) {
+	filter := AuditFilter{
+		SessionID: c.Query("session_id"),
+		Action:    c.Query("action"),
+		Caller:    c.Query("caller"),
+		Limit:     defaultAuditLimit,
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time"})
+			return
+		}
+		filter.Since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxAuditQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditQueryLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+	c.JSON(http.StatusOK, AuditResponse{Entries: a.sessions.audit.Query(filter)})
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_Query(t *testing.T) {
+	log := NewAuditLog()
+	start := time.Now().UTC()
+	log.Record(AuditEntry{Time: start.Add(-time.Hour), Action: AuditPlay, SessionID: "a", Caller: "alice"})
+	log.Record(AuditEntry{Time: start, Action: AuditStop, SessionID: "a", Caller: "bob"})
+	log.Record(AuditEntry{Time: start.Add(time.Second), Action: AuditPlay, SessionID: "b", Caller: "alice"})
+
+	if got := log.Query(AuditFilter{}); len(got) != 3 || got[0].SessionID != "b" || got[2].Time != start.Add(-time.Hour) {
+		t.Errorf("expected all entries newest first, got %+v", got)
+	}
+	if got := log.Query(AuditFilter{SessionID: "a"}); len(got) != 2 {
+		t.Errorf("expected 2 entries for session a, got %+v", got)
+	}
+	if got := log.Query(AuditFilter{Action: AuditPlay, Caller: "alice", Since: start}); len(got) != 1 || got[0].SessionID != "b" {
+		t.Errorf("expected alice's play since start, got %+v", got)
+	}
+	if got := log.Query(AuditFilter{Limit: 1}); len(got) != 1 || got[0].SessionID != "b" {
+		t.Errorf("expected only the newest entry, got %+v", got)
+	}
+}
+
+func TestAuditLog_Ring(t *testing.T) {
+	log := NewAuditLog()
+	for i := range maxAuditEntries + 5 {
+		log.Record(AuditEntry{Action: AuditSeek, Params: map[string]any{"i": i}})
+	}
+	got := log.Query(AuditFilter{})
+	if len(got) != maxAuditEntries {
+		t.Fatalf("expected %d entries kept, got %d", maxAuditEntries, len(got))
+	}
+	if got[0].Params["i"] != maxAuditEntries+4 || got[len(got)-1].Params["i"] != 5 {
+		t.Errorf("expected the newest entries kept, got newest %v oldest %v", got[0].Params, got[len(got)-1].Params)
+	}
+}
+
+func TestAuditLog_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	log, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Record(AuditEntry{Action: AuditStop, SessionID: "a", Via: "http"})
+	log.Record(AuditEntry{Action: AuditDrain, Via: "http"})
+	log.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var actions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		actions = append(actions, entry.Action)
+	}
+	if len(actions) != 2 || actions[0] != AuditStop || actions[1] != AuditDrain {
+		t.Errorf("expected stop then drain in the file, got %v", actions)
+	}
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	api.SetAdminToken("secret")
+	router := SetupRouter(api)
+
+	req, _ := http.NewRequest("POST", "/session/guild-1/stop", nil)
+	req.Header.Set(callerHeader, "discord:1234")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	adminRequest(router, "POST", "/admin/drain", "secret")
+
+	w := adminRequest(router, "GET", "/admin/audit?session_id=guild-1", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AuditResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Entries) != 1 {
+		t.Fatalf("expected 1 entry for guild-1, got %+v", resp.Entries)
+	}
+	if entry := resp.Entries[0]; entry.Action != AuditStop || entry.Caller != "discord:1234" || entry.Via != "http" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	// Without X-Caller the admin key is identified by its fingerprint
+	w = adminRequest(router, "GET", "/admin/audit?action=drain", "secret")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Entries) != 1 || !strings.HasPrefix(resp.Entries[0].Caller, "key:") {
+		t.Errorf("expected the drain by key fingerprint, got %+v", resp.Entries)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Error("audit log exposes the admin token")
+	}
+
+	if w := adminRequest(router, "GET", "/admin/audit?since=yesterday", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a bad since, got %d", w.Code)
+	}
+	if w := adminRequest(router, "GET", "/admin/audit", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without the admin token, got %d", w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// BusEventType identifies the kind of event published on the EventBus.
+type BusEventType string
+
+const (
+	BusStateChanged BusEventType = "state"  // Session moved to a new State
+	BusRetry        BusEventType = "retry"  // Pipeline restarted after a premature end or stall
+	BusClientEvent  BusEventType = "client" // Event for clients (ready, finished, error, levels...)
+)
+
+// BusEvent is a session lifecycle event.
+type BusEvent struct {
+	Type      BusEventType
+	SessionID string
+	Time      time.Time
+
+	State   SessionState  // BusStateChanged: the new state
+	Attempt int           // BusRetry: retry number (1-based)
+	Delay   time.Duration // BusRetry: backoff before the new pipeline starts
+	Reason  string        // BusRetry: "premature_end", "stalled" or "url_expired"
+	Err     error         // Client error events: the underlying error
+	Event   any           // BusClientEvent: the JSON event sent to clients
+}
+
+// EventBus delivers session lifecycle events to subscribers (the audio
+// socket, and anything else that wants to observe sessions). Handlers run
+// synchronously on the publishing goroutine, in subscription order, so
+// client events stay ordered with audio; slow handlers must hand events
+// off to their own goroutine.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []busSubscriber
+	nextID      int
+}
+
+type busSubscriber struct {
+	id      int
+	handler func(BusEvent)
+}
+
+// NewEventBus creates an event bus with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers handler for all events and returns a function that
+// unsubscribes it.
+func (b *EventBus) Subscribe(handler func(BusEvent)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers = append(b.subscribers, busSubscriber{id: id, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subscribers {
+			if sub.id == id {
+				// Copy so a Publish iterating the old slice is unaffected
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers ev to every subscriber.
+func (b *EventBus) Publish(ev BusEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		sub.handler(ev)
+	}
+}
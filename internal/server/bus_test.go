@@ -0,0 +1,81 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestEventBus_SubscribeAndUnsubscribe(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	unsubscribeA := bus.Subscribe(func(ev BusEvent) { got = append(got, "a:"+ev.SessionID) })
+	bus.Subscribe(func(ev BusEvent) { got = append(got, "b:"+ev.SessionID) })
+
+	bus.Publish(BusEvent{Type: BusRetry, SessionID: "1"})
+	unsubscribeA()
+	unsubscribeA() // Idempotent
+	bus.Publish(BusEvent{Type: BusRetry, SessionID: "2"})
+
+	want := []string{"a:1", "b:1", "b:2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestSessionManager_PublishesLifecycle(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline("abc")
+	})
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan struct{})
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch ev.Type {
+		case BusStateChanged:
+			got = append(got, "state:"+ev.State.String())
+		case BusClientEvent:
+			event := ev.Event.(Event)
+			got = append(got, "client:"+string(event.Type))
+			if event.Type == EventFinished {
+				close(done)
+			}
+		}
+	})
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	go func() {
+		for range messages {
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for finished")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"state:extracting", "state:streaming", "client:ready", "state:stopped", "client:finished"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/cache"
+)
+
+// errCacheDisabled is returned by the cache endpoints when no cache is
+// configured.
+var errCacheDisabled = errors.New("disk cache is not enabled")
+
+// SetCache keeps download_first tracks and thumbnails in c, so replays skip
+// yt-dlp and the download. Without a cache each play downloads to a
+// temporary file.
+func (m *SessionManager) SetCache(c *cache.Cache) {
+	m.cache = c
+}
+
+// Cache returns the disk cache, or nil if none is configured.
+func (m *SessionManager) Cache() *cache.Cache {
+	return m.cache
+}
+
+// downloadKey is the cache key of url's download_first copy.
+func downloadKey(url string) string {
+	return "track:" + trackKey(url)
+}
+
+// cachedTrack returns the cached download_first copy of session's track,
+// pinned for the session, or "" if there is none.
+func (m *SessionManager) cachedTrack(session *Session) string {
+	if m.cache == nil || !session.settings.DownloadFirst {
+		return ""
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.download != "" {
+		return session.download
+	}
+	path, release, ok := m.cache.Get(downloadKey(session.URL))
+	if !ok {
+		return ""
+	}
+	logger.Infof("[Session] Playing %s from the cache", shortSessionID(session.ID))
+	session.download = path
+	session.releaseDownload = release
+	return path
+}
+
+// CachePurgeResponse is the response for DELETE /admin/cache.
+type CachePurgeResponse struct {
+	Status  string      `json:"status"`
+	Removed int         `json:"removed"` // Entries deleted (pinned ones in use are kept)
+	Stats   cache.Stats `json:"stats"`
+}
+
+// CacheStats handles GET /admin/cache.
+func (a *API) CacheStats(c *gin.Context) {
+	store := a.sessions.Cache()
+	if store == nil {
+		c.JSON(httpStatus(errCacheDisabled), gin.H{"error": errCacheDisabled.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, store.Stats())
+}
+
+// PurgeCache handles DELETE /admin/cache, deleting the entries of ?kind=
+// (all kinds if empty).
+func (a *API) PurgeCache(c *gin.Context) {
+	store := a.sessions.Cache()
+	if store == nil {
+		c.JSON(httpStatus(errCacheDisabled), gin.H{"error": errCacheDisabled.Error()})
+		return
+	}
+	kind := c.Query("kind")
+	removed := store.Purge(kind)
+	a.audit(c, AuditCachePurge, "", map[string]any{"kind": kind, "removed": removed}, nil)
+	logger.Infof("[Admin] Purged %d cache entries (kind %q)", removed, kind)
+	c.JSON(http.StatusOK, CachePurgeResponse{Status: "purged", Removed: removed, Stats: store.Stats()})
+}
+
+// VerifyCache handles POST /admin/cache/verify, checking every entry's
+// checksum and deleting corrupt ones.
+func (a *API) VerifyCache(c *gin.Context) {
+	store := a.sessions.Cache()
+	if store == nil {
+		c.JSON(httpStatus(errCacheDisabled), gin.H{"error": errCacheDisabled.Error()})
+		return
+	}
+	removed := store.Verify()
+	if removed > 0 {
+		logger.Warnf("[Admin] Cache verification removed %d corrupt entries", removed)
+	}
+	c.JSON(http.StatusOK, CachePurgeResponse{Status: "verified", Removed: removed, Stats: store.Stats()})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/cache"
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestSessionManager_DownloadFirstCache(t *testing.T) {
+	inputs := make(chan string, 4)
+	extractor := &fakeExtractor{}
+	sm, _ := newTestSessionManager(t, extractor, func(string) encoder.Pipeline {
+		return &inputPipeline{Pipeline: newFakePipeline("audio"), inputs: inputs}
+	})
+	store, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("cache.Open failed: %v", err)
+	}
+	sm.SetCache(store)
+	downloads := 0
+	sm.SetDownloader(func(ctx context.Context, streamURL, proxy, dst string) error {
+		downloads++
+		return os.WriteFile(dst, []byte("audio"), 0o600)
+	})
+	on := true
+	sm.Settings().Update("guild-1", SettingsPatch{DownloadFirst: &on})
+
+	var played []string
+	for range 2 {
+		sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: 120})
+		select {
+		case input := <-inputs:
+			played = append(played, input)
+		case <-time.After(2 * time.Second):
+			t.Fatal("pipeline not started")
+		}
+		// Extraction would fail now: the replay must come from the cache
+		extractor.err = errNoConnection
+	}
+	if downloads != 1 || played[0] != played[1] {
+		t.Errorf("expected one download played twice, got %d downloads playing %v", downloads, played)
+	}
+	if _, err := os.Stat(played[0]); err != nil {
+		t.Errorf("expected the track kept in the cache: %v", err)
+	}
+	if stats := store.Stats(); stats.Entries != 1 || stats.Hits != 1 || stats.Kinds["track"].Entries != 1 {
+		t.Errorf("unexpected cache stats %+v", stats)
+	}
+	sm.StopAll()
+}
+
+func TestAdminCache(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	api.SetAdminToken("secret")
+	router := SetupRouter(api)
+
+	if w := adminRequest(router, "GET", "/admin/cache", "secret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without a cache, got %d", w.Code)
+	}
+
+	store, _ := cache.Open(cache.Config{Dir: t.TempDir()})
+	api.sessions.SetCache(store)
+	src, _ := os.CreateTemp(store.Dir(), "track-*.mka")
+	src.WriteString("audio")
+	src.Close()
+	_, release, _ := store.Add("track:abc", src.Name())
+	release()
+
+	w := adminRequest(router, "GET", "/admin/cache", "secret")
+	var stats cache.Stats
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if w.Code != http.StatusOK || stats.Entries != 1 || stats.Bytes != 5 {
+		t.Errorf("expected one 5-byte entry, got %d %+v", w.Code, stats)
+	}
+
+	w = adminRequest(router, "DELETE", "/admin/cache?kind=track", "secret")
+	var resp CachePurgeResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Removed != 1 || resp.Stats.Entries != 0 {
+		t.Errorf("expected the entry purged, got %d %+v", w.Code, resp)
+	}
+	if got := api.sessions.audit.Query(AuditFilter{Action: AuditCachePurge}); len(got) != 1 {
+		t.Errorf("expected the purge audited, got %+v", got)
+	}
+	if w := adminRequest(router, "POST", "/admin/cache/verify", "secret"); w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for verify, got %d", w.Code)
+	}
+}
+
+func TestThumbnail_CachedOnDisk(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/dQw4w9WgXcQ/hqdefault.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("jpeg"))
+	}))
+	defer upstream.Close()
+
+	sm := NewSessionManager(context.Background())
+	api := NewAPI(sm)
+	api.thumbnailBase = upstream.URL + "/"
+	router := SetupRouter(api)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/thumbnail?url="+url, nil))
+		return w
+	}
+
+	// Without a cache the client is sent to YouTube
+	if w := get("dQw4w9WgXcQ"); w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a redirect without a cache, got %d", w.Code)
+	}
+
+	store, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("cache.Open failed: %v", err)
+	}
+	sm.SetCache(store)
+	for range 2 {
+		if w := get("https://www.youtube.com/watch?v=dQw4w9WgXcQ"); w.Code != http.StatusOK || w.Body.String() != "jpeg" {
+			t.Fatalf("expected the thumbnail, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the second request served from the cache, got %d fetches", requests)
+	}
+	if kinds := store.Stats().Kinds; kinds["thumb"].Entries != 1 {
+		t.Errorf("expected a thumb entry, got %+v", kinds)
+	}
+	if w := get("https://example.com/a.jpg"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-YouTube URL, got %d", w.Code)
+	}
+}
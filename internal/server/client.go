@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// maxFrameSize bounds one audio packet read by Client.
+const maxFrameSize = 16 << 20
+
+// Frame is one message from the server: an audio packet or a JSON event.
+type Frame struct {
+	SessionID string          // Audio packets: the session the audio belongs to
+	Audio     []byte          // Audio packets: the chunk (nil for events)
+	Event     json.RawMessage // Events: the JSON object (nil for audio)
+}
+
+// Client speaks the audio protocol from the client side, for Go consumers
+// on any transport (see InProcessTransport to embed the server).
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+
+	errMu sync.Mutex
+	err   error // Why Frames stopped
+}
+
+// NewClient wraps a connection to the server.
+func NewClient(conn net.Conn) *Client {
+	return &Client{conn: conn, reader: bufio.NewReaderSize(conn, 64*1024)}
+}
+
+// ReadFrame reads the next audio packet or event. Events are told apart
+// by their leading '{'; a packet's length header never starts with it.
+func (c *Client) ReadFrame() (Frame, error) {
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		return Frame{}, err
+	}
+	if first[0] == '{' {
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil {
+			return Frame{}, err
+		}
+		return Frame{Event: json.RawMessage(bytes.TrimSpace(line))}, nil
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return Frame{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length < sessionIDLen || length > maxFrameSize {
+		return Frame{}, fmt.Errorf("invalid packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, packet); err != nil {
+		return Frame{}, err
+	}
+	return Frame{
+		SessionID: strings.TrimRight(string(packet[:sessionIDLen]), " "),
+		Audio:     packet[sessionIDLen:],
+	}, nil
+}
+
+// Frames reads frames in the background and delivers them on the returned
+// channel, which is closed when the connection ends (see Err).
+func (c *Client) Frames() <-chan Frame {
+	frames := make(chan Frame, 64)
+	go func() {
+		defer close(frames)
+		for {
+			frame, err := c.ReadFrame()
+			if err != nil {
+				c.errMu.Lock()
+				c.err = err
+				c.errMu.Unlock()
+				return
+			}
+			frames <- frame
+		}
+	}()
+	return frames
+}
+
+// Err returns why the Frames channel was closed.
+func (c *Client) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// Send writes a command. Replies arrive as events.
+func (c *Client) Send(cmd Command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// Clip export configuration
+const (
+	maxClipLength      = 10 * time.Minute // Longest segment a clip may cover
+	maxConcurrentClips = 2                // Exports running at once; the rest wait
+	clipTTL            = time.Hour        // How long finished clips stay downloadable
+)
+
+// Clip job states.
+const (
+	ClipPending = "pending"
+	ClipRunning = "running"
+	ClipDone    = "done"
+	ClipError   = "error"
+)
+
+// errInvalidClip is returned for a clip request with a bad range or format.
+var errInvalidClip = errors.New("invalid clip request")
+
+// errClipNotFound is returned for an unknown or expired clip job.
+var errClipNotFound = errs.New(errs.ErrNotFound, "clip job not found")
+
+// errClipNotReady is returned when downloading a clip that isn't done.
+var errClipNotReady = errors.New("clip is not ready")
+
+// ClipRequest is the request body for POST /clip.
+type ClipRequest struct {
+	URL    string  `json:"url" binding:"required"`
+	Start  float64 `json:"start"`  // Segment start in seconds
+	End    float64 `json:"end"`    // Segment end in seconds
+	Format string  `json:"format"` // mp3 (default), opus, m4a or wav
+}
+
+// ClipJob reports the state of a clip export.
+type ClipJob struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"` // pending, running, done or error
+	URL       string    `json:"url"`
+	Start     float64   `json:"start"`
+	End       float64   `json:"end"`
+	Format    string    `json:"format"`
+	Size      int64     `json:"size,omitempty"`     // File size in bytes, once done
+	FileURL   string    `json:"file_url,omitempty"` // Download path, once done
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// clipJob is a ClipJob with its output file.
+type clipJob struct {
+	ClipJob
+	path     string
+	finished time.Time
+}
+
+// ClipManager runs clip exports in the background. Each job extracts the
+// stream URL, encodes the segment to a temporary file and keeps it for
+// clipTTL so it can be downloaded.
+type ClipManager struct {
+	sessions *SessionManager     // Platform registry and server lifetime
+	runner   execx.CommandRunner // Creates the FFmpeg process (replaceable in tests)
+	slots    chan struct{}       // Limits concurrent exports
+
+	mu   sync.Mutex
+	jobs map[string]*clipJob
+	dir  string // Created on the first job
+}
+
+// NewClipManager creates a clip manager resolving URLs with sessions'
+// platform registry.
+func NewClipManager(sessions *SessionManager) *ClipManager {
+	return &ClipManager{
+		sessions: sessions,
+		runner:   execx.Default,
+		slots:    make(chan struct{}, maxConcurrentClips),
+		jobs:     make(map[string]*clipJob),
+	}
+}
+
+// Submit validates req and queues its export. Returns the new job.
+func (m *ClipManager) Submit(req ClipRequest) (ClipJob, error) {
+	format, err := encoder.ParseClipFormat(req.Format)
+	if err != nil {
+		return ClipJob{}, fmt.Errorf("%w: %v", errInvalidClip, err)
+	}
+	if req.Start < 0 || req.End <= req.Start {
+		return ClipJob{}, fmt.Errorf("%w: end must be after start", errInvalidClip)
+	}
+	if length := time.Duration((req.End - req.Start) * float64(time.Second)); length > maxClipLength {
+		return ClipJob{}, fmt.Errorf("%w: clips are limited to %v", errInvalidClip, maxClipLength)
+	}
+
+	m.mu.Lock()
+	m.sweepLocked()
+	if m.dir == "" {
+		dir, err := os.MkdirTemp("", "natashi-clips-")
+		if err != nil {
+			m.mu.Unlock()
+			return ClipJob{}, fmt.Errorf("create clip directory: %w", err)
+		}
+		m.dir = dir
+	}
+	job := &clipJob{ClipJob: ClipJob{
+		ID:        rand.Text(),
+		Status:    ClipPending,
+		URL:       req.URL,
+		Start:     req.Start,
+		End:       req.End,
+		Format:    string(format),
+		CreatedAt: time.Now(),
+	}}
+	job.path = filepath.Join(m.dir, job.ID+"."+string(format))
+	m.jobs[job.ID] = job
+	snapshot := job.ClipJob
+	m.mu.Unlock()
+
+	logger.Infof("[Clip] Job %s: %s %.1fs-%.1fs as %s", job.ID, req.URL, req.Start, req.End, format)
+	go m.run(job, format)
+	return snapshot, nil
+}
+
+// Get returns the job with the given ID.
+func (m *ClipManager) Get(id string) (ClipJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked()
+	job, ok := m.jobs[id]
+	if !ok {
+		return ClipJob{}, errClipNotFound
+	}
+	return job.ClipJob, nil
+}
+
+// file returns the path and format of a finished job's clip.
+func (m *ClipManager) file(id string) (string, encoder.ClipFormat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return "", "", errClipNotFound
+	}
+	if job.Status != ClipDone {
+		return "", "", errClipNotReady
+	}
+	return job.path, encoder.ClipFormat(job.Format), nil
+}
+
+// run exports job, waiting for a free slot first.
+func (m *ClipManager) run(job *clipJob, format encoder.ClipFormat) {
+	ctx := m.sessions.ctx
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.finish(job, 0, ctx.Err())
+		return
+	}
+	m.setStatus(job, ClipRunning)
+
+	streamURL, err := m.sessions.resolveStreamURL(ctx, job.URL)
+	if err == nil {
+		err = encoder.ExportClip(ctx, m.runner, streamURL, job.Start, job.End, format, job.path)
+	}
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(job.path); err == nil {
+			size = info.Size()
+		}
+	}
+	m.finish(job, size, err)
+}
+
+func (m *ClipManager) setStatus(job *clipJob, status string) {
+	m.mu.Lock()
+	job.Status = status
+	m.mu.Unlock()
+}
+
+// finish records the outcome of job's export.
+func (m *ClipManager) finish(job *clipJob, size int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.finished = time.Now()
+	if err != nil {
+		logger.Warnf("[Clip] Job %s failed: %v", job.ID, err)
+		job.Status = ClipError
+		job.Error = err.Error()
+		os.Remove(job.path)
+		return
+	}
+	logger.Infof("[Clip] Job %s done (%d bytes)", job.ID, size)
+	job.Status = ClipDone
+	job.Size = size
+	job.FileURL = "/clip/" + job.ID + "/file"
+}
+
+// sweepLocked forgets jobs that finished more than clipTTL ago and deletes
+// their files. Caller must hold m.mu.
+func (m *ClipManager) sweepLocked() {
+	for id, job := range m.jobs {
+		if !job.finished.IsZero() && time.Since(job.finished) > clipTTL {
+			os.Remove(job.path)
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Close deletes all clip files.
+func (m *ClipManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dir != "" {
+		os.RemoveAll(m.dir)
+		m.dir = ""
+	}
+	m.jobs = make(map[string]*clipJob)
+}
+
+// CreateClip handles POST /clip: it queues an export and returns the job
+// (202) for polling with GET /clip/:id.
+func (a *API) CreateClip(c *gin.Context) {
+	var req ClipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	job, err := a.clips.Submit(req)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ClipStatus handles GET /clip/:id.
+func (a *API) ClipStatus(c *gin.Context) {
+	job, err := a.clips.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ClipFile handles GET /clip/:id/file, downloading a finished clip.
+func (a *API) ClipFile(c *gin.Context) {
+	id := c.Param("id")
+	path, format, err := a.clips.file(id)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", format.ContentType())
+	c.FileAttachment(path, "clip-"+id+"."+string(format))
+}
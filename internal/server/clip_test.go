@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func clipRequest(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestClipEndpoints(t *testing.T) {
+	sm, _ := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline()
+	})
+	api := NewAPI(sm)
+	t.Cleanup(api.clips.Close)
+	api.clips.runner = execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", `printf clip > "$0"`, args[len(args)-1])
+	})
+	router := SetupRouter(api)
+
+	w := clipRequest(router, "POST", "/clip", `{"url": "fake://track", "start": 10, "end": 25, "format": "opus"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var job ClipJob
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if job.ID == "" || job.Format != "opus" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for job.Status != ClipDone && job.Status != ClipError && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w = clipRequest(router, "GET", "/clip/"+job.ID, "")
+		json.Unmarshal(w.Body.Bytes(), &job)
+	}
+	if job.Status != ClipDone || job.Size != 4 || job.FileURL != "/clip/"+job.ID+"/file" {
+		t.Fatalf("expected a finished job, got %+v", job)
+	}
+
+	w = clipRequest(router, "GET", job.FileURL, "")
+	if w.Code != http.StatusOK || w.Body.String() != "clip" {
+		t.Fatalf("expected the clip file, got %d: %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "audio/ogg" {
+		t.Errorf("expected audio/ogg, got %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") {
+		t.Errorf("expected an attachment, got %q", cd)
+	}
+}
+
+func TestClipEndpoints_Errors(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	t.Cleanup(api.clips.Close)
+	router := SetupRouter(api)
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"start": 0, "end": 10}`, http.StatusBadRequest},                                                // No URL
+		{`{"url": "https://youtu.be/x", "start": 20, "end": 10}`, http.StatusBadRequest},                  // Reversed range
+		{`{"url": "https://youtu.be/x", "start": 0, "end": 3600}`, http.StatusBadRequest},                 // Too long
+		{`{"url": "https://youtu.be/x", "start": 0, "end": 10, "format": "flac"}`, http.StatusBadRequest}, // Unknown format
+	}
+	for _, tt := range tests {
+		if w := clipRequest(router, "POST", "/clip", tt.body); w.Code != tt.want {
+			t.Errorf("POST /clip %s: expected status %d, got %d", tt.body, tt.want, w.Code)
+		}
+	}
+
+	if w := clipRequest(router, "GET", "/clip/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown job, got %d", w.Code)
+	}
+	if w := clipRequest(router, "GET", "/clip/missing/file", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown clip file, got %d", w.Code)
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// sessionIDLen is the fixed width of the session ID in audio packets.
+const sessionIDLen = 24
+
+// errInvalidSessionID is returned for a session ID that doesn't fit the
+// audio packet header.
+var errInvalidSessionID = errors.New("invalid session ID")
+
+// validateSessionID checks that id fits the packet header as is: 1 to 24
+// bytes of letters, digits, '-', '_', '.' or ':'. Longer IDs are rejected
+// rather than truncated, since two could then share a header; spaces would
+// be lost to the header's padding.
+func validateSessionID(id string) error {
+	if id == "" || len(id) > sessionIDLen {
+		return fmt.Errorf("%w %q: must be 1-%d bytes", errInvalidSessionID, id, sessionIDLen)
+	}
+	for _, c := range []byte(id) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.:", c) >= 0) {
+			return fmt.Errorf("%w %q: only letters, digits, '-', '_', '.' and ':' are allowed", errInvalidSessionID, id)
+		}
+	}
+	return nil
+}
+
+// errNoConnection is returned when no client is connected to receive output.
+var errNoConnection = errs.New(errs.ErrTransport, "no connection")
+
+// errNotSubscribed is returned for audio of a session the client has not
+// subscribed to.
+var errNotSubscribed = errs.New(errs.ErrTransport, "client not subscribed to session")
+
+// AudioSink receives the output of all sessions. Session logic only talks
+// to a sink, so connection handling and wire format stay out of it.
+type AudioSink interface {
+	// SendAudio delivers one chunk of a session's audio. The sink takes
+	// ownership of chunk (it is recycled via buffer.PutChunk). An error
+	// means the chunk was dropped.
+	SendAudio(sessionID string, chunk []byte) error
+
+	// SendEvent delivers a control event (any JSON-encodable value).
+	SendEvent(v any) error
+}
+
+// ConnectionRouter is the AudioSink for the audio socket: it frames audio
+// as length-prefixed packets and writes them, and newline-terminated JSON
+// events, to the connected clients. Events go to every client; audio goes
+// to every client subscribed to its session.
+type ConnectionRouter struct {
+	mu      sync.Mutex
+	clients map[net.Conn]*socketClient
+
+	creditMu sync.Mutex
+	credits  map[string]*creditWindow // Per-session flow control (see GrantCredit)
+}
+
+// socketClient is one connected client.
+type socketClient struct {
+	conn     net.Conn
+	writeMu  sync.Mutex      // Keeps packets and events from interleaving
+	sessions map[string]bool // Sessions subscribed to (nil = all)
+}
+
+func (c *socketClient) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// NewConnectionRouter creates a router with no client connected.
+func NewConnectionRouter() *ConnectionRouter {
+	return &ConnectionRouter{
+		clients: make(map[net.Conn]*socketClient),
+		credits: make(map[string]*creditWindow),
+	}
+}
+
+// AddConnection registers a client connection. Other clients stay
+// connected.
+func (r *ConnectionRouter) AddConnection(conn net.Conn) {
+	r.mu.Lock()
+	r.clients[conn] = &socketClient{conn: conn}
+	r.mu.Unlock()
+}
+
+// RemoveConnection unregisters conn and drops the credit it granted. It
+// does nothing if conn is not registered.
+func (r *ConnectionRouter) RemoveConnection(conn net.Conn) {
+	r.mu.Lock()
+	_, ok := r.clients[conn]
+	delete(r.clients, conn)
+	r.mu.Unlock()
+	if ok {
+		r.resetCredits(conn)
+	}
+}
+
+// ConnectionCount returns the number of connected clients.
+func (r *ConnectionRouter) ConnectionCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+// Subscribe limits the audio sent to conn to sessionIDs; none means every
+// session. Events are not filtered.
+func (r *ConnectionRouter) Subscribe(conn net.Conn, sessionIDs []string) error {
+	for _, id := range sessionIDs {
+		if err := validateSessionID(id); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[conn]
+	if !ok {
+		return errNoConnection
+	}
+	client.sessions = nil
+	if len(sessionIDs) > 0 {
+		client.sessions = make(map[string]bool, len(sessionIDs))
+		for _, id := range sessionIDs {
+			client.sessions[id] = true
+		}
+	}
+	return nil
+}
+
+// recipients returns the clients that receive sessionID's audio, or every
+// client for an empty sessionID.
+func (r *ConnectionRouter) recipients(sessionID string) (clients []*socketClient, connected bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, client := range r.clients {
+		if sessionID == "" || client.sessions == nil || client.sessions[sessionID] {
+			clients = append(clients, client)
+		}
+	}
+	return clients, len(r.clients) > 0
+}
+
+// SendAudio writes chunk as an audio packet to the clients subscribed to
+// the session. A client whose write fails is dropped until it reconnects.
+func (r *ConnectionRouter) SendAudio(sessionID string, chunk []byte) error {
+	clients, connected := r.recipients(sessionID)
+	if len(clients) == 0 {
+		buffer.PutChunk(chunk)
+		if !connected {
+			return errNoConnection // Skip chunk (will retry on next chunk)
+		}
+		return errNotSubscribed
+	}
+
+	packet := framePacket(sessionID, chunk)
+	buffer.PutChunk(chunk) // Chunk is copied into the packet; recycle it
+	defer buffer.PutChunk(packet)
+	return r.broadcast(clients, packet)
+}
+
+// SendEvent writes v as a newline-terminated JSON event to every client.
+func (r *ConnectionRouter) SendEvent(v any) error {
+	clients, _ := r.recipients("")
+	if len(clients) == 0 {
+		return errNoConnection
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Errorf("[Socket] Failed to encode event: %v", err)
+		return err
+	}
+	return r.broadcast(clients, append(data, '\n'))
+}
+
+// broadcast writes data to clients, dropping those whose write fails. It
+// succeeds if any client received data.
+func (r *ConnectionRouter) broadcast(clients []*socketClient, data []byte) error {
+	var lastErr error
+	delivered := false
+	for _, client := range clients {
+		if err := client.write(data); err != nil {
+			// Connection broken - drop it and wait for reconnect
+			logger.Warnf("[Socket] Write error (connection lost): %v", err)
+			r.RemoveConnection(client.conn)
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return errs.Wrap(errs.ErrTransport, lastErr)
+	}
+	return nil
+}
+
+// sendEventTo writes v as a newline-terminated JSON event to conn only.
+func (r *ConnectionRouter) sendEventTo(conn net.Conn, v any) error {
+	r.mu.Lock()
+	client, ok := r.clients[conn]
+	r.mu.Unlock()
+	if !ok {
+		return errNoConnection
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Errorf("[Socket] Failed to encode event: %v", err)
+		return err
+	}
+	return errs.Wrap(errs.ErrTransport, client.write(append(data, '\n')))
+}
+
+// framePacket builds an audio packet in a single buffer (one write avoids
+// TCP Nagle delays):
+//
+//	Header:     4 bytes big-endian length (includes session ID + audio data)
+//	Session ID: 24 bytes, right-padded with spaces (see validateSessionID)
+//	Audio:      the chunk
+//
+// The packet comes from buffer.GetChunk; recycle it with buffer.PutChunk.
+func framePacket(sessionID string, chunk []byte) []byte {
+	if len(sessionID) > sessionIDLen {
+		sessionID = sessionID[:sessionIDLen]
+	}
+	paddedID := fmt.Sprintf("%-24s", sessionID)
+
+	length := uint32(sessionIDLen + len(chunk))
+	packet := buffer.GetChunk(4 + sessionIDLen + len(chunk))
+	packet[0] = byte(length >> 24)
+	packet[1] = byte(length >> 16)
+	packet[2] = byte(length >> 8)
+	packet[3] = byte(length)
+	copy(packet[4:4+sessionIDLen], paddedID)
+	copy(packet[4+sessionIDLen:], chunk)
+	return packet
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestFramePacket(t *testing.T) {
+	packet := framePacket("guild-1", []byte("opus"))
+
+	if len(packet) != 4+sessionIDLen+4 {
+		t.Fatalf("unexpected packet length %d", len(packet))
+	}
+	if !bytes.Equal(packet[:4], []byte{0, 0, 0, sessionIDLen + 4}) {
+		t.Errorf("unexpected length header %v", packet[:4])
+	}
+	if id := string(packet[4 : 4+sessionIDLen]); id != "guild-1                 " {
+		t.Errorf("unexpected padded session ID %q", id)
+	}
+	if audio := string(packet[4+sessionIDLen:]); audio != "opus" {
+		t.Errorf("unexpected audio %q", audio)
+	}
+
+	long := framePacket("0123456789012345678901234567", nil)
+	if id := string(long[4:]); id != "012345678901234567890123" {
+		t.Errorf("expected truncated session ID, got %q", id)
+	}
+}
+
+func TestValidateSessionID(t *testing.T) {
+	for _, id := range []string{"guild-1", "123456789012345678", "user:42.a_b", "012345678901234567890123"} {
+		if err := validateSessionID(id); err != nil {
+			t.Errorf("expected %q to be valid, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "0123456789012345678901234", "guild 1", "guild/1", "gïld"} {
+		if err := validateSessionID(id); !errors.Is(err, errInvalidSessionID) {
+			t.Errorf("expected %q to be rejected, got %v", id, err)
+		}
+	}
+}
+
+func TestConnectionRouter_NoConnection(t *testing.T) {
+	router := NewConnectionRouter()
+	if err := router.SendAudio("guild-1", []byte("x")); !errors.Is(err, errNoConnection) {
+		t.Errorf("expected errNoConnection, got %v", err)
+	}
+	if err := router.SendEvent(Event{Type: "ready"}); !errors.Is(err, errNoConnection) {
+		t.Errorf("expected errNoConnection, got %v", err)
+	}
+}
+
+func TestConnectionRouter_DropsBrokenConnection(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	clientConn.Close()
+
+	router := NewConnectionRouter()
+	router.AddConnection(serverConn)
+	if err := router.SendAudio("guild-1", []byte("x")); err == nil {
+		t.Fatal("expected write error")
+	}
+	if router.ConnectionCount() != 0 {
+		t.Error("expected the broken connection to be removed")
+	}
+}
+
+func TestConnectionRouter_Subscribe(t *testing.T) {
+	router := NewConnectionRouter()
+	serverConn, received := pipeClient(t, router)
+	other, _ := net.Pipe()
+	if err := router.Subscribe(other, []string{"guild-1"}); !errors.Is(err, errNoConnection) {
+		t.Errorf("expected a foreign connection to be refused, got %v", err)
+	}
+	if err := router.Subscribe(serverConn, []string{"guild-1"}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := router.SendAudio("guild-2", []byte("x")); !errors.Is(err, errNotSubscribed) {
+		t.Errorf("expected errNotSubscribed, got %v", err)
+	}
+	if err := router.SendAudio("guild-1", []byte("x")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+	if packet := <-received; string(packet[4:11]) != "guild-1" {
+		t.Errorf("expected guild-1 audio, got %q", packet)
+	}
+
+	router.Subscribe(serverConn, nil) // Back to every session
+	if err := router.SendAudio("guild-2", []byte("x")); err != nil {
+		t.Errorf("expected audio for every session, got %v", err)
+	}
+}
+
+// pipeClient connects a client to router and returns what it reads.
+func pipeClient(t *testing.T, router *ConnectionRouter) (net.Conn, <-chan []byte) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+	received := make(chan []byte, 4)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := clientConn.Read(buf)
+			if err != nil {
+				return
+			}
+			received <- buf[:n]
+		}
+	}()
+	router.AddConnection(serverConn)
+	return serverConn, received
+}
+
+func TestConnectionRouter_MultipleClients(t *testing.T) {
+	router := NewConnectionRouter()
+	first, firstReceived := pipeClient(t, router)
+	second, secondReceived := pipeClient(t, router)
+	router.Subscribe(second, []string{"guild-2"})
+
+	if err := router.SendEvent(Event{Type: "ready"}); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	for _, received := range []<-chan []byte{firstReceived, secondReceived} {
+		if data := <-received; !bytes.Contains(data, []byte(`"ready"`)) {
+			t.Errorf("expected the event on every client, got %q", data)
+		}
+	}
+
+	if err := router.SendAudio("guild-1", []byte("x")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+	if packet := <-firstReceived; string(packet[4:11]) != "guild-1" {
+		t.Errorf("expected guild-1 audio on the first client, got %q", packet)
+	}
+	select {
+	case packet := <-secondReceived:
+		t.Errorf("expected no guild-1 audio on the second client, got %q", packet)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A client leaving doesn't disconnect the others
+	router.RemoveConnection(first)
+	if err := router.SendAudio("guild-2", []byte("y")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+	if packet := <-secondReceived; string(packet[4:11]) != "guild-2" {
+		t.Errorf("expected guild-2 audio on the second client, got %q", packet)
+	}
+	if err := router.SendAudio("guild-1", []byte("z")); !errors.Is(err, errNotSubscribed) {
+		t.Errorf("expected errNotSubscribed with no guild-1 subscriber, got %v", err)
+	}
+}
+
+// recordingSink is an AudioSink that keeps everything it receives.
+type recordingSink struct {
+	mu     sync.Mutex
+	audio  []string
+	events []Event
+}
+
+func (s *recordingSink) SendAudio(sessionID string, chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audio = append(s.audio, sessionID+":"+string(chunk))
+	return nil
+}
+
+func (s *recordingSink) SendEvent(v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event, ok := v.(Event); ok {
+		s.events = append(s.events, event)
+	}
+	return nil
+}
+
+func (s *recordingSink) finished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events) > 0 && s.events[len(s.events)-1].Type == "finished"
+}
+
+func TestSessionManager_CustomSink(t *testing.T) {
+	sm, _ := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline("abc", "defg")
+	})
+	sink := &recordingSink{}
+	sm.SetSink(sink)
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !sink.finished() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for finished event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.audio) != 2 || sink.audio[0] != "guild-1:abc" || sink.audio[1] != "guild-1:defg" {
+		t.Errorf("unexpected audio %v", sink.audio)
+	}
+	if sink.events[0].Type != "ready" {
+		t.Errorf("expected ready first, got %v", sink.events)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// maxCommandSize bounds one control message line on the audio socket.
+const maxCommandSize = 64 * 1024
+
+// readCommands reads newline-terminated JSON commands from a socket client
+// until it disconnects, answering each on the same connection with an ack
+// or error event. Audio packets and events keep flowing the other way.
+func (m *SessionManager) readCommands(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxCommandSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var cmd Command
+		var ev Event
+		if err := json.Unmarshal(line, &cmd); err != nil {
+			ev = NewErrorEvent("", fmt.Errorf("invalid command: %w", err))
+		} else {
+			ev = m.handleCommand(conn, cmd)
+		}
+		if cmd.Type == CommandCredit && ev.Type == EventAck {
+			continue // Credit is granted continuously; only failures are answered
+		}
+		if err := m.router.sendEventTo(conn, ev); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warnf("[Socket] Command read error: %v", err)
+	}
+}
+
+// handleCommand runs one socket command from conn and returns its reply.
+func (m *SessionManager) handleCommand(conn net.Conn, cmd Command) Event {
+	logger.Infof("[Socket] Command: type=%s session=%s", cmd.Type, cmd.SessionID)
+
+	var err error
+	idErr := validateSessionID(cmd.SessionID)
+	switch {
+	case cmd.Type == CommandSubscribe:
+		err = m.router.Subscribe(conn, cmd.Sessions)
+	case cmd.SessionID == "":
+		err = fmt.Errorf("session_id is required")
+	case idErr != nil:
+		err = idErr
+	case cmd.Type == CommandPlay:
+		err = m.playCommand(cmd)
+	case cmd.Type == CommandStop:
+		m.Stop(cmd.SessionID)
+	case cmd.Type == CommandPause:
+		err = m.Pause(cmd.SessionID)
+	case cmd.Type == CommandResume:
+		err = m.Resume(cmd.SessionID)
+	case cmd.Type == CommandSeek:
+		err = m.Seek(cmd.SessionID, cmd.Position)
+	case cmd.Type == CommandCredit:
+		err = m.router.GrantCredit(conn, cmd.SessionID, cmd.Frames)
+	case cmd.Type == CommandFormat:
+		err = m.SetFormat(cmd.SessionID, cmd.Format)
+	case cmd.Type == CommandBitrate:
+		_, err = m.SetBitrate(cmd.SessionID, cmd.Bitrate)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd.Type)
+	}
+	if params, ok := auditParams(cmd); ok && idErr == nil {
+		m.auditCommand(conn, string(cmd.Type), cmd, params, err)
+	}
+
+	if err != nil {
+		ev := NewErrorEvent(cmd.SessionID, err)
+		ev.CommandID = cmd.ID
+		return ev
+	}
+	return Event{Type: EventAck, SessionID: cmd.SessionID, CommandID: cmd.ID}
+}
+
+// auditParams returns the parameters of cmd recorded in the audit log, or
+// false for commands that aren't audited (flow control, subscriptions).
+func auditParams(cmd Command) (map[string]any, bool) {
+	switch cmd.Type {
+	case CommandPlay:
+		params := map[string]any{"url": cmd.URL, "format": cmd.Format}
+		if cmd.StartAt > 0 {
+			params["start_at"] = cmd.StartAt
+		}
+		if cmd.EndAt > 0 {
+			params["end_at"] = cmd.EndAt
+		}
+		return params, true
+	case CommandStop, CommandPause, CommandResume:
+		return nil, true
+	case CommandSeek:
+		return map[string]any{"position": cmd.Position}, true
+	case CommandFormat:
+		return map[string]any{"format": cmd.Format}, true
+	case CommandBitrate:
+		return map[string]any{"bitrate": cmd.Bitrate}, true
+	}
+	return nil, false
+}
+
+// playCommand starts playback like POST /session/:id/play.
+func (m *SessionManager) playCommand(cmd Command) error {
+	if cmd.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	format := cmd.Format
+	if format == "" {
+		format = "pcm"
+	}
+	return m.StartPlayback(cmd.SessionID, cmd.URL, format, PlaybackOptions{
+		StartAt:  cmd.StartAt,
+		EndAt:    cmd.EndAt,
+		Duration: cmd.Duration,
+		Levels:   cmd.Levels,
+		Spectrum: cmd.Spectrum,
+		Token:    cmd.Token,
+		Resume:   cmd.Resume,
+		Region:   cmd.Region,
+
+		StreamURL:     cmd.StreamURL,
+		StreamExpires: unixTime(cmd.StreamExpires),
+	})
+}
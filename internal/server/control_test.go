@@ -0,0 +1,386 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestSocketServer_Commands(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sessions := NewSessionManager(ctx)
+	socketPath := filepath.Join(os.TempDir(), "test-music-bot-control.sock")
+	defer os.Remove(socketPath)
+
+	server := NewSocketServer(socketPath, sessions)
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+	defer cancel()
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	replies := bufio.NewScanner(conn)
+
+	send := func(line string) Event {
+		t.Helper()
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		if !replies.Scan() {
+			t.Fatalf("no reply to %s: %v", line, replies.Err())
+		}
+		var ev Event
+		if err := json.Unmarshal(replies.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid reply %q: %v", replies.Text(), err)
+		}
+		return ev
+	}
+
+	ev := send(`{"type": "pause", "id": "1", "session_id": "guild-1"}`)
+	if ev.Type != EventError || ev.CommandID != "1" || ev.Code != "not_found" {
+		t.Errorf("expected not_found error for command 1, got %+v", ev)
+	}
+	ev = send(`{"type": "stop", "id": "2", "session_id": "guild-1"}`)
+	if ev.Type != EventAck || ev.CommandID != "2" || ev.SessionID != "guild-1" {
+		t.Errorf("expected ack for command 2, got %+v", ev)
+	}
+	if ev = send(`not json`); ev.Type != EventError {
+		t.Errorf("expected error for invalid JSON, got %+v", ev)
+	}
+
+	// Disconnecting releases the connection
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for sessions.ConnectionCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sessions.ConnectionCount() != 0 {
+		t.Error("expected connection to be released after the client disconnected")
+	}
+}
+
+func TestSessionManager_HandleCommand(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &holdPipeline{fakePipeline: newFakePipeline()}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	tests := []struct {
+		cmd  Command
+		want EventType
+	}{
+		{Command{Type: CommandPlay, SessionID: "guild-1", URL: "fake://track", Format: "opus"}, EventAck},
+		{Command{Type: CommandPlay, SessionID: "guild-1"}, EventError},
+		{Command{Type: CommandSeek, SessionID: "guild-1", Position: 30}, EventAck},
+		{Command{Type: CommandSeek, SessionID: "guild-1", Position: -1}, EventError},
+		{Command{Type: CommandPause, SessionID: "guild-1"}, EventAck},
+		{Command{Type: CommandResume, SessionID: "guild-1"}, EventAck},
+		{Command{Type: "rewind", SessionID: "guild-1"}, EventError},
+		{Command{Type: CommandStop}, EventError},
+		{Command{Type: CommandStop, SessionID: "guild 1"}, EventError},
+	}
+	for _, tt := range tests {
+		if ev := sm.handleCommand(nil, tt.cmd); ev.Type != tt.want {
+			t.Errorf("handleCommand(%+v) = %+v, want %s", tt.cmd, ev, tt.want)
+		}
+	}
+	if got := sm.audit.Query(AuditFilter{Action: AuditSeek}); len(got) != 2 || got[0].Error == "" || got[1].Via != "socket" {
+		t.Errorf("expected both seeks audited, the newest failed, got %+v", got)
+	}
+	sm.Stop("guild-1")
+}
+
+// seekPipeline reports the position each attempt starts at.
+type seekPipeline struct {
+	*holdPipeline
+	starts chan float64
+}
+
+func (p *seekPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.starts <- startAtSec
+	return p.holdPipeline.Start(ctx, streamURL, format, startAtSec)
+}
+
+func TestSessionManager_Seek(t *testing.T) {
+	starts := make(chan float64, 2)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &seekPipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, starts: starts}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	if err := sm.Seek("guild-1", 10); err != errSessionNotFound {
+		t.Errorf("expected errSessionNotFound, got %v", err)
+	}
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{Duration: 120})
+	for _, want := range []float64{0, 42} {
+		select {
+		case got := <-starts:
+			if got != want {
+				t.Errorf("expected pipeline start at %.0fs, got %.1fs", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("pipeline did not start at %.0fs", want)
+		}
+		if want == 0 {
+			if err := sm.Seek("guild-1", 42); err != nil {
+				t.Fatalf("Seek failed: %v", err)
+			}
+		}
+	}
+
+	if err := sm.Seek("guild-1", 500); err != errInvalidSeek {
+		t.Errorf("expected errInvalidSeek past the end, got %v", err)
+	}
+	sm.Stop("guild-1")
+}
+
+// formatPipeline reports the format each attempt starts with.
+type formatPipeline struct {
+	*holdPipeline
+	formats chan encoder.Format
+}
+
+func (p *formatPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.formats <- format
+	return p.holdPipeline.Start(ctx, streamURL, format, startAtSec)
+}
+
+func TestSessionManager_SetFormat(t *testing.T) {
+	formats := make(chan encoder.Format, 2)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &formatPipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, formats: formats}
+	})
+	events := make(chan map[string]string, 16)
+	go func() {
+		for msg := range messages {
+			if msg.event != nil {
+				events <- msg.event
+			}
+		}
+	}()
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{Duration: 120})
+	if got := <-formats; got != encoder.FormatOpus {
+		t.Fatalf("expected opus, got %s", got)
+	}
+	if err := sm.SetFormat("guild-1", "flac"); err != errInvalidFormat {
+		t.Errorf("expected errInvalidFormat, got %v", err)
+	}
+	if err := sm.SetFormat("guild-1", "opus"); err != nil {
+		t.Errorf("expected the current format to be a no-op, got %v", err)
+	}
+	if err := sm.SetFormat("guild-1", "web"); err != nil {
+		t.Fatalf("SetFormat failed: %v", err)
+	}
+
+	select {
+	case got := <-formats:
+		if got != encoder.FormatWeb {
+			t.Errorf("expected the pipeline to restart as web, got %s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not restart")
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev["type"] == string(EventFormat) {
+				if ev["format"] != "web" {
+					t.Errorf("expected a web format event, got %v", ev)
+				}
+				sm.StopAll()
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected a format event")
+		}
+	}
+}
+
+// bitratePipeline reports the bitrate each attempt is started with.
+type bitratePipeline struct {
+	*holdPipeline
+	bitrate  int
+	bitrates chan int
+}
+
+func (p *bitratePipeline) SetBitrate(bps int) {
+	p.bitrate = bps
+}
+
+func (p *bitratePipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.bitrates <- p.bitrate
+	return p.holdPipeline.Start(ctx, streamURL, format, startAtSec)
+}
+
+func TestSessionManager_SetBitrate(t *testing.T) {
+	bitrates := make(chan int, 2)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &bitratePipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, bitrates: bitrates}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	if _, err := sm.SetBitrate("guild-1", 1000); err != errInvalidBitrate {
+		t.Errorf("expected errInvalidBitrate, got %v", err)
+	}
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{Duration: 120})
+	if got := <-bitrates; got != 0 {
+		t.Fatalf("expected the format default, got %d", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for sm.StreamingSessionCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := sm.SetBitrate("guild-1", 128000); err != nil {
+		t.Fatalf("SetBitrate failed: %v", err)
+	}
+	if bitrate, err := sm.SetBitrate("guild-1", 96000); err != nil || bitrate != 96000 {
+		t.Fatalf("SetBitrate failed: %d, %v", bitrate, err)
+	}
+	select {
+	case got := <-bitrates:
+		if got != 96000 {
+			t.Errorf("expected a restart at 96000, got %d", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not restart")
+	}
+	select {
+	case got := <-bitrates:
+		t.Errorf("expected the unchanged default bitrate not to restart, got a start at %d", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+	sm.StopAll()
+}
+
+func TestSessionManager_SkipSilence(t *testing.T) {
+	starts := make(chan float64, 4)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &seekPipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, starts: starts}
+	})
+	sm.SetSilenceDetector(func(ctx context.Context, streamURL string, found func(encoder.Silence)) error {
+		found(encoder.Silence{Start: 0, End: 30})
+		return nil
+	})
+	skipped := make(chan struct{}, 1)
+	go func() {
+		for msg := range messages {
+			if msg.event["type"] == string(EventSilenceSkipped) {
+				skipped <- struct{}{}
+			}
+		}
+	}()
+	skip := true
+	sm.Settings().Update("guild-1", SettingsPatch{SkipSilence: &skip})
+
+	sm.StartPlayback("guild-1", "fake://hidden-track", "opus", PlaybackOptions{Duration: 120})
+	for _, want := range []float64{0, 30} {
+		select {
+		case got := <-starts:
+			if got != want {
+				t.Errorf("expected pipeline start at %.0fs, got %.1fs", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("pipeline did not start at %.0fs", want)
+		}
+	}
+	select {
+	case <-skipped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no silence_skipped event")
+	}
+
+	select {
+	case got := <-starts:
+		t.Errorf("expected one skip, got another start at %.1fs", got)
+	case <-time.After(3 * silenceCheckInterval):
+	}
+	sm.StopAll()
+}
+
+// speedPipeline reports the filter and position each attempt starts with.
+type speedPipeline struct {
+	*holdPipeline
+	starts chan string
+	filter string
+}
+
+func (p *speedPipeline) SetFilter(volume float64, filter string) { p.filter = filter }
+
+func (p *speedPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.starts <- fmt.Sprintf("%s @ %.0f", p.filter, startAtSec)
+	return p.holdPipeline.Start(ctx, streamURL, format, startAtSec)
+}
+
+func TestSessionManager_SetTempo(t *testing.T) {
+	starts := make(chan string, 2)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &speedPipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, starts: starts}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	speed := 1.5
+	sm.Settings().Update("guild-1", SettingsPatch{Speed: &speed})
+
+	sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: 120, StartAt: 10})
+	for _, want := range []string{"atempo=1.5 @ 10", "asetrate=96000,aresample=48000,atempo=0.5,atempo=0.5 @ 10"} {
+		select {
+		case got := <-starts:
+			if got != want {
+				t.Errorf("expected pipeline start %q, got %q", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("pipeline did not start with %q", want)
+		}
+		if want == "atempo=1.5 @ 10" {
+			sm.SetTempo("guild-1", 0.5, 12)
+		}
+	}
+	sm.StopAll()
+}
+
+func TestSession_PositionAtSpeed(t *testing.T) {
+	session := &Session{
+		seekOffset:       10,
+		speed:            2,
+		pcmBytes:         5 * int64(pcmBytesPerSecond),
+		streamStartTime:  time.Now(),
+		expectedDuration: 60,
+	}
+	if position := session.Position(); position != 20 {
+		t.Errorf("expected 5s of output at 2x to reach 20s, got %.1fs", position)
+	}
+	if remaining := session.Remaining(); remaining != 20 {
+		t.Errorf("expected 40s of track left to take 20s, got %.1fs", remaining)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// maxCredits caps the outstanding credit of one session's window.
+const maxCredits = 1 << 16
+
+// creditWindow limits how many audio packets of one session may be sent
+// before the client grants more. Until the first grant it is unlimited, so
+// clients that never send credit commands are unaffected.
+type creditWindow struct {
+	mu      sync.Mutex
+	enabled bool
+	credits int
+	granted chan struct{} // Signalled on grant or reset
+	owner   net.Conn      // Client that granted the credit (guarded by ConnectionRouter.creditMu)
+}
+
+func newCreditWindow() *creditWindow {
+	return &creditWindow{granted: make(chan struct{}, 1)}
+}
+
+// grant adds frames of credit and enables flow control.
+func (w *creditWindow) grant(frames int) {
+	w.mu.Lock()
+	w.enabled = true
+	w.credits = min(w.credits+frames, maxCredits)
+	w.mu.Unlock()
+	w.signal()
+}
+
+// reset disables flow control, releasing a waiting sender.
+func (w *creditWindow) reset() {
+	w.mu.Lock()
+	w.enabled = false
+	w.credits = 0
+	w.mu.Unlock()
+	w.signal()
+}
+
+func (w *creditWindow) signal() {
+	select {
+	case w.granted <- struct{}{}:
+	default:
+	}
+}
+
+// acquire takes one credit, waiting for a grant while the window is empty.
+// Returns false if ctx is cancelled first.
+func (w *creditWindow) acquire(ctx context.Context) bool {
+	for {
+		w.mu.Lock()
+		if !w.enabled {
+			w.mu.Unlock()
+			return true
+		}
+		if w.credits > 0 {
+			w.credits--
+			w.mu.Unlock()
+			return true
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-w.granted:
+		}
+	}
+}
+
+// GrantCredit lets the socket client conn receive frames more audio packets
+// of session id. Credit belongs to the connection that granted it: when it
+// disconnects, the session goes back to sending without flow control.
+func (r *ConnectionRouter) GrantCredit(conn net.Conn, id string, frames int) error {
+	if frames <= 0 {
+		return fmt.Errorf("credit must be positive, got %d", frames)
+	}
+	w := r.window(id)
+	r.creditMu.Lock()
+	w.owner = conn
+	r.creditMu.Unlock()
+	w.grant(frames)
+	return nil
+}
+
+// acquireCredit waits until session id may send one audio packet.
+// Returns false if ctx is cancelled first.
+func (r *ConnectionRouter) acquireCredit(ctx context.Context, id string) bool {
+	return r.window(id).acquire(ctx)
+}
+
+// window returns session id's credit window, creating it if needed.
+func (r *ConnectionRouter) window(id string) *creditWindow {
+	r.creditMu.Lock()
+	defer r.creditMu.Unlock()
+	w, ok := r.credits[id]
+	if !ok {
+		w = newCreditWindow()
+		r.credits[id] = w
+	}
+	return w
+}
+
+// resetCredits drops the credit windows granted by conn (on disconnect).
+func (r *ConnectionRouter) resetCredits(conn net.Conn) {
+	r.creditMu.Lock()
+	var windows []*creditWindow
+	for id, w := range r.credits {
+		if w.owner == conn {
+			windows = append(windows, w)
+			delete(r.credits, id)
+		}
+	}
+	r.creditMu.Unlock()
+
+	for _, w := range windows {
+		w.reset()
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestCreditWindow(t *testing.T) {
+	w := newCreditWindow()
+	ctx := context.Background()
+	if !w.acquire(ctx) {
+		t.Fatal("expected unlimited sending before the first grant")
+	}
+
+	w.grant(2)
+	for i := 0; i < 2; i++ {
+		if !w.acquire(ctx) {
+			t.Fatalf("expected credit %d to be available", i+1)
+		}
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if w.acquire(timeout) {
+		t.Error("expected acquire to wait with no credit left")
+	}
+
+	done := make(chan bool)
+	go func() { done <- w.acquire(ctx) }()
+	w.reset()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("expected reset to release the waiting sender")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reset did not release the waiting sender")
+	}
+}
+
+func TestSessionManager_CreditFlowControl(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline("one", "two", "three")
+	})
+	if err := sm.router.GrantCredit(nil, "guild-1", 1); err != nil {
+		t.Fatalf("GrantCredit failed: %v", err)
+	}
+	if err := sm.router.GrantCredit(nil, "guild-1", 0); err == nil {
+		t.Error("expected error for zero credit")
+	}
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+	if msg := nextMessage(t, messages); msg.event["type"] != "ready" {
+		t.Fatalf("expected ready event, got %+v", msg)
+	}
+	if msg := nextMessage(t, messages); string(msg.audio) != "one" {
+		t.Fatalf("expected first packet, got %+v", msg)
+	}
+
+	select {
+	case msg := <-messages:
+		t.Fatalf("expected no packet without credit, got %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sm.router.GrantCredit(nil, "guild-1", 2)
+	for _, want := range []string{"two", "three"} {
+		if msg := nextMessage(t, messages); string(msg.audio) != want {
+			t.Fatalf("expected %q after granting credit, got %+v", want, msg)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+const (
+	depsCacheTTL     = time.Minute      // /health is polled; don't spawn yt-dlp every time
+	depsProbeTimeout = 10 * time.Second // Per binary
+)
+
+// DependencyStatus reports one external binary.
+type DependencyStatus struct {
+	Found   bool   `json:"found"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DepsResponse is the response for the deps endpoint.
+type DepsResponse struct {
+	YtDlp      DependencyStatus `json:"yt_dlp"`
+	FFmpeg     DependencyStatus `json:"ffmpeg"`
+	CookieMode string           `json:"cookie_mode"`          // file, browser, default_file or none
+	JSRuntime  string           `json:"js_runtime,omitempty"` // node or deno; empty if none found
+	Degraded   []string         `json:"degraded"`             // Features missing optional dependencies
+	CheckedAt  time.Time        `json:"checked_at"`
+}
+
+// depsProbe detects the versions of yt-dlp and FFmpeg, caching the result
+// for depsCacheTTL.
+type depsProbe struct {
+	runner execx.CommandRunner
+
+	mu      sync.Mutex
+	last    DepsResponse
+	checked time.Time
+}
+
+func newDepsProbe(runner execx.CommandRunner) *depsProbe {
+	return &depsProbe{runner: runner}
+}
+
+// check returns the cached report, probing again when it is stale.
+func (p *depsProbe) check(ctx context.Context) DepsResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.checked.IsZero() && time.Since(p.checked) < depsCacheTTL {
+		return p.last
+	}
+
+	p.last = DepsResponse{
+		YtDlp: probeBinary(ctx, youtube.Binary(), func(ctx context.Context) (string, error) {
+			return youtube.NewWithRunner(p.runner).Version(ctx)
+		}),
+		FFmpeg: probeBinary(ctx, encoder.FFmpegPath(), func(ctx context.Context) (string, error) {
+			return encoder.FFmpegVersion(ctx, p.runner)
+		}),
+		CookieMode: youtube.CookieMode(),
+		JSRuntime:  youtube.JSRuntime(),
+		CheckedAt:  time.Now(),
+	}
+	p.checked = p.last.CheckedAt
+	return p.last
+}
+
+// probeBinary runs version and reports the status of binary (a name in
+// PATH or a path).
+func probeBinary(ctx context.Context, binary string, version func(context.Context) (string, error)) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, depsProbeTimeout)
+	defer cancel()
+
+	var status DependencyStatus
+	status.Path, _ = exec.LookPath(binary)
+	v, err := version(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Found = true
+	status.Version = v
+	return status
+}
+
+// Deps reports external dependency versions and configuration, for
+// triaging extraction failures.
+func (a *API) Deps(c *gin.Context) {
+	resp := a.deps.check(c.Request.Context())
+	resp.Degraded = a.degradedFeatures()
+	c.JSON(http.StatusOK, resp)
+}
+
+// degradedFeatures returns the degraded features, never nil so the JSON
+// is always a list.
+func (a *API) degradedFeatures() []string {
+	if a.degraded == nil {
+		return []string{}
+	}
+	return a.degraded
+}
+
+// versionOrMissing returns the version for /health, or "missing".
+func (s DependencyStatus) versionOrMissing() string {
+	if !s.Found {
+		return "missing"
+	}
+	return s.Version
+}
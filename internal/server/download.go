@@ -0,0 +1,205 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/spill"
+)
+
+// maxDownloadDuration is the longest track the download_first and
+// progressive_download settings download; longer ones (and live streams,
+// whose length is unknown) are streamed as usual.
+const maxDownloadDuration = 3 * time.Hour
+
+// streamConnections is how many range requests fetch a googlevideo stream
+// in parallel; YouTube throttles each connection, not the client.
+const streamConnections = 4
+
+// Downloader copies the audio of streamURL to the file dst, fetching it
+// through proxy ("" = direct). It blocks until the whole track is on disk.
+type Downloader func(ctx context.Context, streamURL, proxy, dst string) error
+
+// downloadTrack is the default Downloader, an FFmpeg stream copy. A
+// googlevideo stream, already audio only, is instead fetched over parallel
+// connections into a spill file next to dst, which then becomes dst; FFmpeg
+// reads any container whatever its extension.
+func downloadTrack(ctx context.Context, streamURL, proxy, dst string) error {
+	options := spillOptions(streamURL, proxy)
+	if options.Connections <= 1 {
+		return encoder.Download(ctx, execx.Default, streamURL, proxy, dst)
+	}
+	options.Dir = filepath.Dir(dst) // Same file system, so Keep is a rename
+	file, err := spill.Open(ctx, streamURL, options)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Wait(ctx); err != nil {
+		return err
+	}
+	return file.Keep(dst)
+}
+
+// spillOptions returns how to fetch streamURL through proxy ("" = direct)
+// into a spill file: with the headers FFmpeg sends, and over
+// streamConnections connections for googlevideo hosts.
+func spillOptions(streamURL, proxy string) spill.Options {
+	options := spill.Options{
+		Client:      http.DefaultClient,
+		Header:      http.Header{"User-Agent": {encoder.StreamUserAgent}, "Referer": {encoder.StreamReferer}},
+		Connections: 1,
+	}
+	if u, err := url.Parse(proxy); err == nil && u.Host != "" {
+		options.Client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}
+	}
+	if u, err := url.Parse(streamURL); err == nil && strings.HasSuffix(u.Hostname(), ".googlevideo.com") {
+		options.Connections = streamConnections
+	}
+	return options
+}
+
+// SetDownloader replaces how tracks are downloaded for sessions with
+// download_first enabled.
+func (m *SessionManager) SetDownloader(downloader Downloader) {
+	m.download = downloader
+}
+
+// playbackInput returns what session's pipeline reads: with download_first,
+// a copy of the track on disk; with progressive_download, the local URL of
+// a spill file downloading ahead of playback; else streamURL. Copies are
+// made on the first attempt and reused by seeks and retries. A failed
+// download falls back to streaming; the error is only returned if ctx was
+// cancelled.
+func (m *SessionManager) playbackInput(ctx context.Context, session *Session, streamURL string) (string, error) {
+	session.mu.Lock()
+	path := session.download
+	if session.spill != nil && session.spill.Err() != nil {
+		// Gave up (e.g. the stream URL expired); start over from the fresh one
+		session.removeDownloadLocked()
+	}
+	if session.spill != nil {
+		path = session.spillURL
+	}
+	duration := session.expectedDuration
+	session.mu.Unlock()
+	settings := session.settings
+	if path != "" || !settings.DownloadFirst && !settings.ProgressiveDownload {
+		return cmp.Or(path, streamURL), nil
+	}
+	if duration <= 0 || duration > maxDownloadDuration.Seconds() {
+		logger.Infof("[Session] Streaming %s instead of downloading it (duration %.0fs)", shortSessionID(session.ID), duration)
+		return streamURL, nil
+	}
+
+	if !settings.DownloadFirst {
+		return m.openSpill(ctx, session, streamURL)
+	}
+
+	dir := ""
+	if m.cache != nil {
+		dir = m.cache.Dir() // Renamed into the cache once complete
+	}
+	file, err := os.CreateTemp(dir, "natashi-track-*.mka")
+	if err != nil {
+		logger.Warnf("[Session] Can't download %s, streaming instead: %v", shortSessionID(session.ID), err)
+		return streamURL, nil
+	}
+	file.Close()
+	path = file.Name()
+
+	logger.Infof("[Session] Downloading %s before playback (%.0fs)", shortSessionID(session.ID), duration)
+	started := time.Now()
+	if err := m.download(ctx, streamURL, youtube.StreamProxy(ctx), path); err != nil {
+		os.Remove(path)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		logger.Warnf("[Session] Download of %s failed, streaming instead: %v", shortSessionID(session.ID), err)
+		return streamURL, nil
+	}
+
+	logger.Infof("[Session] Downloaded %s in %v", shortSessionID(session.ID), time.Since(started).Round(time.Millisecond))
+	var release func()
+	if m.cache != nil {
+		if path, release, err = m.cache.Add(downloadKey(session.URL), path); err != nil {
+			logger.Warnf("[Session] Can't cache %s, streaming instead: %v", shortSessionID(session.ID), err)
+			return streamURL, nil
+		}
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.download = path
+	session.releaseDownload = release
+	if session.isStopped {
+		session.removeDownloadLocked() // Stopped while downloading
+		return "", context.Canceled
+	}
+	return path, nil
+}
+
+// openSpill starts downloading streamURL into a spill file and returns the
+// URL the pipeline reads it from, falling back to streamURL if the download
+// can't start.
+func (m *SessionManager) openSpill(ctx context.Context, session *Session, streamURL string) (string, error) {
+	file, err := spill.Open(ctx, streamURL, spillOptions(streamURL, youtube.StreamProxy(ctx)))
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		logger.Warnf("[Session] Can't spill %s to disk, streaming instead: %v", shortSessionID(session.ID), err)
+		return streamURL, nil
+	}
+	fileURL, err := m.spills.Add(file)
+	if err != nil {
+		file.Close()
+		logger.Warnf("[Session] Can't serve the spill file of %s, streaming instead: %v", shortSessionID(session.ID), err)
+		return streamURL, nil
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.isStopped {
+		file.Close() // Stopped while the download started
+		return "", context.Canceled
+	}
+	session.spill = file
+	session.spillURL = fileURL
+	logger.Infof("[Session] Downloading %s ahead of playback (%d bytes)", shortSessionID(session.ID), file.Size())
+	return fileURL, nil
+}
+
+// removeDownloadLocked deletes session's downloaded copy (or unpins it in
+// the cache) and its spill file, once they no longer play. Caller must hold
+// s.mu.
+func (s *Session) removeDownloadLocked() {
+	if s.releaseDownload != nil {
+		s.releaseDownload()
+		s.releaseDownload = nil
+	} else if s.download != "" {
+		os.Remove(s.download)
+	}
+	s.download = ""
+	if s.spill != nil {
+		s.spill.Close()
+		s.spill = nil
+		s.spillURL = ""
+	}
+}
+
+// removeDownload is removeDownloadLocked taking s.mu.
+func (s *Session) removeDownload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeDownloadLocked()
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+// inputPipeline reports the input each attempt starts with.
+type inputPipeline struct {
+	encoder.Pipeline
+	inputs chan string
+}
+
+func (p *inputPipeline) Start(ctx context.Context, input string, format encoder.Format, startAtSec float64) error {
+	p.inputs <- input
+	return p.Pipeline.Start(ctx, input, format, startAtSec)
+}
+
+func TestSessionManager_DownloadFirst(t *testing.T) {
+	inputs := make(chan string, 4)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &inputPipeline{Pipeline: newFakePipeline("audio"), inputs: inputs}
+	})
+	downloaded := make(chan string, 1)
+	sm.SetDownloader(func(ctx context.Context, streamURL, proxy, dst string) error {
+		downloaded <- streamURL
+		return os.WriteFile(dst, []byte("audio"), 0o600)
+	})
+	on := true
+	sm.Settings().Update("guild-1", SettingsPatch{DownloadFirst: &on})
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: 120}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	if got := <-downloaded; got != "https://stream.invalid/track" {
+		t.Errorf("expected the stream URL downloaded, got %q", got)
+	}
+	var input string
+	select {
+	case input = <-inputs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline not started")
+	}
+	if strings.Contains(input, "://") {
+		t.Fatalf("expected the pipeline to read the download, got %q", input)
+	}
+
+	for msg := range messages {
+		if msg.event["type"] == "finished" {
+			break
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, err := os.Stat(input); err == nil && time.Now().Before(deadline); _, err = os.Stat(input) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(input); !os.IsNotExist(err) {
+		t.Errorf("expected the download removed after the track, got %v", err)
+	}
+}
+
+func TestSessionManager_DownloadFirstFallback(t *testing.T) {
+	inputs := make(chan string, 4)
+	sm, _ := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &inputPipeline{Pipeline: newFakePipeline(), inputs: inputs}
+	})
+	sm.SetDownloader(func(ctx context.Context, streamURL, proxy, dst string) error {
+		return errors.New("connection reset")
+	})
+	on := true
+	sm.Settings().Update("guild-1", SettingsPatch{DownloadFirst: &on})
+
+	// A failed download and an unknown (live) duration both stream instead
+	for _, duration := range []float64{120, 0} {
+		sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: duration})
+		select {
+		case got := <-inputs:
+			if got != "https://stream.invalid/track" {
+				t.Errorf("expected streaming with duration %.0f, got %q", duration, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("pipeline not started")
+		}
+	}
+	sm.StopAll()
+}
+
+func TestSessionManager_ProgressiveDownload(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("remote audio"))
+	}))
+	defer upstream.Close()
+	inputs := make(chan string, 4)
+	sm, _ := newTestSessionManager(t, &fakeExtractor{base: upstream.URL + "/"}, func(string) encoder.Pipeline {
+		return &inputPipeline{Pipeline: &holdPipeline{fakePipeline: newFakePipeline()}, inputs: inputs}
+	})
+	on := true
+	sm.Settings().Update("guild-1", SettingsPatch{ProgressiveDownload: &on})
+
+	sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: 120})
+	var input string
+	select {
+	case input = <-inputs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline not started")
+	}
+	if !strings.HasPrefix(input, "http://127.0.0.1:") {
+		t.Fatalf("expected the pipeline to read the spill file, got %q", input)
+	}
+	resp, err := http.Get(input)
+	if err != nil {
+		t.Fatalf("spill file not served: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "remote audio" {
+		t.Errorf("expected the upstream stream from the spill file, got %q", body)
+	}
+
+	sm.StopAll()
+	if resp, err := http.Get(input); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the spill file gone after stop, got %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestSpillOptions(t *testing.T) {
+	if got := spillOptions("https://rr1---sn-abc.googlevideo.com/videoplayback?id=1", "").Connections; got != streamConnections {
+		t.Errorf("expected %d connections for googlevideo, got %d", streamConnections, got)
+	}
+	if got := spillOptions("https://cdn.example.com/track.m4a", "").Connections; got != 1 {
+		t.Errorf("expected 1 connection for other hosts, got %d", got)
+	}
+	if options := spillOptions("https://cdn.example.com/a", "http://proxy:3128"); options.Client == http.DefaultClient {
+		t.Error("expected a client using the proxy")
+	}
+}
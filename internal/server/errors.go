@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
+// errSessionNotFound is returned for operations on an unknown session ID.
+var errSessionNotFound = errs.New(errs.ErrNotFound, "session not found")
+
+// errDraining is returned by StartPlayback while the server is draining.
+var errDraining = errors.New("server is draining, not accepting new playback")
+
+// errInvalidSeek is returned for a seek outside the track.
+var errInvalidSeek = errors.New("seek position out of range")
+
+// errInvalidRange is returned by StartPlayback for an end_at not after start_at.
+var errInvalidRange = errors.New("end_at must be after start_at")
+
+// errNotPlaying is returned for a seek on a session that has ended.
+var errNotPlaying = errors.New("session is not playing")
+
+// errInvalidSettings is returned for a settings update with a bad value.
+var errInvalidSettings = errors.New("invalid settings")
+
+// errInvalidStreamURL is returned by StartPlayback for a pre-resolved
+// stream URL that isn't http(s).
+var errInvalidStreamURL = errors.New("stream_url must be an http(s) URL")
+
+// errInvalidQueue is returned for a queue file that can't be imported.
+var errInvalidQueue = errors.New("invalid queue")
+
+// errInvalidFormat is returned by SetFormat for an unknown format.
+var errInvalidFormat = errors.New("format must be pcm, opus or web")
+
+// errInvalidBitrate is returned by SetBitrate for a bitrate Opus can't use.
+var errInvalidBitrate = errors.New("bitrate must be between 6000 and 510000 bps")
+
+// errInvalidRegion is returned by StartPlayback for a region that isn't a
+// two-letter country code.
+var errInvalidRegion = errors.New("region must be a two-letter country code")
+
+// errNoABR is returned by Feedback for formats without adaptive bitrate.
+var errNoABR = errors.New("adaptive bitrate not supported for this format")
+
+// httpStatus maps an error to the HTTP status for API responses. It uses
+// the same codes as socket error events (see errs.Code).
+func httpStatus(err error) int {
+	switch errs.Code(err) {
+	case "timeout":
+		return http.StatusGatewayTimeout
+	case "not_found":
+		return http.StatusNotFound
+	case "extraction":
+		return http.StatusBadGateway
+	case "transport":
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, errNoABR) || errors.Is(err, errInvalidSettings) || errors.Is(err, errInvalidSeek) ||
+		errors.Is(err, errInvalidRange) || errors.Is(err, errInvalidClip) || errors.Is(err, errInvalidSessionID) ||
+		errors.Is(err, errInvalidStreamURL) || errors.Is(err, errInvalidQueue) ||
+		errors.Is(err, errInvalidFormat) || errors.Is(err, errInvalidBitrate) || errors.Is(err, errInvalidRegion) ||
+		errors.Is(err, errInvalidThumbnail) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, errNotPlaying) || errors.Is(err, errClipNotReady) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, errDraining) || errors.Is(err, errLoudnessDisabled) || errors.Is(err, errCacheDisabled) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+// stopFadeSlack is how long past the fade a stop waits for the next chunk
+// to fade out before it stops the pipeline regardless.
+const stopFadeSlack = 250 * time.Millisecond
+
+// pcmRamp is a linear gain ramp over s16le PCM. Fades on play and seek are
+// done by FFmpeg (see encoder.FadeSetter); pause, resume and stop act on
+// output that is already encoded, which only PCM allows changing.
+type pcmRamp struct {
+	from, to float64 // Gain at the start and end of the ramp
+	frames   int     // Ramp length in sample frames
+	pos      int     // Frames ramped so far
+}
+
+func newPCMRamp(from, to float64, d time.Duration) *pcmRamp {
+	frames := int(d.Seconds() * float64(encoder.DefaultConfig().SampleRate))
+	return &pcmRamp{from: from, to: to, frames: max(frames, 1)}
+}
+
+// apply scales the start of pcm in place and returns how many bytes the
+// ramp covered; anything after that is past the end of the ramp.
+func (r *pcmRamp) apply(pcm []byte) int {
+	frameSize := 2 * encoder.DefaultConfig().Channels
+	n := 0
+	for ; n+frameSize <= len(pcm) && r.pos < r.frames; n += frameSize {
+		gain := r.from + (r.to-r.from)*float64(r.pos)/float64(r.frames)
+		for i := n; i < n+frameSize; i += 2 {
+			sample := int16(binary.LittleEndian.Uint16(pcm[i:]))
+			binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(float64(sample)*gain)))
+		}
+		r.pos++
+	}
+	return n
+}
+
+// done reports whether the ramp has reached its end gain.
+func (r *pcmRamp) done() bool {
+	return r.pos >= r.frames
+}
+
+// fadeOut ramps the start of chunk down to silence. It returns the faded
+// part and, as a separate chunk, the audio after it (empty if the fade took
+// the whole chunk).
+func fadeOut(chunk []byte, d time.Duration) (faded, rest []byte) {
+	n := newPCMRamp(1, 0, d).apply(chunk)
+	rest = buffer.GetChunk(len(chunk) - n)
+	copy(rest, chunk[n:])
+	return chunk[:n], rest
+}
+
+// pcmFadeLocked returns the fade for pause, resume and stop: the session's
+// fade setting for PCM output, 0 for encoded formats.
+// Caller must hold s.mu.
+func (s *Session) pcmFadeLocked() time.Duration {
+	if s.Format != encoder.FormatPCM {
+		return 0
+	}
+	return s.settings.fade()
+}
+
+// minCrossfade is the shortest overlap worth crossfading; a track closer
+// to its end than this just stops.
+const minCrossfade = time.Second
+
+// tail returns the crossfade from s into a play replacing it with
+// settings: the rest of s from its current position, up to the crossfade
+// length. It is zero if crossfading is off or s isn't streaming.
+func (s *Session) tail(settings SessionSettings) encoder.Crossfade {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	length := settings.crossfade()
+	if length == 0 || s.State != StateStreaming || s.isPaused || s.stopping || s.streamURL == "" {
+		return encoder.Crossfade{}
+	}
+	position := s.positionLocked()
+	if end := s.endLocked(); end > 0 {
+		length = min(length, time.Duration((end-position)*float64(time.Second)))
+	}
+	if length < minCrossfade {
+		return encoder.Crossfade{}
+	}
+	return encoder.Crossfade{URL: s.streamURL, From: position, Duration: length, Curve: settings.CrossfadeCurve}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+// pcmTone returns d of stereo s16le PCM with every sample set to value.
+func pcmTone(value int16, d time.Duration) []byte {
+	pcm := make([]byte, int(d.Seconds()*48000)*4)
+	for i := 0; i < len(pcm); i += 2 {
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(value))
+	}
+	return pcm
+}
+
+func sampleAt(pcm []byte, i int) int16 {
+	return int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+}
+
+func TestPCMRamp_SpansChunks(t *testing.T) {
+	ramp := newPCMRamp(0, 1, 10*time.Millisecond) // 480 frames
+	first := pcmTone(1000, 5*time.Millisecond)
+	second := pcmTone(1000, 10*time.Millisecond)
+
+	if n := ramp.apply(first); n != len(first) || ramp.done() {
+		t.Fatalf("first chunk: covered %d of %d bytes, done=%v", n, len(first), ramp.done())
+	}
+	if n := ramp.apply(second); n != len(first) || !ramp.done() {
+		t.Fatalf("second chunk: covered %d bytes, done=%v", n, ramp.done())
+	}
+	if got := sampleAt(first, 0); got != 0 {
+		t.Errorf("ramp should start silent, got %d", got)
+	}
+	if got := sampleAt(second, 0); got < 490 || got > 510 {
+		t.Errorf("expected about half gain midway, got %d", got)
+	}
+	if got := sampleAt(second, len(second)/2-1); got != 1000 {
+		t.Errorf("audio after the ramp should be untouched, got %d", got)
+	}
+}
+
+func TestFadeOut_SplitsChunk(t *testing.T) {
+	chunk := pcmTone(-1000, 30*time.Millisecond)
+
+	faded, rest := fadeOut(chunk, 10*time.Millisecond)
+
+	if len(faded) != 480*4 || len(rest) != len(chunk)-len(faded) {
+		t.Fatalf("unexpected split %d + %d of %d bytes", len(faded), len(rest), len(chunk))
+	}
+	if got := sampleAt(faded, 0); got != -1000 {
+		t.Errorf("fade should start at full gain, got %d", got)
+	}
+	if got := sampleAt(faded, len(faded)/2-1); got < -5 || got > 0 {
+		t.Errorf("fade should end near silence, got %d", got)
+	}
+	if got := sampleAt(rest, 0); got != -1000 {
+		t.Errorf("rest should be untouched, got %d", got)
+	}
+}
+
+// feedPipeline emits the chunks written to feed and records pauses.
+type feedPipeline struct {
+	*fakePipeline
+	feed   chan []byte
+	paused chan struct{}
+}
+
+func (p *feedPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	go func() {
+		defer close(p.output)
+		for {
+			select {
+			case chunk := <-p.feed:
+				select {
+				case p.output <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *feedPipeline) Pause() { p.paused <- struct{}{} }
+
+func nextAudio(t *testing.T, messages <-chan socketMessage) []byte {
+	t.Helper()
+	for {
+		if msg := nextMessage(t, messages); msg.audio != nil {
+			return msg.audio
+		}
+	}
+}
+
+func TestSessionManager_FadesPCMOnPauseAndResume(t *testing.T) {
+	pipeline := &feedPipeline{
+		fakePipeline: newFakePipeline(),
+		feed:         make(chan []byte),
+		paused:       make(chan struct{}, 1),
+	}
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return pipeline
+	})
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+
+	pipeline.feed <- pcmTone(1000, 200*time.Millisecond)
+	if got := sampleAt(nextAudio(t, messages), 0); got != 1000 {
+		t.Fatalf("chunks before a pause should be untouched, got %d", got)
+	}
+
+	// The chunk after a pause fades out over the default 100ms; the rest is held
+	sm.Pause("guild-1")
+	pipeline.feed <- pcmTone(1000, 200*time.Millisecond)
+	faded := nextAudio(t, messages)
+	if len(faded) != 4800*4 {
+		t.Fatalf("expected a 100ms fade-out, got %d bytes", len(faded))
+	}
+	if got := sampleAt(faded, len(faded)/2-1); got > 5 {
+		t.Errorf("fade-out should end near silence, got %d", got)
+	}
+	select {
+	case <-pipeline.paused:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline was not paused after the fade-out")
+	}
+
+	sm.Resume("guild-1")
+	held := nextAudio(t, messages)
+	if len(held) != 4800*4 {
+		t.Fatalf("expected the held 100ms after resume, got %d bytes", len(held))
+	}
+	if got := sampleAt(held, 0); got != 0 {
+		t.Errorf("resume should fade in from silence, got %d", got)
+	}
+}
+
+func TestSessionManager_FadesPCMOnStop(t *testing.T) {
+	pipeline := &feedPipeline{
+		fakePipeline: newFakePipeline(),
+		feed:         make(chan []byte),
+		paused:       make(chan struct{}, 1),
+	}
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return pipeline
+	})
+	sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{})
+	pipeline.feed <- pcmTone(1000, 50*time.Millisecond)
+	nextAudio(t, messages)
+
+	sm.Stop("guild-1")
+	pipeline.feed <- pcmTone(1000, 200*time.Millisecond)
+	faded := nextAudio(t, messages)
+	if len(faded) != 4800*4 {
+		t.Fatalf("expected only the 100ms fade-out after stop, got %d bytes", len(faded))
+	}
+	if got := sampleAt(faded, len(faded)/2-1); got > 5 {
+		t.Errorf("fade-out should end near silence, got %d", got)
+	}
+	select {
+	case pipeline.feed <- pcmTone(1000, 10*time.Millisecond):
+	case <-time.After(100 * time.Millisecond):
+	}
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg := <-messages:
+			if msg.audio != nil {
+				t.Fatalf("audio sent after the fade-out: %d bytes", len(msg.audio))
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestSessionTail(t *testing.T) {
+	settings := DefaultSessionSettings()
+	session := &Session{State: StateStreaming, StartAt: 100, streamURL: "https://old.invalid", expectedDuration: 300}
+
+	if c := session.tail(settings); c.Duration != 0 {
+		t.Errorf("expected no crossfade when it is off, got %+v", c)
+	}
+
+	settings.CrossfadeMs = 8000
+	c := session.tail(settings)
+	if c.URL != "https://old.invalid" || c.From != 100 || c.Duration != 8*time.Second || c.Curve != encoder.DefaultCrossfadeCurve {
+		t.Errorf("unexpected crossfade %+v", c)
+	}
+
+	session.StartAt = 296
+	if c := session.tail(settings); c.Duration != 4*time.Second {
+		t.Errorf("expected the crossfade cut to the rest of the track, got %v", c.Duration)
+	}
+
+	session.State = StatePaused
+	if c := session.tail(settings); c.Duration != 0 {
+		t.Errorf("expected no crossfade from a paused track, got %+v", c)
+	}
+}
+
+// crossfadePipeline records the crossfade it is started with.
+type crossfadePipeline struct {
+	*holdPipeline
+	crossfades chan encoder.Crossfade
+}
+
+func (p *crossfadePipeline) SetCrossfade(c encoder.Crossfade) {
+	p.crossfades <- c
+}
+
+func TestSessionManager_CrossfadesReplacedTrack(t *testing.T) {
+	crossfades := make(chan encoder.Crossfade, 4)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &crossfadePipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, crossfades: crossfades}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	crossfadeMs := 5000
+	sm.Settings().Update("guild-1", SettingsPatch{CrossfadeMs: &crossfadeMs})
+
+	sm.StartPlayback("guild-1", "fake://first", "opus", PlaybackOptions{Duration: 200})
+	deadline := time.Now().Add(2 * time.Second)
+	for sm.StreamingSessionCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sm.StartPlayback("guild-1", "fake://second", "opus", PlaybackOptions{Duration: 200})
+
+	select {
+	case c := <-crossfades:
+		if c.URL != "https://stream.invalid/first" || c.Duration != 5*time.Second {
+			t.Errorf("expected a 5s crossfade from the first track, got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second track to crossfade from the first")
+	}
+	sm.StopAll()
+}
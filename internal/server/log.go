@@ -0,0 +1,6 @@
+package server
+
+import "github.com/thewind121212/natashi/internal/logging"
+
+// logger is the server's log output (component "server").
+var logger = logging.New("server")
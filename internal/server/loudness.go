@@ -0,0 +1,238 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite" // SQLite driver for the loudness cache
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+// maxConcurrentMeasurements bounds loudness analysis passes running at once.
+const maxConcurrentMeasurements = 2
+
+// errLoudnessDisabled is returned when no loudness cache is configured.
+var errLoudnessDisabled = errors.New("loudness measurement is not enabled")
+
+// LoudnessCache keeps loudness measurements by track in SQLite, so each
+// track is measured once.
+type LoudnessCache struct {
+	db *sql.DB
+}
+
+// OpenLoudnessCache opens (or creates) the cache database at path. An empty
+// path keeps the cache in memory.
+func OpenLoudnessCache(path string) (*LoudnessCache, error) {
+	dsn := ":memory:"
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create loudness cache directory: %w", err)
+		}
+		dsn = path
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open loudness cache: %w", err)
+	}
+	db.SetMaxOpenConns(1) // One writer; also keeps a :memory: database on one connection
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS loudness (
+		track       TEXT PRIMARY KEY,
+		integrated  REAL NOT NULL,
+		true_peak   REAL NOT NULL,
+		lra         REAL NOT NULL,
+		measured_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create loudness cache %s: %w", path, err)
+	}
+	return &LoudnessCache{db: db}, nil
+}
+
+// Get returns the measurement for track, if cached.
+func (c *LoudnessCache) Get(track string) (encoder.Loudness, bool) {
+	var l encoder.Loudness
+	err := c.db.QueryRow(`SELECT integrated, true_peak, lra FROM loudness WHERE track = ?`, track).
+		Scan(&l.Integrated, &l.TruePeak, &l.LRA)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Warnf("[Loudness] Cache read failed for %s: %v", track, err)
+		}
+		return encoder.Loudness{}, false
+	}
+	return l, true
+}
+
+// Put stores the measurement for track.
+func (c *LoudnessCache) Put(track string, l encoder.Loudness) error {
+	_, err := c.db.Exec(`INSERT OR REPLACE INTO loudness (track, integrated, true_peak, lra, measured_at)
+		VALUES (?, ?, ?, ?, ?)`, track, l.Integrated, l.TruePeak, l.LRA, time.Now().Unix())
+	return err
+}
+
+// Close closes the database.
+func (c *LoudnessCache) Close() error {
+	return c.db.Close()
+}
+
+// loudnessAnalyzer measures tracks in the background and caches the result.
+type loudnessAnalyzer struct {
+	cache  *LoudnessCache
+	runner execx.CommandRunner // Creates the FFmpeg process (replaceable in tests)
+	slots  chan struct{}       // Limits concurrent measurements
+
+	mu      sync.Mutex
+	pending map[string]bool // Tracks being measured
+}
+
+// SetLoudnessCache enables two-pass normalisation for the "loudnorm"
+// effect, with measurements kept in cache.
+func (m *SessionManager) SetLoudnessCache(cache *LoudnessCache) {
+	m.loudness = &loudnessAnalyzer{
+		cache:   cache,
+		runner:  execx.Default,
+		slots:   make(chan struct{}, maxConcurrentMeasurements),
+		pending: make(map[string]bool),
+	}
+}
+
+// trackKey identifies url's track in the loudness cache: the video ID for
+// YouTube, otherwise the URL itself.
+func trackKey(url string) string {
+	if id := youtube.VideoID(url); id != "" {
+		return id
+	}
+	return url
+}
+
+// Loudness returns the cached measurement for url. If there is none, it
+// starts measuring url in the background and returns false.
+func (m *SessionManager) Loudness(url string) (encoder.Loudness, bool, error) {
+	if m.loudness == nil {
+		return encoder.Loudness{}, false, errLoudnessDisabled
+	}
+	key := trackKey(url)
+	if l, ok := m.loudness.cache.Get(key); ok {
+		return l, true, nil
+	}
+	m.measureLoudness(key, url, "")
+	return encoder.Loudness{}, false, nil
+}
+
+// measureLoudness measures url in the background unless it is already being
+// measured. streamURL skips extraction when the caller already has one.
+func (m *SessionManager) measureLoudness(key, url, streamURL string) {
+	a := m.loudness
+	a.mu.Lock()
+	if a.pending[key] {
+		a.mu.Unlock()
+		return
+	}
+	a.pending[key] = true
+	a.mu.Unlock()
+
+	go func() {
+		defer func() {
+			a.mu.Lock()
+			delete(a.pending, key)
+			a.mu.Unlock()
+		}()
+		select {
+		case a.slots <- struct{}{}:
+			defer func() { <-a.slots }()
+		case <-m.ctx.Done():
+			return
+		}
+
+		var err error
+		if streamURL == "" {
+			streamURL, err = m.resolveStreamURL(m.ctx, url)
+		}
+		var l encoder.Loudness
+		if err == nil {
+			l, err = encoder.MeasureLoudness(m.ctx, a.runner, streamURL)
+		}
+		if err == nil {
+			err = a.cache.Put(key, l)
+		}
+		if err != nil {
+			logger.Warnf("[Loudness] Measuring %s failed: %v", key, err)
+			return
+		}
+		logger.Infof("[Loudness] %s: %.1f LUFS, %.1f dBTP, gain %+.1f dB", key, l.Integrated, l.TruePeak, l.Gain())
+	}()
+}
+
+// isLoudnorm reports whether effect names the single-pass "loudnorm" effect.
+func isLoudnorm(effect string) bool {
+	return strings.EqualFold(strings.TrimSpace(effect), "loudnorm")
+}
+
+// sessionFilter returns the filter chain for session's next pipeline. With
+// a loudness cache, the "loudnorm" effect becomes a linear gain once the
+// track is measured; until then it stays single-pass and the track is
+// measured for next time.
+func (m *SessionManager) sessionFilter(session *Session, streamURL string) string {
+	settings := session.settings
+	if m.loudness != nil && slices.ContainsFunc(settings.Effects, isLoudnorm) {
+		key := trackKey(session.URL)
+		if l, ok := m.loudness.cache.Get(key); ok {
+			settings.Effects = slices.DeleteFunc(slices.Clone(settings.Effects), isLoudnorm)
+			filter, _ := settings.filter() // Validated when saved
+			if filter == "" {
+				return l.GainFilter()
+			}
+			return filter + "," + l.GainFilter()
+		}
+		m.measureLoudness(key, session.URL, streamURL)
+	}
+	filter, _ := settings.filter() // Validated when saved
+	return filter
+}
+
+// LoudnessRequest is the request body for POST /loudness.
+type LoudnessRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// LoudnessResponse reports a track's loudness measurement.
+type LoudnessResponse struct {
+	Track    string            `json:"track"`
+	Status   string            `json:"status"` // "measured" or "measuring"
+	Loudness *encoder.Loudness `json:"loudness,omitempty"`
+	GainDB   *float64          `json:"gain_db,omitempty"` // Gain applied with the loudnorm effect
+}
+
+// MeasureLoudness handles POST /loudness: it returns a cached measurement,
+// or starts measuring the track (202) so it is ready when it plays. Call it
+// for queued tracks.
+func (a *API) MeasureLoudness(c *gin.Context) {
+	var req LoudnessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	l, ok, err := a.sessions.Loudness(req.URL)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusAccepted, LoudnessResponse{Track: trackKey(req.URL), Status: "measuring"})
+		return
+	}
+	gain := l.Gain()
+	c.JSON(http.StatusOK, LoudnessResponse{Track: trackKey(req.URL), Status: "measured", Loudness: &l, GainDB: &gain})
+}
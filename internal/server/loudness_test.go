@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+func TestLoudnessCache_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "loudness.db")
+	cache, err := OpenLoudnessCache(path)
+	if err != nil {
+		t.Fatalf("OpenLoudnessCache failed: %v", err)
+	}
+	want := encoder.Loudness{Integrated: -9.5, TruePeak: 0.2, LRA: 6}
+	if err := cache.Put("dQw4w9WgXcQ", want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	cache.Close()
+
+	reopened, err := OpenLoudnessCache(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer reopened.Close()
+	if got, ok := reopened.Get("dQw4w9WgXcQ"); !ok || got != want {
+		t.Errorf("expected %+v after reopen, got %+v (found=%v)", want, got, ok)
+	}
+	if _, ok := reopened.Get("missing"); ok {
+		t.Error("expected a miss for an unknown track")
+	}
+}
+
+func TestTrackKey(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=x": "dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ":                       "dQw4w9WgXcQ",
+		"fake://track":                                       "fake://track",
+	} {
+		if got := trackKey(url); got != want {
+			t.Errorf("trackKey(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestLoudnessEndpoint(t *testing.T) {
+	sm, _ := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline()
+	})
+	router := SetupRouter(NewAPI(sm))
+
+	if w := clipRequest(router, "POST", "/loudness", `{"url": "fake://track"}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a cache, got %d", w.Code)
+	}
+
+	cache, err := OpenLoudnessCache("")
+	if err != nil {
+		t.Fatalf("OpenLoudnessCache failed: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	sm.SetLoudnessCache(cache)
+	sm.loudness.runner = execx.RunnerFunc(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", `echo '{"input_i": "-10.0", "input_tp": "-3.0", "input_lra": "5.0"}' >&2`)
+	})
+
+	w := clipRequest(router, "POST", "/loudness", `{"url": "fake://track"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 while measuring, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp LoudnessResponse
+	deadline := time.Now().Add(2 * time.Second)
+	for resp.Status != "measured" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w = clipRequest(router, "POST", "/loudness", `{"url": "fake://track"}`)
+		json.Unmarshal(w.Body.Bytes(), &resp)
+	}
+	if w.Code != http.StatusOK || resp.Loudness == nil || resp.GainDB == nil || *resp.GainDB != -6 {
+		t.Fatalf("expected a cached measurement with -6 dB gain, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSessionManager_LoudnessGainReplacesLoudnorm(t *testing.T) {
+	filters := make(chan string, 1)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &filterPipeline{fakePipeline: newFakePipeline(), filters: filters}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	cache, err := OpenLoudnessCache("")
+	if err != nil {
+		t.Fatalf("OpenLoudnessCache failed: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	cache.Put("fake://track", encoder.Loudness{Integrated: -10, TruePeak: -3, LRA: 5})
+	sm.SetLoudnessCache(cache)
+
+	effects := []string{"bassboost", "loudnorm"}
+	sm.Settings().Update("guild-1", SettingsPatch{Effects: &effects})
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+
+	select {
+	case got := <-filters:
+		if got != "bass=g=8:f=100,volume=-6.00dB @ 1" {
+			t.Errorf("expected the measured gain instead of loudnorm, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline filter was not set")
+	}
+}
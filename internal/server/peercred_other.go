@@ -0,0 +1,14 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials is only implemented on Linux; elsewhere peer checks
+// refuse every connection, so Start rejects them up front.
+func peerCredentials(conn net.Conn) (uid, gid int, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/metrics"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+// Prefetch configuration
+const (
+	maxConcurrentPrefetches = 2  // yt-dlp calls prefetching at once; the rest wait
+	maxPrefetched           = 64 // Stream URLs kept; the oldest are dropped first
+)
+
+// prefetched is a stream URL extracted ahead of its play.
+type prefetched struct {
+	streamURL string
+	at        time.Time
+}
+
+// prefetcher extracts the stream URLs of upcoming queue tracks in the
+// background, so their plays skip yt-dlp.
+type prefetcher struct {
+	tracks int           // Upcoming tracks per queue to prefetch
+	slots  chan struct{} // Limits concurrent extractions
+
+	mu      sync.Mutex
+	ready   map[string]prefetched // By track URL
+	pending map[string]bool       // Tracks being prefetched
+}
+
+// SetPrefetch prefetches the next tracks of each session's saved queue
+// (0 disables prefetching).
+func (m *SessionManager) SetPrefetch(tracks int) {
+	if tracks <= 0 {
+		m.prefetch = nil
+		return
+	}
+	m.prefetch = &prefetcher{
+		tracks:  tracks,
+		slots:   make(chan struct{}, maxConcurrentPrefetches),
+		ready:   make(map[string]prefetched),
+		pending: make(map[string]bool),
+	}
+}
+
+// Queues returns the saved queues, whose upcoming tracks are prefetched.
+func (m *SessionManager) Queues() *QueueStore {
+	return m.queues
+}
+
+// upcoming returns the n tracks of queue after current (the first n if
+// current isn't queued, as when clients queue only what comes next).
+func upcoming(queue []QueueTrack, current string, n int) []QueueTrack {
+	start := 0
+	for i, track := range queue {
+		if track.URL == current {
+			start = i + 1
+			break
+		}
+	}
+	return queue[start:min(start+n, len(queue))]
+}
+
+// PrefetchQueue prefetches the tracks of id's saved queue after the one
+// playing. The first is also downloaded into the cache when the session
+// plays with download_first.
+func (m *SessionManager) PrefetchQueue(id string) {
+	p := m.prefetch
+	if p == nil {
+		return
+	}
+	current := ""
+	if session := m.Get(id); session != nil {
+		current = session.URL
+	}
+	next := upcoming(m.queues.Get(id).Tracks, current, p.tracks)
+	for i, track := range next {
+		download := i == 0 && m.cache != nil && m.settings.Get(id).DownloadFirst &&
+			track.Duration > 0 && float64(track.Duration) <= maxDownloadDuration.Seconds()
+		m.prefetchTrack(track.URL, download)
+	}
+}
+
+// prefetchTrack extracts url's stream URL in the background unless a fresh
+// one is ready, and with download also caches the track for download_first.
+func (m *SessionManager) prefetchTrack(url string, download bool) {
+	p := m.prefetch
+	if m.registry.FindExtractor(url) == nil {
+		return
+	}
+	cached := download && m.cache.Has(downloadKey(url))
+	p.mu.Lock()
+	ready, ok := p.ready[url]
+	fresh := ok && ready.usable()
+	if p.pending[url] || fresh && (!download || cached) {
+		p.mu.Unlock()
+		return
+	}
+	p.pending[url] = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.pending, url)
+			p.mu.Unlock()
+		}()
+		select {
+		case p.slots <- struct{}{}:
+			defer func() { <-p.slots }()
+		case <-m.ctx.Done():
+			return
+		}
+
+		streamURL := ready.streamURL
+		if !fresh {
+			var err error
+			if streamURL, err = m.resolveStreamURL(m.ctx, url); err != nil {
+				logger.Warnf("[Prefetch] %s: %v", url, err)
+				return
+			}
+			p.put(url, streamURL)
+			logger.Infof("[Prefetch] Extracted %s", url)
+		}
+		if download && !cached {
+			m.precache(url, streamURL)
+		}
+	}()
+}
+
+// precache downloads url's track into the cache, as download_first would
+// on its play.
+func (m *SessionManager) precache(url, streamURL string) {
+	file, err := os.CreateTemp(m.cache.Dir(), "natashi-track-*.mka")
+	if err != nil {
+		logger.Warnf("[Prefetch] Can't download %s: %v", url, err)
+		return
+	}
+	file.Close()
+	if err := m.download(m.ctx, streamURL, youtube.StreamProxy(m.ctx), file.Name()); err != nil {
+		os.Remove(file.Name())
+		logger.Warnf("[Prefetch] Download of %s failed: %v", url, err)
+		return
+	}
+	_, release, err := m.cache.Add(downloadKey(url), file.Name())
+	if err != nil {
+		logger.Warnf("[Prefetch] Can't cache %s: %v", url, err)
+		return
+	}
+	release()
+	logger.Infof("[Prefetch] Downloaded %s", url)
+}
+
+// usable reports whether the stream URL is still worth playing.
+func (r prefetched) usable() bool {
+	streamURL, err := usableStreamURL(r.streamURL, time.Time{})
+	return err == nil && streamURL != ""
+}
+
+// put records a prefetched stream URL, dropping the oldest beyond
+// maxPrefetched.
+func (p *prefetcher) put(url, streamURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready[url] = prefetched{streamURL: streamURL, at: time.Now()}
+	if len(p.ready) <= maxPrefetched {
+		return
+	}
+	oldest := ""
+	for u, r := range p.ready {
+		if oldest == "" || r.at.Before(p.ready[oldest].at) {
+			oldest = u
+		}
+	}
+	delete(p.ready, oldest)
+}
+
+// take returns and forgets the prefetched stream URL of url, if it is
+// still usable.
+func (p *prefetcher) take(url string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ready, ok := p.ready[url]
+	if !ok {
+		return ""
+	}
+	delete(p.ready, url)
+	if !ready.usable() {
+		metrics.ObservePrefetch(metrics.PrefetchExpired)
+		return ""
+	}
+	metrics.ObservePrefetch(metrics.PrefetchHit)
+	return ready.streamURL
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/metrics"
+)
+
+func TestUpcoming(t *testing.T) {
+	queue := []QueueTrack{{URL: "a"}, {URL: "b"}, {URL: "c"}, {URL: "d"}}
+	urls := func(tracks []QueueTrack) []string {
+		var out []string
+		for _, track := range tracks {
+			out = append(out, track.URL)
+		}
+		return out
+	}
+	tests := []struct {
+		current string
+		n       int
+		want    []string
+	}{
+		{"a", 2, []string{"b", "c"}},
+		{"c", 2, []string{"d"}},
+		{"d", 2, nil},
+		{"x", 2, []string{"a", "b"}}, // Not queued: the queue is what comes next
+		{"", 10, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		if got := urls(upcoming(queue, tt.current, tt.n)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("upcoming(%q, %d) = %v, want %v", tt.current, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSessionManager_PrefetchQueue(t *testing.T) {
+	extractor := &fakeExtractor{}
+	inputs := make(chan string, 4)
+	sm, _ := newTestSessionManager(t, extractor, func(string) encoder.Pipeline {
+		return &inputPipeline{Pipeline: newFakePipeline("audio"), inputs: inputs}
+	})
+	sm.SetPrefetch(2)
+	sm.Queues().Set("guild-1", QueueFile{Tracks: []QueueTrack{
+		{URL: "fake://a", Duration: 200}, {URL: "fake://b"}, {URL: "fake://c"},
+	}})
+
+	sm.PrefetchQueue("guild-1")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sm.prefetch.mu.Lock()
+		ready := len(sm.prefetch.ready)
+		sm.prefetch.mu.Unlock()
+		if ready == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 tracks prefetched, got %d", ready)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	extracted := extractor.calls.Load()
+
+	if err := sm.StartPlayback("guild-1", "fake://a", "pcm", PlaybackOptions{}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	select {
+	case input := <-inputs:
+		if input != "https://stream.invalid/a" {
+			t.Errorf("expected the prefetched stream URL, got %q", input)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline not started")
+	}
+	if session := sm.Get("guild-1"); session == nil || session.expectedDuration != 200 {
+		t.Error("expected the duration taken from the queue")
+	}
+	if calls := extractor.calls.Load() - extracted; calls > 1 {
+		t.Errorf("expected the play to skip extraction (only c prefetched), got %d extractions", calls)
+	}
+	var out bytes.Buffer
+	metrics.Default.Write(&out)
+	if !strings.Contains(out.String(), `music_bot_prefetch_plays_total{result="hit"}`) {
+		t.Error("expected the prefetch hit in the metrics")
+	}
+}
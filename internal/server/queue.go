@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+// Queue import limits
+const (
+	maxQueueBytes  = 1 << 20
+	maxQueueTracks = 5000
+	maxSavedQueues = 1000 // Queues kept; the least recently saved are dropped first
+)
+
+// QueueTrack is one track of a saved queue.
+type QueueTrack struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	Duration  int    `json:"duration,omitempty"` // Seconds
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+// QueueFile is the JSON form of an exported queue.
+type QueueFile struct {
+	Title  string       `json:"title,omitempty"`
+	Tracks []QueueTrack `json:"tracks"`
+}
+
+// QueueFormat is a file format queues are imported from and exported to.
+type QueueFormat string
+
+const (
+	QueueJSON QueueFormat = "json"
+	QueueM3U  QueueFormat = "m3u"
+	QueueXSPF QueueFormat = "xspf"
+)
+
+// ContentType returns the MIME type of the format.
+func (f QueueFormat) ContentType() string {
+	switch f {
+	case QueueM3U:
+		return "audio/x-mpegurl"
+	case QueueXSPF:
+		return "application/xspf+xml"
+	}
+	return "application/json"
+}
+
+// queueFormat reads the format of an import or export: the format query
+// parameter, else (for imports) the body's Content-Type, else sniffed from
+// body (nil for exports, which default to JSON).
+func queueFormat(c *gin.Context, body []byte) (QueueFormat, error) {
+	switch format := strings.ToLower(c.Query("format")); format {
+	case "json", "m3u", "xspf":
+		return QueueFormat(format), nil
+	case "m3u8":
+		return QueueM3U, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: unknown queue format %q (want json, m3u or xspf)", errInvalidQueue, format)
+	}
+	if body == nil {
+		return QueueJSON, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case "application/json":
+		return QueueJSON, nil
+	case "audio/x-mpegurl", "audio/mpegurl", "application/vnd.apple.mpegurl":
+		return QueueM3U, nil
+	case "application/xspf+xml":
+		return QueueXSPF, nil
+	}
+	switch trimmed := bytes.TrimSpace(body); {
+	case bytes.HasPrefix(trimmed, []byte("{")), bytes.HasPrefix(trimmed, []byte("[")):
+		return QueueJSON, nil
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return QueueXSPF, nil
+	}
+	return QueueM3U, nil
+}
+
+// xspfPlaylist is an XSPF document (https://xspf.org/spec).
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Duration int64  `xml:"duration,omitempty"` // Milliseconds
+	Image    string `xml:"image,omitempty"`
+}
+
+// decodeQueue parses a queue file. Tracks without a URL are an error, so a
+// wrong format isn't mistaken for an empty queue.
+func decodeQueue(format QueueFormat, data []byte) (QueueFile, error) {
+	var queue QueueFile
+	switch format {
+	case QueueJSON:
+		var err error
+		if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+			err = json.Unmarshal(trimmed, &queue.Tracks) // A bare track list
+		} else {
+			err = json.Unmarshal(data, &queue)
+		}
+		if err != nil {
+			return QueueFile{}, fmt.Errorf("%w: parse JSON: %v", errInvalidQueue, err)
+		}
+	case QueueM3U:
+		tracks, err := player.ParseM3U(bytes.NewReader(data), "")
+		if err != nil {
+			return QueueFile{}, fmt.Errorf("%w: parse M3U: %v", errInvalidQueue, err)
+		}
+		for _, t := range tracks {
+			queue.Tracks = append(queue.Tracks, QueueTrack{URL: t.URL, Title: t.Title, Duration: t.Duration})
+		}
+	case QueueXSPF:
+		var playlist xspfPlaylist
+		if err := xml.Unmarshal(data, &playlist); err != nil {
+			return QueueFile{}, fmt.Errorf("%w: parse XSPF: %v", errInvalidQueue, err)
+		}
+		queue.Title = playlist.Title
+		for _, t := range playlist.Tracks {
+			queue.Tracks = append(queue.Tracks, QueueTrack{
+				URL:       strings.TrimSpace(t.Location),
+				Title:     t.Title,
+				Duration:  int(t.Duration / 1000),
+				Thumbnail: t.Image,
+			})
+		}
+	}
+
+	if queue.Tracks == nil {
+		queue.Tracks = []QueueTrack{}
+	}
+	if len(queue.Tracks) > maxQueueTracks {
+		return QueueFile{}, fmt.Errorf("%w: more than %d tracks", errInvalidQueue, maxQueueTracks)
+	}
+	for i, t := range queue.Tracks {
+		if t.URL == "" {
+			return QueueFile{}, fmt.Errorf("%w: track %d has no URL", errInvalidQueue, i+1)
+		}
+	}
+	return queue, nil
+}
+
+// encodeQueue writes queue in format.
+func encodeQueue(w io.Writer, format QueueFormat, queue QueueFile) error {
+	switch format {
+	case QueueM3U:
+		tracks := make([]player.Track, len(queue.Tracks))
+		for i, t := range queue.Tracks {
+			tracks[i] = player.Track{URL: t.URL, Title: t.Title, Duration: t.Duration}
+		}
+		return player.WriteM3U(w, tracks)
+	case QueueXSPF:
+		playlist := xspfPlaylist{Version: "1", Title: queue.Title}
+		for _, t := range queue.Tracks {
+			playlist.Tracks = append(playlist.Tracks, xspfTrack{
+				Location: t.URL,
+				Title:    t.Title,
+				Duration: int64(t.Duration) * 1000,
+				Image:    t.Thumbnail,
+			})
+		}
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		return enc.Encode(playlist)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(queue)
+}
+
+// savedQueue is a queue in the store and when it was saved.
+type savedQueue struct {
+	queue QueueFile
+	saved time.Time
+}
+
+// QueueStore keeps the queue each session ID last saved, in memory, up to
+// maxSavedQueues of them.
+type QueueStore struct {
+	mu     sync.Mutex
+	queues map[string]savedQueue
+}
+
+// NewQueueStore creates an empty store.
+func NewQueueStore() *QueueStore {
+	return &QueueStore{queues: make(map[string]savedQueue)}
+}
+
+// Get returns the queue saved for id (empty if none).
+func (s *QueueStore) Get(id string) QueueFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if saved, ok := s.queues[id]; ok {
+		return saved.queue
+	}
+	return QueueFile{Tracks: []QueueTrack{}}
+}
+
+// Set saves queue for id, dropping the least recently saved queue when the
+// store is full.
+func (s *QueueStore) Set(id string, queue QueueFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[id] = savedQueue{queue: queue, saved: time.Now()}
+	if len(s.queues) <= maxSavedQueues {
+		return
+	}
+	oldest := id
+	for other, saved := range s.queues {
+		if saved.saved.Before(s.queues[oldest].saved) {
+			oldest = other
+		}
+	}
+	delete(s.queues, oldest)
+}
+
+// Delete drops the queue saved for id.
+func (s *QueueStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queues, id)
+}
+
+// duration returns the duration of url in id's saved queue, or 0 if it
+// isn't queued or its duration is unknown.
+func (s *QueueStore) duration(id, url string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, track := range s.queues[id].queue.Tracks {
+		if track.URL == url {
+			return track.Duration
+		}
+	}
+	return 0
+}
+
+// readQueue reads and decodes a queue file from the request body.
+func readQueue(c *gin.Context) (QueueFile, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxQueueBytes))
+	if err != nil {
+		return QueueFile{}, fmt.Errorf("%w: %v", errInvalidQueue, err)
+	}
+	format, err := queueFormat(c, data)
+	if err != nil {
+		return QueueFile{}, err
+	}
+	return decodeQueue(format, data)
+}
+
+// SaveQueue handles PUT /session/:id/queue, saving the session's queue for
+// export. The body is a queue file in any import format.
+func (a *API) SaveQueue(c *gin.Context) {
+	queue, err := readQueue(c)
+	a.audit(c, AuditQueueSave, c.Param("id"), map[string]any{"tracks": len(queue.Tracks)}, err)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	a.queues.Set(c.Param("id"), queue)
+	a.sessions.PrefetchQueue(c.Param("id"))
+	c.JSON(http.StatusOK, queue)
+}
+
+// ExportQueue handles GET /session/:id/queue/export, downloading the saved
+// queue as JSON (default), M3U or XSPF.
+func (a *API) ExportQueue(c *gin.Context) {
+	format, err := queueFormat(c, nil)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	var buf bytes.Buffer
+	if err := encodeQueue(&buf, format, a.queues.Get(c.Param("id"))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="queue-%s.%s"`, c.Param("id"), format))
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// ImportQueue handles POST /queue/import: it parses a JSON, M3U or XSPF
+// queue file and returns its tracks. With a session_id query parameter the
+// queue is also saved for that session.
+func (a *API) ImportQueue(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if sessionID != "" {
+		if err := validateSessionID(sessionID); err != nil {
+			c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+	queue, err := readQueue(c)
+	if err != nil {
+		c.JSON(httpStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if sessionID != "" {
+		a.queues.Set(sessionID, queue)
+		a.sessions.PrefetchQueue(sessionID)
+		a.audit(c, AuditQueueImport, sessionID, map[string]any{"tracks": len(queue.Tracks)}, nil)
+	}
+	logger.Infof("[API] Queue import: session=%s tracks=%d", sessionID, len(queue.Tracks))
+	c.JSON(http.StatusOK, queue)
+}
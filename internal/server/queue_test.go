@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQueue_RoundTrip(t *testing.T) {
+	queue := QueueFile{Title: "Mix", Tracks: []QueueTrack{
+		{URL: "https://www.youtube.com/watch?v=a", Title: "First", Duration: 180, Thumbnail: "https://i.ytimg.com/vi/a/mqdefault.jpg"},
+		{URL: "https://www.youtube.com/watch?v=b & c", Title: "<Second>"},
+	}}
+
+	for _, format := range []QueueFormat{QueueJSON, QueueM3U, QueueXSPF} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeQueue(&buf, format, queue); err != nil {
+				t.Fatalf("encodeQueue failed: %v", err)
+			}
+			got, err := decodeQueue(format, buf.Bytes())
+			if err != nil {
+				t.Fatalf("decodeQueue failed: %v\n%s", err, buf.String())
+			}
+			if len(got.Tracks) != len(queue.Tracks) {
+				t.Fatalf("expected %d tracks, got %+v", len(queue.Tracks), got.Tracks)
+			}
+			for i, want := range queue.Tracks {
+				if format == QueueM3U {
+					want.Thumbnail = "" // M3U has no artwork
+				}
+				if got.Tracks[i] != want {
+					t.Errorf("track %d: expected %+v, got %+v", i, want, got.Tracks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeQueue_Invalid(t *testing.T) {
+	if _, err := decodeQueue(QueueJSON, []byte(`{"tracks":[{"title":"no url"}]}`)); !errors.Is(err, errInvalidQueue) {
+		t.Errorf("expected errInvalidQueue for a track without URL, got %v", err)
+	}
+	if _, err := decodeQueue(QueueXSPF, []byte(`not xml`)); !errors.Is(err, errInvalidQueue) {
+		t.Errorf("expected errInvalidQueue for bad XSPF, got %v", err)
+	}
+}
+
+func TestQueueEndpoints(t *testing.T) {
+	router := SetupRouter(NewAPI(NewSessionManager(context.Background())))
+
+	m3u := "#EXTM3U\n#EXTINF:200,Song\nhttps://www.youtube.com/watch?v=a\nhttps://www.youtube.com/watch?v=b\n"
+	req, _ := http.NewRequest("POST", "/queue/import?session_id=guild-1", strings.NewReader(m3u))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var imported QueueFile
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	if len(imported.Tracks) != 2 || imported.Tracks[0].Title != "Song" || imported.Tracks[0].Duration != 200 {
+		t.Errorf("unexpected import %+v", imported)
+	}
+
+	req, _ = http.NewRequest("GET", "/session/guild-1/queue/export?format=xspf", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xspf+xml" {
+		t.Fatalf("expected an XSPF export, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "<location>https://www.youtube.com/watch?v=b</location>") {
+		t.Errorf("expected the imported tracks in the export, got:\n%s", w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/session/guild-1/queue/export?format=wav", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestQueueStore_Bounded(t *testing.T) {
+	s := NewQueueStore()
+	for i := range maxSavedQueues + 1 {
+		s.Set(fmt.Sprintf("guild-%d", i), QueueFile{Tracks: []QueueTrack{{URL: "a"}}})
+	}
+	if len(s.queues) != maxSavedQueues {
+		t.Errorf("expected %d queues kept, got %d", maxSavedQueues, len(s.queues))
+	}
+	if got := s.Get(fmt.Sprintf("guild-%d", maxSavedQueues)); len(got.Tracks) != 1 {
+		t.Error("expected the latest queue kept")
+	}
+
+	sm := NewSessionManager(context.Background())
+	sm.Queues().Set("guild-1", QueueFile{Tracks: []QueueTrack{{URL: "a"}}})
+	sm.Stop("guild-1")
+	if got := sm.Queues().Get("guild-1"); len(got.Tracks) != 0 {
+		t.Error("expected the queue dropped with its session")
+	}
+}
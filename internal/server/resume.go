@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+// minResumePosition is how far into a track playback must get before its
+// position is remembered (skips short songs).
+const minResumePosition = time.Minute
+
+// SetPositionStore replaces the store of resume positions (in-memory by
+// default).
+func (m *SessionManager) SetPositionStore(store *player.PositionStore) {
+	m.positions = store
+}
+
+// positionKey identifies a track played by session ID id: YouTube URLs by
+// video ID, so any link to the same video resumes it.
+func positionKey(id, url string) string {
+	if videoID := youtube.VideoID(url); videoID != "" {
+		return id + "/" + videoID
+	}
+	return id + "/" + url
+}
+
+// SavedPosition returns where session ID id last left url, in seconds.
+func (m *SessionManager) SavedPosition(id, url string) (float64, bool) {
+	position, ok := m.positions.Get(positionKey(id, url))
+	return position.Seconds(), ok
+}
+
+// ForgetPosition removes the saved position of url for session ID id.
+func (m *SessionManager) ForgetPosition(id, url string) error {
+	return m.positions.Forget(positionKey(id, url))
+}
+
+// resumeAt returns where a play of url with opts starts: the saved
+// position when resuming without an explicit start, else opts.StartAt.
+func (m *SessionManager) resumeAt(id, url string, opts PlaybackOptions) float64 {
+	if !opts.Resume || opts.StartAt > 0 {
+		return opts.StartAt
+	}
+	position, ok := m.SavedPosition(id, url)
+	if !ok || (opts.EndAt > 0 && position >= opts.EndAt) {
+		return opts.StartAt
+	}
+	logger.Infof("[Session] Resuming %s at %.0fs", shortSessionID(id), position)
+	return position
+}
+
+// rememberPosition saves where session was left, or forgets the track
+// once it has played to the end.
+func (m *SessionManager) rememberPosition(session *Session, finished bool) {
+	key := positionKey(session.ID, session.URL)
+	position := session.Position()
+
+	var err error
+	switch {
+	case finished:
+		err = m.positions.Forget(key)
+	case position >= minResumePosition.Seconds():
+		err = m.positions.Save(key, time.Duration(position*float64(time.Second)))
+	}
+	if err != nil {
+		logger.Warnf("[Session] Save position: %v", err)
+	}
+}
+
+// PositionResponse is the response for the position endpoint.
+type PositionResponse struct {
+	Status    string  `json:"status"`
+	SessionID string  `json:"session_id"`
+	URL       string  `json:"url,omitempty"`
+	Position  float64 `json:"position"` // Seconds (0 if none is saved)
+	Saved     bool    `json:"saved"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// GetPosition handles GET /session/:id/position?url=, the position a play
+// with resume would start url at.
+func (a *API) GetPosition(c *gin.Context) {
+	sessionID, url := c.Param("id"), c.Query("url")
+	if url == "" {
+		c.JSON(http.StatusBadRequest, PositionResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   "url query parameter is required",
+		})
+		return
+	}
+	position, saved := a.sessions.SavedPosition(sessionID, url)
+	c.JSON(http.StatusOK, PositionResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		URL:       url,
+		Position:  position,
+		Saved:     saved,
+	})
+}
+
+// ForgetPosition handles DELETE /session/:id/position?url=, so the next
+// resumed play of url starts from the beginning.
+func (a *API) ForgetPosition(c *gin.Context) {
+	sessionID, url := c.Param("id"), c.Query("url")
+	if url == "" {
+		c.JSON(http.StatusBadRequest, PositionResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			Message:   "url query parameter is required",
+		})
+		return
+	}
+	if err := a.sessions.ForgetPosition(sessionID, url); err != nil {
+		c.JSON(http.StatusInternalServerError, PositionResponse{
+			Status:    "error",
+			SessionID: sessionID,
+			URL:       url,
+			Message:   fmt.Sprintf("forget position: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, PositionResponse{
+		Status:    "ok",
+		SessionID: sessionID,
+		URL:       url,
+	})
+}
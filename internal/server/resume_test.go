@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func TestRememberPosition(t *testing.T) {
+	sm := NewSessionManager(context.Background())
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	// Before streaming starts the position is the start position
+	sm.rememberPosition(&Session{ID: "guild-1", URL: url, StartAt: 30}, false)
+	if _, ok := sm.SavedPosition("guild-1", url); ok {
+		t.Error("expected positions under a minute to be skipped")
+	}
+
+	sm.rememberPosition(&Session{ID: "guild-1", URL: url, StartAt: 1500}, false)
+	if position, ok := sm.SavedPosition("guild-1", "https://youtu.be/dQw4w9WgXcQ"); !ok || position != 1500 {
+		t.Errorf("expected 1500s saved for the video, got %v %v", position, ok)
+	}
+	if _, ok := sm.SavedPosition("guild-2", url); ok {
+		t.Error("expected positions to be kept per session ID")
+	}
+
+	sm.rememberPosition(&Session{ID: "guild-1", URL: url, StartAt: 3600}, true)
+	if _, ok := sm.SavedPosition("guild-1", url); ok {
+		t.Error("expected a finished track to be forgotten")
+	}
+}
+
+func TestSessionManager_Resume(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &holdPipeline{fakePipeline: newFakePipeline()}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	sm.rememberPosition(&Session{ID: "guild-1", URL: "fake://talk", StartAt: 900}, false)
+
+	if err := sm.StartPlayback("guild-1", "fake://talk", "opus", PlaybackOptions{Duration: 3600}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	if startAt := sm.Get("guild-1").StartAt; startAt != 0 {
+		t.Errorf("expected no resume without the flag, got %v", startAt)
+	}
+
+	if err := sm.StartPlayback("guild-1", "fake://talk", "opus", PlaybackOptions{Duration: 3600, Resume: true}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	if startAt := sm.Get("guild-1").StartAt; startAt != 900 {
+		t.Errorf("expected resume at 900s, got %v", startAt)
+	}
+
+	if err := sm.StartPlayback("guild-1", "fake://talk", "opus", PlaybackOptions{Duration: 3600, Resume: true, StartAt: 60}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	if startAt := sm.Get("guild-1").StartAt; startAt != 60 {
+		t.Errorf("expected start_at to win over the saved position, got %v", startAt)
+	}
+	sm.StopAll()
+}
+
+func TestPositionEndpoint(t *testing.T) {
+	sessions := NewSessionManager(context.Background())
+	router := SetupRouter(NewAPI(sessions))
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	sessions.rememberPosition(&Session{ID: "guild-1", URL: url, StartAt: 754}, false)
+
+	get := func() PositionResponse {
+		req, _ := http.NewRequest("GET", "/session/guild-1/position?url="+url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var resp PositionResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := get(); !resp.Saved || resp.Position != 754 {
+		t.Errorf("expected the saved position, got %+v", resp)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/session/guild-1/position?url="+url, nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if resp := get(); resp.Saved {
+		t.Errorf("expected the position to be forgotten, got %+v", resp)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how sessions retry a stream that died early or
+// stalled. Delays double from BaseDelay up to MaxDelay with random jitter,
+// so a widespread CDN outage does not turn every session into a tight
+// reconnect loop, and each session may retry at most Budget times within a
+// sliding Window. Zero fields take the DefaultRetryPolicy values.
+type RetryPolicy struct {
+	BaseDelay time.Duration `yaml:"base_delay"` // Delay before the first retry in a window
+	MaxDelay  time.Duration `yaml:"max_delay"`  // Cap on the doubled delay
+	Jitter    float64       `yaml:"jitter"`     // Fraction of the delay randomly taken off (0-1)
+	Budget    int           `yaml:"budget"`     // Retries allowed per session within Window
+	Window    time.Duration `yaml:"window"`     // How far back retries count against Budget
+}
+
+// DefaultRetryPolicy returns the retry policy used unless one is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		BaseDelay: 1 * time.Second,
+		MaxDelay:  30 * time.Second,
+		Jitter:    0.5,
+		Budget:    3,
+		Window:    10 * time.Minute,
+	}
+}
+
+// withDefaults fills zero fields from DefaultRetryPolicy.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	if p.Budget <= 0 {
+		p.Budget = def.Budget
+	}
+	if p.Window <= 0 {
+		p.Window = def.Window
+	}
+	return p
+}
+
+// Delay returns the backoff before retry number attempt (1-based) within
+// the budget window.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := p.BaseDelay << max(shift, 0); d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	return delay - time.Duration(rand.Float64()*p.Jitter*float64(delay))
+}
+
+// retryBudget records when a session retried, for RetryPolicy.Budget.
+type retryBudget struct {
+	times []time.Time
+}
+
+// take charges one retry at now. Returns its attempt number within the
+// window, or false if the budget is spent.
+func (b *retryBudget) take(now time.Time, policy RetryPolicy) (int, bool) {
+	recent := b.times[:0]
+	for _, t := range b.times {
+		if now.Sub(t) < policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	b.times = recent
+	if len(b.times) >= policy.Budget {
+		return 0, false
+	}
+	b.times = append(b.times, now)
+	return len(b.times), true
+}
+
+// SetRetryPolicy replaces the retry policy (DefaultRetryPolicy unless set).
+// Must be called before any playback starts.
+func (m *SessionManager) SetRetryPolicy(policy RetryPolicy) {
+	m.retry = policy.withDefaults()
+}
+
+// takeRetryLocked charges a retry against session's budget. Returns the
+// attempt number within the budget window and the backoff to wait before
+// it, or false once the budget is spent. Caller must hold session.mu.
+func (m *SessionManager) takeRetryLocked(session *Session) (int, time.Duration, bool) {
+	attempt, ok := session.retries.take(time.Now(), m.retry)
+	if !ok {
+		return 0, 0, false
+	}
+	session.retryCount++
+	return attempt, m.retry.Delay(attempt), true
+}
+
+// waitRetry sleeps for a retry's backoff. Returns false if ctx is cancelled
+// or the session is stopped or restarted meanwhile.
+func (m *SessionManager) waitRetry(ctx context.Context, session *Session, epoch int, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return !session.isStopped && session.restartEpoch == epoch
+}
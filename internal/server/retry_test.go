@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: 0.5}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 10 * time.Second}, // Capped
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			delay := policy.Delay(tt.attempt)
+			if delay > tt.max || delay < tt.max/2 {
+				t.Fatalf("Delay(%d) = %v, want between %v and %v", tt.attempt, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicy_WithDefaults(t *testing.T) {
+	policy := RetryPolicy{Budget: 7}.withDefaults()
+	def := DefaultRetryPolicy()
+	if policy.Budget != 7 || policy.BaseDelay != def.BaseDelay || policy.Window != def.Window {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	policy := RetryPolicy{Budget: 2, Window: time.Minute}
+	var budget retryBudget
+	start := time.Now()
+
+	for want := 1; want <= 2; want++ {
+		if attempt, ok := budget.take(start, policy); !ok || attempt != want {
+			t.Fatalf("expected attempt %d, got %d (ok=%v)", want, attempt, ok)
+		}
+	}
+	if _, ok := budget.take(start.Add(30*time.Second), policy); ok {
+		t.Error("expected the budget to be spent within the window")
+	}
+	if attempt, ok := budget.take(start.Add(2*time.Minute), policy); !ok || attempt != 1 {
+		t.Errorf("expected the budget to recover after the window, got attempt %d (ok=%v)", attempt, ok)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/metrics"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
 )
 
 var serverStartTime = time.Now()
@@ -19,24 +21,57 @@ func SetupRouter(api *API) *gin.Engine {
 	r.Use(corsMiddleware())
 
 	// Session control endpoints
-	session := r.Group("/session/:id")
+	session := r.Group("/session/:id", validSessionID)
 	{
 		session.POST("/play", api.Play)
 		session.POST("/stop", api.Stop)
 		session.POST("/pause", api.Pause)
 		session.POST("/resume", api.Resume)
 		session.GET("/status", api.Status)
+		session.POST("/format", api.SetFormat)
+		session.POST("/feedback", api.Feedback)
+		session.POST("/bitrate", api.SetBitrate)
+		session.GET("/settings", api.GetSettings)
+		session.PATCH("/settings", api.UpdateSettings)
+		session.PATCH("/filters", api.UpdateFilters)
+		session.PUT("/queue", api.SaveQueue)
+		session.GET("/queue/export", api.ExportQueue)
+		session.GET("/position", api.GetPosition)
+		session.DELETE("/position", api.ForgetPosition)
 	}
 
+	// Queue import (parse a JSON, M3U or XSPF queue file)
+	r.POST("/queue/import", api.ImportQueue)
+
+	// Audio effects available to session settings
+	r.GET("/filters", api.Filters)
+
 	// Metadata endpoint (for queue)
 	r.GET("/metadata", api.Metadata)
 
 	// Playlist endpoint (extract all videos from playlist)
 	r.GET("/playlist", api.Playlist)
 
+	// Channel endpoint (an artist's or channel's top tracks)
+	r.GET("/channel", api.Channel)
+
 	// Search endpoint (YouTube search)
 	r.GET("/search", api.Search)
 
+	// Search suggestions (query autocomplete, no yt-dlp)
+	r.GET("/suggest", api.Suggest)
+
+	// Clip export (async job: POST, then poll status and download the file)
+	r.POST("/clip", api.CreateClip)
+	r.GET("/clip/:id", api.ClipStatus)
+	r.GET("/clip/:id/file", api.ClipFile)
+
+	// Loudness measurement for two-pass normalisation (call for queued tracks)
+	r.POST("/loudness", api.MeasureLoudness)
+
+	// Track thumbnails through the disk cache
+	r.GET("/thumbnail", api.Thumbnail)
+
 	// Health check with system stats
 	r.GET("/health", func(c *gin.Context) {
 		var memStats runtime.MemStats
@@ -44,6 +79,7 @@ func SetupRouter(api *API) *gin.Engine {
 
 		uptimeSeconds := int64(time.Since(serverStartTime).Seconds())
 		ramMB := float64(memStats.Alloc) / 1024 / 1024
+		deps := api.deps.check(c.Request.Context())
 
 		c.JSON(200, gin.H{
 			"status":             "ok",
@@ -55,9 +91,36 @@ func SetupRouter(api *API) *gin.Engine {
 			"go_version":        runtime.Version(),
 			"os":                runtime.GOOS,
 			"arch":              runtime.GOARCH,
+			"yt_dlp_version":    deps.YtDlp.versionOrMissing(),
+			"ffmpeg_version":    deps.FFmpeg.versionOrMissing(),
+			"cookie_mode":       deps.CookieMode,
+			"cookie_profiles":   youtube.CookieProfiles(), // Rotation state when cookies files are configured
+			"js_runtime":        deps.JSRuntime,
+			"degraded":          api.degradedFeatures(),
+			"draining":          api.sessions.Draining(),
 		})
 	})
 
+	// Prometheus metrics (extraction latency and failures per platform)
+	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+
+	// Dependency report (yt-dlp/FFmpeg versions, cookie mode, JS runtime)
+	r.GET("/debug/deps", api.Deps)
+
+	// Bulk session control for rolling deploys (bearer token required)
+	admin := r.Group("/admin", adminAuth(api))
+	{
+		admin.POST("/sessions/stop-all", api.StopAll)
+		admin.POST("/drain", api.Drain)
+		admin.DELETE("/drain", api.Undrain)
+		admin.GET("/audit", api.Audit)
+		admin.POST("/cookies/reload", api.ReloadCookies)
+		admin.GET("/cookies/check", api.CheckCookies)
+		admin.GET("/cache", api.CacheStats)
+		admin.DELETE("/cache", api.PurgeCache)
+		admin.POST("/cache/verify", api.VerifyCache)
+	}
+
 	return r
 }
 
@@ -65,8 +128,8 @@ func SetupRouter(api *API) *gin.Engine {
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
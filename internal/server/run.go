@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/cache"
+	"github.com/thewind121212/natashi/internal/player"
+	"github.com/thewind121212/natashi/internal/scrobble"
+)
+
+// DefaultHTTPAddr is the API listen address when none is configured.
+const DefaultHTTPAddr = ":8180"
+
+// DefaultDrainGrace is how long in-flight tracks may finish on shutdown.
+const DefaultDrainGrace = 30 * time.Second
+
+// DefaultPrefetch is how many upcoming queue tracks the daemon prefetches.
+const DefaultPrefetch = 2
+
+// shutdownTimeout bounds how long Run waits for in-flight HTTP requests.
+const shutdownTimeout = 5 * time.Second
+
+// Options configures Run.
+type Options struct {
+	HTTPAddr   string             // API listen address (default ":8180")
+	APISocket  string             // Unix socket path serving the API instead of HTTPAddr, restricted like the audio socket (optional)
+	TLS        TLSConfig          // Serve the API over HTTPS (zero serves plain HTTP)
+	NoHTTP     bool               // Serve only the audio protocol, without the HTTP API
+	SocketPath string             // Unix socket path (default DefaultSocketPath)
+	Socket     SocketAccess       // Socket file mode/owner and allowed peers (zero allows any local user)
+	Transport  Transport          // Serves the audio protocol instead of the Unix socket at SocketPath (optional)
+	Scrobbler  scrobble.Scrobbler // Listen submission (nil disables; unused with Sessions)
+	Sessions   *SessionManager    // Existing session manager to serve (nil creates one)
+	Degraded   []string           // Features unavailable due to missing optional dependencies (shown in /health)
+	Settings   string             // JSON file for per-ID settings (empty keeps them in memory; unused with Sessions)
+	Positions  string             // JSON file for resume positions per ID and track (empty keeps them in memory; unused with Sessions)
+	Loudness   string             // SQLite file caching loudness measurements (empty disables two-pass loudnorm; unused with Sessions)
+	AdminToken string             // Bearer token for the /admin endpoints (empty disables them)
+	AuditLog   string             // JSON-lines file recording control actions (empty keeps them in memory; unused with Sessions)
+	DrainGrace time.Duration      // How long in-flight tracks may finish on shutdown (0 stops them at once)
+	Retry      RetryPolicy        // Pipeline retry backoff and budget (zero fields use the defaults; unused with Sessions)
+	Cache      cache.Config       // Disk cache for download_first tracks and thumbnails (empty Dir disables it; unused with Sessions)
+	Prefetch   int                // Upcoming tracks of saved queues to extract ahead (0 disables; unused with Sessions)
+}
+
+// Run starts the HTTP API and the audio socket server and blocks until ctx
+// is cancelled. It then drains: new plays get 503 and in-flight tracks have
+// up to opts.DrainGrace to finish before pipelines are stopped, sockets
+// closed and the HTTP server shut down, in that order.
+func Run(ctx context.Context, opts Options) error {
+	if opts.HTTPAddr == "" {
+		opts.HTTPAddr = DefaultHTTPAddr
+	}
+	var tlsConfig *tls.Config
+	var redirect http.Handler
+	if opts.TLS.Enabled() && !opts.NoHTTP {
+		var err error
+		if tlsConfig, redirect, err = opts.TLS.load(opts.HTTPAddr); err != nil {
+			return err
+		}
+	}
+
+	// Sessions and socket connections outlive ctx so tracks can finish
+	// while draining.
+	serveCtx, stopServing := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopServing()
+
+	// Create shared session manager
+	sessions := opts.Sessions
+	if sessions == nil {
+		sessions = NewSessionManager(serveCtx)
+		sessions.SetScrobbler(opts.Scrobbler)
+		sessions.SetRetryPolicy(opts.Retry)
+		if opts.Settings != "" {
+			store, err := OpenSettingsStore(opts.Settings)
+			if err != nil {
+				return err
+			}
+			sessions.SetSettingsStore(store)
+		}
+		if opts.Positions != "" {
+			store, err := player.OpenPositionStore(opts.Positions)
+			if err != nil {
+				return err
+			}
+			sessions.SetPositionStore(store)
+		}
+		if opts.Loudness != "" {
+			cache, err := OpenLoudnessCache(opts.Loudness)
+			if err != nil {
+				return err
+			}
+			defer cache.Close()
+			sessions.SetLoudnessCache(cache)
+		}
+		if opts.AuditLog != "" {
+			audit, err := OpenAuditLog(opts.AuditLog)
+			if err != nil {
+				return err
+			}
+			defer audit.Close()
+			sessions.SetAuditLog(audit)
+		}
+		if opts.Cache.Dir != "" {
+			store, err := cache.Open(opts.Cache)
+			if err != nil {
+				return err
+			}
+			sessions.SetCache(store)
+		}
+		sessions.SetPrefetch(opts.Prefetch)
+	}
+
+	// Start HTTP API server (Gin)
+	api := NewAPI(sessions)
+	api.SetDegraded(opts.Degraded)
+	api.SetAdminToken(opts.AdminToken)
+	defer api.clips.Close() // Exported clips are temporary files
+	httpServer := &http.Server{Addr: opts.HTTPAddr, Handler: SetupRouter(api), TLSConfig: tlsConfig}
+	redirectServer := &http.Server{Addr: opts.TLS.Redirect, Handler: redirect}
+
+	// With an API socket nothing listens on the network for the API
+	apiAddr := apiURL(opts.HTTPAddr, tlsConfig != nil)
+	var apiListener net.Listener
+	if opts.APISocket != "" && !opts.NoHTTP {
+		var err error
+		if apiListener, err = (&UnixTransport{Path: opts.APISocket, Access: opts.Socket}).Listen(); err != nil {
+			return fmt.Errorf("API socket: %w", err)
+		}
+		apiAddr = "unix:" + opts.APISocket
+	}
+
+	if !opts.NoHTTP {
+		go func() {
+			logger.Infof("[HTTP] API server listening on %s", apiAddr)
+			var err error
+			switch {
+			case apiListener != nil && tlsConfig != nil:
+				err = httpServer.ServeTLS(apiListener, "", "")
+			case apiListener != nil:
+				err = httpServer.Serve(apiListener)
+			case tlsConfig != nil:
+				err = httpServer.ListenAndServeTLS("", "") // Certificates come from TLSConfig
+			default:
+				err = httpServer.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Errorf("[HTTP] Server error: %v", err)
+			}
+		}()
+	}
+	if tlsConfig != nil && opts.TLS.Redirect != "" {
+		go func() {
+			logger.Infof("[HTTP] Redirecting %s to HTTPS", apiURL(opts.TLS.Redirect, false))
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Errorf("[HTTP] Redirect server error: %v", err)
+			}
+		}()
+	}
+
+	// Start the audio socket server (Unix socket unless a transport is given)
+	socketSrv := NewSocketServer(opts.SocketPath, sessions)
+	if opts.Transport != nil {
+		socketSrv = NewTransportServer(opts.Transport, sessions)
+	}
+	socketSrv.SetAccess(opts.Socket)
+	if err := socketSrv.Start(serveCtx); err != nil {
+		httpServer.Close()
+		redirectServer.Close()
+		if apiListener != nil {
+			apiListener.Close() // In case Serve hasn't taken it yet
+		}
+		return err
+	}
+
+	logger.Infof("[INFO] Ready!")
+	if !opts.NoHTTP {
+		logger.Infof("[INFO] - HTTP API: %s", apiAddr)
+	}
+	logger.Infof("[INFO] - Socket: %s", socketSrv.SocketPath())
+	logger.Infof("[INFO] Press Ctrl+C to stop")
+
+	// Wait for shutdown
+	<-ctx.Done()
+	if stdio, ok := opts.Transport.(*StdioTransport); ok && isClosed(stdio.Done()) {
+		opts.DrainGrace = 0 // The parent hung up; nobody is listening
+	}
+	if opts.DrainGrace > 0 {
+		logger.Infof("[INFO] Draining, waiting up to %v for %d sessions", opts.DrainGrace, sessions.StreamingSessionCount())
+		if !sessions.Drain(opts.DrainGrace) {
+			logger.Warnf("[INFO] Drain grace period elapsed, stopping %d sessions", sessions.StreamingSessionCount())
+		}
+	} else {
+		sessions.SetDraining(true)
+	}
+	sessions.StopAll()
+	stopServing()
+	socketSrv.Stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	redirectServer.Shutdown(shutdownCtx)
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// isClosed reports whether ch is closed, without blocking.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/scrobble"
+)
+
+// scrobbleTimeout bounds each submission to the scrobbling services.
+const scrobbleTimeout = 15 * time.Second
+
+// SetScrobbler enables listen submission for all sessions (nil disables).
+// Must be called before any playback starts.
+func (m *SessionManager) SetScrobbler(s scrobble.Scrobbler) {
+	m.scrobbler = s
+}
+
+// startListen resolves the session's artist/title and announces it as
+// now playing. Runs in the background; the metadata call is only made when
+// a scrobbler is configured.
+func (m *SessionManager) startListen(session *Session) {
+	if m.scrobbler == nil {
+		return
+	}
+	startedAt := time.Now()
+	go func() {
+		yt, ok := m.registry.FindExtractor(session.URL).(*youtube.Extractor)
+		if !ok {
+			return
+		}
+		meta, err := yt.ExtractMetadataContext(m.ctx, session.URL)
+		if err != nil {
+			logger.Warnf("[Scrobble] Metadata failed for %s: %v", shortSessionID(session.ID), err)
+			return
+		}
+		artist, title := scrobble.ParseTitle(meta.Title, meta.Artist, meta.Track, meta.Uploader)
+		if artist == "" || title == "" {
+			return
+		}
+		track := scrobble.Track{
+			Artist:    artist,
+			Title:     title,
+			Duration:  time.Duration(meta.Duration) * time.Second,
+			StartedAt: startedAt,
+		}
+
+		session.mu.Lock()
+		if session.isStopped || session.listen != nil {
+			session.mu.Unlock()
+			return
+		}
+		session.listen = &track
+		session.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(m.ctx, scrobbleTimeout)
+		defer cancel()
+		if err := m.scrobbler.NowPlaying(ctx, track); err != nil {
+			logger.Warnf("[Scrobble] Now playing failed: %v", err)
+		}
+	}()
+}
+
+// finishListen submits the session's listen once, if enough of it was
+// played. Called when the session finishes or is stopped.
+func (m *SessionManager) finishListen(session *Session) {
+	if m.scrobbler == nil {
+		return
+	}
+	session.mu.Lock()
+	track := session.listen
+	played := time.Duration((session.positionLocked() - session.StartAt) * float64(time.Second))
+	if track == nil || session.scrobbled {
+		session.mu.Unlock()
+		return
+	}
+	session.scrobbled = true
+	session.mu.Unlock()
+
+	if !scrobble.ShouldScrobble(track.Duration, played) {
+		return
+	}
+	go func() {
+		// Not tied to m.ctx: a listen finished at shutdown still counts
+		ctx, cancel := context.WithTimeout(context.Background(), scrobbleTimeout)
+		defer cancel()
+		if err := m.scrobbler.Scrobble(ctx, *track); err != nil {
+			logger.Warnf("[Scrobble] Submit failed: %v", err)
+			return
+		}
+		logger.Infof("[Scrobble] %s - %s", track.Artist, track.Title)
+	}()
+}
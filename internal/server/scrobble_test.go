@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/scrobble"
+)
+
+// fakeScrobbler records submitted listens.
+type fakeScrobbler struct {
+	mu        sync.Mutex
+	scrobbled []scrobble.Track
+	done      chan struct{}
+}
+
+func (f *fakeScrobbler) NowPlaying(ctx context.Context, track scrobble.Track) error { return nil }
+
+func (f *fakeScrobbler) Scrobble(ctx context.Context, track scrobble.Track) error {
+	f.mu.Lock()
+	f.scrobbled = append(f.scrobbled, track)
+	f.mu.Unlock()
+	f.done <- struct{}{}
+	return nil
+}
+
+func TestSessionManager_FinishListen(t *testing.T) {
+	fake := &fakeScrobbler{done: make(chan struct{}, 1)}
+	sm := NewSessionManager(context.Background())
+	sm.SetScrobbler(fake)
+
+	track := &scrobble.Track{Artist: "Artist", Title: "Song", Duration: 3 * time.Minute}
+	session := &Session{
+		ID:              "session-1",
+		listen:          track,
+		streamStartTime: time.Now().Add(-2 * time.Minute),
+	}
+
+	sm.finishListen(session)
+	select {
+	case <-fake.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the listen to be scrobbled")
+	}
+
+	// A second finish (e.g. Stop after "finished") must not resubmit
+	sm.finishListen(session)
+	select {
+	case <-fake.done:
+		t.Fatal("listen scrobbled twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSessionManager_FinishListenTooShort(t *testing.T) {
+	fake := &fakeScrobbler{done: make(chan struct{}, 1)}
+	sm := NewSessionManager(context.Background())
+	sm.SetScrobbler(fake)
+
+	session := &Session{
+		ID:              "session-1",
+		listen:          &scrobble.Track{Artist: "Artist", Title: "Song", Duration: 3 * time.Minute},
+		streamStartTime: time.Now().Add(-30 * time.Second),
+	}
+
+	sm.finishListen(session)
+	select {
+	case <-fake.done:
+		t.Fatal("expected no scrobble after 30s of a 3 minute track")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -2,16 +2,24 @@ package server
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
+	neturl "net/url"
+	"strconv"
 	"sync"
 	"time"
 
-	"music-bot/internal/buffer"
-	"music-bot/internal/encoder"
-	"music-bot/internal/platform"
-	"music-bot/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/analysis"
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/cache"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/ogg"
+	"github.com/thewind121212/natashi/internal/platform"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/player"
+	"github.com/thewind121212/natashi/internal/scrobble"
+	"github.com/thewind121212/natashi/internal/spill"
 )
 
 // SessionState represents the current state of a session.
@@ -48,12 +56,27 @@ func (s SessionState) String() string {
 
 // Retry configuration
 const (
-	maxRetries          = 3               // Maximum retry attempts for premature stream endings
 	minPlayedForRetry   = 5 * time.Second // Minimum played time before considering retry
 	prematureEndingGap  = 10.0            // Seconds before expected end to consider premature
 	longPauseThreshold  = 30 * time.Minute // Re-extract stream URL if paused longer than this
+	stallTimeout        = 15 * time.Second // Restart pipeline if no audio chunk arrives for this long while streaming
+	stallCheckInterval  = 1 * time.Second  // How often the stall watchdog checks for output
+	maxURLRefreshes     = 5                // Expired stream URLs replaced mid-track per session
 )
 
+// Analysis event configuration
+const (
+	levelsInterval = 100 * time.Millisecond // How often levels events are emitted
+	spectrumBands  = 32                     // Frequency bands per spectrum event
+	spectrumRate   = 10                     // Spectrum events per second
+)
+
+// pcmBytesPerSecond is the rate of FormatPCM output (s16le).
+var pcmBytesPerSecond = encoder.DefaultConfig().SampleRate * encoder.DefaultConfig().Channels * 2
+
+// drainPollInterval is how often Drain checks for sessions still streaming.
+const drainPollInterval = 250 * time.Millisecond
+
 // Session represents an active audio playback session.
 type Session struct {
 	ID               string
@@ -61,18 +84,56 @@ type Session struct {
 	URL              string
 	Format           encoder.Format
 	StartAt          float64
+	EndAt            float64 // Stop position in seconds (0 = end of track)
+	seekOffset       float64 // Position (seconds) the current pipeline started from
 	Pipeline         encoder.Pipeline
 	Cancel           context.CancelFunc
 	BytesSent        int64
 	isPaused         bool
 	resumeCh         chan struct{} // Signal to resume from pause
+	levels           bool          // Emit audio level events
+	spectrum         bool          // Emit spectrum events
+	readySent        bool          // "ready" event already sent (not repeated on retries/restarts)
+	streamURL        string        // Last extracted direct stream URL
+	reuseStreamURL   bool          // Next start reuses streamURL instead of re-extracting
+	download         string        // Track copy on disk played instead of streamURL (download_first)
+	releaseDownload  func()        // Unpins download in the cache (nil = a temporary file to delete)
+	spill            *spill.File   // Track downloading ahead of playback (progressive_download)
+	spillURL         string        // Where the pipeline reads spill
+	bus              *EventBus     // Where state changes are published (nil in tests that build sessions directly)
+	settings         SessionSettings // Saved settings for this ID, captured at StartPlayback
+	token            string        // Request token of the play that started the session
+	crossfade        encoder.Crossfade // Tail of the replaced track, mixed into the first start
+	formatSwitched   bool          // Format changed; announce it before the next pipeline starts
+	speed            float64       // Playback speed (0 = 1x); positions stay in track time
+	pitch            float64       // Pitch shift in semitones
+	region           string        // Country code extraction runs from ("" = configured default)
 	mu               sync.Mutex
 
+	// Adaptive bitrate / pacing fields
+	bitrate   int                 // Opus bitrate in bps for the next pipeline start (0 = format default)
+	abr       *abrController      // nil for formats without a bitrate ladder
+	paced     *buffer.PacedBuffer // Output pacing buffer of the current attempt (nil if unpaced)
+	broadcast *buffer.Broadcast   // Extra consumers of the raw pipeline output (see Subscribe)
+	throttled bool                // Pipeline stopped by the pacing buffer's high watermark
+
+	// Scrobbling fields (only used with a scrobbler)
+	listen    *scrobble.Track // Resolved artist/title, nil until known
+	scrobbled bool            // Listen already submitted (or skipped)
+
 	// Auto-retry fields
 	expectedDuration   float64       // Expected duration in seconds (from metadata)
 	streamStartTime    time.Time     // When streaming started (for calculating played time)
-	retryCount         int           // Current retry attempt
+	encoded            *ogg.OpusTracker // Encoded position of the current pipeline (nil for PCM)
+	pcmBytes           int64         // PCM output of the current pipeline (PCM only)
+	retryCount         int           // Retries so far (including stall restarts)
+	retries            retryBudget   // Recent retries, charged against the retry budget
 	isStopped          bool          // Explicitly stopped by user (don't retry)
+	stopping           bool          // Stopped, waiting for the next chunk to fade out (PCM only)
+	refreshing         bool          // Fetching a fresh stream URL after expiry
+	lastError          string        // Why the last retry or failure happened
+	lastExitCode       *int          // Exit code of the last FFmpeg run that ended on its own
+	urlRefreshes       int           // Expired stream URLs replaced so far
 
 	// Long-pause recovery fields
 	pausedAt           time.Time     // When pause started (for measuring pause duration)
@@ -80,14 +141,32 @@ type Session struct {
 	restartEpoch       int           // Incremented on each long-pause restart; old goroutines compare to exit silently
 }
 
+// PipelineFactory creates the encoding pipeline for a playback attempt.
+type PipelineFactory func(sessionID string) encoder.Pipeline
+
 // SessionManager manages active playback sessions.
 type SessionManager struct {
-	sessions map[string]*Session
-	registry *platform.Registry
-	conn     net.Conn // Current socket connection for audio output
-	connMu   sync.Mutex
-	ctx      context.Context
-	mu       sync.RWMutex
+	sessions    map[string]*Session
+	registry    *platform.Registry
+	newPipeline PipelineFactory
+	scrobbler   scrobble.Scrobbler // nil unless scrobbling is configured
+	router      *ConnectionRouter // Audio socket connection (the default sink)
+	sink        AudioSink         // Where session audio and client events go
+	bus         *EventBus         // Session lifecycle events; the sink is a subscriber
+	settings    *SettingsStore    // Per-ID settings applied on StartPlayback
+	positions   *player.PositionStore // Resume positions per ID and track
+	draining    bool              // Refuse new playback (see SetDraining)
+	retry       RetryPolicy       // Backoff and budget for pipeline retries
+	loudness    *loudnessAnalyzer // Two-pass loudnorm measurements (nil disables)
+	silence     SilenceDetector   // Finds silent stretches for skip_silence
+	download    Downloader        // Copies tracks to disk for download_first
+	cache       *cache.Cache      // Keeps download_first copies across plays (nil = temporary files)
+	spills      *spill.Server     // Serves progressive_download spill files to FFmpeg
+	queues      *QueueStore       // Queues saved by clients; their upcoming tracks are prefetched
+	prefetch    *prefetcher       // Stream URLs of upcoming queue tracks (nil disables)
+	audit       *AuditLog         // Control actions from the API and socket
+	ctx         context.Context
+	mu          sync.RWMutex
 }
 
 // NewSessionManager creates a new session manager.
@@ -95,25 +174,90 @@ func NewSessionManager(ctx context.Context) *SessionManager {
 	registry := platform.NewRegistry()
 	registry.Register(youtube.New())
 
-	return &SessionManager{
-		sessions: make(map[string]*Session),
-		registry: registry,
-		ctx:      ctx,
+	router := NewConnectionRouter()
+	m := &SessionManager{
+		sessions:    make(map[string]*Session),
+		router:      router,
+		sink:        router,
+		bus:         NewEventBus(),
+		settings:    NewSettingsStore(),
+		positions:   player.NewPositionStore(),
+		registry:    registry,
+		newPipeline: newFFmpegPipeline,
+		retry:       DefaultRetryPolicy(),
+		silence:     detectSilence,
+		download:    downloadTrack,
+		spills:      spill.NewServer(),
+		queues:      NewQueueStore(),
+		audit:       NewAuditLog(),
+		ctx:         ctx,
 	}
+	m.bus.Subscribe(m.forwardToSink)
+	context.AfterFunc(ctx, func() { m.spills.Close() })
+	return m
+}
+
+// newFFmpegPipeline is the default PipelineFactory.
+func newFFmpegPipeline(sessionID string) encoder.Pipeline {
+	pipeline := encoder.NewDefaultPipeline()
+	pipeline.SetSessionID(sessionID)
+	return pipeline
+}
+
+// SetRegistry replaces the platform registry used to resolve session URLs.
+// Must be called before any playback starts.
+func (m *SessionManager) SetRegistry(registry *platform.Registry) {
+	m.registry = registry
+}
+
+// SetPipelineFactory replaces the factory used to create encoding pipelines
+// (e.g. to inject a fake pipeline in tests). Must be called before any playback starts.
+func (m *SessionManager) SetPipelineFactory(factory PipelineFactory) {
+	m.newPipeline = factory
+}
+
+// SetSink replaces the sink session output is sent to (by default the
+// audio socket's ConnectionRouter). Must be called before any playback starts.
+func (m *SessionManager) SetSink(sink AudioSink) {
+	m.sink = sink
+}
+
+// SetSettingsStore replaces the per-ID settings store (in-memory by
+// default). Must be called before any playback starts.
+func (m *SessionManager) SetSettingsStore(store *SettingsStore) {
+	m.settings = store
+}
+
+// Settings returns the per-ID settings store.
+func (m *SessionManager) Settings() *SettingsStore {
+	return m.settings
+}
+
+// Bus returns the event bus session lifecycle events are published to.
+func (m *SessionManager) Bus() *EventBus {
+	return m.bus
+}
+
+// forwardToSink is the bus subscriber that sends client events to the sink.
+func (m *SessionManager) forwardToSink(ev BusEvent) {
+	if ev.Type == BusClientEvent {
+		m.sink.SendEvent(ev.Event)
+	}
+}
+
+// AddConnection registers a socket client for audio output and events.
+func (m *SessionManager) AddConnection(conn net.Conn) {
+	m.router.AddConnection(conn)
 }
 
-// SetConnection sets the socket connection for audio output.
-func (m *SessionManager) SetConnection(conn net.Conn) {
-	m.connMu.Lock()
-	defer m.connMu.Unlock()
-	m.conn = conn
+// RemoveConnection unregisters conn, leaving other clients connected.
+func (m *SessionManager) RemoveConnection(conn net.Conn) {
+	m.router.RemoveConnection(conn)
 }
 
-// GetConnection returns the current socket connection.
-func (m *SessionManager) GetConnection() net.Conn {
-	m.connMu.Lock()
-	defer m.connMu.Unlock()
-	return m.conn
+// ConnectionCount returns the number of connected socket clients.
+func (m *SessionManager) ConnectionCount() int {
+	return m.router.ConnectionCount()
 }
 
 func shortSessionID(id string) string {
@@ -123,18 +267,56 @@ func shortSessionID(id string) string {
 	return id[:8]
 }
 
-// StartPlayback starts a new playback session (non-blocking).
-// duration is optional (0 = unknown) - if provided, skips slow metadata extraction.
-func (m *SessionManager) StartPlayback(id string, url string, formatStr string, startAtSec float64, duration float64) error {
+// PlaybackOptions holds optional settings for a playback session.
+type PlaybackOptions struct {
+	StartAt  float64 // Start position in seconds
+	EndAt    float64 // Stop position in seconds (0 = end of track)
+	Duration float64 // Track duration in seconds (0 = unknown) - if provided, skips slow metadata extraction
+	Levels   bool    // Emit periodic audio level (VU) events
+	Spectrum bool    // Emit periodic frequency band (spectrum) events
+	Token    string  // Request token: a retried request with the token of the current play doesn't restart it
+	Resume   bool    // Start where this ID last left the track (unless StartAt is set)
+	Region   string  // Country code to extract from (geo bypass and its proxy), overriding the configured one
+
+	// StreamURL is a direct stream URL the caller already extracted; the
+	// first attempt plays it without running yt-dlp. Retries extract again.
+	StreamURL     string
+	StreamExpires time.Time // When StreamURL stops working (zero = read YouTube's expire parameter)
+}
+
+// StartPlayback starts a new playback session (non-blocking). A request
+// repeating the token of the session's current play is acknowledged
+// without touching the track, so client retries don't restart it.
+func (m *SessionManager) StartPlayback(id string, url string, formatStr string, opts PlaybackOptions) error {
+	if err := validateSessionID(id); err != nil {
+		return err
+	}
+	if opts.EndAt > 0 && opts.EndAt <= opts.StartAt {
+		return errInvalidRange
+	}
+	if !youtube.ValidRegion(opts.Region) {
+		return errInvalidRegion
+	}
+	streamURL, err := usableStreamURL(opts.StreamURL, opts.StreamExpires)
+	if err != nil {
+		return err
+	}
+
 	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return errDraining
+	}
 
-	// Stop only the session with the same ID (if exists)
+	// Replace only the session with the same ID (if exists)
 	// This allows concurrent sessions for different guilds/users
-	if existing, ok := m.sessions[id]; ok {
-		fmt.Printf("[Session] Stopping existing session %s for new playback\n", shortSessionID(id))
-		existing.Stop()
-		delete(m.sessions, id)
+	existing := m.sessions[id]
+	if existing != nil && existing.repeats(opts.Token) {
+		m.mu.Unlock()
+		logger.Infof("[Session] Ignoring repeated play request for %s", shortSessionID(id))
+		return nil
 	}
+	delete(m.sessions, id)
 
 	// Determine format
 	format := encoder.FormatPCM
@@ -145,24 +327,63 @@ func (m *SessionManager) StartPlayback(id string, url string, formatStr string,
 		format = encoder.FormatWeb
 	}
 
+	if streamURL == "" && opts.Region == "" && m.prefetch != nil {
+		streamURL = m.prefetch.take(url)
+	}
+	if opts.Duration == 0 {
+		opts.Duration = float64(m.queues.duration(id, url))
+	}
+
+	settings := m.settings.Get(id)
 	session := &Session{
 		ID:               id,
 		State:            StateIdle,
 		URL:              url,
 		Format:           format,
-		StartAt:          startAtSec,
-		expectedDuration: duration, // Use duration from Node.js (skips yt-dlp metadata call if > 0)
+		StartAt:          m.resumeAt(id, url, opts),
+		EndAt:            opts.EndAt,
+		levels:           opts.Levels,
+		spectrum:         opts.Spectrum,
+		expectedDuration: opts.Duration, // Use duration from Node.js (skips yt-dlp metadata call if > 0)
 		resumeCh:         make(chan struct{}, 1),
+		abr:              newABRController(format),
+		broadcast:        buffer.NewBroadcast(),
+		bus:              m.bus,
+		settings:         settings,
+		speed:            settings.speed(),
+		pitch:            settings.Pitch,
+		region:           opts.Region,
+		token:            opts.Token,
+		streamURL:        streamURL,
+		reuseStreamURL:   streamURL != "",
 	}
 	m.sessions[id] = session
 	m.mu.Unlock()
 
+	// Stopped outside m.mu: bus subscribers may call back into the manager
+	if existing != nil {
+		logger.Infof("[Session] Stopping existing session %s for new playback", shortSessionID(id))
+		session.crossfade = existing.tail(session.settings)
+		existing.Stop()
+		m.finishListen(existing)
+		m.rememberPosition(existing, false)
+	}
+
 	// Start playback in goroutine (non-blocking)
 	go m.runPlayback(session)
+	m.PrefetchQueue(id)
 
 	return nil
 }
 
+// repeats reports whether a play request with token is a retry of the one
+// that started s. Failed sessions may be retried with the same token.
+func (s *Session) repeats(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return token != "" && s.token == token && s.State != StateError
+}
+
 // runPlayback runs the playback pipeline for a session.
 func (m *SessionManager) runPlayback(session *Session) {
 	m.runPlaybackWithRetry(session, session.StartAt)
@@ -177,27 +398,32 @@ func (m *SessionManager) runPlaybackWithRetry(session *Session, seekPosition flo
 	session.isStopped = false
 	myEpoch := session.restartEpoch
 	session.mu.Unlock()
+	sessionCtx = youtube.WithRegion(sessionCtx, session.region)
 
 	session.SetState(StateExtracting)
-	isRetry := session.retryCount > 0
+	session.mu.Lock()
+	isRetry := session.readySent // Restarted by a retry, seek or long pause
+	retries := session.retryCount
+	session.mu.Unlock()
 	if isRetry {
-		fmt.Printf("[Session] Retry #%d for %s (seeking to %.1fs)\n", session.retryCount, shortSessionID(session.ID), seekPosition)
+		logger.Infof("[Session] Restarting %s (seeking to %.1fs, %d retries so far)", shortSessionID(session.ID), seekPosition, retries)
 	} else {
-		fmt.Printf("[Session] Starting playback for %s\n", shortSessionID(session.ID))
+		logger.Infof("[Session] Starting playback for %s", shortSessionID(session.ID))
 	}
 
 	// Find extractor for URL
 	extractor := m.registry.FindExtractor(session.URL)
 	if extractor == nil {
 		session.SetState(StateError)
-		m.sendEvent(session.ID, "error", "unsupported URL")
+		m.sendError(session, errs.New(errs.ErrExtraction, "unsupported URL"))
+		session.broadcast.Close()
 		return
 	}
 
 	// Check if cancelled before extraction
 	select {
 	case <-sessionCtx.Done():
-		fmt.Printf("[Session] Cancelled before extraction %s\n", shortSessionID(session.ID))
+		logger.Infof("[Session] Cancelled before extraction %s", shortSessionID(session.ID))
 		return
 	default:
 	}
@@ -206,251 +432,657 @@ func (m *SessionManager) runPlaybackWithRetry(session *Session, seekPosition flo
 	// If duration was passed from Node.js, skip this slow yt-dlp call
 	if !isRetry && session.expectedDuration == 0 {
 		if ytExtractor, ok := extractor.(*youtube.Extractor); ok {
-			if meta, err := ytExtractor.ExtractMetadata(session.URL); err == nil && meta.Duration > 0 {
+			if meta, err := ytExtractor.ExtractMetadataContext(sessionCtx, session.URL); err == nil && meta.Duration > 0 {
 				session.mu.Lock()
 				session.expectedDuration = float64(meta.Duration)
 				session.mu.Unlock()
-				fmt.Printf("[Session] Track duration: %.0fs (from yt-dlp)\n", session.expectedDuration)
+				logger.Infof("[Session] Track duration: %.0fs (from yt-dlp)", session.expectedDuration)
 			}
 		}
 	}
 
-	// Extract stream URL (fresh URL for each attempt - important for retries)
-	streamURL, err := extractor.ExtractStreamURL(session.URL)
-	if err != nil {
-		session.SetState(StateError)
-		m.sendEvent(session.ID, "error", fmt.Sprintf("extraction failed: %v", err))
-		return
+	// Extract stream URL (fresh URL for each attempt - important for retries),
+	// unless this is a renegotiation restart that reuses the current one
+	session.mu.Lock()
+	streamURL := ""
+	if session.reuseStreamURL {
+		streamURL = session.streamURL
+		session.reuseStreamURL = false
+	}
+	session.mu.Unlock()
+
+	// A cached download_first copy needs no stream URL
+	if streamURL == "" {
+		streamURL = m.cachedTrack(session)
+	}
+	if streamURL == "" {
+		var err error
+		streamURL, err = platform.ExtractStreamURL(sessionCtx, extractor, session.URL)
+		if err != nil {
+			if sessionCtx.Err() != nil {
+				logger.Infof("[Session] Cancelled during extraction %s", shortSessionID(session.ID))
+				return
+			}
+			session.SetState(StateError)
+			m.sendError(session, fmt.Errorf("extraction failed: %w", errs.Wrap(errs.ErrExtraction, err)))
+			session.broadcast.Close()
+			return
+		}
+		session.mu.Lock()
+		session.streamURL = streamURL
+		session.mu.Unlock()
 	}
 
 	// Check if cancelled after extraction (user clicked play again during yt-dlp)
 	select {
 	case <-sessionCtx.Done():
-		fmt.Printf("[Session] Cancelled after extraction %s\n", shortSessionID(session.ID))
+		logger.Infof("[Session] Cancelled after extraction %s", shortSessionID(session.ID))
 		return
 	default:
 	}
 
+	input, err := m.playbackInput(sessionCtx, session, streamURL)
+	if err != nil {
+		logger.Infof("[Session] Cancelled during download %s", shortSessionID(session.ID))
+		return
+	}
+
 	// Create encoding pipeline
-	pipeline := encoder.NewDefaultPipeline()
-	pipeline.SetSessionID(session.ID)
+	pipeline := m.newPipeline(session.ID)
+	if setter, ok := pipeline.(encoder.EndSetter); ok && session.EndAt > 0 {
+		setter.SetEndAt(session.EndAt)
+	}
+	if setter, ok := pipeline.(encoder.BitrateSetter); ok && session.bitrate > 0 {
+		setter.SetBitrate(session.bitrate)
+	}
+	if setter, ok := pipeline.(encoder.ProxySetter); ok && input == streamURL {
+		// The stream URL only works from the IP it was extracted from
+		setter.SetProxy(youtube.StreamProxy(sessionCtx))
+	}
+	if setter, ok := pipeline.(encoder.FilterSetter); ok {
+		filter := m.sessionFilter(session, streamURL)
+		session.mu.Lock()
+		tempo := encoder.PitchFilter(session.pitch, session.speed, encoder.DefaultConfig().SampleRate)
+		session.mu.Unlock()
+		if filter != "" && tempo != "" {
+			filter += ","
+		}
+		filter += tempo
+		setter.SetFilter(float64(session.settings.Volume)/100, filter)
+	}
+	if setter, ok := pipeline.(encoder.FadeSetter); ok {
+		setter.SetFadeIn(session.settings.fade())
+	}
+	session.mu.Lock()
+	crossfade := session.crossfade
+	session.crossfade = encoder.Crossfade{} // First start only
+	session.mu.Unlock()
+	if setter, ok := pipeline.(encoder.CrossfadeSetter); ok && crossfade.Duration > 0 {
+		setter.SetCrossfade(crossfade)
+	}
+	if session.levels || session.spectrum {
+		m.attachAnalysis(session, pipeline)
+	}
+	if notifier, ok := pipeline.(encoder.ExpiryNotifier); ok {
+		notifier.OnExpired(func() { go m.refreshStreamURL(session, myEpoch) })
+	}
 	session.mu.Lock()
 	session.Pipeline = pipeline
+	session.throttled = false
 	session.BytesSent = 0 // Reset bytes for this attempt
+	session.seekOffset = seekPosition
 	session.streamStartTime = time.Now()
+	session.encoded = nil
+	session.pcmBytes = 0
+	if session.Format != encoder.FormatPCM {
+		session.encoded = &ogg.OpusTracker{}
+	}
+	format := session.Format
+	formatSwitched := session.formatSwitched
+	session.formatSwitched = false
 	session.mu.Unlock()
 
+	// Sent after the old pipeline stopped and before the new one's audio
+	if formatSwitched {
+		m.sendJSON(session.ID, NewFormatEvent(session.ID, format))
+	}
+
 	// Start pipeline with seek position
-	if err := pipeline.Start(sessionCtx, streamURL, session.Format, seekPosition); err != nil {
+	if err := pipeline.Start(sessionCtx, input, format, seekPosition); err != nil {
+		session.removeDownload()
 		session.SetState(StateError)
-		m.sendEvent(session.ID, "error", fmt.Sprintf("pipeline failed: %v", err))
+		m.sendError(session, fmt.Errorf("pipeline failed: %w", errs.Wrap(errs.ErrPipeline, err)))
+		session.broadcast.Close()
 		return
 	}
 
 	session.SetState(StateStreaming)
+	session.mu.Lock()
+	firstStart := !session.readySent
+	session.readySent = true
+	session.mu.Unlock()
 
-	// Only send ready event on first attempt (not on retry)
-	if !isRetry {
+	// Only send ready event on first start (not on retry or restart)
+	if firstStart {
 		m.sendEvent(session.ID, "ready", "")
+		m.startListen(session)
+		if session.settings.SkipSilence {
+			go m.skipSilences(session, input)
+		}
 	}
 
 	// Stream audio data
-	prematureEnd := m.streamAudio(session, sessionCtx)
+	prematureEnd := m.streamAudio(session, pipeline, format, sessionCtx)
 
 	// Check if pipeline was replaced by a long-pause restart
 	session.mu.Lock()
 	currentEpoch := session.restartEpoch
 	stopped := session.isStopped
-	retries := session.retryCount
-	expectedDur := session.expectedDuration
-	totalPause := session.totalPauseDuration
+	expectedDur := session.endLocked()
+	playedTime := session.playedLocked()
+	newSeekPosition := session.positionLocked()
 	session.mu.Unlock()
 
 	if currentEpoch != myEpoch {
-		fmt.Printf("[Session] Pipeline replaced by restart for %s (epoch %d→%d)\n", shortSessionID(session.ID), myEpoch, currentEpoch)
+		logger.Infof("[Session] Pipeline replaced by restart for %s (epoch %d→%d)", shortSessionID(session.ID), myEpoch, currentEpoch)
 		return
 	}
 
-	if prematureEnd && !stopped && retries < maxRetries {
-		// Calculate where we stopped (subtract pause time for accurate position)
-		playedTime := time.Since(session.streamStartTime).Seconds() - totalPause.Seconds()
-		newSeekPosition := seekPosition + playedTime
-
-		// Only retry if we played some content and haven't reached near the end
-		if playedTime >= minPlayedForRetry.Seconds() &&
-		   (expectedDur == 0 || newSeekPosition < expectedDur-prematureEndingGap) {
-			session.mu.Lock()
-			session.retryCount++
-			session.mu.Unlock()
-
-			fmt.Printf("[Session] Premature end detected for %s (played %.1fs), retrying from %.1fs...\n",
-				shortSessionID(session.ID), playedTime, newSeekPosition)
-
-			// Small delay before retry to avoid hammering YouTube
-			time.Sleep(1 * time.Second)
+	// FFmpeg's own report of why it ended beats the timing heuristics
+	retryable := false
+	interruption := fmt.Sprintf("stream ended early at %.1fs", newSeekPosition)
+	if reporter, ok := pipeline.(encoder.ExitReporter); ok && !stopped && sessionCtx.Err() == nil {
+		exit := reporter.ExitStatus()
+		session.mu.Lock()
+		session.lastExitCode = &exit.Code
+		session.mu.Unlock()
+		switch {
+		case exit.Class == encoder.ErrorFatal:
+			logger.Errorf("[Session] FFmpeg failed for %s (exit code %d): %s", shortSessionID(session.ID), exit.Code, exit.Error)
+			session.removeDownload()
+			session.SetState(StateError)
+			m.sendError(session, errs.New(errs.ErrPipeline, "ffmpeg: %s", exit.Error))
+			session.broadcast.Close()
+			return
+		case exit.Class.Retryable():
+			logger.Warnf("[Session] Input for %s broke off (%s error, exit code %d): %s",
+				shortSessionID(session.ID), exit.Class, exit.Code, exit.Error)
+			retryable = true
+			interruption = exit.Error
+			prematureEnd = expectedDur == 0 || newSeekPosition < expectedDur-prematureEndingGap
+		}
+	}
 
-			// Retry with new seek position
-			m.runPlaybackWithRetry(session, newSeekPosition)
+	// Only retry if we played some content (or the input broke off) and
+	// haven't reached near the end
+	if prematureEnd && !stopped &&
+		(playedTime >= minPlayedForRetry.Seconds() || retryable) &&
+		(expectedDur == 0 || newSeekPosition < expectedDur-prematureEndingGap) {
+		session.mu.Lock()
+		attempt, delay, ok := m.takeRetryLocked(session)
+		if ok {
+			session.lastError = interruption
+		}
+		session.mu.Unlock()
+		if ok {
+			m.publishRetry(session.ID, attempt, "premature_end", delay)
+			logger.Warnf("[Session] Premature end detected for %s (played %.1fs), retrying from %.1fs in %v...",
+				shortSessionID(session.ID), playedTime, newSeekPosition, delay.Round(time.Millisecond))
+
+			// Back off before retrying so an outage is not hammered
+			if m.waitRetry(sessionCtx, session, myEpoch, delay) {
+				m.runPlaybackWithRetry(session, newSeekPosition)
+			}
 			return
 		}
+		logger.Warnf("[Session] Premature end detected for %s, retry budget spent", shortSessionID(session.ID))
 	}
 
 	// Normal end or no retry needed
+	session.removeDownload()
 	session.SetState(StateStopped)
-	m.sendEvent(session.ID, "finished", "")
-	fmt.Printf("[Session] Streaming finished for %s, sent %d bytes\n", shortSessionID(session.ID), session.BytesSent)
+	finished := NewFinishedEvent(session.ID)
+	finished.Diagnostics = session.Diagnostics()
+	m.sendJSON(session.ID, finished)
+	session.broadcast.Close()
+	m.finishListen(session)
+	if !stopped {
+		m.rememberPosition(session, !prematureEnd)
+	}
+	logger.Infof("[Session] Streaming finished for %s, sent %d bytes", shortSessionID(session.ID), session.BytesSent)
+	if stats, ok := session.BufferStats(); ok && (stats.ChunksDropped > 0 || stats.Underruns > 0) {
+		logger.Warnf("[Session] Buffer for %s dropped %d of %d chunks, %d underruns",
+			shortSessionID(session.ID), stats.ChunksDropped, stats.ChunksBuffered, stats.Underruns)
+	}
 }
 
 // streamAudio streams audio data from pipeline to socket connection.
 // Returns true if the stream ended prematurely (potential retry candidate).
-func (m *SessionManager) streamAudio(session *Session, ctx context.Context) (prematureEnd bool) {
-	output := session.Pipeline.Output()
-	if session.Format == encoder.FormatWeb {
-		paced := buffer.NewPacedBuffer(buffer.Config{
+func (m *SessionManager) streamAudio(session *Session, pipeline encoder.Pipeline, format encoder.Format, ctx context.Context) (prematureEnd bool) {
+	output := session.broadcast.Tee(ctx, pipeline.Output())
+	var paced *buffer.PacedBuffer
+	switch format {
+	case encoder.FormatWeb:
+		paced = buffer.NewPacedBuffer(buffer.Config{
 			Bitrate:     256000,
 			Prebuffer:   500 * time.Millisecond,
 			MaxBuffer:   2 * time.Second,
 			Passthrough: true,
+			Ogg:         true,
+			TargetLead:  1 * time.Second,
+			// Stop FFmpeg before the buffer overflows instead of dropping audio
+			HighWater:   1500 * time.Millisecond,
+			LowWater:    750 * time.Millisecond,
+			OnHighWater: func(time.Duration) { session.throttle(pipeline, true) },
+			OnLowWater:  func(time.Duration) { session.throttle(pipeline, false) },
+		})
+	case encoder.FormatOpus:
+		// Discord plays frames as they arrive, so even out FFmpeg's bursty
+		// page flushes into a steady 20ms cadence
+		paced = buffer.NewPacedBuffer(buffer.Config{
+			Bitrate:   128000,
+			Prebuffer: 200 * time.Millisecond,
+			MaxBuffer: 2 * time.Second,
+			Ogg:       true,
+			Smooth:    true,
 		})
+	}
+	if paced != nil {
+		session.mu.Lock()
+		session.paced = paced
+		session.mu.Unlock()
 		output = paced.Start(ctx, output)
 	}
 
+	// Stall watchdog: FFmpeg can stay alive with its output frozen (e.g. a
+	// stuck CDN connection), which would otherwise hang the session silently.
+	watchdog := time.NewTicker(stallCheckInterval)
+	defer watchdog.Stop()
+	lastChunkAt := time.Now()
+	var ramp *pcmRamp // Fade-in after a PCM resume
+
 	for {
 		select {
 		case <-ctx.Done():
 			// Context cancelled (user stopped) - not a premature end
 			return false
+		case <-watchdog.C:
+			if session.GetState() != StateStreaming || time.Since(lastChunkAt) < stallTimeout {
+				continue
+			}
+			m.handleStall(session, time.Since(lastChunkAt))
+			return false
 		case chunk, ok := <-output:
 			if !ok {
-				// Channel closed - check if premature
+				// Channel closed - check if premature. Played time and
+				// position come from the encoded audio itself, so pauses,
+				// pacing and the format's bitrate don't skew them.
 				session.mu.Lock()
-				playedTime := time.Since(session.streamStartTime).Seconds() - session.totalPauseDuration.Seconds()
-				expectedDur := session.expectedDuration
+				playedTime := session.playedLocked()
+				position := session.positionLocked()
+				expectedDur := session.endLocked()
 				stopped := session.isStopped
-				bytesSent := session.BytesSent
 				session.mu.Unlock()
 
 				// Consider premature if:
 				// 1. Not explicitly stopped by user
 				// 2. Expected duration is known and we're well short of it
 				// 3. OR expected duration unknown but we played very little
-				// 4. OR bytes sent are much less than expected for the duration
 				if !stopped {
-					if expectedDur > 0 && playedTime < expectedDur-prematureEndingGap {
-						fmt.Printf("[Session] Stream ended early for %s: played %.1fs of expected %.1fs\n",
-							shortSessionID(session.ID), playedTime, expectedDur)
+					if expectedDur > 0 && position < expectedDur-prematureEndingGap {
+						logger.Warnf("[Session] Stream ended early for %s: reached %.1fs of expected %.1fs",
+							shortSessionID(session.ID), position, expectedDur)
 						return true
 					} else if expectedDur == 0 && playedTime < 30 {
 						// Unknown duration but very short playback - likely an error
-						fmt.Printf("[Session] Stream ended suspiciously early for %s: only %.1fs played\n",
+						logger.Warnf("[Session] Stream ended suspiciously early for %s: only %.1fs played",
 							shortSessionID(session.ID), playedTime)
 						return true
 					}
-					// Byte-based check: if expected duration is known, verify we sent
-					// enough bytes. At 128kbps Opus, expect ~16KB/s. If we got less
-					// than 60% of expected bytes, stream was likely truncated by TLS errors.
-					if expectedDur > 0 {
-						expectedBytes := int64(expectedDur * 16000) // ~128kbps = 16KB/s
-						if bytesSent < expectedBytes*60/100 {
-							fmt.Printf("[Session] Stream data too short for %s: sent %d bytes, expected ~%d bytes (%.0f%%)\n",
-								shortSessionID(session.ID), bytesSent, expectedBytes, float64(bytesSent)*100/float64(expectedBytes))
-							return true
-						}
-					}
 				}
 				return false
 			}
+			lastChunkAt = time.Now()
 
 			// Check if paused BEFORE writing (immediate response)
 			session.mu.Lock()
+			if session.encoded != nil {
+				session.encoded.Write(chunk)
+			} else {
+				session.pcmBytes += int64(len(chunk))
+			}
 			paused := session.isPaused
+			stopping := session.stopping
+			fade := session.pcmFadeLocked()
 			session.mu.Unlock()
 
-			if paused {
-				session.SetState(StatePaused)
-				fmt.Printf("[Session] Paused %s (dropping chunk)\n", shortSessionID(session.ID))
+			if stopping {
+				// Stop fades this chunk out and ends playback after it
+				faded, rest := fadeOut(chunk, fade)
+				buffer.PutChunk(rest)
+				m.sendChunk(ctx, session, faded)
+				session.halt()
+				return false
+			}
 
+			if paused {
 				// Drain any stale resume signals before waiting
 				select {
 				case <-session.resumeCh:
 				default:
 				}
 
+				if fade > 0 {
+					// Pause left the pipeline running so this chunk could
+					// fade out; hold the rest and stop the pipeline now
+					faded, rest := fadeOut(chunk, fade)
+					chunk = rest
+					if !m.sendChunk(ctx, session, faded) {
+						buffer.PutChunk(chunk)
+						return false
+					}
+					session.mu.Lock()
+					if session.isPaused {
+						pipeline.Pause()
+					}
+					session.mu.Unlock()
+				}
+
+				session.SetState(StatePaused)
+				logger.Infof("[Session] Paused %s (holding chunk)", shortSessionID(session.ID))
+
 				// Wait for resume signal
-			waitLoop:
 				for {
+					session.mu.Lock()
+					stillPaused := session.isPaused
+					session.mu.Unlock()
+					if !stillPaused {
+						break
+					}
 					select {
 					case <-ctx.Done():
+						buffer.PutChunk(chunk)
 						return false // Context cancelled - not premature
 					case <-session.resumeCh:
-						// Check if actually resumed (not a stale signal)
-						session.mu.Lock()
-						stillPaused := session.isPaused
-						session.mu.Unlock()
-						if !stillPaused {
-							session.SetState(StateStreaming)
-							fmt.Printf("[Session] Resumed %s\n", shortSessionID(session.ID))
-							break waitLoop
-						}
-						// Still paused, keep waiting
 					}
 				}
-				continue // Get next chunk after resume
+				session.SetState(StateStreaming)
+				lastChunkAt = time.Now() // Don't count the pause as a stall
+				logger.Infof("[Session] Resumed %s", shortSessionID(session.ID))
+				if fade > 0 {
+					ramp = newPCMRamp(0, 1, fade)
+				}
+				// Send the held chunk; the paced buffer kept the rest
 			}
 
-			conn := m.GetConnection()
-			if conn == nil {
-				continue // No connection, skip chunk (will retry on next chunk)
+			if ramp != nil {
+				ramp.apply(chunk)
+				if ramp.done() {
+					ramp = nil
+				}
 			}
 
-			// Coalesce header + session ID + chunk into single write to avoid TCP Nagle delays
-			// Header: 4 bytes big-endian length (includes session ID + audio data)
-			// Session ID: 24 bytes, right-padded with spaces (truncated if longer)
-			const sessionIDLen = 24
-			sessionID := session.ID
-			if len(sessionID) > sessionIDLen {
-				sessionID = sessionID[:sessionIDLen]
-			}
-			paddedID := fmt.Sprintf("%-24s", sessionID)
-
-			length := uint32(sessionIDLen + len(chunk))
-			packet := make([]byte, 4+sessionIDLen+len(chunk))
-			packet[0] = byte(length >> 24)
-			packet[1] = byte(length >> 16)
-			packet[2] = byte(length >> 8)
-			packet[3] = byte(length)
-			copy(packet[4:4+sessionIDLen], paddedID)
-			copy(packet[4+sessionIDLen:], chunk)
-
-			if _, err := conn.Write(packet); err != nil {
-				// Connection broken - clear it and wait for reconnect
-				fmt.Printf("[Session] Write error (connection lost): %v\n", err)
-				m.SetConnection(nil)
-				continue
+			if !m.sendChunk(ctx, session, chunk) {
+				return false
 			}
+			lastChunkAt = time.Now() // Waiting on the client is not a stall
+		}
+	}
+}
 
-			session.mu.Lock()
-			session.BytesSent += int64(len(chunk))
-			session.mu.Unlock()
+// sendChunk sends one chunk to the sink, waiting for the client to grant
+// credit first (if it uses flow control). Takes ownership of chunk. Returns
+// false if ctx ended while waiting.
+func (m *SessionManager) sendChunk(ctx context.Context, session *Session, chunk []byte) bool {
+	if len(chunk) == 0 {
+		return true // A fade can split off an empty chunk
+	}
+	if !m.router.acquireCredit(ctx, session.ID) {
+		buffer.PutChunk(chunk)
+		return false
+	}
+	chunkLen := len(chunk)
+	if err := m.sink.SendAudio(session.ID, chunk); err != nil {
+		return true
+	}
+	session.mu.Lock()
+	session.BytesSent += int64(chunkLen)
+	session.mu.Unlock()
+	return true
+}
+
+// streamURLMargin is how long a pre-resolved stream URL must still be valid
+// for to be played rather than extracted again.
+const streamURLMargin = 30 * time.Second
+
+// usableStreamURL checks a caller's pre-resolved stream URL. Only http(s)
+// URLs are accepted, so FFmpeg can't be pointed at local files. It returns
+// "" if there is none or it expires too soon to be worth playing.
+func usableStreamURL(streamURL string, expires time.Time) (string, error) {
+	if streamURL == "" {
+		return "", nil
+	}
+	u, err := neturl.Parse(streamURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errInvalidStreamURL
+	}
+	if expires.IsZero() {
+		if unix, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64); err == nil {
+			expires = time.Unix(unix, 0)
 		}
 	}
+	if !expires.IsZero() && time.Until(expires) < streamURLMargin {
+		logger.Infof("[Session] Pre-resolved stream URL expires at %s, extracting a fresh one", expires.Format(time.RFC3339))
+		return "", nil
+	}
+	return streamURL, nil
 }
 
-// sendEvent sends a JSON event to the socket connection.
-func (m *SessionManager) sendEvent(sessionID string, eventType string, message string) {
-	conn := m.GetConnection()
-	if conn == nil {
+// resolveStreamURL extracts a direct stream URL for url outside any session.
+func (m *SessionManager) resolveStreamURL(ctx context.Context, url string) (string, error) {
+	extractor := m.registry.FindExtractor(url)
+	if extractor == nil {
+		return "", errs.New(errs.ErrExtraction, "unsupported URL")
+	}
+	streamURL, err := platform.ExtractStreamURL(ctx, extractor, url)
+	if err != nil {
+		return "", fmt.Errorf("extraction failed: %w", errs.Wrap(errs.ErrExtraction, err))
+	}
+	return streamURL, nil
+}
+
+// handleStall restarts a session whose pipeline stopped producing output.
+// Stall restarts share the premature-end retry budget and backoff so a permanently
+// broken stream still ends with an error instead of looping forever.
+func (m *SessionManager) handleStall(session *Session, silentFor time.Duration) {
+	session.mu.Lock()
+	if session.isStopped || session.isPaused {
+		session.mu.Unlock()
 		return
 	}
+	attempt, delay, ok := m.takeRetryLocked(session)
+	if !ok {
+		session.restartEpoch++ // Let runPlaybackWithRetry exit without sending "finished"
+		session.isStopped = true
+		if session.Pipeline != nil {
+			session.Pipeline.Stop()
+		}
+		session.mu.Unlock()
+		session.SetState(StateError)
 
-	var event string
-	if message != "" {
-		event = fmt.Sprintf(`{"type":"%s","session_id":"%s","message":"%s"}`+"\n", eventType, sessionID, message)
-	} else {
-		event = fmt.Sprintf(`{"type":"%s","session_id":"%s"}`+"\n", eventType, sessionID)
+		logger.Errorf("[Session] Pipeline stalled for %s (no output for %.0fs), retries exhausted",
+			shortSessionID(session.ID), silentFor.Seconds())
+		m.sendError(session, errs.New(errs.ErrPipeline, "stream stalled"))
+		session.broadcast.Close()
+		return
+	}
+
+	position := session.positionLocked()
+	logger.Warnf("[Session] Pipeline stalled for %s (no output for %.0fs), restarting from %.1fs in %v",
+		shortSessionID(session.ID), silentFor.Seconds(), position, delay.Round(time.Millisecond))
+
+	session.lastError = fmt.Sprintf("stream stalled (no output for %.0fs)", silentFor.Seconds())
+	m.restartAfterLocked(session, position, delay)
+	session.mu.Unlock()
+	m.publishRetry(session.ID, attempt, "stalled", delay)
+}
+
+// refreshStreamURL replaces an expired stream URL mid-track: a fresh URL is
+// extracted while the current pipeline plays out what it has buffered, then
+// the session restarts at its current position with it. This avoids the
+// audible gap of waiting for the stream to die and retrying afterwards.
+func (m *SessionManager) refreshStreamURL(session *Session, epoch int) {
+	session.mu.Lock()
+	if session.refreshing || session.isStopped || session.restartEpoch != epoch ||
+		session.urlRefreshes >= maxURLRefreshes {
+		session.mu.Unlock()
+		return
+	}
+	session.refreshing = true
+	session.urlRefreshes++
+	attempt := session.urlRefreshes
+	session.mu.Unlock()
+
+	logger.Warnf("[Session] Stream URL expired for %s, extracting a fresh one", shortSessionID(session.ID))
+	var streamURL string
+	err := errs.New(errs.ErrExtraction, "unsupported URL")
+	if extractor := m.registry.FindExtractor(session.URL); extractor != nil {
+		streamURL, err = platform.ExtractStreamURL(youtube.WithRegion(m.ctx, session.region), extractor, session.URL)
+	}
+
+	// Cleared together with the restart, so the new pipeline can report
+	// its own URL expiring straight away
+	session.mu.Lock()
+	session.refreshing = false
+	if err != nil {
+		session.mu.Unlock()
+		logger.Warnf("[Session] Refreshing stream URL for %s failed: %v", shortSessionID(session.ID), err)
+		return // Premature-end retry still covers the stream dying
+	}
+	if session.isStopped || session.restartEpoch != epoch {
+		session.mu.Unlock()
+		return // Stopped or already restarted
+	}
+	session.streamURL = streamURL
+	if session.isPaused {
+		session.mu.Unlock()
+		return // Paused: leave recovery to resume and the premature-end retry
+	}
+	position := session.positionLocked()
+	logger.Infof("[Session] Switching %s to a fresh stream URL at %.1fs", shortSessionID(session.ID), position)
+	session.reuseStreamURL = true
+	session.lastError = "stream URL expired"
+	m.restartLocked(session, position)
+	session.mu.Unlock()
+	m.publishRetry(session.ID, attempt, "url_expired", 0)
+}
+
+// restartLocked tears down the session's current pipeline and starts a fresh
+// one (with a newly extracted stream URL) from the given position.
+// The old streamAudio goroutine notices the epoch change and exits silently.
+// Caller must hold session.mu.
+func (m *SessionManager) restartLocked(session *Session, position float64) {
+	m.restartAfterLocked(session, position, 0)
+}
+
+// restartAfterLocked is restartLocked with the new pipeline started after
+// delay (a retry's backoff). Stopping the session meanwhile cancels it.
+// Caller must hold session.mu.
+func (m *SessionManager) restartAfterLocked(session *Session, position float64, delay time.Duration) {
+	session.restartEpoch++
+	session.isPaused = false
+
+	if session.Cancel != nil {
+		session.Cancel()
+	}
+	if session.Pipeline != nil {
+		session.Pipeline.Stop()
+	}
+	session.totalPauseDuration = 0 // Reset for new streaming period
+
+	if delay <= 0 {
+		go m.runPlaybackWithRetry(session, position)
+		return
 	}
+	waitCtx, cancel := context.WithCancel(m.ctx)
+	session.Cancel = cancel
+	epoch := session.restartEpoch
+	go func() {
+		defer cancel()
+		if m.waitRetry(waitCtx, session, epoch, delay) {
+			m.runPlaybackWithRetry(session, position)
+		}
+	}()
+}
+
+// attachAnalysis feeds the pipeline's PCM tap into the analysers enabled for
+// the session (level meter, spectrum), which emit events over the socket.
+func (m *SessionManager) attachAnalysis(session *Session, pipeline encoder.Pipeline) {
+	tapper, ok := pipeline.(encoder.PCMTapper)
+	if !ok {
+		return
+	}
+	sampleRate := encoder.DefaultConfig().SampleRate
+
+	var sinks []encoder.TapFunc
+	if session.levels {
+		window := int(levelsInterval.Seconds() * float64(sampleRate))
+		meter := analysis.NewLevelMeter(window, func(levels analysis.Levels) {
+			m.sendJSON(session.ID, NewLevelsEvent(session.ID, levels.RMS, levels.Peak))
+		})
+		sinks = append(sinks, meter.Write)
+	}
+	if session.spectrum {
+		analyzer := analysis.NewSpectrumAnalyzer(sampleRate, spectrumBands, spectrumRate, func(bands []float64) {
+			m.sendJSON(session.ID, NewSpectrumEvent(session.ID, bands))
+		})
+		sinks = append(sinks, analyzer.Write)
+	}
+
+	tapper.SetPCMTap(func(pcm []byte) {
+		for _, sink := range sinks {
+			sink(pcm)
+		}
+	})
+}
+
+// sendEvent publishes a client event.
+func (m *SessionManager) sendEvent(sessionID string, eventType string, message string) {
+	m.sendJSON(sessionID, Event{
+		Type:      EventType(eventType),
+		SessionID: sessionID,
+		Message:   message,
+	})
+}
+
+// sendError records err as the session's last error and publishes an error
+// event carrying its message, code and the session's diagnostics.
+func (m *SessionManager) sendError(session *Session, err error) {
+	session.mu.Lock()
+	session.lastError = err.Error()
+	diagnostics := session.diagnosticsLocked()
+	session.mu.Unlock()
 
-	conn.Write([]byte(event))
+	event := NewErrorEvent(session.ID, err)
+	event.Diagnostics = diagnostics
+	m.bus.Publish(BusEvent{
+		Type:      BusClientEvent,
+		SessionID: session.ID,
+		Err:       err,
+		Event:     event,
+	})
+}
+
+// sendJSON publishes v as a client event (sent to the sink as JSON).
+func (m *SessionManager) sendJSON(sessionID string, v any) {
+	m.bus.Publish(BusEvent{Type: BusClientEvent, SessionID: sessionID, Event: v})
+}
+
+// publishRetry publishes a pipeline retry and tells clients about it with a
+// retrying event.
+func (m *SessionManager) publishRetry(sessionID string, attempt int, reason string, delay time.Duration) {
+	m.bus.Publish(BusEvent{Type: BusRetry, SessionID: sessionID, Attempt: attempt, Reason: reason, Delay: delay})
+	m.sendJSON(sessionID, NewRetryingEvent(sessionID, attempt, reason, delay))
 }
 
 // ActiveSessionCount returns the number of active sessions.
@@ -480,7 +1112,7 @@ func (m *SessionManager) Get(id string) *Session {
 	return m.sessions[id]
 }
 
-// Stop stops a session by ID.
+// Stop stops a session by ID and drops its saved queue.
 func (m *SessionManager) Stop(id string) {
 	m.mu.Lock()
 	session, ok := m.sessions[id]
@@ -488,10 +1120,85 @@ func (m *SessionManager) Stop(id string) {
 		delete(m.sessions, id)
 	}
 	m.mu.Unlock()
+	m.queues.Delete(id)
 
 	if session != nil {
+		session.fadeOutAndStop()
+		m.finishListen(session)
+		m.rememberPosition(session, false)
+	}
+}
+
+// StopAll stops and removes every session, with their saved queues.
+// Returns how many were stopped.
+func (m *SessionManager) StopAll() int {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for id, session := range m.sessions {
+		sessions = append(sessions, session)
+		delete(m.sessions, id)
+		m.queues.Delete(id)
+	}
+	m.mu.Unlock()
+
+	for _, session := range sessions {
 		session.Stop()
+		m.finishListen(session)
+		m.rememberPosition(session, false)
+	}
+	return len(sessions)
+}
+
+// SetDraining starts or ends draining: while draining, StartPlayback is
+// refused and current sessions play to the end, so the server can be
+// replaced once StreamingSessionCount reaches zero. Starting to drain sends
+// a server_draining event.
+func (m *SessionManager) SetDraining(draining bool) {
+	m.mu.Lock()
+	started := draining && !m.draining
+	m.draining = draining
+	m.mu.Unlock()
+
+	if started {
+		m.sendEvent("", string(EventServerDraining), "server is shutting down, not accepting new playback")
+	}
+}
+
+// Drain starts draining and waits up to grace for streaming sessions to
+// finish. Reports whether they all did.
+func (m *SessionManager) Drain(grace time.Duration) bool {
+	m.SetDraining(true)
+
+	deadline := time.Now().Add(grace)
+	for m.StreamingSessionCount() > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+	return true
+}
+
+// Draining reports whether the manager is draining.
+func (m *SessionManager) Draining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
+}
+
+// Subscribe adds a consumer of a session's raw pipeline output (e.g. an HTTP
+// stream or a recording sink) alongside the socket. The subscriber sees
+// output from every retry, so Ogg formats arrive as chained streams.
+// Close the subscriber when done; it also ends when the session does.
+func (m *SessionManager) Subscribe(id string, cfg buffer.SubscriberConfig) (*buffer.Subscriber, error) {
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return nil, errSessionNotFound
 	}
+	return session.broadcast.Subscribe(cfg), nil
 }
 
 // Pause pauses a session by ID.
@@ -501,7 +1208,7 @@ func (m *SessionManager) Pause(id string) error {
 	m.mu.RUnlock()
 
 	if session == nil {
-		return errors.New("session not found")
+		return errSessionNotFound
 	}
 
 	session.mu.Lock()
@@ -512,10 +1219,14 @@ func (m *SessionManager) Pause(id string) error {
 	session.isPaused = true
 	session.pausedAt = time.Now()
 
-	// Pause the pipeline (SIGSTOP to FFmpeg + drain buffer)
-	if session.Pipeline != nil {
+	// Pause the pipeline at a frame boundary. With a PCM fade, streamAudio
+	// pauses it after fading out the next chunk.
+	if session.Pipeline != nil && session.pcmFadeLocked() == 0 {
 		session.Pipeline.Pause()
 	}
+	if session.paced != nil {
+		session.paced.Pause() // Hold buffered audio for resume
+	}
 	session.mu.Unlock()
 
 	return nil
@@ -528,7 +1239,7 @@ func (m *SessionManager) Resume(id string) error {
 	m.mu.RUnlock()
 
 	if session == nil {
-		return errors.New("session not found")
+		return errSessionNotFound
 	}
 
 	session.mu.Lock()
@@ -542,45 +1253,26 @@ func (m *SessionManager) Resume(id string) error {
 
 	if pauseDuration >= longPauseThreshold {
 		// Long pause — YouTube stream URL likely expired.
-		// Calculate actual played time and restart pipeline from correct position.
-		var seekPosition float64
-		if session.streamStartTime.IsZero() {
-			// Paused before streaming started (e.g. web auto-pause during extraction)
-			seekPosition = session.StartAt
-		} else {
-			actualPlayed := time.Since(session.streamStartTime) - session.totalPauseDuration
-			seekPosition = session.StartAt + actualPlayed.Seconds()
-		}
+		// Restart pipeline with a fresh stream URL from the correct position.
+		session.isPaused = false // Pause time is already in totalPauseDuration
+		seekPosition := session.positionLocked()
 
-		fmt.Printf("[Session] Long pause (%.0fm) for %s, re-extracting from %.1fs\n",
+		logger.Infof("[Session] Long pause (%.0fm) for %s, re-extracting from %.1fs",
 			pauseDuration.Minutes(), shortSessionID(id), seekPosition)
 
-		// Bump epoch so the old streamAudio goroutine exits silently
-		session.restartEpoch++
-		session.isPaused = false
-
-		// Kill old pipeline
-		if session.Cancel != nil {
-			session.Cancel()
-		}
-		if session.Pipeline != nil {
-			session.Pipeline.Stop()
-		}
-
-		// Prepare for fresh streaming period
-		session.retryCount = 1          // Treat as retry (skip duplicate "ready" event)
-		session.totalPauseDuration = 0  // Reset for new streaming period
+		m.restartLocked(session, seekPosition)
 		session.mu.Unlock()
-
-		// Restart playback with fresh stream URL from correct position
-		go m.runPlaybackWithRetry(session, seekPosition)
 		return nil
 	}
 
-	// Short pause — normal SIGCONT resume, stream URL still valid
-	if session.Pipeline != nil {
+	// Short pause — resume the same pipeline, stream URL still valid.
+	// A throttled pipeline stays stopped until the buffer drains.
+	if session.Pipeline != nil && !session.throttled {
 		session.Pipeline.Resume()
 	}
+	if session.paced != nil {
+		session.paced.Resume()
+	}
 
 	session.isPaused = false
 	session.mu.Unlock()
@@ -594,11 +1286,210 @@ func (m *SessionManager) Resume(id string) error {
 	return nil
 }
 
-// SetState updates the session state.
+// Seek restarts a session at position (seconds) with its current stream
+// URL. A paused session resumes.
+func (m *SessionManager) Seek(id string, position float64) error {
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return errSessionNotFound
+	}
+	if position < 0 {
+		return errInvalidSeek
+	}
+
+	session.mu.Lock()
+	if session.isStopped || session.State == StateStopped || session.State == StateError {
+		session.mu.Unlock()
+		return errNotPlaying
+	}
+	if end := session.endLocked(); end > 0 && position >= end {
+		session.mu.Unlock()
+		return errInvalidSeek
+	}
+
+	logger.Infof("[Session] Seeking %s to %.1fs", shortSessionID(id), position)
+	session.reuseStreamURL = session.streamURL != ""
+	m.restartLocked(session, position)
+	session.mu.Unlock()
+	return nil
+}
+
+// SetFormat switches a session's output format (e.g. from opus to web when
+// playback moves from Discord to the web player) without losing its place:
+// only the encoder is restarted, at the current position with the same
+// stream URL. A format event tells the client that the audio after it is
+// in the new format. A paused session resumes.
+func (m *SessionManager) SetFormat(id string, formatStr string) error {
+	format := encoder.Format(formatStr)
+	if format != encoder.FormatPCM && format != encoder.FormatOpus && format != encoder.FormatWeb {
+		return errInvalidFormat
+	}
+
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return errSessionNotFound
+	}
+
+	session.mu.Lock()
+	if session.isStopped || session.State == StateStopped || session.State == StateError {
+		session.mu.Unlock()
+		return errNotPlaying
+	}
+	if session.Format == format {
+		session.mu.Unlock()
+		return nil
+	}
+
+	position := session.positionLocked()
+	logger.Infof("[Session] Switching %s from %s to %s at %.1fs", shortSessionID(id), session.Format, format, position)
+	session.Format = format
+	session.abr = newABRController(format)
+	session.bitrate = 0 // The new format's default
+	session.reuseStreamURL = session.streamURL != ""
+	session.formatSwitched = true
+	m.restartLocked(session, position)
+	session.mu.Unlock()
+	return nil
+}
+
+// SetTempo changes the playback speed and pitch shift (semitones) of id's
+// current track, if any, by restarting its encoder at the current position
+// with the same stream URL. A paused session resumes. Both must already be
+// validated (see SettingsPatch); later plays take them from the saved
+// settings.
+func (m *SessionManager) SetTempo(id string, speed, pitch float64) {
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.isStopped || session.State == StateStopped || session.State == StateError ||
+		(session.speed == speed && session.pitch == pitch) {
+		return
+	}
+	position := session.positionLocked() // At the old speed
+	logger.Infof("[Session] Setting %s to %gx, %+g semitones at %.1fs", shortSessionID(id), speed, pitch, position)
+	session.speed = speed
+	session.pitch = pitch
+	session.reuseStreamURL = session.streamURL != ""
+	m.restartLocked(session, position)
+}
+
+// SetBitrate sets a session's Opus bitrate in bps, e.g. when its Discord
+// channel's bitrate changes. Adaptive bitrate may still step down from it
+// but not above it. A playing session restarts its encoder at the current
+// position with the same stream URL; otherwise the bitrate applies from the
+// next start. Returns the bitrate in effect.
+func (m *SessionManager) SetBitrate(id string, bps int) (int, error) {
+	if bps < minOpusBitrate || bps > maxOpusBitrate {
+		return 0, errInvalidBitrate
+	}
+
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return 0, errSessionNotFound
+	}
+
+	session.mu.Lock()
+	if session.Format == encoder.FormatPCM {
+		session.mu.Unlock()
+		return 0, errNoABR
+	}
+	current := session.bitrate
+	if current == 0 {
+		current = session.abr.Bitrate() // Format default
+	}
+	changed := current != bps
+	session.abr = newCappedABRController(session.Format, bps)
+	session.bitrate = bps
+	if changed && session.State == StateStreaming && !session.isPaused && !session.isStopped {
+		position := session.positionLocked()
+		logger.Infof("[Session] Setting %s to %d kbps at %.1fs", shortSessionID(id), bps/1000, position)
+		session.reuseStreamURL = session.streamURL != ""
+		m.restartLocked(session, position)
+	}
+	session.mu.Unlock()
+
+	if changed {
+		m.sendJSON(id, NewBitrateEvent(id, bps))
+	}
+	return bps, nil
+}
+
+// Feedback applies a consumer buffer report to the session's output pacing
+// and adaptive bitrate controller. When the bitrate changes, the pipeline is
+// restarted at the current position with the same stream URL.
+// Returns the bitrate in effect.
+func (m *SessionManager) Feedback(id string, fb Feedback) (int, error) {
+	m.mu.RLock()
+	session := m.sessions[id]
+	m.mu.RUnlock()
+
+	if session == nil {
+		return 0, errSessionNotFound
+	}
+
+	session.mu.Lock()
+	if session.paced != nil {
+		session.paced.Ack(fb.Buffered)
+	}
+	if session.abr == nil {
+		session.mu.Unlock()
+		return 0, errNoABR
+	}
+
+	bitrate, changed := session.abr.Observe(fb, time.Now())
+	if !changed {
+		session.mu.Unlock()
+		return bitrate, nil
+	}
+
+	session.bitrate = bitrate
+	if session.State == StateStreaming && !session.isPaused && !session.isStopped {
+		position := session.positionLocked()
+		logger.Infof("[Session] Switching %s to %d kbps at %.1fs (buffered %dms, underruns %d)",
+			shortSessionID(id), bitrate/1000, position, fb.Buffered.Milliseconds(), fb.Underruns)
+		session.reuseStreamURL = session.streamURL != ""
+		m.restartLocked(session, position)
+	}
+	session.mu.Unlock()
+
+	m.sendJSON(id, NewBitrateEvent(id, bitrate))
+	return bitrate, nil
+}
+
+// SetState updates the session state, publishing the change on the bus.
 func (s *Session) SetState(state SessionState) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	changed := s.State != state
 	s.State = state
+	s.mu.Unlock()
+
+	if changed {
+		s.publishState(state)
+	}
+}
+
+// publishState publishes a state change. Must not be called with s.mu held,
+// since subscribers may inspect the session.
+func (s *Session) publishState(state SessionState) {
+	if s.bus != nil {
+		s.bus.Publish(BusEvent{Type: BusStateChanged, SessionID: s.ID, State: state})
+	}
 }
 
 // GetState returns the current session state.
@@ -613,17 +1504,200 @@ func (s *Session) GetStateString() string {
 	return s.GetState().String()
 }
 
+// throttle pauses or resumes pipeline on buffer watermarks. It never resumes
+// a pipeline the user paused, and ignores pipelines replaced by a restart.
+func (s *Session) throttle(pipeline encoder.Pipeline, stop bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Pipeline != pipeline || s.throttled == stop {
+		return
+	}
+	s.throttled = stop
+	if s.isPaused {
+		return // Pause/Resume own the pipeline state
+	}
+	if stop {
+		pipeline.Pause()
+	} else {
+		pipeline.Resume()
+	}
+}
+
+// positionLocked returns the current playback position in seconds: where the
+// current pipeline started plus how much audio it has produced, scaled by
+// the playback speed to track time.
+// Caller must hold s.mu.
+func (s *Session) positionLocked() float64 {
+	if s.streamStartTime.IsZero() {
+		// Paused or stalled before streaming started (e.g. web auto-pause during extraction)
+		return s.StartAt
+	}
+	played := s.playedLocked()
+	if s.speed > 0 {
+		played *= s.speed
+	}
+	return s.seekOffset + played
+}
+
+// endLocked returns the position in seconds where playback should end: the
+// requested end_at, or the track duration (0 if neither is known).
+// Caller must hold s.mu.
+func (s *Session) endLocked() float64 {
+	if s.EndAt > 0 && (s.expectedDuration == 0 || s.EndAt < s.expectedDuration) {
+		return s.EndAt
+	}
+	return s.expectedDuration
+}
+
+// playedLocked returns how many seconds of audio the current pipeline has
+// produced. This is measured on the output itself - the granule position for
+// Ogg formats, the byte count for PCM - which is immune to -re drift, pauses
+// and VBR; wall-clock time minus pauses is only used before any output.
+// Caller must hold s.mu.
+func (s *Session) playedLocked() float64 {
+	if s.encoded != nil && s.encoded.HasPosition() {
+		return s.encoded.Position().Seconds()
+	}
+	if s.encoded == nil && s.pcmBytes > 0 {
+		return float64(s.pcmBytes) / float64(pcmBytesPerSecond)
+	}
+	if s.streamStartTime.IsZero() {
+		return 0
+	}
+	played := time.Since(s.streamStartTime) - s.totalPauseDuration
+	if s.isPaused && !s.pausedAt.IsZero() {
+		played -= time.Since(s.pausedAt)
+	}
+	if played < 0 {
+		played = 0
+	}
+	return played.Seconds()
+}
+
+// Diagnostics returns the session's retry and error history.
+func (s *Session) Diagnostics() *Diagnostics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.diagnosticsLocked()
+}
+
+// diagnosticsLocked is Diagnostics for callers holding s.mu.
+func (s *Session) diagnosticsLocked() *Diagnostics {
+	return &Diagnostics{
+		RetryCount:   s.retryCount,
+		LastError:    s.lastError,
+		LastExitCode: s.lastExitCode,
+		SeekOffset:   s.seekOffset,
+	}
+}
+
+// BufferStats returns the output buffer counters of the current attempt,
+// or false if the session's output is not buffered (non-web formats).
+func (s *Session) BufferStats() (buffer.Stats, bool) {
+	s.mu.Lock()
+	paced := s.paced
+	s.mu.Unlock()
+	if paced == nil {
+		return buffer.Stats{}, false
+	}
+	return paced.Stats(), true
+}
+
+// Position returns the current playback position in seconds.
+func (s *Session) Position() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.positionLocked()
+}
+
+// Speed returns the playback speed.
+func (s *Session) Speed() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.speed > 0 {
+		return s.speed
+	}
+	return 1
+}
+
+// Remaining returns how long the rest of the track takes to play at the
+// current speed, in seconds (0 if the track length is unknown).
+func (s *Session) Remaining() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.endLocked()
+	if end == 0 {
+		return 0
+	}
+	remaining := max(end-s.positionLocked(), 0)
+	if s.speed > 0 {
+		remaining /= s.speed
+	}
+	return remaining
+}
+
 // Stop stops the session and its pipeline.
 func (s *Session) Stop() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	changed := s.State != StateStopped
+	defer func() {
+		s.mu.Unlock()
+		if changed {
+			s.publishState(StateStopped)
+		}
+	}()
 
 	s.isStopped = true // Mark as explicitly stopped (prevents auto-retry)
+	s.stopping = false
 	if s.Cancel != nil {
 		s.Cancel()
 	}
 	if s.Pipeline != nil {
 		s.Pipeline.Stop()
 	}
+	if s.broadcast != nil {
+		s.broadcast.Close()
+	}
+	s.removeDownloadLocked()
+	s.State = StateStopped
+}
+
+// fadeOutAndStop stops the session like Stop, but a streaming PCM session
+// first fades out the next chunk. The pipeline is stopped regardless once
+// the fade should have finished.
+func (s *Session) fadeOutAndStop() {
+	s.mu.Lock()
+	fade := s.pcmFadeLocked()
+	if fade == 0 || s.State != StateStreaming || s.isPaused || s.isStopped {
+		s.mu.Unlock()
+		s.Stop()
+		return
+	}
+	s.isStopped = true
+	s.stopping = true
+	if s.broadcast != nil {
+		s.broadcast.Close()
+	}
 	s.State = StateStopped
+	s.mu.Unlock()
+
+	s.publishState(StateStopped)
+	time.AfterFunc(fade+stopFadeSlack, s.halt)
+}
+
+// halt finishes a fading stop by cancelling the session and stopping its
+// pipeline. Does nothing if the stop already finished.
+func (s *Session) halt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopping {
+		return
+	}
+	s.stopping = false
+	if s.Cancel != nil {
+		s.Cancel()
+	}
+	if s.Pipeline != nil {
+		s.Pipeline.Stop()
+	}
 }
@@ -1,8 +1,23 @@
 package server
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/platform"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
 )
 
 func TestSessionManager_GetNonexistent(t *testing.T) {
@@ -49,19 +64,22 @@ func TestSessionManager_ResumeNonexistent(t *testing.T) {
 	}
 }
 
-func TestSessionManager_SetConnection(t *testing.T) {
+func TestSessionManager_Connections(t *testing.T) {
 	ctx := context.Background()
 	sm := NewSessionManager(ctx)
 
-	// Initial connection should be nil
-	if conn := sm.GetConnection(); conn != nil {
-		t.Error("expected nil initial connection")
+	if n := sm.ConnectionCount(); n != 0 {
+		t.Errorf("expected no initial connections, got %d", n)
 	}
 
-	// SetConnection(nil) should work
-	sm.SetConnection(nil)
-	if conn := sm.GetConnection(); conn != nil {
-		t.Error("expected nil connection after SetConnection(nil)")
+	first, _ := net.Pipe()
+	second, _ := net.Pipe()
+	sm.AddConnection(first)
+	sm.AddConnection(second)
+	sm.RemoveConnection(first)
+	sm.RemoveConnection(first) // Removing twice is harmless
+	if n := sm.ConnectionCount(); n != 1 {
+		t.Errorf("expected the second connection to remain, got %d", n)
 	}
 }
 
@@ -114,3 +132,580 @@ func TestSession_Stop(t *testing.T) {
 		t.Errorf("expected StateStopped, got %v", session.GetState())
 	}
 }
+
+// fakeExtractor resolves "fake://" URLs without running yt-dlp.
+type fakeExtractor struct {
+	err   error
+	base  string       // Stream URL prefix ("" = https://stream.invalid/)
+	calls atomic.Int32 // Extractions so far
+}
+
+func (f *fakeExtractor) ExtractStreamURL(url string) (string, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return "", f.err
+	}
+	return cmp.Or(f.base, "https://stream.invalid/") + strings.TrimPrefix(url, "fake://"), nil
+}
+
+func (f *fakeExtractor) CanHandle(url string) bool { return strings.HasPrefix(url, "fake://") }
+
+func (f *fakeExtractor) Name() string { return "fake" }
+
+// fakePipeline emits a fixed list of chunks and then closes its output,
+// standing in for FFmpeg.
+type fakePipeline struct {
+	chunks [][]byte
+	output chan []byte
+}
+
+func newFakePipeline(chunks ...string) *fakePipeline {
+	p := &fakePipeline{output: make(chan []byte)}
+	for _, c := range chunks {
+		p.chunks = append(p.chunks, []byte(c))
+	}
+	return p
+}
+
+func (p *fakePipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	go func() {
+		defer close(p.output)
+		for _, chunk := range p.chunks {
+			select {
+			case p.output <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *fakePipeline) Output() <-chan []byte { return p.output }
+func (p *fakePipeline) Pause()                {}
+func (p *fakePipeline) Resume()               {}
+func (p *fakePipeline) Stop()                 {}
+
+// socketMessage is either a JSON event or an audio packet read from the socket.
+type socketMessage struct {
+	event     map[string]string
+	sessionID string
+	audio     []byte
+}
+
+// readSocketMessages decodes the socket protocol from conn until it is closed.
+func readSocketMessages(conn net.Conn) <-chan socketMessage {
+	out := make(chan socketMessage, 16)
+	go func() {
+		defer close(out)
+		r := bufio.NewReader(conn)
+		for {
+			first, err := r.Peek(1)
+			if err != nil {
+				return
+			}
+			if first[0] == '{' {
+				line, err := r.ReadBytes('\n')
+				if err != nil {
+					return
+				}
+				var event map[string]string
+				json.Unmarshal(line, &event)
+				out <- socketMessage{event: event}
+				continue
+			}
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(r, header); err != nil {
+				return
+			}
+			payload := make([]byte, binary.BigEndian.Uint32(header))
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			out <- socketMessage{
+				sessionID: strings.TrimRight(string(payload[:24]), " "),
+				audio:     payload[24:],
+			}
+		}
+	}()
+	return out
+}
+
+func newTestSessionManager(t *testing.T, extractor *fakeExtractor, factory PipelineFactory) (*SessionManager, <-chan socketMessage) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	sm := NewSessionManager(ctx)
+	registry := platform.NewRegistry()
+	registry.Register(extractor)
+	sm.SetRegistry(registry)
+	sm.SetPipelineFactory(factory)
+
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() {
+		serverConn.Close()
+		clientConn.Close()
+	})
+	sm.AddConnection(serverConn)
+
+	return sm, readSocketMessages(clientConn)
+}
+
+func nextMessage(t *testing.T, messages <-chan socketMessage) socketMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("socket closed unexpectedly")
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for socket message")
+	}
+	return socketMessage{}
+}
+
+func TestSessionManager_StreamsPipelineOutput(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline("abc", "defg")
+	})
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+
+	if msg := nextMessage(t, messages); msg.event["type"] != "ready" {
+		t.Fatalf("expected ready event, got %+v", msg)
+	}
+	for _, want := range []string{"abc", "defg"} {
+		msg := nextMessage(t, messages)
+		if msg.sessionID != "guild-1" || string(msg.audio) != want {
+			t.Fatalf("expected audio %q for guild-1, got %+v", want, msg)
+		}
+	}
+	if msg := nextMessage(t, messages); msg.event["type"] != "finished" {
+		t.Fatalf("expected finished event, got %+v", msg)
+	}
+
+	if got := sm.Get("guild-1").GetState(); got != StateStopped {
+		t.Errorf("expected StateStopped, got %v", got)
+	}
+}
+
+func TestSessionManager_ExtractionError(t *testing.T) {
+	extractor := &fakeExtractor{err: errors.New("video unavailable")}
+	sm, messages := newTestSessionManager(t, extractor, func(string) encoder.Pipeline {
+		t.Error("pipeline should not be created when extraction fails")
+		return newFakePipeline()
+	})
+
+	sm.StartPlayback("guild-1", "fake://missing", "opus", PlaybackOptions{})
+
+	msg := nextMessage(t, messages)
+	if msg.event["type"] != "error" || !strings.Contains(msg.event["message"], "video unavailable") {
+		t.Fatalf("expected extraction error event, got %+v", msg)
+	}
+	if msg.event["code"] != "extraction" {
+		t.Errorf("expected extraction code, got %q", msg.event["code"])
+	}
+	if got := sm.Get("guild-1").GetState(); got != StateError {
+		t.Errorf("expected StateError, got %v", got)
+	}
+}
+
+// expiringPipeline reports an expired stream URL right after starting.
+type expiringPipeline struct {
+	*holdPipeline
+	onExpired func()
+	starts    chan float64
+}
+
+func (p *expiringPipeline) OnExpired(fn func()) { p.onExpired = fn }
+
+func (p *expiringPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.starts <- startAtSec
+	if err := p.holdPipeline.Start(ctx, streamURL, format, startAtSec); err != nil {
+		return err
+	}
+	p.onExpired()
+	return nil
+}
+
+func TestSessionManager_RefreshesExpiredStreamURL(t *testing.T) {
+	starts := make(chan float64, maxURLRefreshes+2)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &expiringPipeline{holdPipeline: &holdPipeline{fakePipeline: newFakePipeline()}, starts: starts}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	retries := make(chan string, maxURLRefreshes+1)
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		if ev.Type == BusRetry {
+			retries <- ev.Reason
+		}
+	})
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{StartAt: 30})
+	for i := 0; i <= maxURLRefreshes; i++ {
+		select {
+		case <-starts:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("pipeline start %d did not happen", i+1)
+		}
+	}
+	for i := 0; i < maxURLRefreshes; i++ {
+		if reason := <-retries; reason != "url_expired" {
+			t.Errorf("expected url_expired retry, got %q", reason)
+		}
+	}
+
+	select {
+	case <-starts:
+		t.Error("expected refreshes to stop after maxURLRefreshes")
+	case <-time.After(200 * time.Millisecond):
+	}
+	sm.Stop("guild-1")
+}
+
+// exitPipeline ends its output and then reports a fixed exit status.
+type exitPipeline struct {
+	*fakePipeline
+	exit encoder.ExitStatus
+}
+
+func (p *exitPipeline) ExitStatus() encoder.ExitStatus { return p.exit }
+
+func TestSessionManager_FatalExitFailsFast(t *testing.T) {
+	starts := 0
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		starts++
+		return &exitPipeline{
+			fakePipeline: newFakePipeline(),
+			exit:         encoder.ExitStatus{Code: 1, Class: encoder.ErrorFatal, Error: "Invalid data found when processing input"},
+		}
+	})
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+
+	for {
+		msg := nextMessage(t, messages)
+		if msg.event["type"] == "finished" {
+			t.Fatal("expected an error event, got finished")
+		}
+		if msg.event["type"] != "error" {
+			continue
+		}
+		if msg.event["code"] != "pipeline" || !strings.Contains(msg.event["message"], "Invalid data") {
+			t.Errorf("expected pipeline error with the ffmpeg message, got %+v", msg.event)
+		}
+		if !strings.Contains(msg.event["last_error"], "Invalid data") {
+			t.Errorf("expected last_error on the error event, got %+v", msg.event)
+		}
+		break
+	}
+	if got := sm.Get("guild-1").GetState(); got != StateError {
+		t.Errorf("expected StateError, got %v", got)
+	}
+	if starts != 1 {
+		t.Errorf("expected no retry after a fatal exit, got %d starts", starts)
+	}
+}
+
+func TestSessionManager_NetworkExitRetries(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &exitPipeline{
+			fakePipeline: newFakePipeline("a"),
+			exit:         encoder.ExitStatus{Code: 1, Class: encoder.ErrorNetwork, Error: "Connection reset by peer"},
+		}
+	})
+	sm.SetRetryPolicy(RetryPolicy{BaseDelay: time.Millisecond})
+	go func() {
+		for range messages {
+		}
+	}()
+	retries := make(chan string, DefaultRetryPolicy().Budget)
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		if ev.Type == BusRetry {
+			retries <- ev.Reason
+		}
+	})
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+
+	select {
+	case reason := <-retries:
+		if reason != "premature_end" {
+			t.Errorf("expected premature_end retry, got %q", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a network exit to be retried despite the short play time")
+	}
+	sm.Stop("guild-1")
+}
+
+func TestSessionManager_RetryingEvents(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &exitPipeline{
+			fakePipeline: newFakePipeline("a"),
+			exit:         encoder.ExitStatus{Code: 1, Class: encoder.ErrorNetwork, Error: "Connection reset by peer"},
+		}
+	})
+	sm.SetRetryPolicy(RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond, Budget: 2})
+	events := make(chan RetryingEvent, 4)
+	finished := make(chan Event, 1)
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		switch event := ev.Event.(type) {
+		case RetryingEvent:
+			events <- event
+		case Event:
+			if event.Type == EventFinished {
+				finished <- event
+			}
+		}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case ev := <-events:
+			if ev.Attempt != want || ev.Reason != "premature_end" || ev.DelayMs > 20 {
+				t.Errorf("unexpected retrying event %+v", ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected retrying event %d", want)
+		}
+	}
+	select {
+	case ev := <-finished:
+		diag := ev.Diagnostics
+		if diag == nil || diag.RetryCount != 2 || diag.LastError != "Connection reset by peer" ||
+			diag.LastExitCode == nil || *diag.LastExitCode != 1 {
+			t.Errorf("expected retry diagnostics on the finished event, got %+v", diag)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the session to finish once the retry budget is spent")
+	}
+}
+
+func TestSession_PlayedFromPCMOutput(t *testing.T) {
+	session := &Session{
+		Format:          encoder.FormatPCM,
+		seekOffset:      10,
+		streamStartTime: time.Now().Add(-time.Minute), // Mostly spent paused
+		pcmBytes:        int64(3 * pcmBytesPerSecond),
+	}
+	if got := session.positionLocked(); got != 13 {
+		t.Errorf("expected position 13s from the PCM byte count, got %.2fs", got)
+	}
+}
+
+func TestSessionManager_EncodedDurationDetectsTruncation(t *testing.T) {
+	second := strings.Repeat("\x00", pcmBytesPerSecond)
+	chunks := make([]string, 8)
+	for i := range chunks {
+		chunks[i] = second
+	}
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return newFakePipeline(chunks...)
+	})
+	sm.SetRetryPolicy(RetryPolicy{BaseDelay: time.Millisecond})
+	go func() {
+		for range messages {
+		}
+	}()
+	retries := make(chan string, DefaultRetryPolicy().Budget)
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		if ev.Type == BusRetry {
+			retries <- ev.Reason
+		}
+	})
+
+	// 8s of a 40s track arrive in well under a second of wall-clock time:
+	// enough played audio to retry, and well short of the end
+	sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Duration: 40})
+	select {
+	case reason := <-retries:
+		if reason != "premature_end" {
+			t.Errorf("expected premature_end retry, got %q", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the truncated track to be retried")
+	}
+	sm.Stop("guild-1")
+}
+
+// endPipeline records the end position it was given.
+type endPipeline struct {
+	*fakePipeline
+	endAt chan float64
+}
+
+func (p *endPipeline) SetEndAt(sec float64) { p.endAt <- sec }
+
+func TestSessionManager_PlaysTimeRange(t *testing.T) {
+	second := strings.Repeat("\x00", pcmBytesPerSecond)
+	chunks := make([]string, 8)
+	for i := range chunks {
+		chunks[i] = second
+	}
+	endAt := make(chan float64, 1)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &endPipeline{fakePipeline: newFakePipeline(chunks...), endAt: endAt}
+	})
+	retried := make(chan struct{}, 1)
+	sm.Bus().Subscribe(func(ev BusEvent) {
+		if ev.Type == BusRetry {
+			retried <- struct{}{}
+		}
+	})
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{StartAt: 60, EndAt: 30}); !errors.Is(err, errInvalidRange) {
+		t.Fatalf("expected errInvalidRange, got %v", err)
+	}
+
+	// 8s from 60s reaches end_at 68s of a 300s track: finished, not truncated
+	sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{StartAt: 60, EndAt: 68, Duration: 300})
+	for {
+		msg := nextMessage(t, messages)
+		if msg.event["type"] == "finished" {
+			break
+		}
+	}
+	if got := <-endAt; got != 68 {
+		t.Errorf("expected the pipeline to end at 68s, got %v", got)
+	}
+	select {
+	case <-retried:
+		t.Error("expected no retry for a range that played to end_at")
+	default:
+	}
+}
+
+func TestSessionManager_RepeatedPlayToken(t *testing.T) {
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &holdPipeline{fakePipeline: newFakePipeline()}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+	defer sm.Stop("guild-1")
+
+	opts := PlaybackOptions{Token: "interaction-1"}
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", opts); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	first := sm.Get("guild-1")
+
+	// A retried request leaves the track alone
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", opts); err != nil {
+		t.Fatalf("repeated StartPlayback failed: %v", err)
+	}
+	if sm.Get("guild-1") != first {
+		t.Error("expected the repeated request to keep the playing session")
+	}
+
+	// A new request replaces it
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{Token: "interaction-2"}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	if sm.Get("guild-1") == first {
+		t.Error("expected a new token to restart playback")
+	}
+}
+
+// urlPipeline reports the stream URL each attempt plays.
+type urlPipeline struct {
+	*fakePipeline
+	urls chan string
+}
+
+func (p *urlPipeline) Start(ctx context.Context, streamURL string, format encoder.Format, startAtSec float64) error {
+	p.urls <- streamURL
+	return p.fakePipeline.Start(ctx, streamURL, format, startAtSec)
+}
+
+func TestSessionManager_PreResolvedStreamURL(t *testing.T) {
+	urls := make(chan string, 1)
+	extraction := errors.New("yt-dlp should not run")
+	sm, messages := newTestSessionManager(t, &fakeExtractor{err: extraction}, func(string) encoder.Pipeline {
+		return &urlPipeline{fakePipeline: newFakePipeline("abc"), urls: urls}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	streamURL := fmt.Sprintf("https://rr1.googlevideo.invalid/videoplayback?expire=%d", time.Now().Add(time.Hour).Unix())
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{Duration: 1, StreamURL: streamURL}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	select {
+	case got := <-urls:
+		if got != streamURL {
+			t.Errorf("expected the pre-resolved URL, got %s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected playback without extraction")
+	}
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{StreamURL: "file:///etc/passwd"}); !errors.Is(err, errInvalidStreamURL) {
+		t.Errorf("expected errInvalidStreamURL for a local file, got %v", err)
+	}
+}
+
+func TestUsableStreamURL(t *testing.T) {
+	expired := fmt.Sprintf("https://host.invalid/v?expire=%d", time.Now().Add(10*time.Second).Unix())
+	if got, err := usableStreamURL(expired, time.Time{}); err != nil || got != "" {
+		t.Errorf("expected an expiring URL to be dropped, got %q, %v", got, err)
+	}
+	if got, _ := usableStreamURL("https://host.invalid/v", time.Now().Add(-time.Minute)); got != "" {
+		t.Errorf("expected an explicit past expiry to drop the URL, got %q", got)
+	}
+	if got, _ := usableStreamURL("https://host.invalid/v", time.Time{}); got != "https://host.invalid/v" {
+		t.Errorf("expected a URL without expiry to be kept, got %q", got)
+	}
+}
+
+// proxyPipeline records the proxy the session sets.
+type proxyPipeline struct {
+	*fakePipeline
+	proxy chan string
+}
+
+func (p *proxyPipeline) SetProxy(proxy string) { p.proxy <- proxy }
+
+func TestSessionManager_Region(t *testing.T) {
+	youtube.SetConfig(youtube.Config{
+		Proxy:         "socks5://default:1080",
+		RegionProxies: map[string]string{"JP": "http://jp-proxy:3128"},
+	})
+	defer youtube.SetConfig(youtube.Config{})
+	proxy := make(chan string, 1)
+	sm, _ := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &proxyPipeline{fakePipeline: newFakePipeline(), proxy: proxy}
+	})
+
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Region: "japan"}); !errors.Is(err, errInvalidRegion) {
+		t.Fatalf("expected errInvalidRegion, got %v", err)
+	}
+	if err := sm.StartPlayback("guild-1", "fake://track", "pcm", PlaybackOptions{Region: "jp"}); err != nil {
+		t.Fatalf("StartPlayback failed: %v", err)
+	}
+	select {
+	case got := <-proxy:
+		if got != "http://jp-proxy:3128" {
+			t.Errorf("expected the JP proxy for FFmpeg, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("proxy not set on the pipeline")
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+// maxVolume is the highest settable volume in percent.
+const maxVolume = 200
+
+// maxFadeMs is the longest settable transport fade in milliseconds.
+const maxFadeMs = 2000
+
+// maxCrossfadeMs is the longest settable crossfade in milliseconds.
+const maxCrossfadeMs = 12000
+
+// SessionSettings are the persistent preferences of a session ID (usually a
+// Discord guild). Volume, filters, the crossfade, silence skipping and the
+// download modes are applied on StartPlayback, speed and pitch also to the
+// playing track; autoplay and announcements are stored for the client.
+type SessionSettings struct {
+	Volume              int      `json:"volume"`               // Percent, 0-200
+	FadeMs              int      `json:"fade_ms"`              // Fade on play/seek/resume and pause/stop, 0-2000 (0 = off)
+	CrossfadeMs         int      `json:"crossfade_ms"`         // Overlap when a play replaces a playing track, 0-12000 (0 = off)
+	CrossfadeCurve      string   `json:"crossfade_curve"`      // See encoder.CrossfadeCurves ("" = encoder.DefaultCrossfadeCurve)
+	EQ                  string   `json:"eq"`                   // Equalizer bands, e.g. "60=4,1k=-2"
+	Speed               float64  `json:"speed"`                // Playback speed, pitch preserved, 0.5-2.0 (0 = 1x)
+	Pitch               float64  `json:"pitch"`                // Pitch shift in semitones, tempo preserved, -12..12
+	Effects             []string `json:"effects"`              // Effect names, see encoder.Effects
+	SkipSilence         bool     `json:"skip_silence"`         // Seek past long silent stretches (hidden tracks, dead air)
+	DownloadFirst       bool     `json:"download_first"`       // Download each track before playing it (slower start, immune to stream resets)
+	ProgressiveDownload bool     `json:"progressive_download"` // Download ahead of playback into a spill file and play from it
+	Autoplay            bool     `json:"autoplay"`             // Queue related tracks when the queue runs out
+	Announcements       bool     `json:"announcements"`        // Announce each track as it starts
+}
+
+// DefaultSessionSettings returns the settings of a session ID with none saved.
+func DefaultSessionSettings() SessionSettings {
+	return SessionSettings{
+		Volume:         100,
+		FadeMs:         100,
+		CrossfadeCurve: encoder.DefaultCrossfadeCurve,
+		Speed:          1,
+		Effects:        []string{},
+		Announcements:  true,
+	}
+}
+
+// SettingsPatch is a partial update of SessionSettings; nil fields are
+// left unchanged.
+type SettingsPatch struct {
+	Volume              *int      `json:"volume"`
+	FadeMs              *int      `json:"fade_ms"`
+	CrossfadeMs         *int      `json:"crossfade_ms"`
+	CrossfadeCurve      *string   `json:"crossfade_curve"`
+	EQ                  *string   `json:"eq"`
+	Speed               *float64  `json:"speed"`
+	Pitch               *float64  `json:"pitch"`
+	Effects             *[]string `json:"effects"`
+	SkipSilence         *bool     `json:"skip_silence"`
+	DownloadFirst       *bool     `json:"download_first"`
+	ProgressiveDownload *bool     `json:"progressive_download"`
+	Autoplay            *bool     `json:"autoplay"`
+	Announcements       *bool     `json:"announcements"`
+}
+
+// apply returns settings with the patch applied, or an error if a value
+// is invalid.
+func (p SettingsPatch) apply(settings SessionSettings) (SessionSettings, error) {
+	if p.Volume != nil {
+		if *p.Volume < 0 || *p.Volume > maxVolume {
+			return settings, fmt.Errorf("%w: volume must be between 0 and %d", errInvalidSettings, maxVolume)
+		}
+		settings.Volume = *p.Volume
+	}
+	if p.FadeMs != nil {
+		if *p.FadeMs < 0 || *p.FadeMs > maxFadeMs {
+			return settings, fmt.Errorf("%w: fade_ms must be between 0 and %d", errInvalidSettings, maxFadeMs)
+		}
+		settings.FadeMs = *p.FadeMs
+	}
+	if p.CrossfadeMs != nil {
+		if *p.CrossfadeMs < 0 || *p.CrossfadeMs > maxCrossfadeMs {
+			return settings, fmt.Errorf("%w: crossfade_ms must be between 0 and %d", errInvalidSettings, maxCrossfadeMs)
+		}
+		settings.CrossfadeMs = *p.CrossfadeMs
+	}
+	if p.CrossfadeCurve != nil {
+		if !slices.Contains(encoder.CrossfadeCurves, *p.CrossfadeCurve) {
+			return settings, fmt.Errorf("%w: crossfade_curve must be one of %s", errInvalidSettings, strings.Join(encoder.CrossfadeCurves, ", "))
+		}
+		settings.CrossfadeCurve = *p.CrossfadeCurve
+	}
+	if p.Speed != nil {
+		if *p.Speed < encoder.MinSpeed || *p.Speed > encoder.MaxSpeed {
+			return settings, fmt.Errorf("%w: speed must be between %g and %g", errInvalidSettings, encoder.MinSpeed, encoder.MaxSpeed)
+		}
+		settings.Speed = *p.Speed
+	}
+	if p.Pitch != nil {
+		if math.Abs(*p.Pitch) > encoder.MaxPitch {
+			return settings, fmt.Errorf("%w: pitch must be between -%d and %d semitones", errInvalidSettings, encoder.MaxPitch, encoder.MaxPitch)
+		}
+		settings.Pitch = *p.Pitch
+	}
+	if p.EQ != nil {
+		settings.EQ = *p.EQ
+	}
+	if p.Effects != nil {
+		settings.Effects = append([]string{}, *p.Effects...)
+	}
+	if p.SkipSilence != nil {
+		settings.SkipSilence = *p.SkipSilence
+	}
+	if p.DownloadFirst != nil {
+		settings.DownloadFirst = *p.DownloadFirst
+	}
+	if p.ProgressiveDownload != nil {
+		settings.ProgressiveDownload = *p.ProgressiveDownload
+	}
+	if p.Autoplay != nil {
+		settings.Autoplay = *p.Autoplay
+	}
+	if p.Announcements != nil {
+		settings.Announcements = *p.Announcements
+	}
+	if _, err := settings.filter(); err != nil {
+		return settings, fmt.Errorf("%w: %v", errInvalidSettings, err)
+	}
+	return settings, nil
+}
+
+// fade returns the transport fade duration.
+func (s SessionSettings) fade() time.Duration {
+	return time.Duration(s.FadeMs) * time.Millisecond
+}
+
+// crossfade returns the crossfade length.
+func (s SessionSettings) crossfade() time.Duration {
+	return time.Duration(s.CrossfadeMs) * time.Millisecond
+}
+
+// speed returns the playback speed (settings saved before it existed have 0).
+func (s SessionSettings) speed() float64 {
+	if s.Speed == 0 {
+		return 1
+	}
+	return s.Speed
+}
+
+// filter returns the FFmpeg filter chain for the EQ and effects.
+func (s SessionSettings) filter() (string, error) {
+	return encoder.FilterChain(s.EQ, s.Effects, encoder.DefaultConfig().SampleRate)
+}
+
+// SettingsStore keeps SessionSettings per session ID, optionally persisted
+// to a JSON file.
+type SettingsStore struct {
+	path string // Empty keeps settings in memory only
+
+	mu       sync.Mutex
+	settings map[string]SessionSettings
+}
+
+// NewSettingsStore creates an in-memory store.
+func NewSettingsStore() *SettingsStore {
+	return &SettingsStore{settings: make(map[string]SessionSettings)}
+}
+
+// OpenSettingsStore loads the store at path. A missing file yields an
+// empty store; it is created on the first Update.
+func OpenSettingsStore(path string) (*SettingsStore, error) {
+	s := NewSettingsStore()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.settings); err != nil {
+		return nil, fmt.Errorf("parse settings %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the settings for id (the defaults if none are saved).
+func (s *SettingsStore) Get(id string) SessionSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings, ok := s.settings[id]; ok {
+		return settings
+	}
+	return DefaultSessionSettings()
+}
+
+// Update applies patch to the settings for id and writes the store.
+func (s *SettingsStore) Update(id string, patch SettingsPatch) (SessionSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.settings[id]
+	if !ok {
+		current = DefaultSessionSettings()
+	}
+	updated, err := patch.apply(current)
+	if err != nil {
+		return current, err
+	}
+	s.settings[id] = updated
+	return updated, s.writeLocked()
+}
+
+// writeLocked writes the store atomically (temp file + rename).
+func (s *SettingsStore) writeLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("write settings: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write settings: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestSettingsStore_UpdatePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	store, err := OpenSettingsStore(path)
+	if err != nil {
+		t.Fatalf("OpenSettingsStore failed: %v", err)
+	}
+
+	if got := store.Get("guild-1"); got.Volume != 100 || !got.Announcements {
+		t.Errorf("expected defaults, got %+v", got)
+	}
+
+	effects := []string{"bassboost"}
+	if _, err := store.Update("guild-1", SettingsPatch{Volume: intPtr(60), Effects: &effects}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	reopened, err := OpenSettingsStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	got := reopened.Get("guild-1")
+	if got.Volume != 60 || len(got.Effects) != 1 || !got.Announcements {
+		t.Errorf("unexpected settings after reopen %+v", got)
+	}
+}
+
+func TestSettingsStore_RejectsInvalidValues(t *testing.T) {
+	store := NewSettingsStore()
+
+	if _, err := store.Update("guild-1", SettingsPatch{Volume: intPtr(500)}); !errors.Is(err, errInvalidSettings) {
+		t.Errorf("expected invalid volume error, got %v", err)
+	}
+	effects := []string{"chipmunk"}
+	if _, err := store.Update("guild-1", SettingsPatch{Effects: &effects}); !errors.Is(err, errInvalidSettings) {
+		t.Errorf("expected invalid effect error, got %v", err)
+	}
+	if _, err := store.Update("guild-1", SettingsPatch{FadeMs: intPtr(5000)}); !errors.Is(err, errInvalidSettings) {
+		t.Errorf("expected invalid fade error, got %v", err)
+	}
+	speed := 4.0
+	if _, err := store.Update("guild-1", SettingsPatch{Speed: &speed}); !errors.Is(err, errInvalidSettings) {
+		t.Errorf("expected invalid speed error, got %v", err)
+	}
+	pitch := -13.0
+	if _, err := store.Update("guild-1", SettingsPatch{Pitch: &pitch}); !errors.Is(err, errInvalidSettings) {
+		t.Errorf("expected invalid pitch error, got %v", err)
+	}
+	if got := store.Get("guild-1"); got.Volume != 100 {
+		t.Errorf("invalid updates should not be saved, got %+v", got)
+	}
+}
+
+func TestSettingsEndpoints(t *testing.T) {
+	api := NewAPI(NewSessionManager(t.Context()))
+	router := SetupRouter(api)
+
+	req, _ := http.NewRequest("PATCH", "/session/guild-1/settings", strings.NewReader(`{"volume": 40, "autoplay": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/session/guild-1/settings", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp SettingsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Settings == nil || resp.Settings.Volume != 40 || !resp.Settings.Autoplay {
+		t.Errorf("unexpected settings %+v", resp.Settings)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/session/guild-1/settings", strings.NewReader(`{"eq": "60=99"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid EQ, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/filters", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var filters FiltersResponse
+	json.Unmarshal(w.Body.Bytes(), &filters)
+	if !slices.Contains(filters.Effects, FilterInfo{Name: "karaoke", Description: "Remove center-panned vocals"}) {
+		t.Errorf("expected karaoke in the filters, got %+v", filters.Effects)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/session/guild-1/filters", strings.NewReader(`{"speed": 1.5, "effects": ["karaoke"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp = SettingsResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Settings == nil || resp.Settings.Speed != 1.5 || resp.Settings.Volume != 40 {
+		t.Errorf("unexpected filters update %d: %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("PATCH", "/session/guild-1/filters", strings.NewReader(`{"speed": 3}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid speed, got %d", w.Code)
+	}
+}
+
+// filterPipeline records the filter it was given.
+type filterPipeline struct {
+	*fakePipeline
+	filters chan string
+}
+
+func (p *filterPipeline) SetFilter(volume float64, filter string) {
+	p.filters <- fmt.Sprintf("%s @ %g", filter, volume)
+}
+
+func TestSessionManager_AppliesSettings(t *testing.T) {
+	filters := make(chan string, 1)
+	sm, messages := newTestSessionManager(t, &fakeExtractor{}, func(string) encoder.Pipeline {
+		return &filterPipeline{fakePipeline: newFakePipeline(), filters: filters}
+	})
+	go func() {
+		for range messages {
+		}
+	}()
+
+	effects := []string{"bassboost"}
+	sm.Settings().Update("guild-1", SettingsPatch{Volume: intPtr(50), Effects: &effects})
+	sm.StartPlayback("guild-1", "fake://track", "opus", PlaybackOptions{})
+
+	select {
+	case got := <-filters:
+		if got != "bass=g=8:f=100 @ 0.5" {
+			t.Errorf("unexpected filter %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline filter was not set")
+	}
+}
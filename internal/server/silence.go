@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/execx"
+)
+
+// Silence skipping (the skip_silence setting)
+const (
+	silenceNoiseDB       = -50.0                  // Quieter than this counts as silence, in dBFS
+	minSkippedSilence    = 5 * time.Second        // Shorter gaps (between songs, pauses) are left alone
+	silenceCheckInterval = 250 * time.Millisecond // How often the position is checked against found stretches
+	silenceSkipSlack     = 1.0                    // Seconds; a stretch this close to its end plays out
+)
+
+// SilenceDetector finds silent stretches of streamURL, calling found for
+// each as it is detected. It blocks until the whole track is analysed.
+type SilenceDetector func(ctx context.Context, streamURL string, found func(encoder.Silence)) error
+
+// detectSilence is the default SilenceDetector, an FFmpeg silencedetect pass.
+func detectSilence(ctx context.Context, streamURL string, found func(encoder.Silence)) error {
+	return encoder.DetectSilence(ctx, execx.Default, streamURL, silenceNoiseDB, minSkippedSilence, found)
+}
+
+// SetSilenceDetector replaces how silent stretches are found for sessions
+// with skip_silence enabled.
+func (m *SessionManager) SetSilenceDetector(detector SilenceDetector) {
+	m.silence = detector
+}
+
+// SilenceSkippedEvent reports that playback jumped over a silent stretch.
+type SilenceSkippedEvent struct {
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	Start     float64   `json:"start"` // Where the silence starts, in seconds
+	End       float64   `json:"end"`   // Where playback continues, in seconds
+}
+
+// NewSilenceSkippedEvent creates a silence_skipped event.
+func NewSilenceSkippedEvent(sessionID string, silence encoder.Silence) SilenceSkippedEvent {
+	return SilenceSkippedEvent{
+		Type:      EventSilenceSkipped,
+		SessionID: sessionID,
+		Start:     silence.Start,
+		End:       silence.End,
+	}
+}
+
+// skipSilences analyses session's track in the background and seeks past
+// each long silent stretch playback enters. Stretches are found ahead of
+// playback, so a seek back into one skips it again. It returns when the
+// session stops or ends.
+func (m *SessionManager) skipSilences(session *Session, streamURL string) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	found := make(chan encoder.Silence)
+	go func() {
+		err := m.silence(ctx, streamURL, func(silence encoder.Silence) {
+			select {
+			case found <- silence:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Warnf("[Session] Silence detection for %s failed: %v", shortSessionID(session.ID), err)
+		}
+	}()
+
+	var silences []encoder.Silence
+	skipEpoch, skippedTo := -1, 0.0 // Last skip, until its pipeline has started
+	ticker := time.NewTicker(silenceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case silence := <-found:
+			silences = append(silences, silence)
+			continue
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		session.mu.Lock()
+		if session.isStopped || session.State == StateStopped || session.State == StateError {
+			session.mu.Unlock()
+			return
+		}
+		if session.State != StateStreaming || session.isPaused ||
+			(session.restartEpoch == skipEpoch && session.seekOffset != skippedTo) {
+			session.mu.Unlock()
+			continue
+		}
+		position := session.positionLocked()
+		skipped := -1
+		for i, silence := range silences {
+			if position >= silence.Start && position < silence.End-silenceSkipSlack {
+				skipped = i
+				break
+			}
+		}
+		if skipped < 0 {
+			session.mu.Unlock()
+			continue
+		}
+		silence := silences[skipped]
+		logger.Infof("[Session] Skipping silence in %s from %.1fs to %.1fs", shortSessionID(session.ID), position, silence.End)
+		session.reuseStreamURL = session.streamURL != ""
+		m.restartLocked(session, silence.End)
+		skipEpoch, skippedTo = session.restartEpoch, silence.End
+		session.mu.Unlock()
+		m.sendJSON(session.ID, NewSilenceSkippedEvent(session.ID, silence))
+	}
+}
@@ -2,21 +2,34 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 )
 
-const DefaultSocketPath = "/tmp/music-playground.sock"
+// DefaultSocketPath is where the audio socket listens unless configured:
+// /tmp on Unix, where the Node.js client looks by default, and the temp
+// directory on Windows (AF_UNIX sockets need Windows 10 1803 or later).
+var DefaultSocketPath = defaultSocketPath()
 
-// SocketServer is the Unix socket server for audio streaming.
-// It only handles audio output - control is done via HTTP API.
+func defaultSocketPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.TempDir(), "music-playground.sock")
+	}
+	return "/tmp/music-playground.sock"
+}
+
+// SocketServer serves the audio protocol to clients of a Transport (a Unix
+// socket unless configured). Clients may also send control commands on it
+// (see Command) instead of using the HTTP API.
 type SocketServer struct {
-	socketPath string
-	listener   net.Listener
-	sessions   *SessionManager
-	wg         sync.WaitGroup
+	transport Transport
+	listener  net.Listener
+	sessions  *SessionManager
+	wg        sync.WaitGroup
 }
 
 // NewSocketServer creates a new Unix socket server.
@@ -24,24 +37,34 @@ func NewSocketServer(socketPath string, sessions *SessionManager) *SocketServer
 	if socketPath == "" {
 		socketPath = DefaultSocketPath
 	}
+	return NewTransportServer(&UnixTransport{Path: socketPath}, sessions)
+}
+
+// NewTransportServer creates a server for clients of transport.
+func NewTransportServer(transport Transport, sessions *SessionManager) *SocketServer {
 	return &SocketServer{
-		socketPath: socketPath,
-		sessions:   sessions,
+		transport: transport,
+		sessions:  sessions,
+	}
+}
+
+// SetAccess sets the socket file's mode and owner and the peers allowed to
+// connect. It only applies to Unix sockets. Call before Start.
+func (s *SocketServer) SetAccess(access SocketAccess) {
+	if unix, ok := s.transport.(*UnixTransport); ok {
+		unix.Access = access
 	}
 }
 
 // Start starts the server and listens for connections.
 func (s *SocketServer) Start(ctx context.Context) error {
-	// Remove existing socket file if any
-	os.Remove(s.socketPath)
-
 	var err error
-	s.listener, err = net.Listen("unix", s.socketPath)
+	s.listener, err = s.transport.Listen()
 	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %w", s.socketPath, err)
+		return err
 	}
 
-	fmt.Printf("[Socket] Listening on %s\n", s.socketPath)
+	logger.Infof("[Socket] Listening on %s", s.transport.Addr())
 
 	// Accept connections in background
 	go s.acceptLoop(ctx)
@@ -62,33 +85,45 @@ func (s *SocketServer) acceptLoop(ctx context.Context) {
 				case <-ctx.Done():
 					return
 				default:
-					fmt.Printf("[Socket] Accept failed: %v\n", err)
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
+					logger.Errorf("[Socket] Accept failed: %v", err)
 					continue
 				}
 			}
 
-			fmt.Println("[Socket] Client connected")
+			logger.Infof("[Socket] Client connected")
 			s.wg.Add(1)
 			go func() {
 				defer s.wg.Done()
 				s.handleConnection(ctx, conn)
-				fmt.Println("[Socket] Client disconnected")
+				logger.Infof("[Socket] Client disconnected")
 			}()
 		}
 	}
 }
 
-// handleConnection handles a single client connection.
-// The connection is used for receiving audio data from sessions.
+// handleConnection handles a single client connection: it receives audio
+// and events from sessions and may send control commands back.
 func (s *SocketServer) handleConnection(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
 	// Register this connection with session manager
-	s.sessions.SetConnection(conn)
-	defer s.sessions.SetConnection(nil)
-
-	// Keep connection alive until context is cancelled or connection closes
-	<-ctx.Done()
+	s.sessions.AddConnection(conn)
+	defer s.sessions.RemoveConnection(conn)
+
+	// Read commands until the client disconnects or the context is cancelled
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.sessions.readCommands(conn)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
 }
 
 // Stop stops the server and waits for all connections to close.
@@ -97,11 +132,11 @@ func (s *SocketServer) Stop() {
 		s.listener.Close()
 	}
 	s.wg.Wait()
-	os.Remove(s.socketPath)
-	fmt.Println("[Socket] Server stopped")
+	logger.Infof("[Socket] Server stopped")
 }
 
-// SocketPath returns the socket path.
+// SocketPath returns where clients connect: the socket path for Unix
+// sockets.
 func (s *SocketServer) SocketPath() string {
-	return s.socketPath
+	return s.transport.Addr()
 }
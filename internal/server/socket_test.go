@@ -90,7 +90,7 @@ func TestSocketServer_AcceptConnection(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Verify connection was registered
-	if sessions.GetConnection() == nil {
+	if sessions.ConnectionCount() != 1 {
 		t.Error("expected connection to be registered with session manager")
 	}
 
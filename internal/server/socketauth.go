@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+)
+
+// SocketAccess restricts who may use the audio socket. Without it, any
+// local user who can reach the socket file receives every session's audio.
+type SocketAccess struct {
+	Mode      os.FileMode `yaml:"mode"`       // Socket file permissions, e.g. 0660 (0 keeps the umask default)
+	Owner     string      `yaml:"owner"`      // User owning the socket file, by name or UID (empty keeps the server's)
+	Group     string      `yaml:"group"`      // Group owning the socket file, by name or GID (empty keeps the server's)
+	AllowUIDs []int       `yaml:"allow_uids"` // Peer UIDs that may connect
+	AllowGIDs []int       `yaml:"allow_gids"` // Peer GIDs that may connect
+}
+
+// checksPeers reports whether connecting peers are authenticated: only
+// when some UIDs or GIDs are allowed.
+func (a SocketAccess) checksPeers() bool {
+	return len(a.AllowUIDs) > 0 || len(a.AllowGIDs) > 0
+}
+
+// apply sets the mode and owner of the socket file at path.
+func (a SocketAccess) apply(path string) error {
+	if a.Owner != "" || a.Group != "" {
+		uid, gid := -1, -1 // Unchanged
+		var err error
+		if a.Owner != "" {
+			if uid, err = lookupID(a.Owner, func(name string) (string, error) {
+				u, err := user.Lookup(name)
+				if err != nil {
+					return "", err
+				}
+				return u.Uid, nil
+			}); err != nil {
+				return fmt.Errorf("socket owner: %w", err)
+			}
+		}
+		if a.Group != "" {
+			if gid, err = lookupID(a.Group, func(name string) (string, error) {
+				g, err := user.LookupGroup(name)
+				if err != nil {
+					return "", err
+				}
+				return g.Gid, nil
+			}); err != nil {
+				return fmt.Errorf("socket group: %w", err)
+			}
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("chown socket: %w", err)
+		}
+	}
+	if a.Mode != 0 {
+		if err := os.Chmod(path, a.Mode); err != nil {
+			return fmt.Errorf("chmod socket: %w", err)
+		}
+	}
+	return nil
+}
+
+// lookupID resolves a user or group given by numeric ID or by name.
+func lookupID(value string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	id, err := lookup(value)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// authorize reports whether the peer on conn may use the socket. Peers are
+// let in if their UID or GID is allowed; if their credentials can't be read
+// they are refused.
+func (a SocketAccess) authorize(conn net.Conn) error {
+	if !a.checksPeers() {
+		return nil
+	}
+	uid, gid, err := peerCredentials(conn)
+	if err != nil {
+		return fmt.Errorf("read peer credentials: %w", err)
+	}
+	if !slices.Contains(a.AllowUIDs, uid) && !slices.Contains(a.AllowGIDs, gid) {
+		return fmt.Errorf("peer uid %d gid %d is not allowed", uid, gid)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLookupID(t *testing.T) {
+	lookup := func(name string) (string, error) {
+		if name == "audio" {
+			return "29", nil
+		}
+		return "", errors.New("unknown")
+	}
+	if id, err := lookupID("1000", lookup); err != nil || id != 1000 {
+		t.Errorf("expected numeric ID 1000, got %d, %v", id, err)
+	}
+	if id, err := lookupID("audio", lookup); err != nil || id != 29 {
+		t.Errorf("expected audio to resolve to 29, got %d, %v", id, err)
+	}
+	if _, err := lookupID("nobody-here", lookup); err == nil {
+		t.Error("expected error for unknown name")
+	}
+}
+
+func TestSocketServer_Access(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only read on Linux")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessions := NewSessionManager(ctx)
+
+	socketPath := filepath.Join(t.TempDir(), "access.sock")
+	server := NewSocketServer(socketPath, sessions)
+	server.SetAccess(SocketAccess{Mode: 0o600, Owner: strconv.Itoa(os.Getuid()), AllowUIDs: []int{os.Getuid() + 1}})
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Stop()
+	defer cancel()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected mode 0600, got %o", perm)
+	}
+	// Created in a private directory, then moved into place
+	if entries, _ := os.ReadDir(filepath.Dir(socketPath)); len(entries) != 1 {
+		t.Errorf("expected only the socket left in its directory, found %d entries", len(entries))
+	}
+
+	// This process's UID is not allowed: the server hangs up
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected the refused connection to be closed, got %v", err)
+	}
+	if n := sessions.ConnectionCount(); n != 0 {
+		t.Errorf("expected no registered connections, got %d", n)
+	}
+
+	// Its GID is
+	allowedPath := filepath.Join(t.TempDir(), "allowed.sock")
+	allowedServer := NewSocketServer(allowedPath, sessions)
+	allowedServer.SetAccess(SocketAccess{AllowUIDs: []int{os.Getuid() + 1}, AllowGIDs: []int{os.Getgid()}})
+	if err := allowedServer.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer allowedServer.Stop()
+	allowed, err := net.DialTimeout("unix", allowedPath, time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer allowed.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for sessions.ConnectionCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sessions.ConnectionCount() != 1 {
+		t.Error("expected the allowed peer to be registered")
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+)
+
+// Thumbnail limits
+const (
+	thumbnailBase     = "https://i.ytimg.com/vi/" // Followed by <video ID>/hqdefault.jpg
+	maxThumbnailBytes = 2 << 20
+)
+
+// errInvalidThumbnail is returned for a thumbnail request without a
+// YouTube video.
+var errInvalidThumbnail = errors.New("url must be a YouTube video URL or ID")
+
+// Thumbnail handles GET /thumbnail?url=, serving the video's thumbnail from
+// the disk cache ("thumb:<video ID>"), fetched from YouTube on a miss.
+// Without a cache it redirects to YouTube.
+func (a *API) Thumbnail(c *gin.Context) {
+	id := youtube.VideoID(c.Query("url"))
+	if id == "" {
+		c.JSON(httpStatus(errInvalidThumbnail), gin.H{"error": errInvalidThumbnail.Error()})
+		return
+	}
+	upstream := a.thumbnailBase + id + "/hqdefault.jpg"
+	store := a.sessions.Cache()
+	if store == nil {
+		c.Redirect(http.StatusTemporaryRedirect, upstream)
+		return
+	}
+
+	key := "thumb:" + id
+	path, release, ok := store.Get(key)
+	if !ok {
+		tmp, err := fetchThumbnail(c, upstream, store.Dir())
+		if err != nil {
+			logger.Warnf("[API] Thumbnail %s: %v", id, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		if path, release, err = store.Add(key, tmp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	defer release()
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(path)
+}
+
+// fetchThumbnail downloads the image at url into a new file in dir and
+// returns its path.
+func fetchThumbnail(c *gin.Context, url, dir string) (string, error) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch thumbnail: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch thumbnail: HTTP %s", resp.Status)
+	}
+
+	file, err := os.CreateTemp(dir, "natashi-thumb-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(file, io.LimitReader(resp.Body, maxThumbnailBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxThumbnailBytes {
+		err = fmt.Errorf("thumbnail larger than %d bytes", maxThumbnailBytes)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig serves the HTTP API over HTTPS, with a certificate from files or
+// from Let's Encrypt (autocert). The zero value serves plain HTTP.
+type TLSConfig struct {
+	CertFile string   `yaml:"cert"`      // PEM certificate chain (with KeyFile)
+	KeyFile  string   `yaml:"key"`       // PEM private key
+	Autocert []string `yaml:"autocert"`  // Hostnames to obtain certificates for instead of CertFile/KeyFile
+	CacheDir string   `yaml:"cache_dir"` // Where autocert keeps certificates and the account key
+	Email    string   `yaml:"email"`     // Let's Encrypt account contact (optional)
+	Redirect string   `yaml:"redirect"`  // Plain HTTP address redirecting to HTTPS, e.g. ":80" (autocert answers HTTP-01 challenges there)
+}
+
+// Enabled reports whether the API is served over HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.Autocert) > 0
+}
+
+// Validate checks that exactly one certificate source is configured.
+func (c TLSConfig) Validate() error {
+	if !c.Enabled() {
+		if c.Redirect != "" {
+			return errors.New("tls: redirect needs a certificate (cert/key or autocert)")
+		}
+		return nil
+	}
+	if len(c.Autocert) > 0 {
+		if c.CertFile != "" || c.KeyFile != "" {
+			return errors.New("tls: use either cert/key or autocert, not both")
+		}
+		if c.CacheDir == "" {
+			return errors.New("tls: autocert needs a cache_dir")
+		}
+		return nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("tls: cert and key must be given together")
+	}
+	return nil
+}
+
+// load returns the server TLS configuration and the handler for the
+// redirect address: an HTTPS redirect that, with autocert, also answers
+// ACME challenges. httpsAddr is where the API listens.
+func (c TLSConfig) load(httpsAddr string) (*tls.Config, http.Handler, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	redirect := httpsRedirect(httpsAddr)
+	if len(c.Autocert) == 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: load certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, redirect, nil
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Autocert...),
+		Cache:      autocert.DirCache(c.CacheDir),
+		Email:      c.Email,
+	}
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config, manager.HTTPHandler(redirect), nil
+}
+
+// httpsRedirect redirects requests to the same host and path on the HTTPS
+// port of httpsAddr.
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host // No port
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// apiURL returns the URL the API at addr is reachable on locally, for logs.
+func apiURL(addr string, tls bool) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return scheme + "://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for localhost and returns
+// the certificate and key paths.
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config TLSConfig
+		valid  bool
+	}{
+		{TLSConfig{}, true},
+		{TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", Redirect: ":80"}, true},
+		{TLSConfig{Autocert: []string{"music.example.com"}, CacheDir: "/var/cache/autocert"}, true},
+		{TLSConfig{CertFile: "cert.pem"}, false},
+		{TLSConfig{Redirect: ":80"}, false},
+		{TLSConfig{Autocert: []string{"music.example.com"}}, false},
+		{TLSConfig{Autocert: []string{"music.example.com"}, CacheDir: "/tmp", CertFile: "cert.pem", KeyFile: "key.pem"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.config, err, tt.valid)
+		}
+	}
+}
+
+func TestTLSConfig_Load(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	config, _, err := TLSConfig{CertFile: certFile, KeyFile: keyFile}.load(":8443")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(config.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, _, err := (TLSConfig{CertFile: certFile, KeyFile: certFile}).load(":8443"); err == nil {
+		t.Error("expected error for a certificate used as the key")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		httpsAddr, host, want string
+	}{
+		{":8443", "music.example.com", "https://music.example.com:8443/health?x=1"},
+		{":443", "music.example.com:80", "https://music.example.com/health?x=1"},
+		{"0.0.0.0:8443", "[::1]:8080", "https://[::1]:8443/health?x=1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/health?x=1", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		httpsRedirect(tt.httpsAddr).ServeHTTP(w, req)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.want {
+			t.Errorf("redirect %s via %s = %d %q, want %q", tt.host, tt.httpsAddr, w.Code, w.Header().Get("Location"), tt.want)
+		}
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	if got := apiURL(":8180", false); got != "http://localhost:8180" {
+		t.Errorf("got %q", got)
+	}
+	if got := apiURL("10.0.0.5:8443", true); got != "https://10.0.0.5:8443" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Transport carries the audio protocol (audio packets, JSON events and
+// commands) between the server and its clients. The protocol is the same
+// on every transport; only how clients connect differs.
+type Transport interface {
+	// Listen starts listening. Clients are taken from the listener's Accept;
+	// closing it stops the transport.
+	Listen() (net.Listener, error)
+
+	// Addr describes where clients connect, for logs.
+	Addr() string
+}
+
+// UnixTransport listens on a Unix socket, restricted by Access.
+type UnixTransport struct {
+	Path   string
+	Access SocketAccess
+}
+
+// Listen replaces any stale socket file at Path and listens on it. The
+// socket is created in a private directory and moved to Path once Access
+// is applied, so no one can connect while it has the umask's permissions.
+func (t *UnixTransport) Listen() (net.Listener, error) {
+	if t.Access.checksPeers() && runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("socket peer authentication is not supported on %s", runtime.GOOS)
+	}
+
+	// Next to Path, so the socket can be renamed into place
+	dir, err := os.MkdirTemp(filepath.Dir(t.Path), ".natashi-socket-*") // Mode 0700
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", t.Path, err)
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "socket")
+
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", t.Path, err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false) // Close removes Path instead
+	if err := t.Access.apply(private); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// Replaces any stale socket file
+	if err := os.Rename(private, t.Path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", t.Path, err)
+	}
+	return &unixListener{Listener: listener, transport: t}, nil
+}
+
+// Addr returns the socket path.
+func (t *UnixTransport) Addr() string {
+	return t.Path
+}
+
+// unixListener refuses peers Access doesn't allow and removes the socket
+// file on Close.
+type unixListener struct {
+	net.Listener
+	transport *UnixTransport
+}
+
+func (l *unixListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := l.transport.Access.authorize(conn); err != nil {
+			logger.Warnf("[Socket] Refused client: %v", err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.transport.Path)
+	return err
+}
+
+// TCPTransport listens on a TCP address, for clients on other hosts or
+// containers. It has no authentication: bind it to a private interface.
+type TCPTransport struct {
+	Address string // e.g. "127.0.0.1:8181"
+}
+
+// Listen listens on Address.
+func (t *TCPTransport) Listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", t.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", t.Address, err)
+	}
+	return listener, nil
+}
+
+// Addr returns the TCP address.
+func (t *TCPTransport) Addr() string {
+	return t.Address
+}
+
+// InProcessTransport connects clients in the same process over in-memory
+// pipes, so a Go program can embed the server without any OS socket. Get
+// a connection with Dial and read it with a Client.
+type InProcessTransport struct {
+	mu       sync.Mutex
+	listener *pipeListener
+}
+
+// NewInProcessTransport creates an in-process transport.
+func NewInProcessTransport() *InProcessTransport {
+	return &InProcessTransport{}
+}
+
+// Listen starts accepting Dial calls.
+func (t *InProcessTransport) Listen() (net.Listener, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listener = &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+	return t.listener, nil
+}
+
+// Addr returns "in-process".
+func (t *InProcessTransport) Addr() string {
+	return "in-process"
+}
+
+// Dial connects a new client. It fails unless the transport is listening.
+func (t *InProcessTransport) Dial() (net.Conn, error) {
+	t.mu.Lock()
+	listener := t.listener
+	t.mu.Unlock()
+	if listener == nil {
+		return nil, errNoConnection
+	}
+
+	serverConn, clientConn := net.Pipe()
+	select {
+	case listener.conns <- serverConn:
+		return clientConn, nil
+	case <-listener.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// pipeListener hands the server ends of Dial's pipes to Accept.
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// pipeAddr is the net.Addr of in-process connections.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "in-process" }
+
+// StdioTransport serves one client over the process's stdin and stdout,
+// for bots that spawn the server as a child process: no socket file, no
+// port. Nothing else may write to stdout. When stdin closes, Done is
+// closed so the process can exit with its parent.
+type StdioTransport struct {
+	conn *stdioConn
+}
+
+// NewStdioTransport creates a transport reading in and writing out,
+// normally the process's stdin and stdout.
+func NewStdioTransport(in io.Reader, out io.Writer) *StdioTransport {
+	return &StdioTransport{conn: &stdioConn{in: in, out: out, done: make(chan struct{})}}
+}
+
+// Listen returns a listener that accepts the stdio connection once.
+func (t *StdioTransport) Listen() (net.Listener, error) {
+	return &stdioListener{conn: t.conn, done: make(chan struct{})}, nil
+}
+
+// Addr returns "stdio".
+func (t *StdioTransport) Addr() string {
+	return "stdio"
+}
+
+// Done is closed once the client has gone: stdin reached its end or the
+// connection was closed.
+func (t *StdioTransport) Done() <-chan struct{} {
+	return t.conn.done
+}
+
+// stdioListener accepts the stdio connection, then blocks until closed.
+type stdioListener struct {
+	conn      *stdioConn
+	accepted  bool
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *stdioListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	first := !l.accepted
+	l.accepted = true
+	l.mu.Unlock()
+	if first {
+		return l.conn, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *stdioListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *stdioListener) Addr() net.Addr {
+	return stdioAddr{}
+}
+
+// stdioConn is a net.Conn reading stdin and writing stdout. Deadlines are
+// not supported.
+type stdioConn struct {
+	in        io.Reader
+	out       io.Writer
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *stdioConn) Read(p []byte) (int, error) {
+	n, err := c.in.Read(p)
+	if err != nil {
+		c.Close() // Parent closed stdin
+	}
+	return n, err
+}
+
+func (c *stdioConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.out.Write(p)
+}
+
+func (c *stdioConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *stdioConn) LocalAddr() net.Addr                { return stdioAddr{} }
+func (c *stdioConn) RemoteAddr() net.Addr               { return stdioAddr{} }
+func (c *stdioConn) SetDeadline(t time.Time) error      { return nil }
+func (c *stdioConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *stdioConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// stdioAddr is the net.Addr of the stdio connection.
+type stdioAddr struct{}
+
+func (stdioAddr) Network() string { return "stdio" }
+func (stdioAddr) String() string  { return "stdio" }
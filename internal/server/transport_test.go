@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/platform"
+)
+
+func TestClient_ReadFrame(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	client := NewClient(clientConn)
+	defer client.Close()
+
+	go func() {
+		serverConn.Write([]byte("{\"type\":\"ready\",\"session_id\":\"guild-1\"}\n"))
+		serverConn.Write(framePacket("guild-1", []byte("opus")))
+	}()
+
+	frame, err := client.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	if string(frame.Event) != `{"type":"ready","session_id":"guild-1"}` || frame.Audio != nil {
+		t.Errorf("expected the ready event, got %+v", frame)
+	}
+	frame, err = client.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	if frame.SessionID != "guild-1" || string(frame.Audio) != "opus" || frame.Event != nil {
+		t.Errorf("expected guild-1 audio, got %+v", frame)
+	}
+}
+
+func TestInProcessTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessions := NewSessionManager(ctx)
+	registry := platform.NewRegistry()
+	registry.Register(&fakeExtractor{})
+	sessions.SetRegistry(registry)
+	sessions.SetPipelineFactory(func(string) encoder.Pipeline {
+		return newFakePipeline("abc")
+	})
+
+	transport := NewInProcessTransport()
+	if _, err := transport.Dial(); err == nil {
+		t.Error("expected Dial to fail before the server listens")
+	}
+	server := NewTransportServer(transport, sessions)
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Stop()
+	defer cancel()
+
+	conn, err := transport.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	client := NewClient(conn)
+	defer client.Close()
+	if err := client.Send(Command{Type: CommandPlay, ID: "1", SessionID: "guild-1", URL: "fake://track"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var events []string
+	var audio string
+	frames := client.Frames()
+	timeout := time.After(2 * time.Second)
+	for audio == "" || len(events) < 3 {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				t.Fatalf("connection closed: %v", client.Err())
+			}
+			if frame.Audio != nil {
+				audio += string(frame.Audio)
+				continue
+			}
+			var ev Event
+			json.Unmarshal(frame.Event, &ev)
+			events = append(events, string(ev.Type))
+		case <-timeout:
+			t.Fatalf("timed out; events %v, audio %q", events, audio)
+		}
+	}
+	if audio != "abc" {
+		t.Errorf("expected the track's audio, got %q", audio)
+	}
+	for _, want := range []string{"ack", "ready", "finished"} {
+		if !slices.Contains(events, want) {
+			t.Errorf("expected a %s event, got %v", want, events)
+		}
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	transport := &TCPTransport{Address: "127.0.0.1:0"}
+	listener, err := transport.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestStdioTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessions := NewSessionManager(ctx)
+
+	stdin, parentIn := io.Pipe()
+	parentOut, stdout := io.Pipe()
+	transport := NewStdioTransport(stdin, stdout)
+	server := NewTransportServer(transport, sessions)
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Stop()
+	defer cancel()
+
+	go parentIn.Write([]byte(`{"type":"stop","id":"1","session_id":"guild-1"}` + "\n"))
+	line, err := bufio.NewReader(parentOut).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("no reply on stdout: %v", err)
+	}
+	var ev Event
+	if err := json.Unmarshal(line, &ev); err != nil || ev.Type != EventAck || ev.CommandID != "1" {
+		t.Errorf("expected an ack on stdout, got %q", line)
+	}
+
+	parentIn.Close() // The parent exits
+	select {
+	case <-transport.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Done after stdin closed")
+	}
+}
@@ -1,20 +1,55 @@
 // Package server provides the Unix socket server for the audio playground.
 package server
 
+import (
+	"time"
+
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+)
+
 // CommandType identifies the type of command from Node.js.
 type CommandType string
 
 const (
-	CommandPlay CommandType = "play"
-	CommandStop CommandType = "stop"
+	CommandPlay    CommandType = "play"
+	CommandStop    CommandType = "stop"
+	CommandPause   CommandType = "pause"
+	CommandResume  CommandType = "resume"
+	CommandSeek    CommandType = "seek"
+	CommandCredit  CommandType = "credit"  // Flow control; acknowledged only on error
+	CommandFormat  CommandType = "format"  // Switch the output format (format field)
+	CommandBitrate CommandType = "bitrate" // Set the Opus bitrate (bitrate field)
+
+	// CommandSubscribe limits the audio sent to this connection to the
+	// listed sessions (no session ID needed).
+	CommandSubscribe CommandType = "subscribe"
 )
 
-// Command represents a command received from Node.js.
+// Command represents a control message received on the audio socket, one
+// JSON object per line. Each is answered with an ack or error event
+// carrying the same ID.
 type Command struct {
-	Type      CommandType `json:"type"`
-	SessionID string      `json:"session_id"`
-	URL       string      `json:"url,omitempty"`
-	Format    string      `json:"format,omitempty"` // "webm" or "raw"
+	Type          CommandType `json:"type"`
+	ID            string      `json:"id,omitempty"` // Echoed as command_id in the reply
+	SessionID     string      `json:"session_id"`
+	URL           string      `json:"url,omitempty"`
+	Format        string      `json:"format,omitempty"`         // play, format: "pcm" (default), "opus" or "web"
+	StartAt       float64     `json:"start_at,omitempty"`       // play: start position in seconds
+	EndAt         float64     `json:"end_at,omitempty"`         // play: stop position in seconds
+	Duration      float64     `json:"duration,omitempty"`       // play: track duration if known
+	Levels        bool        `json:"levels,omitempty"`         // play: emit levels events
+	Spectrum      bool        `json:"spectrum,omitempty"`       // play: emit spectrum events
+	Token         string      `json:"request_token,omitempty"`  // play: retries with the same token don't restart the track
+	Resume        bool        `json:"resume,omitempty"`         // play: start where this session last left the track
+	Region        string      `json:"region,omitempty"`         // play: two-letter country to extract from (geo bypass)
+	StreamURL     string      `json:"stream_url,omitempty"`     // play: pre-resolved direct stream URL (skips yt-dlp)
+	StreamExpires int64       `json:"stream_expires,omitempty"` // play: when stream_url expires, in Unix seconds
+	Position      float64     `json:"position,omitempty"`       // seek: target position in seconds
+	Frames        int         `json:"frames,omitempty"`         // credit: audio packets the client can take
+	Bitrate       int         `json:"bitrate,omitempty"`        // bitrate: Opus bitrate in bps
+	Sessions      []string    `json:"sessions,omitempty"`       // subscribe: sessions to receive audio for (empty = all)
+	Caller        string      `json:"caller,omitempty"`         // Who the client acts for, recorded in the audit log
 }
 
 // EventType identifies the type of event sent to Node.js.
@@ -24,14 +59,41 @@ const (
 	EventReady    EventType = "ready"
 	EventError    EventType = "error"
 	EventFinished EventType = "finished"
+	EventLevels   EventType = "levels"
+	EventSpectrum EventType = "spectrum"
+	EventBitrate  EventType = "bitrate"
+	EventAck      EventType = "ack" // Reply to a socket command that succeeded
+	EventRetrying EventType = "retrying"
+	EventFormat   EventType = "format" // Audio after this event is in the new format
+
+	EventSilenceSkipped EventType = "silence_skipped" // Playback jumped over a silent stretch
+
+	// EventServerDraining is sent once (with no session ID) when the server
+	// stops accepting new playback ahead of a shutdown or deploy.
+	EventServerDraining EventType = "server_draining"
 )
 
 // Event represents an event sent to Node.js.
 type Event struct {
 	Type      EventType `json:"type"`
 	SessionID string    `json:"session_id"`
-	Duration  int       `json:"duration,omitempty"` // seconds, 0 if unknown
-	Message   string    `json:"message,omitempty"`  // error message
+	Duration  int       `json:"duration,omitempty"`   // seconds, 0 if unknown
+	Message   string    `json:"message,omitempty"`    // error message
+	Code      string    `json:"code,omitempty"`       // error code (see errs.Code)
+	Bitrate   int       `json:"bitrate,omitempty"`    // bps, for bitrate events
+	Format    string    `json:"format,omitempty"`     // pcm, opus or web, for format events
+	CommandID string    `json:"command_id,omitempty"` // ID of the socket command this answers
+
+	*Diagnostics // finished and error events: how playback went
+}
+
+// Diagnostics reports a session's retries and errors, so clients can tell
+// users about recovered interruptions.
+type Diagnostics struct {
+	RetryCount   int     `json:"retry_count"`                     // Pipeline restarts after early ends, stalls and expired URLs
+	LastError    string  `json:"last_error,omitempty"`            // Why the last retry or failure happened
+	LastExitCode *int    `json:"last_ffmpeg_exit_code,omitempty"` // Exit code of the last FFmpeg run that ended on its own
+	SeekOffset   float64 `json:"seek_offset"`                     // Position (seconds) the current pipeline started from
 }
 
 // NewReadyEvent creates a ready event.
@@ -43,12 +105,13 @@ func NewReadyEvent(sessionID string, duration int) Event {
 	}
 }
 
-// NewErrorEvent creates an error event.
-func NewErrorEvent(sessionID string, message string) Event {
+// NewErrorEvent creates an error event from err.
+func NewErrorEvent(sessionID string, err error) Event {
 	return Event{
 		Type:      EventError,
 		SessionID: sessionID,
-		Message:   message,
+		Message:   err.Error(),
+		Code:      errs.Code(err),
 	}
 }
 
@@ -60,6 +123,78 @@ func NewFinishedEvent(sessionID string) Event {
 	}
 }
 
+// NewFormatEvent creates a format event (output format switched).
+func NewFormatEvent(sessionID string, format encoder.Format) Event {
+	return Event{
+		Type:      EventFormat,
+		SessionID: sessionID,
+		Format:    string(format),
+	}
+}
+
+// NewBitrateEvent creates a bitrate event (adaptive bitrate switched).
+func NewBitrateEvent(sessionID string, bitrate int) Event {
+	return Event{
+		Type:      EventBitrate,
+		SessionID: sessionID,
+		Bitrate:   bitrate,
+	}
+}
+
+// LevelsEvent reports audio levels for the last metering window.
+type LevelsEvent struct {
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	RMS       float64   `json:"rms_db"`  // RMS level in dBFS
+	Peak      float64   `json:"peak_db"` // Peak level in dBFS
+}
+
+// NewLevelsEvent creates a levels event.
+func NewLevelsEvent(sessionID string, rms, peak float64) LevelsEvent {
+	return LevelsEvent{
+		Type:      EventLevels,
+		SessionID: sessionID,
+		RMS:       rms,
+		Peak:      peak,
+	}
+}
+
+// SpectrumEvent reports log-spaced frequency band magnitudes (0.0-1.0, low to high).
+type SpectrumEvent struct {
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	Bands     []float64 `json:"bands"`
+}
+
+// NewSpectrumEvent creates a spectrum event.
+func NewSpectrumEvent(sessionID string, bands []float64) SpectrumEvent {
+	return SpectrumEvent{
+		Type:      EventSpectrum,
+		SessionID: sessionID,
+		Bands:     bands,
+	}
+}
+
+// RetryingEvent reports that a session's pipeline is being restarted.
+type RetryingEvent struct {
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	Attempt   int       `json:"attempt"`  // Retry number (1-based)
+	Reason    string    `json:"reason"`   // "premature_end", "stalled" or "url_expired"
+	DelayMs   int64     `json:"delay_ms"` // Backoff before the new pipeline starts
+}
+
+// NewRetryingEvent creates a retrying event.
+func NewRetryingEvent(sessionID string, attempt int, reason string, delay time.Duration) RetryingEvent {
+	return RetryingEvent{
+		Type:      EventRetrying,
+		SessionID: sessionID,
+		Attempt:   attempt,
+		Reason:    reason,
+		DelayMs:   delay.Milliseconds(),
+	}
+}
+
 // TrackMetadata contains information about a track (for queue display).
 type TrackMetadata struct {
 	URL       string `json:"url"`
@@ -0,0 +1,74 @@
+package spill
+
+import (
+	"crypto/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Server serves spill files to local readers (FFmpeg) over HTTP on a
+// loopback port, each under an unguessable path. It starts listening on
+// the first Add.
+type Server struct {
+	mu       sync.Mutex
+	listener net.Listener
+	files    map[string]*File
+}
+
+// NewServer creates a server; it doesn't listen until a file is added.
+func NewServer() *Server {
+	return &Server{files: make(map[string]*File)}
+}
+
+// Add serves f until it is closed and returns its URL.
+func (s *Server) Add(f *File) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		s.listener = listener
+		go http.Serve(listener, s)
+	}
+	key := rand.Text()
+	s.files[key] = f
+	f.mu.Lock()
+	f.onClose = func() { s.remove(key) }
+	f.mu.Unlock()
+	return "http://" + s.listener.Addr().String() + "/" + key, nil
+}
+
+// remove stops serving the file under key.
+func (s *Server) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+}
+
+// Close stops listening. Files are not closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = make(map[string]*File)
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.listener = nil
+	return err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	f := s.files[strings.TrimPrefix(r.URL.Path, "/")]
+	s.mu.Unlock()
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	f.ServeHTTP(w, r)
+}
@@ -0,0 +1,487 @@
+// Package spill downloads a remote stream ahead of playback into a
+// temporary file and serves the downloaded part to local readers, so
+// backward seeks come from disk and network stalls are absorbed by the lead
+// already on disk. With several connections, chunks are fetched in parallel
+// (like aria2c), which beats hosts that throttle each connection.
+package spill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Download tuning
+const (
+	DefaultChunkSize = 10 << 20         // Bytes per range request; YouTube throttles larger unranged reads
+	maxAttempts      = 5                // Consecutive failed requests before the download gives up
+	stallTimeout     = 15 * time.Second // A request receiving nothing this long is retried
+)
+
+// ErrClosed is returned by reads from a closed File.
+var ErrClosed = errors.New("spill: file closed")
+
+// Options configures a File.
+type Options struct {
+	Client      *http.Client // nil = http.DefaultClient
+	Header      http.Header  // Sent with every request (e.g. User-Agent)
+	Dir         string       // Where the spill file is created ("" = os.TempDir)
+	ChunkSize   int64        // Bytes per range request (0 = DefaultChunkSize)
+	Connections int          // Chunks fetched at once, in order (0 = 1)
+}
+
+// Progress is a snapshot of a download.
+type Progress struct {
+	Downloaded int64 // Bytes on disk, from the start
+	Size       int64 // Total bytes
+	Done       bool  // Downloaded the whole stream
+	Retries    int   // Requests that failed or stalled and were retried
+}
+
+// File is a stream being downloaded into a temporary file. Reads block
+// until the range they need is on disk.
+type File struct {
+	url     string
+	options Options
+	file    *os.File
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	size      int64   // Total size, -1 until the first response
+	chunkSize int64   // Bytes per chunk; the whole stream if the server ignores ranges
+	filled    []int64 // Bytes on disk from the start of each chunk
+	next      int     // Next chunk to fetch
+	have      int64   // Contiguous bytes on disk from offset 0
+	retries   int
+	err       error // Why the download stopped early
+	closed    bool
+	onClose   func() // Set by Server.Add
+}
+
+// Open starts downloading url into a new spill file and returns once the
+// stream's size is known (after the first response), or with an error if
+// that request fails or ctx ends first. The download itself runs until the
+// File is closed.
+func Open(ctx context.Context, url string, options Options) (*File, error) {
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = DefaultChunkSize
+	}
+	options.Connections = max(options.Connections, 1)
+	file, err := os.CreateTemp(options.Dir, "natashi-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("spill: %w", err)
+	}
+	downloadCtx, cancel := context.WithCancel(context.Background())
+	f := &File{url: url, options: options, file: file, cancel: cancel, size: -1, chunkSize: options.ChunkSize}
+	f.cond = sync.NewCond(&f.mu)
+	go f.download(downloadCtx)
+
+	stop := context.AfterFunc(ctx, f.wake)
+	defer stop()
+	f.mu.Lock()
+	for f.size < 0 && f.err == nil && ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	err = f.err
+	f.mu.Unlock()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// wake unblocks waiting readers so they recheck their conditions.
+func (f *File) wake() {
+	f.mu.Lock()
+	f.cond.Broadcast()
+	f.mu.Unlock()
+}
+
+// Name returns the path of the spill file. Once Wait returns nil it holds
+// the whole stream.
+func (f *File) Name() string {
+	return f.file.Name()
+}
+
+// Wait blocks until the whole stream is on disk, the download fails or ctx
+// ends.
+func (f *File) Wait(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, f.wake)
+	defer stop()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.have < f.size && f.err == nil && !f.closed && ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	switch {
+	case f.have == f.size:
+		return nil
+	case f.closed:
+		return ErrClosed
+	case f.err != nil:
+		return f.err
+	}
+	return ctx.Err()
+}
+
+// Size returns the total size of the stream in bytes.
+func (f *File) Size() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.size
+}
+
+// Progress returns how far the download got.
+func (f *File) Progress() Progress {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return Progress{Downloaded: f.have, Size: f.size, Done: f.have == f.size, Retries: f.retries}
+}
+
+// Err returns why the download stopped before the end, or nil.
+func (f *File) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// ReadAt implements io.ReaderAt, waiting for the range to be downloaded.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	return f.readAt(context.Background(), p, off)
+}
+
+// readAt is ReadAt giving up when ctx ends.
+func (f *File) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	stop := context.AfterFunc(ctx, f.wake)
+	defer stop()
+
+	f.mu.Lock()
+	end := min(off+int64(len(p)), f.size)
+	for f.have < end && f.err == nil && !f.closed && ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	closed, err, size := f.closed, f.err, f.size
+	available := f.have
+	f.mu.Unlock()
+
+	switch {
+	case closed:
+		return 0, ErrClosed
+	case ctx.Err() != nil:
+		return 0, ctx.Err()
+	case off >= size:
+		return 0, io.EOF
+	case available < end:
+		return 0, err // The download failed before reaching the range
+	}
+	n, readErr := f.file.ReadAt(p[:end-off], off)
+	if readErr == nil && end == size && n < len(p) {
+		readErr = io.EOF
+	}
+	return n, readErr
+}
+
+// ServeHTTP serves the stream with range support; requests for parts not
+// downloaded yet wait for them.
+func (f *File) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reader := io.NewSectionReader(contextReader{f, r.Context()}, 0, f.Size())
+	http.ServeContent(w, r, "", time.Time{}, reader)
+}
+
+// contextReader is a File read on behalf of a request, so a disconnected
+// reader stops waiting.
+type contextReader struct {
+	file *File
+	ctx  context.Context
+}
+
+func (r contextReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.file.readAt(r.ctx, p, off)
+}
+
+// Close stops the download and deletes the spill file. Blocked reads
+// return ErrClosed.
+func (f *File) Close() error {
+	f.cancel()
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.cond.Broadcast()
+	onClose := f.onClose
+	f.mu.Unlock()
+	if onClose != nil {
+		onClose()
+	}
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// Keep closes f, moving the spill file to path instead of deleting it. The
+// download must be complete (see Wait).
+func (f *File) Keep(path string) error {
+	f.mu.Lock()
+	done := f.have == f.size
+	f.mu.Unlock()
+	if !done {
+		return errors.New("spill: download not complete")
+	}
+	if err := os.Rename(f.file.Name(), path); err != nil {
+		return fmt.Errorf("spill: %w", err)
+	}
+	f.Close() // Only the handle is left to close
+	return nil
+}
+
+// download fetches the stream chunk by chunk with up to Connections
+// requests at once. Chunks are taken in order, so the part on disk grows
+// from the start and readers near it aren't kept waiting.
+func (f *File) download(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range f.options.Connections {
+		wg.Go(func() {
+			for {
+				chunk, ok := f.claim(ctx)
+				if !ok {
+					return
+				}
+				if err := f.fetchChunk(ctx, chunk); err != nil {
+					f.fail(err)
+					cancel() // Stop the other connections
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// claim returns the next chunk to fetch, or false when there is none left
+// or the download stopped. Until the first response reports the size only
+// the first chunk is handed out.
+func (f *File) claim(ctx context.Context) (int, bool) {
+	stop := context.AfterFunc(ctx, f.wake)
+	defer stop()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.size < 0 && f.next > 0 && f.err == nil && ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	if f.err != nil || ctx.Err() != nil || f.size >= 0 && f.next >= len(f.filled) {
+		return 0, false
+	}
+	chunk := f.next
+	f.next++
+	return chunk, true
+}
+
+// fail records why the download stopped, unless it already has a reason.
+func (f *File) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err == nil {
+		f.err = fmt.Errorf("spill: download failed: %w", err)
+	}
+	f.cond.Broadcast()
+}
+
+// fetchChunk downloads one chunk, resuming where a failed or stalled
+// request left off. It gives up after maxAttempts requests in a row
+// without progress (2 before the size is known).
+func (f *File) fetchChunk(ctx context.Context, chunk int) error {
+	failures := 0
+	for {
+		f.mu.Lock()
+		if f.size >= 0 && chunk >= len(f.filled) {
+			f.mu.Unlock()
+			return nil // The stream turned out shorter (or empty)
+		}
+		offset, end := f.chunkRange(chunk)
+		size := f.size
+		f.mu.Unlock()
+		if size >= 0 && offset >= end {
+			return nil
+		}
+
+		progressed, err := f.fetch(ctx, chunk, offset, end)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if progressed {
+			failures = 0
+		}
+		failures++
+		f.mu.Lock()
+		if failures >= maxAttempts || f.size < 0 && failures >= 2 {
+			f.mu.Unlock()
+			return err
+		}
+		f.retries++
+		f.mu.Unlock()
+
+		select {
+		case <-time.After(time.Duration(failures) * 500 * time.Millisecond):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// chunkRange returns where the rest of chunk starts and where the chunk
+// ends (exclusive; the first chunk's ChunkSize until the size is known).
+// Caller must hold f.mu.
+func (f *File) chunkRange(chunk int) (offset, end int64) {
+	start := int64(chunk) * f.chunkSize
+	if f.size < 0 {
+		return start, start + f.chunkSize
+	}
+	return start + f.filled[chunk], min(start+f.chunkSize, f.size)
+}
+
+// fetch requests offset up to end of chunk, writing what arrives into the
+// file. It reports whether any bytes arrived.
+func (f *File) fetch(ctx context.Context, chunk int, offset, end int64) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range f.options.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end-1))
+
+	// Cancelled when nothing arrives for stallTimeout
+	stall := time.AfterFunc(stallTimeout, cancel)
+	defer stall.Stop()
+
+	resp, err := f.options.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if err := f.setSize(totalSize(resp.Header.Get("Content-Range")), false); err != nil {
+			return false, err
+		}
+	case http.StatusOK:
+		// No range support: the whole stream from the start, as one chunk
+		if offset > 0 {
+			return false, errors.New("server ignored the range request")
+		}
+		if err := f.setSize(resp.ContentLength, true); err != nil {
+			return false, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 {
+			return false, fmt.Errorf("HTTP %s", resp.Status)
+		}
+		return false, f.setSize(0, false) // Empty stream
+	default:
+		return false, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	f.mu.Lock()
+	_, end = f.chunkRange(chunk)
+	f.mu.Unlock()
+
+	buf := make([]byte, 64<<10)
+	progressed := false
+	for offset < end {
+		n, readErr := resp.Body.Read(buf[:min(int64(len(buf)), end-offset)])
+		if n > 0 {
+			stall.Reset(stallTimeout)
+			if _, err := f.file.WriteAt(buf[:n], offset); err != nil {
+				return progressed, err
+			}
+			offset += int64(n)
+			progressed = true
+			f.advance(chunk, int64(n))
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return progressed, readErr
+		}
+	}
+	if !progressed {
+		return false, errors.New("empty response")
+	}
+	return true, nil
+}
+
+// advance records n more bytes of chunk on disk and moves the contiguous
+// part forward past every complete chunk.
+func (f *File) advance(chunk int, n int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.filled[chunk] += n
+	first := int(f.have / f.chunkSize)
+	for first < len(f.filled) {
+		start := int64(first) * f.chunkSize
+		f.have = start + f.filled[first]
+		if f.have < min(start+f.chunkSize, f.size) {
+			break
+		}
+		first++
+	}
+	f.cond.Broadcast()
+}
+
+// setSize records the stream size from the first response, and splits it
+// into chunks: one if whole, as the server sent the stream without ranges.
+func (f *File) setSize(size int64, whole bool) error {
+	if size < 0 {
+		return errors.New("server did not report the stream size")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size < 0 {
+		f.size = size
+		if whole {
+			f.chunkSize = max(size, 1)
+		}
+		f.filled = make([]int64, (size+f.chunkSize-1)/f.chunkSize)
+		f.cond.Broadcast()
+	}
+	return nil
+}
+
+// totalSize returns the complete length from a Content-Range header
+// ("bytes 0-99/1234"), or -1 if it is missing or unknown.
+func totalSize(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
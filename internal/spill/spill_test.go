@@ -0,0 +1,189 @@
+package spill
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStream returns content of n bytes and a server for it with range
+// support, failing the requests for which fail returns true.
+func testStream(t *testing.T, n int, fail func(r *http.Request) bool) ([]byte, *httptest.Server) {
+	t.Helper()
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail != nil && fail(r) {
+			http.Error(w, "reset", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return content, server
+}
+
+func TestFile_Downloads(t *testing.T) {
+	content, server := testStream(t, 100_000, nil)
+	f, err := Open(context.Background(), server.URL, Options{ChunkSize: 16_000})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	if f.Size() != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), f.Size())
+	}
+
+	// The tail waits for the download to get there
+	tail := make([]byte, 1000)
+	if n, err := f.ReadAt(tail, int64(len(content))-500); n != 500 || err != io.EOF {
+		t.Fatalf("expected 500 bytes and EOF at the end, got %d, %v", n, err)
+	}
+	if !bytes.Equal(tail[:500], content[len(content)-500:]) {
+		t.Error("tail differs from the stream")
+	}
+	got, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected the whole stream back, got %d bytes (%v)", len(got), err)
+	}
+	if p := f.Progress(); !p.Done || p.Downloaded != int64(len(content)) {
+		t.Errorf("expected a finished download, got %+v", p)
+	}
+}
+
+func TestFile_RetriesFailedRequests(t *testing.T) {
+	var requests atomic.Int32
+	content, server := testStream(t, 50_000, func(r *http.Request) bool {
+		return requests.Add(1) == 3 // The second chunk fails once
+	})
+	f, err := Open(context.Background(), server.URL, Options{ChunkSize: 20_000})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	got, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("expected the whole stream despite the failure, got %d bytes (%v)", len(got), err)
+	}
+	if p := f.Progress(); p.Retries != 1 {
+		t.Errorf("expected 1 retry, got %+v", p)
+	}
+}
+
+func TestFile_ParallelConnections(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	content, server := testStream(t, 100_000, func(*http.Request) bool {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond) // Overlaps the other connections' requests
+		return false
+	})
+	f, err := Open(context.Background(), server.URL, Options{ChunkSize: 10_000, Connections: 4})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	got, err := os.ReadFile(f.Name())
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("expected the whole stream on disk, got %d bytes (%v)", len(got), err)
+	}
+	if p := peak.Load(); p < 2 || p > 4 {
+		t.Errorf("expected 2 to 4 requests at once, got %d", p)
+	}
+}
+
+func TestFile_Keep(t *testing.T) {
+	dir := t.TempDir()
+	content, server := testStream(t, 30_000, nil)
+	f, err := Open(context.Background(), server.URL, Options{Dir: dir, ChunkSize: 10_000, Connections: 2})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := f.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	path := filepath.Join(dir, "track.webm")
+	if err := f.Keep(path); err != nil {
+		t.Fatalf("Keep failed: %v", err)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected the stream kept at %s, got %d bytes (%v)", path, len(got), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the kept file, found %d files", len(entries))
+	}
+}
+
+func TestOpen_Fails(t *testing.T) {
+	_, server := testStream(t, 1000, func(*http.Request) bool { return true })
+	if _, err := Open(context.Background(), server.URL, Options{}); err == nil {
+		t.Error("expected an error when the stream can't be fetched")
+	}
+}
+
+func TestFile_CloseRemovesFile(t *testing.T) {
+	dir := t.TempDir()
+	_, server := testStream(t, 1000, nil)
+	f, err := Open(context.Background(), server.URL, Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the spill file removed, found %d files", len(entries))
+	}
+	if _, err := f.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestServer_ServesRanges(t *testing.T) {
+	content, upstream := testStream(t, 30_000, nil)
+	f, err := Open(context.Background(), upstream.URL, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	server := NewServer()
+	defer server.Close()
+	url, err := server.Add(f)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=1000-1999")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, content[1000:2000]) {
+		t.Errorf("expected bytes 1000-1999, got %d with %d bytes", resp.StatusCode, len(body))
+	}
+
+	f.Close()
+	if resp, err := http.Get(url); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 once the file is closed, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
@@ -5,15 +5,29 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
-	"music-bot/cmd"
-	"music-bot/internal/platform"
-	"music-bot/internal/platform/youtube"
-	"music-bot/internal/player/ffmpeg"
-	"music-bot/pkg/deps"
+	"github.com/gin-gonic/gin"
+
+	"github.com/thewind121212/natashi/cmd"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/logging"
+	"github.com/thewind121212/natashi/internal/platform"
+	"github.com/thewind121212/natashi/internal/platform/direct"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/player"
+	"github.com/thewind121212/natashi/internal/player/ffmpeg"
+	"github.com/thewind121212/natashi/internal/scrobble"
+	"github.com/thewind121212/natashi/internal/server"
+	"github.com/thewind121212/natashi/pkg/deps"
 )
 
+// out is the CLI's log and status output, configured from flags.
+var out *cmd.Output
+
 func main() {
 	// ─── Step 1: Parse CLI arguments ───
 	config, err := cmd.ParseArgs()
@@ -21,78 +35,460 @@ func main() {
 		fmt.Println("[ERROR]", err)
 		cmd.PrintUsageAndExit()
 	}
+	if config.Daemon != nil {
+		config.Daemon.ClaimStdout() // Stdio mode: stdout carries only the audio protocol
+	}
+	out = cmd.NewOutput(config)
+
+	// music-bot doctor runs its own dependency checks and reports failures
+	if config.Doctor != nil {
+		os.Exit(runDoctor(config.Doctor))
+	}
 
 	// ─── Step 2: Check dependencies ───
-	checker := deps.NewChecker("yt-dlp", "ffmpeg")
-	if err := checker.CheckAndPrint(); err != nil {
+	checker := deps.NewCheckerFor(deps.Defaults()...)
+	if config.Daemon != nil && config.Daemon.Stdio {
+		// Stdout carries the audio protocol
+		if err = checker.CheckAll(); err != nil {
+			fmt.Fprintln(os.Stderr, "[ERROR]", err)
+		}
+	} else if out.Verbosity() == cmd.VerbosityQuiet || out.JSON() {
+		err = checker.CheckAll()
+		if err != nil {
+			out.Errorf("%v", err)
+		}
+	} else {
+		err = checker.CheckAndPrint()
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 
-	// Load YouTube config from environment
+	// Load YouTube config from the config file, then environment
+	youtube.SetConfig(youtube.Config{
+		CookiesFile:        expandHome(config.Cookies.File),
+		CookieProfiles:     expandHomes(config.Cookies.Profiles),
+		CookiesFromBrowser: config.Cookies.Browser,
+		GeoCountry:         config.Geo.Country,
+		Proxy:              config.Geo.Proxy,
+		RegionProxies:      config.Geo.RegionProxies,
+		Format:             config.Format,
+		Binary:             checker.Path("yt-dlp"),
+	})
 	youtube.LoadConfigFromEnv()
+	encoder.SetFFmpegPath(checker.Path("ffmpeg"))
+
+	if config.Daemon != nil {
+		runDaemon(config.Daemon, checker.Degraded())
+		return
+	}
 
 	// ─── Step 3: Setup platform registry (Open/Closed Principle) ───
+	yt := youtube.New()
 	registry := platform.NewRegistry()
-	registry.Register(youtube.New())
+	registry.Register(yt)
+	// Direct URLs and local files; registered last so it only catches
+	// what the platform extractors don't handle
+	registry.Register(direct.New())
 	// Easy to add new platforms:
 	// registry.Register(soundcloud.New())
 	// registry.Register(spotify.New())
 
-	// ─── Step 4: Find appropriate extractor ───
-	var extractor platform.StreamExtractor
-
-	if config.Platform != "" {
-		// User specified a platform
-		extractor = registry.GetExtractorByName(config.Platform)
-		if extractor == nil {
-			fmt.Printf("[ERROR] Unknown platform: %s\n", config.Platform)
-			fmt.Printf("[INFO] Available platforms: %v\n", registry.ListPlatforms())
+	// Search mode: pick one result and play it
+	if config.Search != "" {
+		out.Infof("Searching YouTube for %q...", config.Search)
+		results, err := yt.Search(config.Search, config.Limit)
+		if err != nil {
+			out.Errorf("%v", err)
 			os.Exit(1)
 		}
-	} else {
-		// Auto-detect platform from URL
-		extractor = registry.FindExtractor(config.URL)
-		if extractor == nil {
-			fmt.Println("[ERROR] Could not detect platform from URL")
-			fmt.Printf("[INFO] Please specify platform with -p flag\n")
-			fmt.Printf("[INFO] Available platforms: %v\n", registry.ListPlatforms())
-			os.Exit(1)
+		if len(results) == 0 {
+			out.Infof("No results")
+			return
+		}
+		cmd.PrintSearchResults(os.Stdout, results)
+		picked, ok := cmd.PickSearchResult(os.Stdin, os.Stdout, results)
+		if !ok {
+			return
 		}
+		config.URLs = []string{picked.URL}
 	}
 
-	fmt.Printf("[INFO] Using platform: %s\n", extractor.Name())
-	fmt.Println("[INFO] URL:", config.URL)
+	// ─── Step 4: Setup context with signal handling ───
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// ─── Step 5: Extract stream URL ───
-	fmt.Println("[INFO] Fetching audio stream...")
-	streamURL, err := extractor.ExtractStreamURL(config.URL)
-	if err != nil {
-		fmt.Println("[ERROR]", err)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	// ─── Step 5: Play the queue (Dependency Inversion - uses interface) ───
+	queue := player.NewQueue(buildTracks(registry, readQueue(config)))
+	if queue.Len() == 0 {
+		out.Errorf("Nothing to play")
 		os.Exit(1)
 	}
-	fmt.Println("[INFO] Stream extracted")
+	if config.Shuffle {
+		queue.Shuffle()
+	}
+	switch {
+	case config.Loop:
+		queue.Repeat = player.RepeatOne
+	case config.RepeatAll:
+		queue.Repeat = player.RepeatAll
+	}
+	keys, restoreTerminal := cmd.ReadKeys()
+	defer restoreTerminal()
+
+	out.Infof("Keys: space pause, ←/→ seek, ↑/↓ volume, n next, Ctrl+C stop")
+
+	playerConfig := player.DefaultConfig()
+	playerConfig.StartAt = config.StartAt
+	playerConfig.Volume = float64(config.Volume) / 100
+	playerConfig.Logf = out.Infof
+	if config.Device != "" {
+		playerConfig.Device = config.Device
+	}
+	playerConfig.FFmpegPath = encoder.FFmpegPath()
+	// Validated in ParseArgs
+	playerConfig.Filter, _ = encoder.FilterChain(config.EQ, config.Effects, playerConfig.SampleRate)
+	if out.Verbosity() == cmd.VerbosityQuiet || out.JSON() {
+		playerConfig.LogLevel = "error" // No FFmpeg progress output
+	}
+	audioPlayer := ffmpeg.New(playerConfig)
+	positions := newPositionTracker(config.Resume)
+	listens := &listenTracker{scrobbler: scrobble.New(config.Scrobble)}
+	failures := 0 // Consecutive failed tracks; stops repeat modes spinning on dead URLs
+	for track, ok := queue.Current(); ok && ctx.Err() == nil; track, ok = queue.Current() {
+		out.Infof("Track %d/%d: %s", queue.Position(), queue.Len(), track)
+		out.Event("track_started", map[string]any{
+			"index": queue.Position(), "total": queue.Len(), "url": track.URL, "title": track.Title,
+		})
+		positions.begin(track.URL, audioPlayer, config.StartAt)
+		listens.begin()
+		skipped, err := playTrack(ctx, registry, config.Platform, track.URL, audioPlayer, keys, positions, listens)
+		positions.end(track.URL, err == nil && !skipped && ctx.Err() == nil)
+		listens.finish(positions.played())
+		if err == nil && ctx.Err() == nil {
+			out.Event("track_finished", map[string]any{"url": track.URL, "skipped": skipped})
+		}
+		switch {
+		case err != nil:
+			out.Errorf("%v", err)
+			if queue.Len() == 1 {
+				restoreTerminal()
+				os.Exit(1)
+			}
+			if failures++; failures >= queue.Len() {
+				out.Errorf("No playable tracks left")
+				return
+			}
+			queue.Next()
+		case skipped:
+			failures = 0
+			queue.Next()
+		default:
+			failures = 0
+			queue.Finished()
+		}
+	}
+}
+
+// expandHome expands a leading ~/ in paths from the config file.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// expandHomes applies expandHome to each path.
+func expandHomes(paths []string) []string {
+	expanded := make([]string, len(paths))
+	for i, path := range paths {
+		expanded[i] = expandHome(path)
+	}
+	return expanded
+}
 
-	// ─── Step 6: Setup context with signal handling ───
+// runDaemon runs the playground server (HTTP API + audio socket) until
+// interrupted, or in stdio mode until the parent closes stdin.
+func runDaemon(config *cmd.DaemonConfig, degraded []string) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	console := os.Stdout
+	if config.Stdio {
+		console = os.Stderr // Stdout carries the audio protocol
+		gin.DefaultWriter = os.Stderr
+	}
+
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-
 	go func() {
 		<-sig
+		fmt.Fprintln(console, "\n[INFO] Shutting down, letting playing tracks finish (signal again to force)...")
 		cancel()
+		<-sig
+		os.Exit(1)
 	}()
 
-	// ─── Step 7: Play audio (Dependency Inversion - uses interface) ───
-	fmt.Println("[INFO] Playing audio...")
-	fmt.Println("[INFO] Press Ctrl+C to stop\n")
+	// SIGHUP reloads the cookies file without dropping sessions
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			server.ReloadCookies()
+		}
+	}()
 
-	audioPlayer := ffmpeg.NewDefault()
-	if err := audioPlayer.Play(ctx, streamURL); err != nil {
-		if err != context.Canceled {
-			fmt.Println("[ERROR]", err)
-			os.Exit(1)
+	if err := logging.Configure(config.Logging); err != nil {
+		fmt.Fprintf(console, "[ERROR] logging: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := logging.Close(); err != nil {
+			fmt.Fprintf(console, "[ERROR] logging: %v\n", err)
+		}
+	}()
+
+	opts := config.ServerOptions()
+	opts.Degraded = degraded
+	if stdio, ok := opts.Transport.(*server.StdioTransport); ok {
+		go func() {
+			<-stdio.Done()
+			fmt.Fprintln(console, "[INFO] Stdin closed, shutting down")
+			cancel()
+		}()
+	}
+	if err := server.Run(ctx, opts); err != nil {
+		fmt.Fprintf(console, "[ERROR] %v\n", err)
+		logging.Close()
+		os.Exit(1)
+	}
+}
+
+// readQueue collects the requested tracks: entries from -list files first,
+// then URLs in order, where "-" reads a list from stdin.
+func readQueue(config *cmd.Config) []player.Track {
+	var tracks []player.Track
+	for _, path := range config.Lists {
+		list, err := readList(path)
+		if err != nil {
+			out.Errorf("List: %v", err)
+			continue
+		}
+		out.Infof("List %s: %d tracks", path, len(list))
+		tracks = append(tracks, list...)
+	}
+	for _, url := range config.URLs {
+		if url != "-" {
+			tracks = append(tracks, player.Track{URL: url})
+			continue
+		}
+		list, err := player.ParseM3U(os.Stdin, ".")
+		if err != nil {
+			out.Errorf("Stdin: %v", err)
+			continue
+		}
+		tracks = append(tracks, list...)
+	}
+	return tracks
+}
+
+// readList parses an M3U or plain-text list; relative paths in it are
+// resolved against the list's directory.
+func readList(path string) ([]player.Track, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return player.ParseM3U(f, filepath.Dir(path))
+}
+
+// buildTracks expands YouTube playlists in the queue into their entries.
+func buildTracks(registry *platform.Registry, queued []player.Track) []player.Track {
+	var tracks []player.Track
+	for _, track := range queued {
+		yt, ok := registry.FindExtractor(track.URL).(*youtube.Extractor)
+		if !ok || !yt.IsPlaylist(track.URL) {
+			tracks = append(tracks, track)
+			continue
+		}
+
+		out.Infof("Fetching playlist...")
+		entries, err := yt.ExtractPlaylist(track.URL)
+		if err != nil {
+			out.Errorf("Playlist: %v", err)
+			continue
+		}
+		out.Infof("Playlist: %d tracks", len(entries))
+		for _, entry := range entries {
+			tracks = append(tracks, player.Track{URL: entry.URL, Title: entry.Title})
+		}
+	}
+	return tracks
+}
+
+// playTrack extracts and plays one URL. Pressing n skips to the next track
+// (reported as skipped); cancelling ctx (Ctrl+C) stops playback entirely.
+func playTrack(ctx context.Context, registry *platform.Registry, platformName, url string, audioPlayer player.AudioPlayer, keys <-chan cmd.Key, positions *positionTracker, listens *listenTracker) (skipped bool, err error) {
+	extractor, err := findExtractor(registry, platformName, url)
+	if err != nil {
+		return false, err
+	}
+	out.Infof("Using platform: %s", extractor.Name())
+
+	out.Infof("Fetching audio stream...")
+	streamURL, err := platform.ExtractStreamURL(ctx, extractor, url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, nil // Ctrl+C while yt-dlp was running
+		}
+		return false, err
+	}
+	out.Infof("Stream extracted")
+	out.Debugf("Stream URL: %s", streamURL)
+
+	ctrl, controllable := audioPlayer.(player.Controller)
+	trackCtx, skip := context.WithCancel(ctx)
+	defer skip()
+
+	chapters := &chapterTracker{}
+	if yt, ok := extractor.(*youtube.Extractor); ok {
+		// One metadata call feeds chapters and scrobbling
+		go func() {
+			meta, err := yt.ExtractMetadataContext(trackCtx, url)
+			if err != nil {
+				out.Debugf("Metadata unavailable: %v", err)
+				return
+			}
+			chapters.set(meta)
+			listens.start(meta)
+		}()
+		if controllable {
+			go chapters.watch(trackCtx, ctrl)
+		}
+	}
+	if controllable {
+		go positions.watch(trackCtx, ctrl)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-trackCtx.Done():
+				return
+			case key, ok := <-keys:
+				if !ok {
+					return
+				}
+				if key == 'n' {
+					out.Infof("Skipping...")
+					skip()
+					return
+				}
+				if !controllable {
+					continue
+				}
+				switch key {
+				case '[', ']':
+					chapters.jump(ctrl, key == ']')
+				default:
+					handleControlKey(ctrl, key)
+				}
+			}
+		}
+	}()
+
+	if controllable && out.JSON() {
+		go reportPosition(trackCtx, ctrl)
+	}
+
+	out.Infof("Playing audio...")
+	if err := audioPlayer.Play(trackCtx, streamURL); err != nil && err != context.Canceled {
+		return false, err
+	}
+	return trackCtx.Err() != nil && ctx.Err() == nil, nil
+}
+
+// positionInterval is how often JSON mode reports the playback position.
+const positionInterval = time.Second
+
+// reportPosition emits "position" events until ctx is done.
+func reportPosition(ctx context.Context, ctrl player.Controller) {
+	ticker := time.NewTicker(positionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			out.Event("position", map[string]any{"position": ctrl.Position().Seconds()})
 		}
 	}
 }
+
+// Keyboard control steps
+const (
+	seekStep   = 10 * time.Second
+	volumeStep = 0.1
+)
+
+// handleControlKey applies a pause/seek/volume keypress to the player.
+func handleControlKey(ctrl player.Controller, key cmd.Key) {
+	switch key {
+	case ' ':
+		if ctrl.TogglePause() {
+			out.Infof("Paused")
+			out.Event("paused", nil)
+		} else {
+			out.Infof("Resumed")
+			out.Event("resumed", nil)
+		}
+	case cmd.KeyLeft, cmd.KeyRight:
+		offset := seekStep
+		if key == cmd.KeyLeft {
+			offset = -seekStep
+		}
+		position := ctrl.Seek(offset)
+		out.Infof("Seek to %s", position.Round(time.Second))
+		out.Event("seek", map[string]any{"position": position.Seconds()})
+	case cmd.KeyUp, cmd.KeyDown:
+		step := volumeStep
+		if key == cmd.KeyDown {
+			step = -volumeStep
+		}
+		volume := ctrl.SetVolume(ctrl.Volume() + step)
+		out.Infof("Volume %.0f%%", volume*100)
+		out.Event("volume", map[string]any{"volume": volume})
+	}
+}
+
+// findExtractor returns the extractor for url, either the platform the user
+// named or the one detected from the URL.
+func findExtractor(registry *platform.Registry, platformName, url string) (platform.StreamExtractor, error) {
+	if platformName != "" {
+		// User specified a platform
+		if extractor := registry.GetExtractorByName(platformName); extractor != nil {
+			return extractor, nil
+		}
+		out.Infof("Available platforms: %v", registry.ListPlatforms())
+		return nil, fmt.Errorf("unknown platform: %s", platformName)
+	}
+
+	// Auto-detect platform from URL
+	if extractor := registry.FindExtractor(url); extractor != nil {
+		return extractor, nil
+	}
+	out.Infof("Please specify platform with -p flag")
+	out.Infof("Available platforms: %v", registry.ListPlatforms())
+	return nil, fmt.Errorf("could not detect platform from URL")
+}
@@ -1,64 +1,297 @@
 package deps
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Checker verifies that required dependencies are available.
+// Dependency is a binary and the names or paths to try for it.
+type Dependency struct {
+	Name       string   // Name used in messages and for Path lookups (e.g. "yt-dlp")
+	Candidates []string // Names in PATH or file paths, tried in order (default: Name)
+
+	// Optional dependencies only produce a warning when missing; Feature
+	// describes what is degraded without them.
+	Optional bool
+	Feature  string
+}
+
+// candidates returns the names to try for d.
+func (d Dependency) candidates() []string {
+	var names []string
+	for _, candidate := range d.Candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			names = append(names, candidate)
+		}
+	}
+	if len(names) == 0 {
+		return []string{d.Name}
+	}
+	return names
+}
+
+// Defaults returns the required yt-dlp and ffmpeg, with their common
+// alternative names, and the optional helpers yt-dlp can use. Paths set
+// in YT_DLP_PATH and FFMPEG_PATH are tried first.
+func Defaults() []Dependency {
+	return []Dependency{
+		{Name: "yt-dlp", Candidates: []string{os.Getenv("YT_DLP_PATH"), "yt-dlp", "yt-dlp_linux", "yt-dlp_macos"}},
+		{Name: "ffmpeg", Candidates: []string{os.Getenv("FFMPEG_PATH"), "ffmpeg"}},
+		{
+			Name:       "js-runtime",
+			Candidates: []string{"node", "deno"},
+			Optional:   true,
+			Feature:    "YouTube JS challenges (some formats may be unavailable)",
+		},
+		{
+			Name:     "aria2c",
+			Optional: true,
+			Feature:  "segmented downloads",
+		},
+	}
+}
+
+// defaultTimeout bounds each --version run.
+const defaultTimeout = 10 * time.Second
+
+// versionArgs are the version flags for tools that don't take --version.
+var versionArgs = map[string][]string{
+	"ffmpeg":  {"-version"},
+	"ffprobe": {"-version"},
+	"ffplay":  {"-version"},
+}
+
+// Result is the outcome of checking one dependency.
+type Result struct {
+	Name     string
+	Path     string // Selected binary; empty if no candidate works
+	Version  string // First line of the version output
+	Err      error  // Why the dependency is unusable; nil if OK
+	Optional bool   // A failure only degrades Feature
+	Feature  string
+}
+
+// OK reports whether the dependency was found and runs.
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// Checker verifies that required dependencies are installed and run.
 // Single Responsibility: Only handles dependency checking.
 type Checker struct {
-	dependencies []string
+	dependencies []Dependency
+	timeout      time.Duration
+
+	mu       sync.Mutex
+	resolved map[string]string // Name -> selected path
+	last     []Result          // Results of the last Check
 }
 
 // NewChecker creates a new dependency checker with the given dependencies.
 func NewChecker(deps ...string) *Checker {
-	return &Checker{dependencies: deps}
+	dependencies := make([]Dependency, len(deps))
+	for i, name := range deps {
+		dependencies[i] = Dependency{Name: name}
+	}
+	return NewCheckerFor(dependencies...)
 }
 
-// CheckAll verifies all dependencies are available.
-// Returns an error listing all missing dependencies.
-func (c *Checker) CheckAll() error {
-	var missing []string
-
-	for _, dep := range c.dependencies {
-		if !c.IsAvailable(dep) {
-			missing = append(missing, dep)
-		}
+// NewCheckerFor creates a dependency checker for dependencies with
+// alternative names or paths.
+func NewCheckerFor(deps ...Dependency) *Checker {
+	return &Checker{
+		dependencies: deps,
+		timeout:      defaultTimeout,
+		resolved:     make(map[string]string),
 	}
+}
 
-	if len(missing) > 0 {
-		return &MissingDepsError{Dependencies: missing}
+// SetTimeout sets how long each binary may take to print its version.
+func (c *Checker) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// Check checks all dependencies concurrently. For each, the first
+// candidate in PATH whose version command succeeds within the timeout is
+// selected, so broken installs (e.g. a glibc mismatch) fall through to the
+// next candidate. Results are in the order the dependencies were given.
+func (c *Checker) Check(ctx context.Context) []Result {
+	results := make([]Result, len(c.dependencies))
+	var wg sync.WaitGroup
+	for i, dep := range c.dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.check(ctx, dep)
+		}()
 	}
+	wg.Wait()
 
-	return nil
+	c.mu.Lock()
+	c.last = results
+	c.mu.Unlock()
+	return results
 }
 
-// IsAvailable checks if a single dependency is available in PATH.
+// Degraded returns the features unavailable because optional
+// dependencies failed the last Check.
+func (c *Checker) Degraded() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return degraded(c.last)
+}
+
+// CheckAll verifies all dependencies are available.
+// Returns an error listing all missing required dependencies.
+func (c *Checker) CheckAll() error {
+	return missingError(c.Check(context.Background()))
+}
+
+// IsAvailable checks if a single dependency is available.
 func (c *Checker) IsAvailable(name string) bool {
-	_, err := exec.LookPath(name)
-	return err == nil
+	return c.check(context.Background(), c.dependency(name)).OK()
+}
+
+// Path returns the path selected for a dependency by the last check, or
+// its name if it hasn't been found (so it can still be passed to exec).
+func (c *Checker) Path(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if path, ok := c.resolved[name]; ok {
+		return path
+	}
+	return name
 }
 
 // CheckAndPrint checks all dependencies and prints status.
-// Returns error if any dependency is missing.
+// Returns error if any required dependency is missing; missing optional
+// dependencies are printed as warnings with the degraded features.
 func (c *Checker) CheckAndPrint() error {
-	var missing []string
+	results := c.Check(context.Background())
+
+	for _, result := range results {
+		switch {
+		case result.OK():
+			fmt.Printf("[OK] %s (%s)\n", result.Name, result.Path)
+		case result.Optional:
+			fmt.Printf("[WARN] %v (optional)\n", result.Err)
+		default:
+			fmt.Printf("[ERROR] %v\n", result.Err)
+			fmt.Printf("[INFO]  Install '%s' and retry\n\n", result.Name)
+		}
+	}
+	if features := degraded(results); len(features) > 0 {
+		fmt.Printf("[WARN] Degraded: %s\n", strings.Join(features, "; "))
+	}
+
+	return missingError(results)
+}
 
+// dependency returns the registered dependency called name, or a plain
+// PATH lookup of name.
+func (c *Checker) dependency(name string) Dependency {
 	for _, dep := range c.dependencies {
-		if c.IsAvailable(dep) {
-			fmt.Printf("[OK] %s\n", dep)
+		if dep.Name == name {
+			return dep
+		}
+	}
+	return Dependency{Name: name}
+}
+
+// check finds the first working candidate for dep and records it.
+func (c *Checker) check(ctx context.Context, dep Dependency) Result {
+	result := Result{Name: dep.Name, Optional: dep.Optional, Feature: dep.Feature}
+	candidates := dep.candidates()
+	for _, candidate := range candidates {
+		path, err := exec.LookPath(candidate)
+		if err != nil {
+			continue
+		}
+		version, err := c.version(ctx, dep, path)
+		if err != nil {
+			if result.Err == nil {
+				result.Err = fmt.Errorf("'%s' at %s does not run: %w", dep.Name, path, err)
+			}
+			continue
+		}
+		result.Path, result.Version, result.Err = path, version, nil
+		break
+	}
+	if result.Path == "" && result.Err == nil {
+		if len(candidates) > 1 {
+			result.Err = fmt.Errorf("'%s' not found (tried %s)", dep.Name, strings.Join(candidates, ", "))
 		} else {
-			fmt.Printf("[ERROR] '%s' not found in PATH\n", dep)
-			fmt.Printf("[INFO]  Install '%s' and retry\n\n", dep)
-			missing = append(missing, dep)
+			result.Err = fmt.Errorf("'%s' not found in PATH", dep.Name)
 		}
 	}
 
+	c.mu.Lock()
+	if result.OK() {
+		c.resolved[dep.Name] = result.Path
+	} else {
+		delete(c.resolved, dep.Name)
+	}
+	c.mu.Unlock()
+	return result
+}
+
+// version runs path's version command and returns its first line.
+func (c *Checker) version(ctx context.Context, dep Dependency, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	args, ok := versionArgs[dep.Name]
+	if !ok {
+		args = []string{"--version"}
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.WaitDelay = time.Second // Don't wait on pipes held open by killed children
+	out, err := cmd.CombinedOutput()
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	firstLine = strings.TrimSpace(firstLine)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", fmt.Errorf("timed out after %s", c.timeout)
+	case err != nil && firstLine != "":
+		return "", fmt.Errorf("%w: %s", err, firstLine)
+	case err != nil:
+		return "", err
+	}
+	return firstLine, nil
+}
+
+// degraded returns the features of the failed optional results.
+func degraded(results []Result) []string {
+	var features []string
+	for _, result := range results {
+		if !result.OK() && result.Optional {
+			feature := result.Feature
+			if feature == "" {
+				feature = result.Name
+			}
+			features = append(features, feature)
+		}
+	}
+	return features
+}
+
+// missingError returns a MissingDepsError for the failed required
+// results, or nil.
+func missingError(results []Result) error {
+	var missing []string
+	for _, result := range results {
+		if !result.OK() && !result.Optional {
+			missing = append(missing, result.Name)
+		}
+	}
 	if len(missing) > 0 {
 		return &MissingDepsError{Dependencies: missing}
 	}
-
 	return nil
 }
 
@@ -0,0 +1,113 @@
+package deps
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBinary writes an executable shell script to dir and returns its path.
+func fakeBinary(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestChecker_SelectsFirstWorkingCandidate(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+":/bin:/usr/bin")
+	fakeBinary(t, dir, "yt-dlp", `echo "yt-dlp: /lib/libc.so.6: version GLIBC_2.38 not found" >&2; exit 1`)
+	fakeBinary(t, dir, "yt-dlp_linux", `echo 2025.01.15`)
+	ffmpeg := fakeBinary(t, t.TempDir(), "ffmpeg-static", `[ "$1" = "-version" ] && echo "ffmpeg version 7.1"`)
+
+	checker := NewCheckerFor(
+		Dependency{Name: "yt-dlp", Candidates: []string{"", "yt-dlp", "yt-dlp_linux"}},
+		Dependency{Name: "ffmpeg", Candidates: []string{"/nonexistent/ffmpeg", ffmpeg}},
+	)
+	results := checker.Check(context.Background())
+
+	if !results[0].OK() || results[0].Path != filepath.Join(dir, "yt-dlp_linux") || results[0].Version != "2025.01.15" {
+		t.Errorf("expected the broken yt-dlp to be skipped, got %+v", results[0])
+	}
+	if !results[1].OK() || results[1].Version != "ffmpeg version 7.1" {
+		t.Errorf("unexpected ffmpeg result %+v", results[1])
+	}
+	if got := checker.Path("yt-dlp"); got != filepath.Join(dir, "yt-dlp_linux") {
+		t.Errorf("expected yt-dlp_linux to be selected, got %q", got)
+	}
+	if got := checker.Path("ffmpeg"); got != ffmpeg {
+		t.Errorf("expected %q, got %q", ffmpeg, got)
+	}
+}
+
+func TestChecker_BrokenInstall(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+":/bin:/usr/bin")
+	fakeBinary(t, dir, "yt-dlp", `echo "GLIBC_2.38 not found" >&2; exit 1`)
+	fakeBinary(t, dir, "ffmpeg", `exec sleep 5`)
+
+	checker := NewChecker("yt-dlp", "ffmpeg")
+	checker.SetTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	results := checker.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("checks took %v", elapsed)
+	}
+	if results[0].OK() || !strings.Contains(results[0].Err.Error(), "GLIBC_2.38") {
+		t.Errorf("expected the glibc error, got %+v", results[0])
+	}
+	if results[1].OK() || !strings.Contains(results[1].Err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %+v", results[1])
+	}
+
+	var missing *MissingDepsError
+	if err := checker.CheckAll(); !errors.As(err, &missing) || len(missing.Dependencies) != 2 {
+		t.Errorf("expected both dependencies missing, got %v", err)
+	}
+}
+
+func TestChecker_Missing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	checker := NewChecker("yt-dlp")
+	var missing *MissingDepsError
+	if err := checker.CheckAll(); !errors.As(err, &missing) || missing.Dependencies[0] != "yt-dlp" {
+		t.Fatalf("expected missing yt-dlp, got %v", err)
+	}
+	if checker.IsAvailable("yt-dlp") {
+		t.Error("expected yt-dlp to be unavailable")
+	}
+	if got := checker.Path("yt-dlp"); got != "yt-dlp" {
+		t.Errorf("expected the name for a missing dependency, got %q", got)
+	}
+}
+
+func TestChecker_OptionalDependencies(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	fakeBinary(t, dir, "yt-dlp", `echo 2025.01.15`)
+	fakeBinary(t, dir, "deno", `echo "deno 2.1.4"`)
+
+	checker := NewCheckerFor(
+		Dependency{Name: "yt-dlp"},
+		Dependency{Name: "js-runtime", Candidates: []string{"node", "deno"}, Optional: true, Feature: "JS challenges"},
+		Dependency{Name: "aria2c", Optional: true, Feature: "segmented downloads"},
+	)
+	if err := checker.CheckAll(); err != nil {
+		t.Fatalf("missing optional dependencies should not fail: %v", err)
+	}
+	if got := checker.Path("js-runtime"); got != filepath.Join(dir, "deno") {
+		t.Errorf("expected deno to be selected, got %q", got)
+	}
+	if got := checker.Degraded(); len(got) != 1 || got[0] != "segmented downloads" {
+		t.Errorf("unexpected degraded features %v", got)
+	}
+}
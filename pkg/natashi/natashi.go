@@ -0,0 +1,186 @@
+// Package natashi is the embeddable API of the audio engine: it resolves
+// media URLs (YouTube, direct links), runs FFmpeg encoding pipelines and
+// manages concurrent playback sessions, for use by other Go programs.
+//
+// The engine does the same work as the music-bot daemon without its
+// transports; attach your own AudioSink, subscribe to session output, or
+// serve the standard HTTP API and audio socket with Serve. Fetch it with
+// go get github.com/thewind121212/natashi/pkg/natashi.
+//
+//	engine := natashi.NewEngine(ctx, natashi.Options{Sink: mySink})
+//	err := engine.Play("guild-1", "https://youtu.be/dQw4w9WgXcQ", natashi.FormatOpus, natashi.PlaybackOptions{})
+package natashi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/thewind121212/natashi/internal/buffer"
+	"github.com/thewind121212/natashi/internal/encoder"
+	"github.com/thewind121212/natashi/internal/errs"
+	"github.com/thewind121212/natashi/internal/platform"
+	"github.com/thewind121212/natashi/internal/platform/direct"
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/scrobble"
+	"github.com/thewind121212/natashi/internal/server"
+)
+
+// Types re-exported from the engine's packages.
+type (
+	// StreamExtractor resolves page URLs of one platform to direct stream URLs.
+	StreamExtractor = platform.StreamExtractor
+	// Format is the encoded output format of a session.
+	Format = encoder.Format
+	// Pipeline is an encoding pipeline (implement it to replace FFmpeg).
+	Pipeline = encoder.Pipeline
+	// PlaybackOptions tune a single Play call.
+	PlaybackOptions = server.PlaybackOptions
+	// AudioSink receives all session audio and events.
+	AudioSink = server.AudioSink
+	// Event is a session event delivered to the AudioSink.
+	Event = server.Event
+	// BusEvent is a session lifecycle event (state change, retry or client event).
+	BusEvent = server.BusEvent
+	// Session is a running playback session.
+	Session = server.Session
+	// SubscriberConfig configures an extra consumer of a session's output.
+	SubscriberConfig = buffer.SubscriberConfig
+	// Subscriber receives a copy of a session's output.
+	Subscriber = buffer.Subscriber
+	// Scrobbler submits listens to Last.fm / ListenBrainz.
+	Scrobbler = scrobble.Scrobbler
+)
+
+// Output formats.
+const (
+	FormatPCM  = encoder.FormatPCM  // Raw PCM s16le, 48kHz stereo
+	FormatOpus = encoder.FormatOpus // Ogg Opus for Discord voice (128kbps)
+	FormatWeb  = encoder.FormatWeb  // Ogg Opus for browsers (256kbps)
+)
+
+// Error kinds; match them with errors.Is. ErrorCode returns the code
+// sent in socket error events.
+var (
+	ErrNotFound   = errs.ErrNotFound
+	ErrExtraction = errs.ErrExtraction
+	ErrPipeline   = errs.ErrPipeline
+	ErrTransport  = errs.ErrTransport
+)
+
+// ErrorCode returns the short code for err ("not_found", "extraction",
+// "pipeline", "transport", "timeout", "cancelled" or "internal").
+func ErrorCode(err error) string {
+	return errs.Code(err)
+}
+
+// Options configures NewEngine. The zero value plays YouTube URLs and
+// sends output to the built-in audio socket router.
+type Options struct {
+	// Extractors resolve URLs in order (default: YouTube()).
+	Extractors []StreamExtractor
+	// Sink receives session audio and events (default: the audio socket
+	// router, which only delivers once Serve has a client connected).
+	Sink AudioSink
+	// NewPipeline creates a pipeline per playback attempt (default: FFmpeg).
+	NewPipeline func(sessionID string) Pipeline
+	// Scrobbler submits listens (default: none).
+	Scrobbler Scrobbler
+}
+
+// YouTube returns the YouTube extractor (yt-dlp).
+func YouTube() StreamExtractor {
+	return youtube.New()
+}
+
+// Direct returns the extractor for direct http(s)/file URLs and local
+// files. Only register it when URLs come from trusted callers.
+func Direct() StreamExtractor {
+	return direct.New()
+}
+
+// Engine manages playback sessions. It is safe for concurrent use.
+type Engine struct {
+	ctx      context.Context
+	sessions *server.SessionManager
+}
+
+// NewEngine creates an engine whose sessions live until ctx is cancelled.
+func NewEngine(ctx context.Context, opts Options) *Engine {
+	sessions := server.NewSessionManager(ctx)
+	if len(opts.Extractors) > 0 {
+		registry := platform.NewRegistry()
+		for _, extractor := range opts.Extractors {
+			registry.Register(extractor)
+		}
+		sessions.SetRegistry(registry)
+	}
+	if opts.Sink != nil {
+		sessions.SetSink(opts.Sink)
+	}
+	if opts.NewPipeline != nil {
+		sessions.SetPipelineFactory(server.PipelineFactory(opts.NewPipeline))
+	}
+	sessions.SetScrobbler(opts.Scrobbler)
+	return &Engine{ctx: ctx, sessions: sessions}
+}
+
+// Play starts (or replaces) session id playing url. It returns immediately;
+// progress is reported as events to the sink.
+func (e *Engine) Play(id, url string, format Format, opts PlaybackOptions) error {
+	return e.sessions.StartPlayback(id, url, string(format), opts)
+}
+
+// Pause pauses session id.
+func (e *Engine) Pause(id string) error {
+	return e.sessions.Pause(id)
+}
+
+// Resume resumes session id.
+func (e *Engine) Resume(id string) error {
+	return e.sessions.Resume(id)
+}
+
+// Seek restarts session id at position seconds.
+func (e *Engine) Seek(id string, position float64) error {
+	return e.sessions.Seek(id, position)
+}
+
+// Stop stops and removes session id.
+func (e *Engine) Stop(id string) {
+	e.sessions.Stop(id)
+}
+
+// Session returns session id, or nil.
+func (e *Engine) Session(id string) *Session {
+	return e.sessions.Get(id)
+}
+
+// Subscribe adds a consumer of session id's encoded output.
+func (e *Engine) Subscribe(id string, cfg SubscriberConfig) (*Subscriber, error) {
+	return e.sessions.Subscribe(id, cfg)
+}
+
+// OnEvent calls handler for every session lifecycle event and returns a
+// function that unsubscribes it. Handlers run on the session's goroutine
+// and must not block.
+func (e *Engine) OnEvent(handler func(BusEvent)) (unsubscribe func()) {
+	return e.sessions.Bus().Subscribe(handler)
+}
+
+// Handler returns the engine's HTTP control API (the daemon's /session,
+// /metadata, /search and /health routes), for mounting in an existing server.
+func (e *Engine) Handler() http.Handler {
+	return server.SetupRouter(server.NewAPI(e.sessions))
+}
+
+// Serve runs the HTTP API on httpAddr and the audio socket on socketPath
+// (empty for the defaults) until ctx is cancelled. Audio reaches socket
+// clients only with the default Sink; socket clients can send commands
+// either way.
+func (e *Engine) Serve(ctx context.Context, httpAddr, socketPath string) error {
+	return server.Run(ctx, server.Options{
+		HTTPAddr:   httpAddr,
+		SocketPath: socketPath,
+		Sessions:   e.sessions,
+	})
+}
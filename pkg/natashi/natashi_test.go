@@ -0,0 +1,102 @@
+package natashi_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thewind121212/natashi/pkg/natashi"
+)
+
+// stubExtractor resolves "stub://" URLs without yt-dlp.
+type stubExtractor struct{}
+
+func (stubExtractor) Name() string              { return "stub" }
+func (stubExtractor) CanHandle(url string) bool { return strings.HasPrefix(url, "stub://") }
+func (stubExtractor) ExtractStreamURL(url string) (string, error) {
+	return "https://stream.invalid", nil
+}
+
+// stubPipeline emits one chunk, standing in for FFmpeg.
+type stubPipeline struct{ output chan []byte }
+
+func (p *stubPipeline) Start(ctx context.Context, streamURL string, format natashi.Format, startAtSec float64) error {
+	go func() {
+		defer close(p.output)
+		p.output <- []byte("audio")
+	}()
+	return nil
+}
+func (p *stubPipeline) Output() <-chan []byte { return p.output }
+func (p *stubPipeline) Pause()                {}
+func (p *stubPipeline) Resume()               {}
+func (p *stubPipeline) Stop()                 {}
+
+// collectSink records what the engine delivers.
+type collectSink struct {
+	mu     sync.Mutex
+	audio  []string
+	events []natashi.Event
+}
+
+func (s *collectSink) SendAudio(sessionID string, chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audio = append(s.audio, string(chunk))
+	return nil
+}
+
+func (s *collectSink) SendEvent(v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event, ok := v.(natashi.Event); ok {
+		s.events = append(s.events, event)
+	}
+	return nil
+}
+
+func TestEngine_Play(t *testing.T) {
+	sink := &collectSink{}
+	engine := natashi.NewEngine(context.Background(), natashi.Options{
+		Extractors:  []natashi.StreamExtractor{stubExtractor{}},
+		Sink:        sink,
+		NewPipeline: func(string) natashi.Pipeline { return &stubPipeline{output: make(chan []byte)} },
+	})
+
+	if err := engine.Play("guild-1", "stub://track", natashi.FormatPCM, natashi.PlaybackOptions{}); err != nil {
+		t.Fatalf("Play failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sink.mu.Lock()
+		done := len(sink.events) > 0 && sink.events[len(sink.events)-1].Type == "finished"
+		audio := strings.Join(sink.audio, "")
+		sink.mu.Unlock()
+		if done {
+			if audio != "audio" {
+				t.Errorf("expected audio %q, got %q", "audio", audio)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the session to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func ExampleNewEngine() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	engine := natashi.NewEngine(ctx, natashi.Options{
+		Extractors: []natashi.StreamExtractor{natashi.YouTube()},
+	})
+	// Serve the standard HTTP API and audio socket, as `music-bot daemon` does
+	go engine.Serve(ctx, ":8180", "/tmp/music-playground.sock")
+
+	engine.Play("guild-1", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", natashi.FormatOpus, natashi.PlaybackOptions{})
+}
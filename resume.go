@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/thewind121212/natashi/cmd"
+	"github.com/thewind121212/natashi/internal/player"
+)
+
+const (
+	// minResumePosition is how far into a track playback must get before
+	// its position is worth remembering (skips short songs).
+	minResumePosition = time.Minute
+	// positionSampleInterval is how often the playing position is recorded.
+	positionSampleInterval = time.Second
+)
+
+// positionTracker saves where each track was left off and, with -resume,
+// starts tracks from their saved position.
+type positionTracker struct {
+	store  *player.PositionStore // nil if the store could not be opened
+	resume bool
+	from   time.Duration // Position the playing track started at
+	last   atomic.Int64  // Last sampled position of the playing track
+}
+
+// newPositionTracker opens the positions store. Failures only disable
+// saving; playback goes on.
+func newPositionTracker(resume bool) *positionTracker {
+	t := &positionTracker{resume: resume}
+	path := cmd.PositionsPath()
+	if path == "" {
+		return t
+	}
+	store, err := player.OpenPositionStore(path)
+	if err != nil {
+		out.Errorf("Saved positions unavailable: %v", err)
+		return t
+	}
+	t.store = store
+	return t
+}
+
+// begin prepares the next track, which starts at startAt unless resuming
+// makes audioPlayer start at the saved position.
+func (t *positionTracker) begin(url string, audioPlayer player.AudioPlayer, startAt time.Duration) {
+	t.from = startAt
+	t.last.Store(int64(startAt))
+	if !t.resume || t.store == nil {
+		return
+	}
+	position, ok := t.store.Get(url)
+	setter, canStart := audioPlayer.(player.StartSetter)
+	if !ok || !canStart {
+		return
+	}
+	setter.SetStartAt(position)
+	t.from = position
+	t.last.Store(int64(position))
+	out.Infof("Resuming at %s", position.Round(time.Second))
+}
+
+// watch samples the playing position until ctx is done.
+func (t *positionTracker) watch(ctx context.Context, ctrl player.Controller) {
+	ticker := time.NewTicker(positionSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.last.Store(int64(ctrl.Position()))
+		}
+	}
+}
+
+// played returns roughly how much of the playing track has been heard.
+func (t *positionTracker) played() time.Duration {
+	return max(time.Duration(t.last.Load())-t.from, 0)
+}
+
+// end saves the position of an unfinished track, or forgets it once the
+// track played to the end.
+func (t *positionTracker) end(url string, finished bool) {
+	if t.store == nil {
+		return
+	}
+	var err error
+	position := time.Duration(t.last.Load())
+	switch {
+	case finished:
+		err = t.store.Forget(url)
+	case position >= minResumePosition:
+		err = t.store.Save(url, position)
+		out.Debugf("Saved position %s", position.Round(time.Second))
+	}
+	if err != nil {
+		out.Errorf("Save position: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/thewind121212/natashi/internal/platform/youtube"
+	"github.com/thewind121212/natashi/internal/scrobble"
+)
+
+// scrobbleTimeout bounds each submission to the scrobbling services.
+const scrobbleTimeout = 10 * time.Second
+
+// listenTracker reports the playing track to the configured scrobbling
+// services: now playing once its metadata is known, and the listen when it
+// ends after being played long enough.
+type listenTracker struct {
+	scrobbler scrobble.Scrobbler // nil disables
+
+	mu        sync.Mutex
+	startedAt time.Time
+	track     *scrobble.Track
+}
+
+// begin resets the tracker for a new track.
+func (l *listenTracker) begin() {
+	l.mu.Lock()
+	l.startedAt = time.Now()
+	l.track = nil
+	l.mu.Unlock()
+}
+
+// start announces the track as now playing, with artist/title parsed from
+// its metadata.
+func (l *listenTracker) start(meta *youtube.Metadata) {
+	if l.scrobbler == nil {
+		return
+	}
+	artist, title := scrobble.ParseTitle(meta.Title, meta.Artist, meta.Track, meta.Uploader)
+	if artist == "" || title == "" {
+		return
+	}
+
+	l.mu.Lock()
+	track := &scrobble.Track{
+		Artist:    artist,
+		Title:     title,
+		Duration:  time.Duration(meta.Duration) * time.Second,
+		StartedAt: l.startedAt,
+	}
+	l.track = track
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrobbleTimeout)
+	defer cancel()
+	if err := l.scrobbler.NowPlaying(ctx, *track); err != nil {
+		out.Debugf("Scrobble now playing: %v", err)
+	}
+}
+
+// finish submits the listen if enough of the track was played. It blocks
+// so a listen ended by Ctrl+C is still sent before exiting.
+func (l *listenTracker) finish(played time.Duration) {
+	l.mu.Lock()
+	track := l.track
+	l.track = nil
+	l.mu.Unlock()
+	if l.scrobbler == nil || track == nil || !scrobble.ShouldScrobble(track.Duration, played) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrobbleTimeout)
+	defer cancel()
+	if err := l.scrobbler.Scrobble(ctx, *track); err != nil {
+		out.Errorf("Scrobble: %v", err)
+		return
+	}
+	out.Debugf("Scrobbled %s - %s", track.Artist, track.Title)
+}